@@ -96,8 +96,9 @@ type EventRouter struct {
 	startTime time.Time
 }
 
-// NewEventRouter will create a new event router using the input params
-func NewEventRouter(kubeClient *kubernetes.Clientset, eventsInformer coreinformers.EventInformer) *EventRouter {
+// NewEventRouter will create a new event router using the input params. The
+// sink runs until stopCh is closed.
+func NewEventRouter(kubeClient *kubernetes.Clientset, eventsInformer coreinformers.EventInformer, stopCh <-chan struct{}) *EventRouter {
 	if viper.GetBool("enable-prometheus") {
 		prometheus.MustRegister(kubernetesWarningEventCounterVec)
 		prometheus.MustRegister(kubernetesNormalEventCounterVec)
@@ -107,7 +108,7 @@ func NewEventRouter(kubeClient *kubernetes.Clientset, eventsInformer coreinforme
 
 	er := &EventRouter{
 		kubeClient: kubeClient,
-		eSink:      sinks.ManufactureSink(),
+		eSink:      sinks.ManufactureSink(stopCh),
 		startTime:  time.Now().UTC(),
 	}
 	eventsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/heptiolabs/eventrouter/sinks"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 
@@ -35,7 +36,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// addr tells us what address to have the Prometheus metrics listen on.
+// addr tells us what address to serve readiness and Prometheus metrics on.
 var addr = flag.String("listen-address", ":8080", "The address to listen on for HTTP requests.")
 
 // setup a signal hander to gracefully exit
@@ -110,17 +111,19 @@ func main() {
 	eventsInformer := sharedInformers.Core().V1().Events()
 
 	// TODO: Support locking for HA https://github.com/kubernetes/kubernetes/pull/42666
-	eventRouter := NewEventRouter(clientset, eventsInformer)
 	stop := sigHandler()
+	eventRouter := NewEventRouter(clientset, eventsInformer, stop)
 
-	// Startup the http listener for Prometheus Metrics endpoint.
+	// Startup the http listener serving the sinks' health for readiness
+	// probes on /readyz, and the Prometheus Metrics endpoint when enabled.
+	http.Handle("/readyz", sinks.ReadinessHandler())
 	if viper.GetBool("enable-prometheus") {
-		go func() {
-			glog.Info("Starting prometheus metrics.")
-			http.Handle("/metrics", promhttp.Handler())
-			glog.Warning(http.ListenAndServe(*addr, nil))
-		}()
+		glog.Info("Starting prometheus metrics.")
+		http.Handle("/metrics", promhttp.Handler())
 	}
+	go func() {
+		glog.Warning(http.ListenAndServe(*addr, nil))
+	}()
 
 	// Startup the EventRouter
 	wg.Add(1)
@@ -133,6 +136,11 @@ func main() {
 	glog.Infof("Starting shared Informer(s)")
 	sharedInformers.Start(stop)
 	wg.Wait()
+
+	// Give the sinks a chance to deliver what they buffered.
+	if !sinks.WaitForShutdown() {
+		glog.Warningf("Sinks did not finish draining before the timeout")
+	}
 	glog.Warningf("Exiting main()")
 	os.Exit(1)
 }
@@ -0,0 +1,545 @@
+// Copyright 2017 Heptio Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: eventrouter/v1/event.proto
+
+// Package eventrouter.v1 is the schema of the events eventrouter streams to
+// gRPC consumers. Fields are only ever added to this version; incompatible
+// changes go into a new package version.
+
+package eventrouterv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PublishRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*Event               `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishRequest) Reset() {
+	*x = PublishRequest{}
+	mi := &file_eventrouter_v1_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishRequest) ProtoMessage() {}
+
+func (x *PublishRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eventrouter_v1_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishRequest.ProtoReflect.Descriptor instead.
+func (*PublishRequest) Descriptor() ([]byte, []int) {
+	return file_eventrouter_v1_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PublishRequest) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type PublishResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// accepted counts the events the consumer received on the stream.
+	Accepted      uint64 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishResponse) Reset() {
+	*x = PublishResponse{}
+	mi := &file_eventrouter_v1_event_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishResponse) ProtoMessage() {}
+
+func (x *PublishResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eventrouter_v1_event_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishResponse.ProtoReflect.Descriptor instead.
+func (*PublishResponse) Descriptor() ([]byte, []int) {
+	return file_eventrouter_v1_event_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PublishResponse) GetAccepted() uint64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+// Event is a Kubernetes core/v1 Event together with how it changed.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// verb is ADDED, UPDATED or DELETED.
+	Verb            string           `protobuf:"bytes,1,opt,name=verb,proto3" json:"verb,omitempty"`
+	Cluster         string           `protobuf:"bytes,2,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Namespace       string           `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name            string           `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Uid             string           `protobuf:"bytes,5,opt,name=uid,proto3" json:"uid,omitempty"`
+	ResourceVersion string           `protobuf:"bytes,6,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	InvolvedObject  *ObjectReference `protobuf:"bytes,7,opt,name=involved_object,json=involvedObject,proto3" json:"involved_object,omitempty"`
+	Reason          string           `protobuf:"bytes,8,opt,name=reason,proto3" json:"reason,omitempty"`
+	Message         string           `protobuf:"bytes,9,opt,name=message,proto3" json:"message,omitempty"`
+	// type is Normal or Warning.
+	Type                string                 `protobuf:"bytes,10,opt,name=type,proto3" json:"type,omitempty"`
+	Count               int32                  `protobuf:"varint,11,opt,name=count,proto3" json:"count,omitempty"`
+	FirstTimestamp      *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=first_timestamp,json=firstTimestamp,proto3" json:"first_timestamp,omitempty"`
+	LastTimestamp       *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=last_timestamp,json=lastTimestamp,proto3" json:"last_timestamp,omitempty"`
+	EventTime           *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`
+	SourceComponent     string                 `protobuf:"bytes,15,opt,name=source_component,json=sourceComponent,proto3" json:"source_component,omitempty"`
+	SourceHost          string                 `protobuf:"bytes,16,opt,name=source_host,json=sourceHost,proto3" json:"source_host,omitempty"`
+	ReportingController string                 `protobuf:"bytes,17,opt,name=reporting_controller,json=reportingController,proto3" json:"reporting_controller,omitempty"`
+	ReportingInstance   string                 `protobuf:"bytes,18,opt,name=reporting_instance,json=reportingInstance,proto3" json:"reporting_instance,omitempty"`
+	Action              string                 `protobuf:"bytes,19,opt,name=action,proto3" json:"action,omitempty"`
+	Labels              map[string]string      `protobuf:"bytes,20,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations         map[string]string      `protobuf:"bytes,21,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_eventrouter_v1_event_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_eventrouter_v1_event_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_eventrouter_v1_event_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Event) GetVerb() string {
+	if x != nil {
+		return x.Verb
+	}
+	return ""
+}
+
+func (x *Event) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *Event) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *Event) GetResourceVersion() string {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return ""
+}
+
+func (x *Event) GetInvolvedObject() *ObjectReference {
+	if x != nil {
+		return x.InvolvedObject
+	}
+	return nil
+}
+
+func (x *Event) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Event) GetFirstTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstTimestamp
+	}
+	return nil
+}
+
+func (x *Event) GetLastTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastTimestamp
+	}
+	return nil
+}
+
+func (x *Event) GetEventTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EventTime
+	}
+	return nil
+}
+
+func (x *Event) GetSourceComponent() string {
+	if x != nil {
+		return x.SourceComponent
+	}
+	return ""
+}
+
+func (x *Event) GetSourceHost() string {
+	if x != nil {
+		return x.SourceHost
+	}
+	return ""
+}
+
+func (x *Event) GetReportingController() string {
+	if x != nil {
+		return x.ReportingController
+	}
+	return ""
+}
+
+func (x *Event) GetReportingInstance() string {
+	if x != nil {
+		return x.ReportingInstance
+	}
+	return ""
+}
+
+func (x *Event) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *Event) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Event) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type ObjectReference struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Kind            string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Namespace       string                 `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name            string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Uid             string                 `protobuf:"bytes,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	ApiVersion      string                 `protobuf:"bytes,5,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
+	ResourceVersion string                 `protobuf:"bytes,6,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	FieldPath       string                 `protobuf:"bytes,7,opt,name=field_path,json=fieldPath,proto3" json:"field_path,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ObjectReference) Reset() {
+	*x = ObjectReference{}
+	mi := &file_eventrouter_v1_event_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ObjectReference) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObjectReference) ProtoMessage() {}
+
+func (x *ObjectReference) ProtoReflect() protoreflect.Message {
+	mi := &file_eventrouter_v1_event_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObjectReference.ProtoReflect.Descriptor instead.
+func (*ObjectReference) Descriptor() ([]byte, []int) {
+	return file_eventrouter_v1_event_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ObjectReference) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetApiVersion() string {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetResourceVersion() string {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return ""
+}
+
+func (x *ObjectReference) GetFieldPath() string {
+	if x != nil {
+		return x.FieldPath
+	}
+	return ""
+}
+
+var File_eventrouter_v1_event_proto protoreflect.FileDescriptor
+
+const file_eventrouter_v1_event_proto_rawDesc = "" +
+	"\n" +
+	"\x1aeventrouter/v1/event.proto\x12\x0eeventrouter.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"?\n" +
+	"\x0ePublishRequest\x12-\n" +
+	"\x06events\x18\x01 \x03(\v2\x15.eventrouter.v1.EventR\x06events\"-\n" +
+	"\x0fPublishResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x04R\baccepted\"\xd3\a\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04verb\x18\x01 \x01(\tR\x04verb\x12\x18\n" +
+	"\acluster\x18\x02 \x01(\tR\acluster\x12\x1c\n" +
+	"\tnamespace\x18\x03 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x05 \x01(\tR\x03uid\x12)\n" +
+	"\x10resource_version\x18\x06 \x01(\tR\x0fresourceVersion\x12H\n" +
+	"\x0finvolved_object\x18\a \x01(\v2\x1f.eventrouter.v1.ObjectReferenceR\x0einvolvedObject\x12\x16\n" +
+	"\x06reason\x18\b \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\t \x01(\tR\amessage\x12\x12\n" +
+	"\x04type\x18\n" +
+	" \x01(\tR\x04type\x12\x14\n" +
+	"\x05count\x18\v \x01(\x05R\x05count\x12C\n" +
+	"\x0ffirst_timestamp\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\x0efirstTimestamp\x12A\n" +
+	"\x0elast_timestamp\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\rlastTimestamp\x129\n" +
+	"\n" +
+	"event_time\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\teventTime\x12)\n" +
+	"\x10source_component\x18\x0f \x01(\tR\x0fsourceComponent\x12\x1f\n" +
+	"\vsource_host\x18\x10 \x01(\tR\n" +
+	"sourceHost\x121\n" +
+	"\x14reporting_controller\x18\x11 \x01(\tR\x13reportingController\x12-\n" +
+	"\x12reporting_instance\x18\x12 \x01(\tR\x11reportingInstance\x12\x16\n" +
+	"\x06action\x18\x13 \x01(\tR\x06action\x129\n" +
+	"\x06labels\x18\x14 \x03(\v2!.eventrouter.v1.Event.LabelsEntryR\x06labels\x12H\n" +
+	"\vannotations\x18\x15 \x03(\v2&.eventrouter.v1.Event.AnnotationsEntryR\vannotations\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd4\x01\n" +
+	"\x0fObjectReference\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x04 \x01(\tR\x03uid\x12\x1f\n" +
+	"\vapi_version\x18\x05 \x01(\tR\n" +
+	"apiVersion\x12)\n" +
+	"\x10resource_version\x18\x06 \x01(\tR\x0fresourceVersion\x12\x1d\n" +
+	"\n" +
+	"field_path\x18\a \x01(\tR\tfieldPath2Y\n" +
+	"\tEventSink\x12L\n" +
+	"\aPublish\x12\x1e.eventrouter.v1.PublishRequest\x1a\x1f.eventrouter.v1.PublishResponse(\x01BFZDgithub.com/heptiolabs/eventrouter/proto/eventrouter/v1;eventrouterv1b\x06proto3"
+
+var (
+	file_eventrouter_v1_event_proto_rawDescOnce sync.Once
+	file_eventrouter_v1_event_proto_rawDescData []byte
+)
+
+func file_eventrouter_v1_event_proto_rawDescGZIP() []byte {
+	file_eventrouter_v1_event_proto_rawDescOnce.Do(func() {
+		file_eventrouter_v1_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_eventrouter_v1_event_proto_rawDesc), len(file_eventrouter_v1_event_proto_rawDesc)))
+	})
+	return file_eventrouter_v1_event_proto_rawDescData
+}
+
+var file_eventrouter_v1_event_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_eventrouter_v1_event_proto_goTypes = []any{
+	(*PublishRequest)(nil),        // 0: eventrouter.v1.PublishRequest
+	(*PublishResponse)(nil),       // 1: eventrouter.v1.PublishResponse
+	(*Event)(nil),                 // 2: eventrouter.v1.Event
+	(*ObjectReference)(nil),       // 3: eventrouter.v1.ObjectReference
+	nil,                           // 4: eventrouter.v1.Event.LabelsEntry
+	nil,                           // 5: eventrouter.v1.Event.AnnotationsEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_eventrouter_v1_event_proto_depIdxs = []int32{
+	2, // 0: eventrouter.v1.PublishRequest.events:type_name -> eventrouter.v1.Event
+	3, // 1: eventrouter.v1.Event.involved_object:type_name -> eventrouter.v1.ObjectReference
+	6, // 2: eventrouter.v1.Event.first_timestamp:type_name -> google.protobuf.Timestamp
+	6, // 3: eventrouter.v1.Event.last_timestamp:type_name -> google.protobuf.Timestamp
+	6, // 4: eventrouter.v1.Event.event_time:type_name -> google.protobuf.Timestamp
+	4, // 5: eventrouter.v1.Event.labels:type_name -> eventrouter.v1.Event.LabelsEntry
+	5, // 6: eventrouter.v1.Event.annotations:type_name -> eventrouter.v1.Event.AnnotationsEntry
+	0, // 7: eventrouter.v1.EventSink.Publish:input_type -> eventrouter.v1.PublishRequest
+	1, // 8: eventrouter.v1.EventSink.Publish:output_type -> eventrouter.v1.PublishResponse
+	8, // [8:9] is the sub-list for method output_type
+	7, // [7:8] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_eventrouter_v1_event_proto_init() }
+func file_eventrouter_v1_event_proto_init() {
+	if File_eventrouter_v1_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_eventrouter_v1_event_proto_rawDesc), len(file_eventrouter_v1_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_eventrouter_v1_event_proto_goTypes,
+		DependencyIndexes: file_eventrouter_v1_event_proto_depIdxs,
+		MessageInfos:      file_eventrouter_v1_event_proto_msgTypes,
+	}.Build()
+	File_eventrouter_v1_event_proto = out.File
+	file_eventrouter_v1_event_proto_goTypes = nil
+	file_eventrouter_v1_event_proto_depIdxs = nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Heptio Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: eventrouter/v1/event.proto
+
+// Package eventrouter.v1 is the schema of the events eventrouter streams to
+// gRPC consumers. Fields are only ever added to this version; incompatible
+// changes go into a new package version.
+
+package eventrouterv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EventSink_Publish_FullMethodName = "/eventrouter.v1.EventSink/Publish"
+)
+
+// EventSinkClient is the client API for EventSink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// EventSink is implemented by consumers. eventrouter opens a Publish stream
+// per batch of events, and resends the batch unless the response accepts
+// all of its events.
+type EventSinkClient interface {
+	Publish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishResponse], error)
+}
+
+type eventSinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventSinkClient(cc grpc.ClientConnInterface) EventSinkClient {
+	return &eventSinkClient{cc}
+}
+
+func (c *eventSinkClient) Publish(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PublishRequest, PublishResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventSink_ServiceDesc.Streams[0], EventSink_Publish_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PublishRequest, PublishResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventSink_PublishClient = grpc.ClientStreamingClient[PublishRequest, PublishResponse]
+
+// EventSinkServer is the server API for EventSink service.
+// All implementations must embed UnimplementedEventSinkServer
+// for forward compatibility.
+//
+// EventSink is implemented by consumers. eventrouter opens a Publish stream
+// per batch of events, and resends the batch unless the response accepts
+// all of its events.
+type EventSinkServer interface {
+	Publish(grpc.ClientStreamingServer[PublishRequest, PublishResponse]) error
+	mustEmbedUnimplementedEventSinkServer()
+}
+
+// UnimplementedEventSinkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEventSinkServer struct{}
+
+func (UnimplementedEventSinkServer) Publish(grpc.ClientStreamingServer[PublishRequest, PublishResponse]) error {
+	return status.Error(codes.Unimplemented, "method Publish not implemented")
+}
+func (UnimplementedEventSinkServer) mustEmbedUnimplementedEventSinkServer() {}
+func (UnimplementedEventSinkServer) testEmbeddedByValue()                   {}
+
+// UnsafeEventSinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventSinkServer will
+// result in compilation errors.
+type UnsafeEventSinkServer interface {
+	mustEmbedUnimplementedEventSinkServer()
+}
+
+func RegisterEventSinkServer(s grpc.ServiceRegistrar, srv EventSinkServer) {
+	// If the following call panics, it indicates UnimplementedEventSinkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EventSink_ServiceDesc, srv)
+}
+
+func _EventSink_Publish_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventSinkServer).Publish(&grpc.GenericServerStream[PublishRequest, PublishResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventSink_PublishServer = grpc.ClientStreamingServer[PublishRequest, PublishResponse]
+
+// EventSink_ServiceDesc is the grpc.ServiceDesc for EventSink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventSink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eventrouter.v1.EventSink",
+	HandlerType: (*EventSinkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Publish",
+			Handler:       _EventSink_Publish_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "eventrouter/v1/event.proto",
+}
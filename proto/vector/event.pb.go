@@ -0,0 +1,573 @@
+// Copyright 2017 Heptio Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: vector/event.proto
+
+// Package event is the subset of Vector's event schema
+// (lib/vector-core/proto/event.proto) needed to send log events. Package,
+// message names and field numbers match Vector's so the messages are wire
+// compatible; metrics and traces are left out.
+
+package vectorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ValueNull int32
+
+const (
+	ValueNull_NULL_VALUE ValueNull = 0
+)
+
+// Enum value maps for ValueNull.
+var (
+	ValueNull_name = map[int32]string{
+		0: "NULL_VALUE",
+	}
+	ValueNull_value = map[string]int32{
+		"NULL_VALUE": 0,
+	}
+)
+
+func (x ValueNull) Enum() *ValueNull {
+	p := new(ValueNull)
+	*p = x
+	return p
+}
+
+func (x ValueNull) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ValueNull) Descriptor() protoreflect.EnumDescriptor {
+	return file_vector_event_proto_enumTypes[0].Descriptor()
+}
+
+func (ValueNull) Type() protoreflect.EnumType {
+	return &file_vector_event_proto_enumTypes[0]
+}
+
+func (x ValueNull) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ValueNull.Descriptor instead.
+func (ValueNull) EnumDescriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{0}
+}
+
+type EventWrapper struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*EventWrapper_Log
+	Event         isEventWrapper_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventWrapper) Reset() {
+	*x = EventWrapper{}
+	mi := &file_vector_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventWrapper) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventWrapper) ProtoMessage() {}
+
+func (x *EventWrapper) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventWrapper.ProtoReflect.Descriptor instead.
+func (*EventWrapper) Descriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EventWrapper) GetEvent() isEventWrapper_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *EventWrapper) GetLog() *Log {
+	if x != nil {
+		if x, ok := x.Event.(*EventWrapper_Log); ok {
+			return x.Log
+		}
+	}
+	return nil
+}
+
+type isEventWrapper_Event interface {
+	isEventWrapper_Event()
+}
+
+type EventWrapper_Log struct {
+	Log *Log `protobuf:"bytes,1,opt,name=log,proto3,oneof"`
+}
+
+func (*EventWrapper_Log) isEventWrapper_Event() {}
+
+type Log struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *Value                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Log) Reset() {
+	*x = Log{}
+	mi := &file_vector_event_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Log) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Log) ProtoMessage() {}
+
+func (x *Log) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_event_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Log.ProtoReflect.Descriptor instead.
+func (*Log) Descriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Log) GetValue() *Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_RawBytes
+	//	*Value_Timestamp
+	//	*Value_Integer
+	//	*Value_Float
+	//	*Value_Boolean
+	//	*Value_Map
+	//	*Value_Array
+	//	*Value_Null
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_vector_event_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_event_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetRawBytes() []byte {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_RawBytes); ok {
+			return x.RawBytes
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Timestamp); ok {
+			return x.Timestamp
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetInteger() int64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Integer); ok {
+			return x.Integer
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetFloat() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Float); ok {
+			return x.Float
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetBoolean() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Boolean); ok {
+			return x.Boolean
+		}
+	}
+	return false
+}
+
+func (x *Value) GetMap() *ValueMap {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Map); ok {
+			return x.Map
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetArray() *ValueArray {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Array); ok {
+			return x.Array
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetNull() ValueNull {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Null); ok {
+			return x.Null
+		}
+	}
+	return ValueNull_NULL_VALUE
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_RawBytes struct {
+	RawBytes []byte `protobuf:"bytes,1,opt,name=raw_bytes,json=rawBytes,proto3,oneof"`
+}
+
+type Value_Timestamp struct {
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3,oneof"`
+}
+
+type Value_Integer struct {
+	Integer int64 `protobuf:"varint,4,opt,name=integer,proto3,oneof"`
+}
+
+type Value_Float struct {
+	Float float64 `protobuf:"fixed64,5,opt,name=float,proto3,oneof"`
+}
+
+type Value_Boolean struct {
+	Boolean bool `protobuf:"varint,6,opt,name=boolean,proto3,oneof"`
+}
+
+type Value_Map struct {
+	Map *ValueMap `protobuf:"bytes,7,opt,name=map,proto3,oneof"`
+}
+
+type Value_Array struct {
+	Array *ValueArray `protobuf:"bytes,8,opt,name=array,proto3,oneof"`
+}
+
+type Value_Null struct {
+	Null ValueNull `protobuf:"varint,9,opt,name=null,proto3,enum=event.ValueNull,oneof"`
+}
+
+func (*Value_RawBytes) isValue_Kind() {}
+
+func (*Value_Timestamp) isValue_Kind() {}
+
+func (*Value_Integer) isValue_Kind() {}
+
+func (*Value_Float) isValue_Kind() {}
+
+func (*Value_Boolean) isValue_Kind() {}
+
+func (*Value_Map) isValue_Kind() {}
+
+func (*Value_Array) isValue_Kind() {}
+
+func (*Value_Null) isValue_Kind() {}
+
+type ValueMap struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fields        map[string]*Value      `protobuf:"bytes,1,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueMap) Reset() {
+	*x = ValueMap{}
+	mi := &file_vector_event_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueMap) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueMap) ProtoMessage() {}
+
+func (x *ValueMap) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_event_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueMap.ProtoReflect.Descriptor instead.
+func (*ValueMap) Descriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ValueMap) GetFields() map[string]*Value {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type ValueArray struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*Value               `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueArray) Reset() {
+	*x = ValueArray{}
+	mi := &file_vector_event_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueArray) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueArray) ProtoMessage() {}
+
+func (x *ValueArray) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_event_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueArray.ProtoReflect.Descriptor instead.
+func (*ValueArray) Descriptor() ([]byte, []int) {
+	return file_vector_event_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ValueArray) GetItems() []*Value {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+var File_vector_event_proto protoreflect.FileDescriptor
+
+const file_vector_event_proto_rawDesc = "" +
+	"\n" +
+	"\x12vector/event.proto\x12\x05event\x1a\x1fgoogle/protobuf/timestamp.proto\"7\n" +
+	"\fEventWrapper\x12\x1e\n" +
+	"\x03log\x18\x01 \x01(\v2\n" +
+	".event.LogH\x00R\x03logB\a\n" +
+	"\x05event\"5\n" +
+	"\x03Log\x12\"\n" +
+	"\x05value\x18\x02 \x01(\v2\f.event.ValueR\x05valueJ\x04\b\x01\x10\x02J\x04\b\x03\x10\x04\"\xb2\x02\n" +
+	"\x05Value\x12\x1d\n" +
+	"\traw_bytes\x18\x01 \x01(\fH\x00R\brawBytes\x12:\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampH\x00R\ttimestamp\x12\x1a\n" +
+	"\ainteger\x18\x04 \x01(\x03H\x00R\ainteger\x12\x16\n" +
+	"\x05float\x18\x05 \x01(\x01H\x00R\x05float\x12\x1a\n" +
+	"\aboolean\x18\x06 \x01(\bH\x00R\aboolean\x12#\n" +
+	"\x03map\x18\a \x01(\v2\x0f.event.ValueMapH\x00R\x03map\x12)\n" +
+	"\x05array\x18\b \x01(\v2\x11.event.ValueArrayH\x00R\x05array\x12&\n" +
+	"\x04null\x18\t \x01(\x0e2\x10.event.ValueNullH\x00R\x04nullB\x06\n" +
+	"\x04kind\"\x88\x01\n" +
+	"\bValueMap\x123\n" +
+	"\x06fields\x18\x01 \x03(\v2\x1b.event.ValueMap.FieldsEntryR\x06fields\x1aG\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\"\n" +
+	"\x05value\x18\x02 \x01(\v2\f.event.ValueR\x05value:\x028\x01\"0\n" +
+	"\n" +
+	"ValueArray\x12\"\n" +
+	"\x05items\x18\x01 \x03(\v2\f.event.ValueR\x05items*\x1b\n" +
+	"\tValueNull\x12\x0e\n" +
+	"\n" +
+	"NULL_VALUE\x10\x00B9Z7github.com/heptiolabs/eventrouter/proto/vector;vectorpbb\x06proto3"
+
+var (
+	file_vector_event_proto_rawDescOnce sync.Once
+	file_vector_event_proto_rawDescData []byte
+)
+
+func file_vector_event_proto_rawDescGZIP() []byte {
+	file_vector_event_proto_rawDescOnce.Do(func() {
+		file_vector_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_vector_event_proto_rawDesc), len(file_vector_event_proto_rawDesc)))
+	})
+	return file_vector_event_proto_rawDescData
+}
+
+var file_vector_event_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_vector_event_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_vector_event_proto_goTypes = []any{
+	(ValueNull)(0),                // 0: event.ValueNull
+	(*EventWrapper)(nil),          // 1: event.EventWrapper
+	(*Log)(nil),                   // 2: event.Log
+	(*Value)(nil),                 // 3: event.Value
+	(*ValueMap)(nil),              // 4: event.ValueMap
+	(*ValueArray)(nil),            // 5: event.ValueArray
+	nil,                           // 6: event.ValueMap.FieldsEntry
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_vector_event_proto_depIdxs = []int32{
+	2, // 0: event.EventWrapper.log:type_name -> event.Log
+	3, // 1: event.Log.value:type_name -> event.Value
+	7, // 2: event.Value.timestamp:type_name -> google.protobuf.Timestamp
+	4, // 3: event.Value.map:type_name -> event.ValueMap
+	5, // 4: event.Value.array:type_name -> event.ValueArray
+	0, // 5: event.Value.null:type_name -> event.ValueNull
+	6, // 6: event.ValueMap.fields:type_name -> event.ValueMap.FieldsEntry
+	3, // 7: event.ValueArray.items:type_name -> event.Value
+	3, // 8: event.ValueMap.FieldsEntry.value:type_name -> event.Value
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_vector_event_proto_init() }
+func file_vector_event_proto_init() {
+	if File_vector_event_proto != nil {
+		return
+	}
+	file_vector_event_proto_msgTypes[0].OneofWrappers = []any{
+		(*EventWrapper_Log)(nil),
+	}
+	file_vector_event_proto_msgTypes[2].OneofWrappers = []any{
+		(*Value_RawBytes)(nil),
+		(*Value_Timestamp)(nil),
+		(*Value_Integer)(nil),
+		(*Value_Float)(nil),
+		(*Value_Boolean)(nil),
+		(*Value_Map)(nil),
+		(*Value_Array)(nil),
+		(*Value_Null)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vector_event_proto_rawDesc), len(file_vector_event_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_vector_event_proto_goTypes,
+		DependencyIndexes: file_vector_event_proto_depIdxs,
+		EnumInfos:         file_vector_event_proto_enumTypes,
+		MessageInfos:      file_vector_event_proto_msgTypes,
+	}.Build()
+	File_vector_event_proto = out.File
+	file_vector_event_proto_goTypes = nil
+	file_vector_event_proto_depIdxs = nil
+}
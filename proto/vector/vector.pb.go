@@ -0,0 +1,326 @@
+// Copyright 2017 Heptio Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: vector/vector.proto
+
+// Package vector is the service of Vector's native "vector" source and sink
+// (proto/vector/vector.proto in the Vector repository).
+
+package vectorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ServingStatus int32
+
+const (
+	ServingStatus_SERVING     ServingStatus = 0
+	ServingStatus_NOT_SERVING ServingStatus = 1
+)
+
+// Enum value maps for ServingStatus.
+var (
+	ServingStatus_name = map[int32]string{
+		0: "SERVING",
+		1: "NOT_SERVING",
+	}
+	ServingStatus_value = map[string]int32{
+		"SERVING":     0,
+		"NOT_SERVING": 1,
+	}
+)
+
+func (x ServingStatus) Enum() *ServingStatus {
+	p := new(ServingStatus)
+	*p = x
+	return p
+}
+
+func (x ServingStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServingStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_vector_vector_proto_enumTypes[0].Descriptor()
+}
+
+func (ServingStatus) Type() protoreflect.EnumType {
+	return &file_vector_vector_proto_enumTypes[0]
+}
+
+func (x ServingStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServingStatus.Descriptor instead.
+func (ServingStatus) EnumDescriptor() ([]byte, []int) {
+	return file_vector_vector_proto_rawDescGZIP(), []int{0}
+}
+
+type PushEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*EventWrapper        `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushEventsRequest) Reset() {
+	*x = PushEventsRequest{}
+	mi := &file_vector_vector_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushEventsRequest) ProtoMessage() {}
+
+func (x *PushEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_vector_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushEventsRequest.ProtoReflect.Descriptor instead.
+func (*PushEventsRequest) Descriptor() ([]byte, []int) {
+	return file_vector_vector_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PushEventsRequest) GetEvents() []*EventWrapper {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type PushEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushEventsResponse) Reset() {
+	*x = PushEventsResponse{}
+	mi := &file_vector_vector_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushEventsResponse) ProtoMessage() {}
+
+func (x *PushEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_vector_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushEventsResponse.ProtoReflect.Descriptor instead.
+func (*PushEventsResponse) Descriptor() ([]byte, []int) {
+	return file_vector_vector_proto_rawDescGZIP(), []int{1}
+}
+
+type HealthCheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckRequest) Reset() {
+	*x = HealthCheckRequest{}
+	mi := &file_vector_vector_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckRequest) ProtoMessage() {}
+
+func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_vector_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
+func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
+	return file_vector_vector_proto_rawDescGZIP(), []int{2}
+}
+
+type HealthCheckResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        ServingStatus          `protobuf:"varint,1,opt,name=status,proto3,enum=vector.ServingStatus" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckResponse) Reset() {
+	*x = HealthCheckResponse{}
+	mi := &file_vector_vector_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckResponse) ProtoMessage() {}
+
+func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vector_vector_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
+func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
+	return file_vector_vector_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *HealthCheckResponse) GetStatus() ServingStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ServingStatus_SERVING
+}
+
+var File_vector_vector_proto protoreflect.FileDescriptor
+
+const file_vector_vector_proto_rawDesc = "" +
+	"\n" +
+	"\x13vector/vector.proto\x12\x06vector\x1a\x12vector/event.proto\"@\n" +
+	"\x11PushEventsRequest\x12+\n" +
+	"\x06events\x18\x01 \x03(\v2\x13.event.EventWrapperR\x06events\"\x14\n" +
+	"\x12PushEventsResponse\"\x14\n" +
+	"\x12HealthCheckRequest\"D\n" +
+	"\x13HealthCheckResponse\x12-\n" +
+	"\x06status\x18\x01 \x01(\x0e2\x15.vector.ServingStatusR\x06status*-\n" +
+	"\rServingStatus\x12\v\n" +
+	"\aSERVING\x10\x00\x12\x0f\n" +
+	"\vNOT_SERVING\x10\x012\x97\x01\n" +
+	"\x06Vector\x12E\n" +
+	"\n" +
+	"PushEvents\x12\x19.vector.PushEventsRequest\x1a\x1a.vector.PushEventsResponse\"\x00\x12F\n" +
+	"\vHealthCheck\x12\x1a.vector.HealthCheckRequest\x1a\x1b.vector.HealthCheckResponseB9Z7github.com/heptiolabs/eventrouter/proto/vector;vectorpbb\x06proto3"
+
+var (
+	file_vector_vector_proto_rawDescOnce sync.Once
+	file_vector_vector_proto_rawDescData []byte
+)
+
+func file_vector_vector_proto_rawDescGZIP() []byte {
+	file_vector_vector_proto_rawDescOnce.Do(func() {
+		file_vector_vector_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_vector_vector_proto_rawDesc), len(file_vector_vector_proto_rawDesc)))
+	})
+	return file_vector_vector_proto_rawDescData
+}
+
+var file_vector_vector_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_vector_vector_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_vector_vector_proto_goTypes = []any{
+	(ServingStatus)(0),          // 0: vector.ServingStatus
+	(*PushEventsRequest)(nil),   // 1: vector.PushEventsRequest
+	(*PushEventsResponse)(nil),  // 2: vector.PushEventsResponse
+	(*HealthCheckRequest)(nil),  // 3: vector.HealthCheckRequest
+	(*HealthCheckResponse)(nil), // 4: vector.HealthCheckResponse
+	(*EventWrapper)(nil),        // 5: event.EventWrapper
+}
+var file_vector_vector_proto_depIdxs = []int32{
+	5, // 0: vector.PushEventsRequest.events:type_name -> event.EventWrapper
+	0, // 1: vector.HealthCheckResponse.status:type_name -> vector.ServingStatus
+	1, // 2: vector.Vector.PushEvents:input_type -> vector.PushEventsRequest
+	3, // 3: vector.Vector.HealthCheck:input_type -> vector.HealthCheckRequest
+	2, // 4: vector.Vector.PushEvents:output_type -> vector.PushEventsResponse
+	4, // 5: vector.Vector.HealthCheck:output_type -> vector.HealthCheckResponse
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_vector_vector_proto_init() }
+func file_vector_vector_proto_init() {
+	if File_vector_vector_proto != nil {
+		return
+	}
+	file_vector_event_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vector_vector_proto_rawDesc), len(file_vector_vector_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_vector_vector_proto_goTypes,
+		DependencyIndexes: file_vector_vector_proto_depIdxs,
+		EnumInfos:         file_vector_vector_proto_enumTypes,
+		MessageInfos:      file_vector_vector_proto_msgTypes,
+	}.Build()
+	File_vector_vector_proto = out.File
+	file_vector_vector_proto_goTypes = nil
+	file_vector_vector_proto_depIdxs = nil
+}
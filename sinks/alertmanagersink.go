@@ -0,0 +1,242 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// alertmanagerAlert is a postable alert of the v2 API.
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt,omitempty"`
+	EndsAt       time.Time         `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// AlertmanagerSinkConfig holds the options used to construct an
+// AlertmanagerSink.
+type AlertmanagerSinkConfig struct {
+	// URLs are the Alertmanager base URLs. Alerts are sent to every one, as
+	// Alertmanager replicas do not forward alerts to each other.
+	URLs []string
+
+	// BearerToken, if set, is sent with every request, e.g. for an
+	// authenticating proxy.
+	BearerToken string
+
+	// Reasons, when set, limits the Warning events that fire alerts.
+	Reasons []string
+
+	// ResolveReasons maps the reason of a Normal event to the reason of the
+	// alert it resolves on the same object, e.g. NodeReady resolves
+	// NodeNotReady.
+	ResolveReasons map[string]string
+
+	// AlertTTL is how long an alert fires after its last event. Alerts
+	// that are not resolved explicitly resolve themselves after it.
+	AlertTTL time.Duration
+
+	// Labels are added to every alert.
+	Labels map[string]string
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// AlertmanagerSink turns Warning events into Prometheus Alertmanager
+// alerts, so they go through the existing routing, grouping and silences.
+// The alert is identified by its labels: the reason as alertname, and the
+// cluster, namespace, kind and name of the involved object.
+type AlertmanagerSink struct {
+	alertsURLs     []string
+	bearerToken    string
+	filter         eventFilter
+	resolveReasons map[string]string
+	alertTTL       time.Duration
+	labels         map[string]string
+	clusterName    string
+	retryMax       int
+	client         *http.Client
+	eventCh        channels.Channel
+
+	// firing holds the labels of alerts sent by this sink that have not
+	// expired, keyed by object and lower cased reason, so resolving events
+	// can end them. It is only used from Run.
+	firing map[string]firingAlert
+}
+
+type firingAlert struct {
+	labels map[string]string
+	endsAt time.Time
+}
+
+// NewAlertmanagerSink constructs a new AlertmanagerSink. Reason keys are
+// matched case insensitively, as configuration maps lose their case.
+func NewAlertmanagerSink(cfg AlertmanagerSinkConfig) *AlertmanagerSink {
+	urls := make([]string, 0, len(cfg.URLs))
+	for _, u := range cfg.URLs {
+		urls = append(urls, strings.TrimSuffix(u, "/")+"/api/v2/alerts")
+	}
+
+	return &AlertmanagerSink{
+		alertsURLs:     urls,
+		bearerToken:    cfg.BearerToken,
+		filter:         newEventFilter([]string{v1.EventTypeWarning}, cfg.Reasons, nil),
+		resolveReasons: lowerKeys(cfg.ResolveReasons),
+		alertTTL:       cfg.AlertTTL,
+		labels:         cfg.Labels,
+		clusterName:    cfg.ClusterName,
+		retryMax:       cfg.RetryMax,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		eventCh:        newEventChannel(cfg.Overflow, cfg.BufferSize),
+		firing:         map[string]firingAlert{},
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes events that
+// fire or resolve alerts to the event channel, which is drained by Run.
+func (a *AlertmanagerSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !a.filter.match(eNew) && a.resolves(eNew) == "" {
+		return
+	}
+	a.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through a.eventCh,
+// and sending it to Alertmanager.
+func (a *AlertmanagerSink) Run(stopCh <-chan bool) {
+	runBatches(a.eventCh, stopCh, a.drainEvents)
+}
+
+// resolves returns the reason of the alert a Normal event resolves, if any.
+func (a *AlertmanagerSink) resolves(e *v1.Event) string {
+	if e.Type != v1.EventTypeNormal {
+		return ""
+	}
+	return a.resolveReasons[strings.ToLower(e.Reason)]
+}
+
+// firingKey identifies the alert for reason on e's involved object.
+func firingKey(e *v1.Event, reason string) string {
+	o := e.InvolvedObject
+	return strings.Join([]string{o.Namespace, o.Kind, o.Name, strings.ToLower(reason)}, "/")
+}
+
+// drainEvents sends the alerts fired and resolved by an array of event
+// data with one request per Alertmanager.
+func (a *AlertmanagerSink) drainEvents(events []EventData) {
+	now := time.Now()
+	for key, alert := range a.firing {
+		if now.After(alert.endsAt) {
+			delete(a.firing, key)
+		}
+	}
+
+	var alerts []alertmanagerAlert
+	for _, evt := range events {
+		e := evt.Event
+		if reason := a.resolves(e); reason != "" {
+			key := firingKey(e, reason)
+			if alert, ok := a.firing[key]; ok {
+				delete(a.firing, key)
+				alerts = append(alerts, alertmanagerAlert{
+					Labels: alert.labels,
+					EndsAt: now,
+				})
+			}
+			continue
+		}
+
+		alert := a.alert(evt, now)
+		a.firing[firingKey(e, e.Reason)] = firingAlert{labels: alert.Labels, endsAt: alert.EndsAt}
+		alerts = append(alerts, alert)
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		glog.Warningf("Failed to json serialize alertmanager alerts: %v", err)
+		return
+	}
+	for _, u := range a.alertsURLs {
+		_, err := postWithRetry(a.client, u, body, a.retryMax, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json")
+			if a.bearerToken != "" {
+				req.Header.Set("Authorization", "Bearer "+a.bearerToken)
+			}
+		})
+		if err != nil {
+			glog.Errorf("Failed to send %d alerts to %s: %v", len(alerts), u, err)
+			deadLetter("alertmanager", events, fmt.Errorf("%s: %v", u, err))
+		}
+	}
+}
+
+// alert builds the firing alert for a Warning event.
+func (a *AlertmanagerSink) alert(evt EventData, now time.Time) alertmanagerAlert {
+	e := evt.Event
+	labels := map[string]string{}
+	for k, v := range a.labels {
+		labels[k] = v
+	}
+	for k, v := range map[string]string{
+		"alertname": e.Reason,
+		"severity":  "warning",
+		"cluster":   a.clusterName,
+		"namespace": e.InvolvedObject.Namespace,
+		"kind":      e.InvolvedObject.Kind,
+		"name":      e.InvolvedObject.Name,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+
+	annotations := map[string]string{
+		"summary":     e.InvolvedObject.Kind + " " + e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name + ": " + e.Reason,
+		"description": e.Message,
+	}
+	if e.Source.Component != "" {
+		annotations["source"] = e.Source.Component
+	}
+
+	startsAt := e.FirstTimestamp.Time
+	if startsAt.IsZero() {
+		startsAt = eventTimestamp(e)
+	}
+	return alertmanagerAlert{
+		Labels:      labels,
+		Annotations: annotations,
+		StartsAt:    startsAt,
+		EndsAt:      now.Add(a.alertTTL),
+	}
+}
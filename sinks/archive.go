@@ -0,0 +1,157 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+)
+
+// archiveUploader writes a finished archive object under key.
+type archiveUploader func(key string, body []byte) error
+
+// eventArchive buffers events as gzip-compressed newline-delimited JSON and
+// hands the finished object to an uploader once flushEvents events have
+// accumulated or flushInterval has passed. It backs the object store sinks.
+//
+// Objects are written under <prefix>/<cluster>/YYYY/MM/DD/HH/ so the bucket
+// can be queried as a partitioned table, e.g. with Athena.
+type eventArchive struct {
+	sink          string
+	prefix        string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	upload        archiveUploader
+	eventCh       channels.Channel
+
+	// events are those in the current object, kept to dead letter them if
+	// it cannot be uploaded.
+	buf    bytes.Buffer
+	gz     *gzip.Writer
+	events []EventData
+	seq    int
+}
+
+// newEventArchive creates an archive for the sink named sink, as it is
+// configured by.
+func newEventArchive(sink string, prefix string, clusterName string, flushEvents int, flushInterval time.Duration, retryMax int, overflow bool, bufferSize int, upload archiveUploader) (*eventArchive, error) {
+	if err := checkFlushInterval(sink, flushInterval); err != nil {
+		return nil, err
+	}
+
+	a := &eventArchive{
+		sink:          sink,
+		prefix:        prefix,
+		clusterName:   clusterName,
+		flushEvents:   flushEvents,
+		flushInterval: flushInterval,
+		retryMax:      retryMax,
+		upload:        upload,
+		eventCh:       newEventChannel(overflow, bufferSize),
+	}
+	a.gz = gzip.NewWriter(&a.buf)
+	return a, nil
+}
+
+// run sits in a loop, appending events from a.eventCh to the current object
+// and flushing it when it is full or old enough. Whatever is buffered when
+// stopCh fires is flushed before returning.
+func (a *eventArchive) run(stopCh <-chan bool) {
+	ticker := time.NewTicker(a.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-a.eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			a.add(evt)
+			if len(a.events) >= a.flushEvents {
+				a.flush()
+			}
+		case <-ticker.C:
+			a.flush()
+		case <-stopCh:
+			a.flush()
+			return
+		}
+	}
+}
+
+// add appends a single event as one NDJSON line.
+func (a *eventArchive) add(evt EventData) {
+	eJSONBytes, err := json.Marshal(evt)
+	if err != nil {
+		glog.Warningf("Failed to json serialize event: %v", err)
+		return
+	}
+	a.gz.Write(eJSONBytes)
+	a.gz.Write([]byte{'\n'})
+	a.events = append(a.events, evt)
+}
+
+// flush closes the current object, uploads it and starts a new one. Uploads
+// are retried with backoff up to retryMax times before the object's events
+// are dead lettered.
+func (a *eventArchive) flush() {
+	if len(a.events) == 0 {
+		return
+	}
+	if err := a.gz.Close(); err != nil {
+		glog.Errorf("Failed to compress archive: %v", err)
+	}
+
+	key := a.objectKey(time.Now().UTC())
+	body := a.buf.Bytes()
+	for attempt := 0; ; attempt++ {
+		err := a.upload(key, body)
+		if err == nil {
+			glog.V(4).Infof("Archived %d events to %s", len(a.events), key)
+			break
+		}
+		if attempt >= a.retryMax {
+			glog.Errorf("Failed to archive %d events to %s: %v", len(a.events), key, err)
+			deadLetter(a.sink, a.events, err)
+			break
+		}
+		glog.Warningf("Failed to archive events to %s, retrying: %v", key, err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+
+	a.buf.Reset()
+	a.gz.Reset(&a.buf)
+	a.events = nil
+}
+
+// objectKey returns the time-partitioned key for an object flushed at t.
+func (a *eventArchive) objectKey(t time.Time) string {
+	a.seq++
+	name := fmt.Sprintf("%s-%06d.ndjson.gz", t.Format("20060102T150405Z"), a.seq)
+	return path.Join(a.prefix, a.clusterName, t.Format("2006/01/02/15"), name)
+}
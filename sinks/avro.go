@@ -0,0 +1,211 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// avroEventName is the full name of the Avro record events are encoded as.
+const avroEventName = "io.k8s.eventrouter.Event"
+
+// avroEventSchema is the Avro schema of an event: the fields of
+// flattenEventData, plus the complete EventData as JSON. Every field has a
+// default, so readers using an older or newer version of the schema can
+// resolve it.
+const avroEventSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "io.k8s.eventrouter",
+  "fields": [
+    {"name": "verb", "type": "string", "default": ""},
+    {"name": "namespace", "type": "string", "default": ""},
+    {"name": "name", "type": "string", "default": ""},
+    {"name": "uid", "type": "string", "default": ""},
+    {"name": "type", "type": "string", "default": ""},
+    {"name": "reason", "type": "string", "default": ""},
+    {"name": "message", "type": "string", "default": ""},
+    {"name": "count", "type": "long", "default": 0},
+    {"name": "first_timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}, "default": 0},
+    {"name": "last_timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}, "default": 0},
+    {"name": "source_component", "type": "string", "default": ""},
+    {"name": "source_host", "type": "string", "default": ""},
+    {"name": "involved_object_kind", "type": "string", "default": ""},
+    {"name": "involved_object_namespace", "type": "string", "default": ""},
+    {"name": "involved_object_name", "type": "string", "default": ""},
+    {"name": "involved_object_uid", "type": "string", "default": ""},
+    {"name": "event_json", "type": "string", "default": ""}
+  ]
+}`
+
+// SchemaRegistryConfig holds the Confluent Schema Registry options of the
+// Avro codec.
+type SchemaRegistryConfig struct {
+	URL      string
+	Username string
+	Password string
+
+	// SubjectStrategy is "topic" (<topic>-value), "record" (the record
+	// name) or "topicrecord" (<topic>-<record name>), as the serializers'
+	// subject name strategies.
+	SubjectStrategy string
+
+	// AutoRegister registers the schema under the subject if it is not
+	// registered yet. Otherwise it has to be registered beforehand.
+	AutoRegister bool
+
+	// Compatibility, if set, is applied to the subject before registering,
+	// e.g. BACKWARD or FULL_TRANSITIVE.
+	Compatibility string
+
+	RetryMax int
+}
+
+// avroEncoder encodes events in the Schema Registry wire format: a zero
+// magic byte, the 4 byte big-endian schema ID, then the Avro binary record.
+type avroEncoder struct {
+	codec  *goavro.Codec
+	header []byte
+}
+
+// newAvroEncoder looks up, and if configured registers, the event schema
+// for topic and returns an encoder using its ID.
+func newAvroEncoder(cfg SchemaRegistryConfig, topic string) (*avroEncoder, error) {
+	codec, err := goavro.NewCodec(avroEventSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject string
+	switch cfg.SubjectStrategy {
+	case "topic":
+		subject = topic + "-value"
+	case "record":
+		subject = avroEventName
+	case "topicrecord":
+		subject = topic + "-" + avroEventName
+	default:
+		return nil, fmt.Errorf("unknown schema registry subject strategy %q, expected topic, record or topicrecord", cfg.SubjectStrategy)
+	}
+
+	registry := &schemaRegistry{
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		retryMax: cfg.RetryMax,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if cfg.Username != "" {
+		registry.authHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+cfg.Password))
+	}
+
+	if cfg.Compatibility != "" {
+		if err := registry.setCompatibility(subject, cfg.Compatibility); err != nil {
+			return nil, fmt.Errorf("failed to set compatibility of %s: %v", subject, err)
+		}
+	}
+	id, err := registry.schemaID(subject, codec.CanonicalSchema(), cfg.AutoRegister)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up schema of %s: %v", subject, err)
+	}
+
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return &avroEncoder{codec: codec, header: header}, nil
+}
+
+// encode returns the wire format encoding of an event.
+func (a *avroEncoder) encode(evt EventData) ([]byte, error) {
+	native, err := avroEventNative(evt)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.BinaryFromNative(append([]byte(nil), a.header...), native)
+}
+
+// avroEventNative returns an event as the native goavro representation of
+// avroEventSchema.
+func avroEventNative(evt EventData) (map[string]interface{}, error) {
+	eventJSON, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+	native := flattenEventData(evt)
+	native["count"] = int64(evt.Event.Count)
+	native["event_json"] = string(eventJSON)
+	return native, nil
+}
+
+// schemaRegistry is a minimal Confluent Schema Registry client.
+type schemaRegistry struct {
+	url        string
+	authHeader string
+	retryMax   int
+	client     *http.Client
+}
+
+// schemaID returns the ID of schema under subject. If register is set, the
+// schema is registered, which returns the existing ID if it already is.
+func (s *schemaRegistry) schemaID(subject string, schema string, register bool) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	path := "/subjects/" + url.PathEscape(subject)
+	if register {
+		path += "/versions"
+	}
+
+	respBody, err := s.request(http.MethodPost, path, body)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// setCompatibility sets the compatibility level of subject.
+func (s *schemaRegistry) setCompatibility(subject string, level string) error {
+	body, err := json.Marshal(map[string]string{"compatibility": level})
+	if err != nil {
+		return err
+	}
+	_, err = s.request(http.MethodPut, "/config/"+url.PathEscape(subject), body)
+	return err
+}
+
+func (s *schemaRegistry) request(method string, path string, body []byte) ([]byte, error) {
+	return requestWithRetry(s.client, method, s.url+path, body, s.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+		if s.authHeader != "" {
+			req.Header.Set("Authorization", s.authHeader)
+		}
+	})
+}
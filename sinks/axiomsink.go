@@ -0,0 +1,154 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// AxiomSinkConfig holds the options used to construct an AxiomSink.
+type AxiomSinkConfig struct {
+	// URL is https://api.axiom.co, or the regional edge endpoint.
+	URL     string
+	Dataset string
+
+	// Token is an API token with ingest permission on the dataset. OrgID
+	// is only needed with personal tokens.
+	Token string
+	OrgID string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// AxiomSink posts events to an Axiom dataset through the ingest API. Each
+// event's timestamp is sent as _time, the field Axiom orders data by, so
+// events are indexed by when they happened rather than when they arrived.
+type AxiomSink struct {
+	ingestURL     string
+	token         string
+	orgID         string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewAxiomSink constructs a new AxiomSink.
+func NewAxiomSink(cfg AxiomSinkConfig) (*AxiomSink, error) {
+	if err := checkFlushInterval("axiom", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	return &AxiomSink{
+		ingestURL:     strings.TrimSuffix(cfg.URL, "/") + "/v1/datasets/" + url.PathEscape(cfg.Dataset) + "/ingest",
+		token:         cfg.Token,
+		orgID:         cfg.OrgID,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (a *AxiomSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	a.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through a.eventCh, and
+// sending it to Axiom in batches of up to flushEvents events.
+func (a *AxiomSink) Run(stopCh <-chan bool) {
+	runTimedBatches(a.eventCh, stopCh, a.flushEvents, a.flushInterval, a.drainEvents)
+}
+
+// drainEvents sends an array of event data with a single gzipped request
+// and logs events Axiom failed to ingest.
+func (a *AxiomSink) drainEvents(events []EventData) {
+	docs := make([]map[string]interface{}, 0, len(events))
+	for _, evt := range events {
+		doc := flattenEventData(evt)
+		doc["_time"] = eventTimestamp(evt.Event).UTC().Format(time.RFC3339Nano)
+		if a.clusterName != "" {
+			doc["cluster"] = a.clusterName
+		}
+		docs = append(docs, doc)
+	}
+
+	body, err := json.Marshal(docs)
+	if err != nil {
+		glog.Warningf("Failed to json serialize axiom request: %v", err)
+		return
+	}
+	if body, err = gzipBytes(body); err != nil {
+		glog.Warningf("Failed to compress axiom request: %v", err)
+		deadLetter("axiom", events, err)
+		return
+	}
+
+	respBody, err := postWithRetry(a.client, a.ingestURL, body, a.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Authorization", "Bearer "+a.token)
+		if a.orgID != "" {
+			req.Header.Set("X-Axiom-Org-Id", a.orgID)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to Axiom: %v", len(events), err)
+		deadLetter("axiom", events, err)
+		return
+	}
+
+	var status struct {
+		Ingested int `json:"ingested"`
+		Failed   int `json:"failed"`
+		Failures []struct {
+			Error string `json:"error"`
+		} `json:"failures"`
+	}
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		glog.Warningf("Failed to decode axiom ingest response: %v", err)
+		return
+	}
+	if status.Failed > 0 {
+		reason := ""
+		if len(status.Failures) > 0 {
+			reason = status.Failures[0].Error
+		}
+		glog.Errorf("Axiom failed to ingest %d of %d events: %s", status.Failed, len(events), reason)
+	}
+}
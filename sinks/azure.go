@@ -0,0 +1,107 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/spf13/viper"
+)
+
+// AzureTLSConfig holds the TLS options of the Azure clients, for
+// environments with TLS-inspecting proxies.
+type AzureTLSConfig struct {
+	// CAFile is a PEM bundle of root CAs trusted in addition to the
+	// system's, e.g. that of the proxy.
+	CAFile string
+
+	// MinVersion is the minimum TLS version, "1.2" or "1.3". When empty,
+	// Go's default of TLS 1.2 applies.
+	MinVersion string
+}
+
+// azureTLSConfigFromViper returns the TLS options shared by the Azure
+// sinks, azureTlsCaFile and azureTlsMinVersion.
+func azureTLSConfigFromViper() AzureTLSConfig {
+	return AzureTLSConfig{
+		CAFile:     viper.GetString("azureTlsCaFile"),
+		MinVersion: viper.GetString("azureTlsMinVersion"),
+	}
+}
+
+// newAzureTLSConfig builds a client TLS configuration, or returns nil when
+// cfg is empty so the SDKs use their own.
+func newAzureTLSConfig(cfg AzureTLSConfig) (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.MinVersion == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	switch cfg.MinVersion {
+	case "":
+	case "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid minimum TLS version %q, expected 1.2 or 1.3", cfg.MinVersion)
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// newAzureHTTPClient returns an HTTP client using tlsConfig, when not nil,
+// and proxy, or the proxy of the environment when proxy is nil.
+func newAzureHTTPClient(tlsConfig *tls.Config, proxy *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}
+}
+
+// azureClientOptions returns the options of Azure SDK HTTP clients and
+// credentials using tlsConfig, or the defaults when it is nil.
+func azureClientOptions(tlsConfig *tls.Config) azcore.ClientOptions {
+	if tlsConfig == nil {
+		return azcore.ClientOptions{}
+	}
+	return azcore.ClientOptions{Transport: newAzureHTTPClient(tlsConfig, nil)}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/linkedin/goavro/v2"
+)
+
+// azureSchemaRegistryAPIVersion is the Azure Schema Registry REST API
+// version used.
+const azureSchemaRegistryAPIVersion = "2022-10"
+
+// AzureSchemaRegistryConfig holds the Azure Schema Registry options of the
+// Avro codec.
+type AzureSchemaRegistryConfig struct {
+	// Endpoint is the fully qualified Event Hubs namespace hosting the
+	// registry, e.g. eventrouter-ns.servicebus.windows.net.
+	Endpoint string
+
+	// Group is the schema group, whose serialization type must be Avro.
+	Group string
+
+	// AutoRegister registers the schema in the group if it is not
+	// registered yet. Otherwise it has to be registered beforehand.
+	AutoRegister bool
+}
+
+// azureAvroEncoder encodes events as Avro records of avroEventSchema in the
+// Azure Schema Registry format: the binary record as the body, with a
+// content type of avro/binary+<schema ID>, as the Azure Schema Registry
+// Avro serializers produce and consume.
+type azureAvroEncoder struct {
+	codec       *goavro.Codec
+	contentType string
+}
+
+// newAzureAvroEncoder looks up, and if configured registers, the event
+// schema and returns an encoder using its ID. The identity of cred needs
+// the Schema Registry Reader role, or Contributor to register. opts
+// configures the HTTP pipeline, e.g. its transport.
+func newAzureAvroEncoder(cfg AzureSchemaRegistryConfig, cred azcore.TokenCredential, opts *policy.ClientOptions) (*azureAvroEncoder, error) {
+	codec, err := goavro.NewCodec(avroEventSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	pl := runtime.NewPipeline("eventrouter", "v1", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{
+			runtime.NewBearerTokenPolicy(cred, []string{"https://eventhubs.azure.net/.default"}, nil),
+		},
+	}, opts)
+
+	id, err := azureSchemaID(pl, cfg, codec.CanonicalSchema())
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up schema %s in group %s: %v", avroEventName, cfg.Group, err)
+	}
+	return &azureAvroEncoder{codec: codec, contentType: "avro/binary+" + id}, nil
+}
+
+// azureSchemaID returns the ID of schema in the group. With AutoRegister,
+// the schema is registered, which returns the existing ID if it already is.
+func azureSchemaID(pl runtime.Pipeline, cfg AzureSchemaRegistryConfig, schema string) (string, error) {
+	method := http.MethodPost
+	endpoint := "https://" + cfg.Endpoint + "/$schemaGroups/" + url.PathEscape(cfg.Group) + "/schemas/" + url.PathEscape(avroEventName)
+	if cfg.AutoRegister {
+		method = http.MethodPut
+	} else {
+		endpoint += ":get-id"
+	}
+
+	req, err := runtime.NewRequest(context.TODO(), method, endpoint+"?api-version="+azureSchemaRegistryAPIVersion)
+	if err != nil {
+		return "", err
+	}
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader([]byte(schema))), "application/json; serialization=Avro"); err != nil {
+		return "", err
+	}
+	resp, err := pl.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusNoContent) {
+		return "", runtime.NewResponseError(resp)
+	}
+
+	id := resp.Header.Get("Schema-Id")
+	if id == "" {
+		return "", errors.New("schema registry response has no Schema-Id")
+	}
+	return id, nil
+}
+
+// encode returns the Avro binary encoding of an event.
+func (a *azureAvroEncoder) encode(evt EventData) ([]byte, error) {
+	native, err := avroEventNative(evt)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.BinaryFromNative(nil, native)
+}
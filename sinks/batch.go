@@ -0,0 +1,117 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+)
+
+// newEventChannel creates the buffer that sits between UpdateEvents and a
+// sink's Run loop. When overflow is set, events written beyond bufferSize are
+// discarded instead of blocking the informer.
+func newEventChannel(overflow bool, bufferSize int) channels.Channel {
+	if overflow {
+		return channels.NewOverflowingChannel(channels.BufferCap(bufferSize))
+	}
+	return channels.NewNativeChannel(channels.BufferCap(bufferSize))
+}
+
+// runBatches sits in a loop, waiting for data to come in through eventCh, and
+// hands it to drain. If multiple events have happened between loop iterations,
+// all of them are passed in one call instead of making a call per event.
+func runBatches(eventCh channels.Channel, stopCh <-chan bool, drain func([]EventData)) {
+loop:
+	for {
+		select {
+		case e := <-eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue loop
+			}
+
+			// Start with just this event...
+			arr := []EventData{evt}
+
+			// Consume all buffered events into an array, in case more have been written
+			// since we last forwarded them
+			numEvents := eventCh.Len()
+			for i := 0; i < numEvents; i++ {
+				e := <-eventCh.Out()
+				if evt, ok = e.(EventData); ok {
+					arr = append(arr, evt)
+				} else {
+					glog.Warningf("Invalid type sent through event channel: %T", e)
+				}
+			}
+
+			drain(arr)
+		case <-stopCh:
+			break loop
+		}
+	}
+}
+
+// checkFlushInterval rejects a flush interval runTimedBatches cannot tick
+// at. sink names the sink in the error.
+func checkFlushInterval(sink string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid %s flush interval %v, expected a positive duration", sink, interval)
+	}
+	return nil
+}
+
+// runTimedBatches sits in a loop like runBatches, but accumulates events and
+// hands them to drain once maxEvents have been buffered or every interval,
+// whichever comes first; a maxEvents of zero flushes on the interval only.
+// Buffered events are drained before it returns.
+func runTimedBatches(eventCh channels.Channel, stopCh <-chan bool, maxEvents int, interval time.Duration, drain func([]EventData)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var arr []EventData
+	flush := func() {
+		if len(arr) > 0 {
+			drain(arr)
+			arr = nil
+		}
+	}
+
+	for {
+		select {
+		case e := <-eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			arr = append(arr, evt)
+			if maxEvents > 0 && len(arr) >= maxEvents {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-stopCh:
+			flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	v1 "k8s.io/api/core/v1"
+)
+
+// bigQueryMaxAppendBytes keeps append requests under the 10MB Storage Write
+// API request limit, with headroom for the request envelope.
+const bigQueryMaxAppendBytes = 9 * 1024 * 1024
+
+// bigQueryEventSchema is the table schema BigQuerySink creates when the
+// destination table does not exist. The equivalent DDL is:
+//
+//	CREATE TABLE dataset.events (
+//	  cluster STRING,
+//	  verb STRING,
+//	  namespace STRING,
+//	  name STRING,
+//	  uid STRING,
+//	  type STRING,
+//	  reason STRING,
+//	  message STRING,
+//	  count INT64,
+//	  source_component STRING,
+//	  source_host STRING,
+//	  involved_object_kind STRING,
+//	  involved_object_namespace STRING,
+//	  involved_object_name STRING,
+//	  involved_object_uid STRING,
+//	  first_timestamp TIMESTAMP,
+//	  last_timestamp TIMESTAMP,
+//	  event_time TIMESTAMP,
+//	  raw JSON
+//	) PARTITION BY DATE(last_timestamp);
+//
+// raw holds the complete EventData JSON. Existing tables may drop or add
+// columns; only the columns the table has are written.
+var bigQueryEventSchema = bigquery.Schema{
+	{Name: "cluster", Type: bigquery.StringFieldType},
+	{Name: "verb", Type: bigquery.StringFieldType},
+	{Name: "namespace", Type: bigquery.StringFieldType},
+	{Name: "name", Type: bigquery.StringFieldType},
+	{Name: "uid", Type: bigquery.StringFieldType},
+	{Name: "type", Type: bigquery.StringFieldType},
+	{Name: "reason", Type: bigquery.StringFieldType},
+	{Name: "message", Type: bigquery.StringFieldType},
+	{Name: "count", Type: bigquery.IntegerFieldType},
+	{Name: "source_component", Type: bigquery.StringFieldType},
+	{Name: "source_host", Type: bigquery.StringFieldType},
+	{Name: "involved_object_kind", Type: bigquery.StringFieldType},
+	{Name: "involved_object_namespace", Type: bigquery.StringFieldType},
+	{Name: "involved_object_name", Type: bigquery.StringFieldType},
+	{Name: "involved_object_uid", Type: bigquery.StringFieldType},
+	{Name: "first_timestamp", Type: bigquery.TimestampFieldType},
+	{Name: "last_timestamp", Type: bigquery.TimestampFieldType},
+	{Name: "event_time", Type: bigquery.TimestampFieldType},
+	{Name: "raw", Type: bigquery.JSONFieldType},
+}
+
+// BigQuerySink streams events into a BigQuery table through the default
+// stream of the Storage Write API.
+type BigQuerySink struct {
+	bqClient     *bigquery.Client
+	writerClient *managedwriter.Client
+	table        *bigquery.Table
+	clusterName  string
+	eventCh      channels.Channel
+
+	stream *managedwriter.ManagedStream
+
+	// rowDescriptor describes rows of the current table schema, and
+	// descriptorProto is its wire form. schemaChanged is set when the
+	// descriptor must be sent with the next append.
+	rowDescriptor   protoreflect.MessageDescriptor
+	descriptorProto *descriptorpb.DescriptorProto
+	schemaChanged   bool
+}
+
+// NewBigQuerySink constructs a new BigQuerySink writing to
+// projectID.datasetID.tableID, creating the table with bigQueryEventSchema
+// if it does not exist yet. Credentials come from Application Default
+// Credentials, and an empty projectID is detected from the environment.
+func NewBigQuerySink(projectID string, datasetID string, tableID string, clusterName string, overflow bool, bufferSize int) (*BigQuerySink, error) {
+	ctx := context.TODO()
+	if projectID == "" {
+		projectID = bigquery.DetectProjectID
+	}
+
+	bqClient, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	table := bqClient.Dataset(datasetID).Table(tableID)
+
+	meta, err := table.Metadata(ctx)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		glog.Infof("Creating BigQuery table %s.%s", datasetID, tableID)
+		err = table.Create(ctx, &bigquery.TableMetadata{
+			Schema: bigQueryEventSchema,
+			TimePartitioning: &bigquery.TimePartitioning{
+				Type:  bigquery.DayPartitioningType,
+				Field: "last_timestamp",
+			},
+		})
+		if err == nil {
+			meta, err = table.Metadata(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	writerClient, err := managedwriter.NewClient(ctx, bqClient.Project())
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BigQuerySink{
+		bqClient:     bqClient,
+		writerClient: writerClient,
+		table:        table,
+		clusterName:  clusterName,
+		eventCh:      newEventChannel(overflow, bufferSize),
+	}
+	storageSchema, err := adapt.BQSchemaToStorageTableSchema(meta.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.setSchema(storageSchema); err != nil {
+		return nil, err
+	}
+
+	b.stream, err = writerClient.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(table.ProjectID, table.DatasetID, table.TableID)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(b.descriptorProto),
+	)
+	if err != nil {
+		return nil, err
+	}
+	b.schemaChanged = false
+
+	return b, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (b *BigQuerySink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	b.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through b.eventCh,
+// and appending it to the table.
+func (b *BigQuerySink) Run(stopCh <-chan bool) {
+	defer b.bqClient.Close()
+	defer b.writerClient.Close()
+	defer b.stream.Close()
+	runBatches(b.eventCh, stopCh, b.drainEvents)
+}
+
+// drainEvents encodes an array of event data as rows of the current table
+// schema and appends them in as few requests as the request limit allows.
+func (b *BigQuerySink) drainEvents(events []EventData) {
+	// batched are the events of rows.
+	var rows [][]byte
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		row, err := b.encodeRow(evt)
+		if err != nil {
+			glog.Warningf("Failed to encode event as a BigQuery row: %v", err)
+			deadLetter("bigquery", []EventData{evt}, err)
+			continue
+		}
+		if len(rows) > 0 && batchBytes+len(row) > bigQueryMaxAppendBytes {
+			b.appendRows(rows, batched)
+			rows, batched = nil, nil
+			batchBytes = 0
+		}
+		rows = append(rows, row)
+		batched = append(batched, evt)
+		batchBytes += len(row)
+	}
+
+	if len(rows) > 0 {
+		b.appendRows(rows, batched)
+	}
+}
+
+// appendRows sends one append request. If BigQuery reports that the table
+// schema has changed, the row descriptor is rebuilt so later rows match it.
+// The events of a rejected append are dead lettered.
+func (b *BigQuerySink) appendRows(rows [][]byte, events []EventData) {
+	var opts []managedwriter.AppendOption
+	if b.schemaChanged {
+		opts = append(opts, managedwriter.UpdateSchemaDescriptor(b.descriptorProto))
+		b.schemaChanged = false
+	}
+
+	result, err := b.stream.AppendRows(context.TODO(), rows, opts...)
+	if err == nil {
+		_, err = result.GetResult(context.TODO())
+	}
+	if err != nil {
+		glog.Errorf("Failed to append %d rows to BigQuery: %v", len(rows), err)
+		deadLetter("bigquery", events, err)
+		// The most common cause of a rejected append on the default stream
+		// is a table schema that no longer matches our descriptor.
+		if err := b.refreshSchema(); err != nil {
+			glog.Errorf("Failed to refresh BigQuery table schema: %v", err)
+		}
+		return
+	}
+
+	updated, err := result.UpdatedSchema(context.TODO())
+	if err == nil && updated != nil {
+		glog.Infof("BigQuery table schema changed, updating row descriptor")
+		if err := b.setSchema(updated); err != nil {
+			glog.Errorf("Failed to apply updated BigQuery table schema: %v", err)
+		}
+	}
+}
+
+// refreshSchema reloads the table schema from BigQuery.
+func (b *BigQuerySink) refreshSchema() error {
+	meta, err := b.table.Metadata(context.TODO())
+	if err != nil {
+		return err
+	}
+	storageSchema, err := adapt.BQSchemaToStorageTableSchema(meta.Schema)
+	if err != nil {
+		return err
+	}
+	return b.setSchema(storageSchema)
+}
+
+// setSchema builds the row descriptor for a table schema. The new descriptor
+// is sent along with the next append request.
+func (b *BigQuerySink) setSchema(schema *storagepb.TableSchema) error {
+	descriptor, err := adapt.StorageSchemaToProto2Descriptor(schema, "root")
+	if err != nil {
+		return err
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return fmt.Errorf("unexpected descriptor type %T", descriptor)
+	}
+	descriptorProto, err := adapt.NormalizeDescriptor(messageDescriptor)
+	if err != nil {
+		return err
+	}
+
+	b.rowDescriptor = messageDescriptor
+	b.descriptorProto = descriptorProto
+	b.schemaChanged = true
+	return nil
+}
+
+// encodeRow flattens an event into a row and serializes it with the current
+// row descriptor. Fields that are not columns of the table are dropped.
+func (b *BigQuerySink) encodeRow(evt EventData) ([]byte, error) {
+	raw, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	e := evt.Event
+	row := map[string]interface{}{
+		"cluster":                   b.clusterName,
+		"verb":                      evt.Verb,
+		"namespace":                 e.Namespace,
+		"name":                      e.Name,
+		"uid":                       string(e.UID),
+		"type":                      e.Type,
+		"reason":                    e.Reason,
+		"message":                   e.Message,
+		"count":                     e.Count,
+		"source_component":          e.Source.Component,
+		"source_host":               e.Source.Host,
+		"involved_object_kind":      e.InvolvedObject.Kind,
+		"involved_object_namespace": e.InvolvedObject.Namespace,
+		"involved_object_name":      e.InvolvedObject.Name,
+		"involved_object_uid":       string(e.InvolvedObject.UID),
+		"raw":                       string(raw),
+	}
+	// The Storage Write API expects TIMESTAMP columns as epoch microseconds.
+	if !e.FirstTimestamp.IsZero() {
+		row["first_timestamp"] = e.FirstTimestamp.UnixMicro()
+	}
+	if !e.LastTimestamp.IsZero() {
+		row["last_timestamp"] = e.LastTimestamp.UnixMicro()
+	}
+	if !e.EventTime.IsZero() {
+		row["event_time"] = e.EventTime.UnixMicro()
+	}
+
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(b.rowDescriptor)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(rowJSON, msg); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// bulkDocument is the document indexed for each event. It adds an
+// @timestamp field to EventData for index patterns and data views.
+type bulkDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	EventData
+}
+
+// bulkResponse is the subset of a _bulk response we inspect.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulkIndexer indexes events through the _bulk API shared by Elasticsearch
+// and OpenSearch. Events go to time-based indices named indexPrefix followed
+// by the event time formatted with indexDateLayout, e.g. k8s-events-2024.06.
+type bulkIndexer struct {
+	// sink names the sink, as it is configured by, for dead letters.
+	sink            string
+	url             string
+	indexPrefix     string
+	indexDateLayout string
+	retryMax        int
+	client          *http.Client
+
+	// authorize adds credentials to a request just before it is sent.
+	authorize func(req *http.Request, body []byte) error
+}
+
+// index sends events in a single _bulk request. Requests and items rejected
+// with a retryable status (429 Too Many Requests or 503 Service Unavailable)
+// are retried with backoff up to retryMax times, and the events that still
+// fail are dead lettered.
+func (b *bulkIndexer) index(events []EventData) {
+	var lastErr error
+	for attempt := 0; len(events) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > b.retryMax {
+				glog.Errorf("Failed to index %d events after %d attempts", len(events), attempt)
+				deadLetter(b.sink, events, lastErr)
+				return
+			}
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+
+		var body []byte
+		body, events = b.encode(events)
+		if len(events) == 0 {
+			return
+		}
+		resp, err := b.send(body)
+		if err != nil {
+			glog.Warningf("Failed to send bulk request: %v", err)
+			lastErr = err
+			continue
+		}
+
+		if bulkRetryable(resp.StatusCode) {
+			resp.Body.Close()
+			glog.Warningf("Bulk request rejected with status %d, retrying", resp.StatusCode)
+			lastErr = fmt.Errorf("bulk request rejected with status %d", resp.StatusCode)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			msg, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			glog.Errorf("Bulk request failed with status %d: %s", resp.StatusCode, msg)
+			deadLetter(b.sink, events, fmt.Errorf("bulk request failed with status %d: %s", resp.StatusCode, msg))
+			return
+		}
+
+		var result bulkResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			// The document IDs make indexing the dead letters again safe.
+			glog.Errorf("Failed to decode bulk response: %v", err)
+			deadLetter(b.sink, events, err)
+			return
+		}
+		if !result.Errors {
+			return
+		}
+
+		var retry []EventData
+		for i, item := range result.Items {
+			for _, r := range item {
+				switch {
+				case r.Status < 300:
+				case bulkRetryable(r.Status) && i < len(events):
+					retry = append(retry, events[i])
+					lastErr = fmt.Errorf("status %d: %s", r.Status, r.Error)
+				default:
+					glog.Errorf("Failed to index event: status %d: %s", r.Status, r.Error)
+					if i < len(events) {
+						deadLetter(b.sink, []EventData{events[i]}, fmt.Errorf("status %d: %s", r.Status, r.Error))
+					}
+				}
+			}
+		}
+		events = retry
+	}
+}
+
+// encode builds the NDJSON body of a _bulk request. Document IDs are derived
+// from the event UID and resourceVersion so retried requests do not create
+// duplicates. It returns the events in the body, in order, which the items of
+// the response correspond to; events that cannot be serialized are dead
+// lettered.
+func (b *bulkIndexer) encode(events []EventData) ([]byte, []EventData) {
+	var buf bytes.Buffer
+	encoded := make([]EventData, 0, len(events))
+	for _, evt := range events {
+		ts := eventTimestamp(evt.Event).UTC()
+		action := map[string]map[string]string{
+			"index": {
+				"_index": b.indexPrefix + "-" + ts.Format(b.indexDateLayout),
+				"_id":    fmt.Sprintf("%s-%s", evt.Event.UID, evt.Event.ResourceVersion),
+			},
+		}
+		actionJSON, err := json.Marshal(action)
+		if err != nil {
+			glog.Warningf("Failed to json serialize bulk action: %v", err)
+			deadLetter(b.sink, []EventData{evt}, err)
+			continue
+		}
+		docJSON, err := json.Marshal(bulkDocument{Timestamp: ts, EventData: evt})
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			deadLetter(b.sink, []EventData{evt}, err)
+			continue
+		}
+		buf.Write(actionJSON)
+		buf.WriteByte('\n')
+		buf.Write(docJSON)
+		buf.WriteByte('\n')
+		encoded = append(encoded, evt)
+	}
+	return buf.Bytes(), encoded
+}
+
+// send posts a _bulk request body.
+func (b *bulkIndexer) send(body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(b.url, "/")+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if b.authorize != nil {
+		if err := b.authorize(req, body); err != nil {
+			return nil, err
+		}
+	}
+	return b.client.Do(req)
+}
+
+// bulkRetryable reports whether a status means the cluster is overloaded and
+// the request should be retried later.
+func bulkRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/gocql/gocql"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// cassandraMaxBatchSize bounds the number of rows in a single batch, keeping
+// batches below Cassandra's batch_size_fail_threshold.
+const cassandraMaxBatchSize = 50
+
+// cassandraSchema creates the events table. Rows are partitioned by cluster,
+// namespace and day, so a partition never grows without bound and a
+// namespace's events for a day are read from one replica set. %[1]s is the
+// table name and %[2]d the default TTL in seconds.
+const cassandraSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+  cluster text,
+  namespace text,
+  day date,
+  ts timestamp,
+  id timeuuid,
+  verb text,
+  kind text,
+  name text,
+  uid text,
+  type text,
+  reason text,
+  message text,
+  count int,
+  event text,
+  PRIMARY KEY ((cluster, namespace, day), ts, id)
+) WITH CLUSTERING ORDER BY (ts DESC, id ASC)
+  AND default_time_to_live = %[2]d`
+
+// CassandraSinkConfig holds the options used to construct a CassandraSink.
+type CassandraSinkConfig struct {
+	Hosts []string
+
+	// Keyspace must already exist, since its replication settings are
+	// deployment specific. Table is created if it does not exist.
+	Keyspace string
+	Table    string
+
+	ClusterName string
+
+	// Consistency is a consistency level name such as LOCAL_QUORUM.
+	Consistency string
+
+	// LocalDC keeps requests in one datacenter when set.
+	LocalDC string
+
+	// TTL expires rows that long after they were written; zero keeps them
+	// forever.
+	TTL time.Duration
+
+	Username string
+	Password string
+
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	Overflow   bool
+	BufferSize int
+}
+
+// CassandraSink writes events to a Cassandra or ScyllaDB table.
+type CassandraSink struct {
+	session     *gocql.Session
+	insert      string
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// cassandraPartition identifies a partition of the events table.
+type cassandraPartition struct {
+	namespace string
+	day       time.Time
+}
+
+// NewCassandraSink connects to the cluster and constructs a new
+// CassandraSink.
+func NewCassandraSink(cfg CassandraSinkConfig) (*CassandraSink, error) {
+	consistency, err := gocql.ParseConsistencyWrapper(cfg.Consistency)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := gocql.NewCluster(cfg.Hosts...)
+	cluster.Keyspace = cfg.Keyspace
+	cluster.Consistency = consistency
+	cluster.Timeout = 10 * time.Second
+
+	// Route each batch straight to a replica owning its partition.
+	fallback := gocql.RoundRobinHostPolicy()
+	if cfg.LocalDC != "" {
+		fallback = gocql.DCAwareRoundRobinPolicy(cfg.LocalDC)
+	}
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(fallback)
+
+	if cfg.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}
+	}
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		cluster.SslOpts = &gocql.SslOptions{
+			Config:                 tlsConfig,
+			EnableHostVerification: !cfg.TLSInsecureSkipVerify,
+		}
+	}
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := int(cfg.TTL / time.Second)
+	if err := session.Query(fmt.Sprintf(cassandraSchema, cfg.Table, ttl)).Exec(); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return &CassandraSink{
+		session: session,
+		insert: "INSERT INTO " + cfg.Table + " (cluster, namespace, day, ts, id, verb, kind, name, uid, type, reason, message, count, event)" +
+			" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		clusterName: cfg.ClusterName,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (c *CassandraSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	c.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through c.eventCh,
+// and writing it to the table.
+func (c *CassandraSink) Run(stopCh <-chan bool) {
+	defer c.session.Close()
+	runBatches(c.eventCh, stopCh, c.drainEvents)
+}
+
+// drainEvents writes an array of event data. Events are grouped by
+// partition and each group is sent as unlogged batches: single-partition
+// batches are applied atomically by one replica set, without the
+// coordinator overhead of multi-partition batches.
+func (c *CassandraSink) drainEvents(events []EventData) {
+	batches := make(map[cassandraPartition]*gocql.Batch)
+	batched := make(map[cassandraPartition][]EventData)
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		e := evt.Event
+		ts := eventTimestamp(e).UTC()
+		key := cassandraPartition{
+			namespace: e.InvolvedObject.Namespace,
+			day:       ts.Truncate(24 * time.Hour),
+		}
+		b := batches[key]
+		if b == nil {
+			b = c.session.NewBatch(gocql.UnloggedBatch).WithContext(context.TODO())
+			batches[key] = b
+		}
+		b.Query(c.insert, c.clusterName, key.namespace, key.day, ts, gocql.TimeUUID(),
+			evt.Verb, e.InvolvedObject.Kind, e.InvolvedObject.Name, string(e.InvolvedObject.UID),
+			e.Type, e.Reason, e.Message, int(e.Count), string(eJSONBytes))
+		batched[key] = append(batched[key], evt)
+
+		if b.Size() == cassandraMaxBatchSize {
+			c.execute(b, batched[key])
+			delete(batches, key)
+			delete(batched, key)
+		}
+	}
+
+	for key, b := range batches {
+		c.execute(b, batched[key])
+	}
+}
+
+// execute runs a batch of events, logging and dead lettering failures.
+func (c *CassandraSink) execute(b *gocql.Batch, events []EventData) {
+	if err := c.session.ExecuteBatch(b); err != nil {
+		glog.Errorf("Failed to write %d events to Cassandra: %v", b.Size(), err)
+		deadLetter("cassandra", events, err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ClickHouseSinkConfig holds the options used to construct a ClickHouseSink.
+type ClickHouseSinkConfig struct {
+	// URL is the HTTP interface, e.g. http://clickhouse:8123.
+	URL      string
+	Database string
+	Table    string
+	Username string
+	Password string
+
+	// AsyncInsert lets the server buffer small inserts itself, which is
+	// recommended when many clusters write to the same table.
+	AsyncInsert bool
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// ClickHouseSink inserts events into a ClickHouse table through the HTTP
+// interface using the JSONEachRow format. The table is expected to look
+// like:
+//
+//	CREATE TABLE k8s_events (
+//	  cluster LowCardinality(String),
+//	  verb LowCardinality(String),
+//	  namespace LowCardinality(String),
+//	  name String,
+//	  uid String,
+//	  type LowCardinality(String),
+//	  reason LowCardinality(String),
+//	  message String,
+//	  count UInt32,
+//	  first_timestamp DateTime64(3),
+//	  last_timestamp DateTime64(3),
+//	  source_component LowCardinality(String),
+//	  source_host String,
+//	  involved_object_kind LowCardinality(String),
+//	  involved_object_namespace LowCardinality(String),
+//	  involved_object_name String,
+//	  involved_object_uid String,
+//	  raw String
+//	) ENGINE = MergeTree
+//	PARTITION BY toYYYYMM(last_timestamp)
+//	ORDER BY (cluster, namespace, last_timestamp);
+type ClickHouseSink struct {
+	insertURL     string
+	username      string
+	password      string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewClickHouseSink constructs a new ClickHouseSink. Events are inserted once
+// FlushEvents have been buffered or every FlushInterval.
+func NewClickHouseSink(cfg ClickHouseSinkConfig) (*ClickHouseSink, error) {
+	if err := checkFlushInterval("clickhouse", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("query", "INSERT INTO "+cfg.Table+" FORMAT JSONEachRow")
+	params.Set("database", cfg.Database)
+	params.Set("date_time_input_format", "best_effort")
+	params.Set("input_format_skip_unknown_fields", "1")
+	if cfg.AsyncInsert {
+		params.Set("async_insert", "1")
+		params.Set("wait_for_async_insert", "1")
+	}
+
+	return &ClickHouseSink{
+		insertURL:     strings.TrimSuffix(cfg.URL, "/") + "/?" + params.Encode(),
+		username:      cfg.Username,
+		password:      cfg.Password,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 60 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (c *ClickHouseSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	c.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through c.eventCh,
+// and inserting it in batches.
+func (c *ClickHouseSink) Run(stopCh <-chan bool) {
+	runTimedBatches(c.eventCh, stopCh, c.flushEvents, c.flushInterval, c.drainEvents)
+}
+
+// drainEvents inserts an array of event data with a single INSERT.
+func (c *ClickHouseSink) drainEvents(events []EventData) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		raw, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		row := flattenEventData(evt)
+		row["cluster"] = c.clusterName
+		row["raw"] = string(raw)
+
+		rowJSON, err := json.Marshal(row)
+		if err != nil {
+			glog.Warningf("Failed to json serialize clickhouse row: %v", err)
+			continue
+		}
+		buf.Write(rowJSON)
+		buf.WriteByte('\n')
+	}
+
+	_, err := postWithRetry(c.client, c.insertURL, buf.Bytes(), c.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if c.username != "" {
+			req.Header.Set("X-ClickHouse-User", c.username)
+			req.Header.Set("X-ClickHouse-Key", c.password)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to insert %d events into ClickHouse: %v", len(events), err)
+		deadLetter("clickhouse", events, err)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// CloudEventsSinkConfig holds the options used to construct a
+// CloudEventsSink.
+type CloudEventsSinkConfig struct {
+	// URL receives the events, e.g. a Knative Broker ingress such as
+	// http://broker-ingress.knative-eventing.svc/default/default.
+	URL string
+
+	// Mode is "binary", with attributes in ce- headers and the event data
+	// as the body, or "structured", with the whole CloudEvent as JSON.
+	Mode string
+
+	// Type is the CloudEvents type attribute.
+	Type string
+
+	// BearerToken, when set, is sent in the Authorization header.
+	BearerToken string
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// cloudEvent is a CloudEvents 1.0 event in the JSON event format.
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            string    `json:"time,omitempty"`
+	DataContentType string    `json:"datacontenttype"`
+	Reason          string    `json:"reason,omitempty"`
+	EventType       string    `json:"eventtype,omitempty"`
+	Data            EventData `json:"data"`
+}
+
+// CloudEventsSink delivers each event as a CloudEvents 1.0 HTTP request, so
+// events can be sent to Knative Brokers and other CloudEvents receivers.
+//
+// The source identifies the cluster and namespace, and the subject is
+// the involved object's kind and name. The reason and eventtype extension
+// attributes carry the event's reason and type, for Trigger filters.
+type CloudEventsSink struct {
+	url         string
+	structured  bool
+	eventType   string
+	bearerToken string
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+}
+
+// NewCloudEventsSink constructs a new CloudEventsSink.
+func NewCloudEventsSink(cfg CloudEventsSinkConfig) (*CloudEventsSink, error) {
+	if cfg.Mode != "binary" && cfg.Mode != "structured" {
+		return nil, fmt.Errorf("unknown cloudevents mode %q, expected binary or structured", cfg.Mode)
+	}
+
+	return &CloudEventsSink{
+		url:         cfg.URL,
+		structured:  cfg.Mode == "structured",
+		eventType:   cfg.Type,
+		bearerToken: cfg.BearerToken,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (c *CloudEventsSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	c.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through c.eventCh,
+// and delivering it.
+func (c *CloudEventsSink) Run(stopCh <-chan bool) {
+	runBatches(c.eventCh, stopCh, c.drainEvents)
+}
+
+// drainEvents sends one request per event.
+func (c *CloudEventsSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		if err := c.send(c.newCloudEvent(evt)); err != nil {
+			glog.Errorf("Failed to deliver CloudEvent for %s/%s: %v", evt.Event.Namespace, evt.Event.Name, err)
+			deadLetter("cloudevents", []EventData{evt}, err)
+		}
+	}
+}
+
+// newCloudEvent wraps event data in a CloudEvent. The ID combines the
+// event's UID and resource version, so redeliveries of the same update
+// share it while every update gets its own.
+func (c *CloudEventsSink) newCloudEvent(evt EventData) cloudEvent {
+	e := evt.Event
+	ce := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              string(e.UID) + "." + e.ResourceVersion,
+		Source:          cloudEventSource(c.clusterName, e),
+		Type:            c.eventType,
+		Subject:         e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+		DataContentType: "application/json",
+		Reason:          e.Reason,
+		EventType:       e.Type,
+		Data:            evt,
+	}
+	if ts := eventTimestamp(e); !ts.IsZero() {
+		ce.Time = ts.UTC().Format(time.RFC3339Nano)
+	}
+	return ce
+}
+
+// cloudEventSource returns /clusters/<cluster>/namespaces/<namespace>,
+// leaving out the parts that are empty, e.g. the namespace of a Node's
+// events.
+func cloudEventSource(clusterName string, e *v1.Event) string {
+	source := ""
+	if clusterName != "" {
+		source = "/clusters/" + clusterName
+	}
+	if ns := e.InvolvedObject.Namespace; ns != "" {
+		source += "/namespaces/" + ns
+	}
+	if source == "" {
+		source = "/"
+	}
+	return source
+}
+
+// send posts a CloudEvent in the configured content mode.
+func (c *CloudEventsSink) send(ce cloudEvent) error {
+	var body []byte
+	var err error
+	if c.structured {
+		body, err = json.Marshal(ce)
+	} else {
+		body, err = json.Marshal(ce.Data)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = postWithRetry(c.client, c.url, body, c.retryMax, func(req *http.Request) {
+		if c.structured {
+			req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+		} else {
+			req.Header.Set("Content-Type", ce.DataContentType)
+			req.Header.Set("ce-specversion", ce.SpecVersion)
+			req.Header.Set("ce-id", ce.ID)
+			req.Header.Set("ce-source", ce.Source)
+			req.Header.Set("ce-type", ce.Type)
+			req.Header.Set("ce-subject", ce.Subject)
+			if ce.Time != "" {
+				req.Header.Set("ce-time", ce.Time)
+			}
+			if ce.Reason != "" {
+				req.Header.Set("ce-reason", ce.Reason)
+			}
+			if ce.EventType != "" {
+				req.Header.Set("ce-eventtype", ce.EventType)
+			}
+		}
+		if c.bearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+		}
+	})
+	return err
+}
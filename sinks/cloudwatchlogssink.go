@@ -0,0 +1,231 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// cloudWatchLogsMaxBatchEvents, cloudWatchLogsMaxBatchBytes and
+	// cloudWatchLogsEventOverhead are the PutLogEvents limits; every event
+	// counts its message size plus a fixed overhead towards the byte limit.
+	cloudWatchLogsMaxBatchEvents = 10000
+	cloudWatchLogsMaxBatchBytes  = 1048576
+	cloudWatchLogsEventOverhead  = 26
+
+	// cloudWatchLogsMaxBatchSpan is the longest time range a single
+	// PutLogEvents call may cover.
+	cloudWatchLogsMaxBatchSpan = 24 * time.Hour
+)
+
+// CloudWatchLogsSink writes events to an AWS CloudWatch Logs log group, with
+// one log stream per cluster or one per namespace.
+type CloudWatchLogsSink struct {
+	client      *cloudwatchlogs.Client
+	logGroup    string
+	streamBy    string
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+
+	// sequenceTokens holds the next sequence token for each log stream we
+	// have created or written to. A nil token is valid for a new stream.
+	sequenceTokens map[string]*string
+}
+
+// NewCloudWatchLogsSink constructs a new CloudWatchLogsSink. streamBy is
+// either "cluster", writing everything to a stream named after clusterName,
+// or "namespace", writing each namespace to its own stream. The log group
+// must already exist; streams are created as needed.
+func NewCloudWatchLogsSink(logGroup string, streamBy string, clusterName string, region string, retryMax int, overflow bool, bufferSize int) (*CloudWatchLogsSink, error) {
+	if streamBy != "cluster" && streamBy != "namespace" {
+		return nil, fmt.Errorf("invalid cloudwatch logs stream selection %q, expected cluster or namespace", streamBy)
+	}
+	if clusterName == "" {
+		return nil, errors.New("cloudwatch logs sink requires a cluster name")
+	}
+
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloudWatchLogsSink{
+		client:         cloudwatchlogs.NewFromConfig(cfg),
+		logGroup:       logGroup,
+		streamBy:       streamBy,
+		clusterName:    clusterName,
+		retryMax:       retryMax,
+		eventCh:        newEventChannel(overflow, bufferSize),
+		sequenceTokens: map[string]*string{},
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (c *CloudWatchLogsSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	c.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through c.eventCh,
+// and writing it to the log group.
+func (c *CloudWatchLogsSink) Run(stopCh <-chan bool) {
+	runBatches(c.eventCh, stopCh, c.drainEvents)
+}
+
+// cloudWatchLogsEntry is a log event with the event data it was made from.
+type cloudWatchLogsEntry struct {
+	logEvent types.InputLogEvent
+	evt      EventData
+}
+
+// drainEvents groups an array of event data by log stream, then writes each
+// stream's events in chronological order, split to fit the PutLogEvents limits.
+func (c *CloudWatchLogsSink) drainEvents(events []EventData) {
+	streams := map[string][]cloudWatchLogsEntry{}
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(eJSONBytes)+cloudWatchLogsEventOverhead > cloudWatchLogsMaxBatchBytes {
+			glog.Errorf("Dropping event %s/%s: %d bytes exceeds the CloudWatch Logs event limit", evt.Event.Namespace, evt.Event.Name, len(eJSONBytes))
+			deadLetter("cloudwatchlogs", []EventData{evt}, fmt.Errorf("%d bytes exceeds the CloudWatch Logs event limit", len(eJSONBytes)))
+			continue
+		}
+
+		stream := c.streamName(evt.Event)
+		streams[stream] = append(streams[stream], cloudWatchLogsEntry{
+			logEvent: types.InputLogEvent{
+				Message:   aws.String(string(eJSONBytes)),
+				Timestamp: aws.Int64(eventTimestamp(evt.Event).UnixMilli()),
+			},
+			evt: evt,
+		})
+	}
+
+	for stream, entries := range streams {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return *entries[i].logEvent.Timestamp < *entries[j].logEvent.Timestamp
+		})
+
+		start := 0
+		batchBytes := 0
+		for i, entry := range entries {
+			size := len(*entry.logEvent.Message) + cloudWatchLogsEventOverhead
+			span := time.Duration(*entry.logEvent.Timestamp-*entries[start].logEvent.Timestamp) * time.Millisecond
+			if i-start == cloudWatchLogsMaxBatchEvents || batchBytes+size > cloudWatchLogsMaxBatchBytes || span >= cloudWatchLogsMaxBatchSpan {
+				c.putLogEvents(stream, entries[start:i])
+				start = i
+				batchBytes = 0
+			}
+			batchBytes += size
+		}
+		c.putLogEvents(stream, entries[start:])
+	}
+}
+
+// putLogEvents writes a single batch to a log stream, creating the stream on
+// first use. Stale sequence tokens are refreshed from the error CloudWatch
+// returns, and throttled requests are retried with backoff. The events of a
+// batch that still fails are dead lettered.
+func (c *CloudWatchLogsSink) putLogEvents(stream string, entries []cloudWatchLogsEntry) {
+	logEvents := make([]types.InputLogEvent, len(entries))
+	events := make([]EventData, len(entries))
+	for i, entry := range entries {
+		logEvents[i], events[i] = entry.logEvent, entry.evt
+	}
+
+	if err := c.ensureStream(stream); err != nil {
+		glog.Errorf("Failed to create log stream %s: %v", stream, err)
+		deadLetter("cloudwatchlogs", events, err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		out, err := c.client.PutLogEvents(context.TODO(), &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(c.logGroup),
+			LogStreamName: aws.String(stream),
+			LogEvents:     logEvents,
+			SequenceToken: c.sequenceTokens[stream],
+		})
+		if err == nil {
+			c.sequenceTokens[stream] = out.NextSequenceToken
+			if out.RejectedLogEventsInfo != nil {
+				glog.Warningf("CloudWatch Logs rejected some events in stream %s: %+v", stream, *out.RejectedLogEventsInfo)
+			}
+			return
+		}
+
+		var invalidToken *types.InvalidSequenceTokenException
+		var alreadyAccepted *types.DataAlreadyAcceptedException
+		var throttled *types.ThrottlingException
+		switch {
+		case errors.As(err, &alreadyAccepted):
+			c.sequenceTokens[stream] = alreadyAccepted.ExpectedSequenceToken
+			return
+		case errors.As(err, &invalidToken) && attempt < c.retryMax:
+			c.sequenceTokens[stream] = invalidToken.ExpectedSequenceToken
+		case errors.As(err, &throttled) && attempt < c.retryMax:
+			time.Sleep(backoff(attempt+1, 200*time.Millisecond, 10*time.Second))
+		default:
+			glog.Errorf("Failed to put %d events to log stream %s: %v", len(logEvents), stream, err)
+			deadLetter("cloudwatchlogs", events, err)
+			return
+		}
+	}
+}
+
+// ensureStream creates a log stream unless we have already seen it.
+func (c *CloudWatchLogsSink) ensureStream(stream string) error {
+	if _, ok := c.sequenceTokens[stream]; ok {
+		return nil
+	}
+
+	_, err := c.client.CreateLogStream(context.TODO(), &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(c.logGroup),
+		LogStreamName: aws.String(stream),
+	})
+	var exists *types.ResourceAlreadyExistsException
+	if err != nil && !errors.As(err, &exists) {
+		return err
+	}
+	c.sequenceTokens[stream] = nil
+	return nil
+}
+
+// streamName returns the log stream an event is written to.
+func (c *CloudWatchLogsSink) streamName(e *v1.Event) string {
+	if c.streamBy == "namespace" && e.InvolvedObject.Namespace != "" {
+		return c.clusterName + "/" + e.InvolvedObject.Namespace
+	}
+	return c.clusterName
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// coralogixMaxRequestBytes is the ingestion API's request size limit.
+const coralogixMaxRequestBytes = 2 * 1000 * 1000
+
+// Coralogix severities.
+const (
+	coralogixSeverityInfo    = 3
+	coralogixSeverityWarning = 4
+)
+
+// coralogixLog is a single entry of a singles request.
+type coralogixLog struct {
+	ApplicationName string  `json:"applicationName"`
+	SubsystemName   string  `json:"subsystemName"`
+	ComputerName    string  `json:"computerName,omitempty"`
+	Timestamp       float64 `json:"timestamp"`
+	Severity        int     `json:"severity"`
+	Text            string  `json:"text"`
+	Category        string  `json:"category,omitempty"`
+	ClassName       string  `json:"className,omitempty"`
+}
+
+// CoralogixSinkConfig holds the options used to construct a CoralogixSink.
+type CoralogixSinkConfig struct {
+	// Domain is the account's Coralogix domain, e.g. coralogix.com or
+	// eu2.coralogix.com.
+	Domain string
+
+	// PrivateKey is a Send-Your-Data API key.
+	PrivateKey string
+
+	// ApplicationName defaults to the cluster name. SubsystemName is used
+	// for events without a namespace; other events use their namespace.
+	ApplicationName string
+	SubsystemName   string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// CoralogixSink ships events to the Coralogix REST ingestion API. The
+// application is the cluster and the subsystem the event's namespace, so
+// Coralogix's per-application and per-subsystem views and quotas follow the
+// cluster layout.
+type CoralogixSink struct {
+	url             string
+	privateKey      string
+	applicationName string
+	subsystemName   string
+	flushEvents     int
+	flushInterval   time.Duration
+	retryMax        int
+	client          *http.Client
+	eventCh         channels.Channel
+}
+
+// NewCoralogixSink constructs a new CoralogixSink.
+func NewCoralogixSink(cfg CoralogixSinkConfig) (*CoralogixSink, error) {
+	if err := checkFlushInterval("coralogix", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	applicationName := cfg.ApplicationName
+	if applicationName == "" {
+		applicationName = cfg.ClusterName
+	}
+	if applicationName == "" {
+		applicationName = "kubernetes"
+	}
+
+	return &CoralogixSink{
+		url:             "https://ingress." + cfg.Domain + "/logs/v1/singles",
+		privateKey:      cfg.PrivateKey,
+		applicationName: applicationName,
+		subsystemName:   cfg.SubsystemName,
+		flushEvents:     cfg.FlushEvents,
+		flushInterval:   cfg.FlushInterval,
+		retryMax:        cfg.RetryMax,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		eventCh:         newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (c *CoralogixSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	c.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through c.eventCh, and
+// sending it to Coralogix in batches of up to flushEvents events.
+func (c *CoralogixSink) Run(stopCh <-chan bool) {
+	runTimedBatches(c.eventCh, stopCh, c.flushEvents, c.flushInterval, c.drainEvents)
+}
+
+// drainEvents sends an array of event data in as few requests as the
+// request size limit allows.
+func (c *CoralogixSink) drainEvents(events []EventData) {
+	// batched are the events of batch.
+	var batch []json.RawMessage
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		entry, err := c.log(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(batch) > 0 && batchBytes+len(entry)+1 > coralogixMaxRequestBytes {
+			c.send(batch, batched)
+			batch, batched = nil, nil
+			batchBytes = 0
+		}
+		batch = append(batch, entry)
+		batched = append(batched, evt)
+		batchBytes += len(entry) + 1
+	}
+
+	if len(batch) > 0 {
+		c.send(batch, batched)
+	}
+}
+
+// log encodes the Coralogix log entry for an event. The text is the event
+// data as JSON, which Coralogix parses into fields.
+func (c *CoralogixSink) log(evt EventData) ([]byte, error) {
+	e := evt.Event
+	text, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	subsystem := e.InvolvedObject.Namespace
+	if subsystem == "" {
+		subsystem = c.subsystemName
+	}
+	severity := coralogixSeverityInfo
+	if e.Type == v1.EventTypeWarning {
+		severity = coralogixSeverityWarning
+	}
+
+	return json.Marshal(coralogixLog{
+		ApplicationName: c.applicationName,
+		SubsystemName:   subsystem,
+		ComputerName:    e.Source.Host,
+		Timestamp:       float64(eventTimestamp(e).UnixNano()) / float64(time.Millisecond),
+		Severity:        severity,
+		Text:            string(text),
+		Category:        e.Reason,
+		ClassName:       e.InvolvedObject.Kind,
+	})
+}
+
+// send posts a single request, dead lettering events if it fails.
+func (c *CoralogixSink) send(batch []json.RawMessage, events []EventData) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		glog.Warningf("Failed to json serialize coralogix request: %v", err)
+		return
+	}
+
+	_, err = postWithRetry(c.client, c.url, body, c.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.privateKey)
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to Coralogix: %v", len(batch), err)
+		deadLetter("coralogix", events, err)
+	}
+}
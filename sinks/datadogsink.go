@@ -0,0 +1,244 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// datadogMaxBatchEntries, datadogMaxBatchBytes and datadogMaxEntryBytes
+	// are the Logs intake limits.
+	datadogMaxBatchEntries = 1000
+	datadogMaxBatchBytes   = 5 * 1000 * 1000
+	datadogMaxEntryBytes   = 1000 * 1000
+)
+
+// DatadogSinkConfig holds the options used to construct a DatadogSink.
+type DatadogSinkConfig struct {
+	APIKey string
+
+	// Site is the Datadog site, e.g. datadoghq.com or datadoghq.eu.
+	Site string
+
+	// API selects the "logs" intake or the "events" API.
+	API string
+
+	ClusterName string
+	Service     string
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// datadogLog is a single entry of a Logs intake request.
+type datadogLog struct {
+	DDSource string `json:"ddsource"`
+	DDTags   string `json:"ddtags"`
+	Hostname string `json:"hostname,omitempty"`
+	Service  string `json:"service"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+}
+
+// datadogEvent is the body of an Events API request.
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Tags           []string `json:"tags"`
+	AlertType      string   `json:"alert_type"`
+	SourceTypeName string   `json:"source_type_name"`
+	AggregationKey string   `json:"aggregation_key"`
+	DateHappened   int64    `json:"date_happened"`
+	Host           string   `json:"host,omitempty"`
+}
+
+// DatadogSink ships events to Datadog, either as logs through the Logs
+// intake or as Datadog events through the Events API. Both are tagged with
+// the cluster, namespace, reason and involved object kind.
+type DatadogSink struct {
+	cfg     DatadogSinkConfig
+	client  *http.Client
+	eventCh channels.Channel
+}
+
+// NewDatadogSink constructs a new DatadogSink.
+func NewDatadogSink(cfg DatadogSinkConfig) (*DatadogSink, error) {
+	if cfg.API != "logs" && cfg.API != "events" {
+		return nil, fmt.Errorf("invalid datadog api %q, expected logs or events", cfg.API)
+	}
+
+	return &DatadogSink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (d *DatadogSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	d.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through d.eventCh,
+// and sending it to Datadog.
+func (d *DatadogSink) Run(stopCh <-chan bool) {
+	if d.cfg.API == "events" {
+		runBatches(d.eventCh, stopCh, d.postEvents)
+		return
+	}
+	runBatches(d.eventCh, stopCh, d.postLogs)
+}
+
+// postLogs sends an array of event data to the Logs intake, split into
+// requests that respect the intake's entry and payload limits.
+func (d *DatadogSink) postLogs(events []EventData) {
+	// batched are the events of batch.
+	var batch []json.RawMessage
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		entry, err := json.Marshal(datadogLog{
+			DDSource: "kubernetes",
+			DDTags:   strings.Join(d.tags(evt.Event), ","),
+			Hostname: evt.Event.Source.Host,
+			Service:  d.cfg.Service,
+			Status:   datadogStatus(evt.Event),
+			Message:  string(eJSONBytes),
+		})
+		if err != nil {
+			glog.Warningf("Failed to json serialize datadog log: %v", err)
+			continue
+		}
+		if len(entry) > datadogMaxEntryBytes {
+			glog.Errorf("Dropping event %s/%s: %d bytes exceeds the Datadog log entry limit", evt.Event.Namespace, evt.Event.Name, len(entry))
+			deadLetter("datadog", []EventData{evt}, fmt.Errorf("%d bytes exceeds the Datadog log entry limit", len(entry)))
+			continue
+		}
+
+		// Account for the array brackets and separating commas.
+		if len(batch) == datadogMaxBatchEntries || batchBytes+len(entry)+1 > datadogMaxBatchBytes-2 {
+			d.sendLogs(batch, batched)
+			batch, batched = nil, nil
+			batchBytes = 0
+		}
+		batch = append(batch, entry)
+		batched = append(batched, evt)
+		batchBytes += len(entry) + 1
+	}
+
+	if len(batch) > 0 {
+		d.sendLogs(batch, batched)
+	}
+}
+
+// sendLogs posts a single Logs intake request, dead lettering events if it
+// fails.
+func (d *DatadogSink) sendLogs(batch []json.RawMessage, events []EventData) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		glog.Warningf("Failed to json serialize datadog logs: %v", err)
+		return
+	}
+	body, err = gzipBytes(body)
+	if err != nil {
+		glog.Errorf("Failed to compress datadog logs: %v", err)
+		deadLetter("datadog", events, err)
+		return
+	}
+
+	url := fmt.Sprintf("https://http-intake.logs.%s/api/v2/logs", d.cfg.Site)
+	_, err = postWithRetry(d.client, url, body, d.cfg.RetryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("DD-API-KEY", d.cfg.APIKey)
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d logs to Datadog: %v", len(batch), err)
+		deadLetter("datadog", events, err)
+	}
+}
+
+// postEvents sends each event in an array to the Events API, which accepts
+// a single event per request.
+func (d *DatadogSink) postEvents(events []EventData) {
+	url := fmt.Sprintf("https://api.%s/api/v1/events", d.cfg.Site)
+	for _, evt := range events {
+		e := evt.Event
+		body, err := json.Marshal(datadogEvent{
+			Title:          fmt.Sprintf("%s %s/%s: %s", e.InvolvedObject.Kind, e.InvolvedObject.Namespace, e.InvolvedObject.Name, e.Reason),
+			Text:           e.Message,
+			Tags:           d.tags(e),
+			AlertType:      datadogStatus(e),
+			SourceTypeName: "kubernetes",
+			AggregationKey: string(e.InvolvedObject.UID),
+			DateHappened:   eventTimestamp(e).Unix(),
+			Host:           e.Source.Host,
+		})
+		if err != nil {
+			glog.Warningf("Failed to json serialize datadog event: %v", err)
+			continue
+		}
+
+		_, err = postWithRetry(d.client, url, body, d.cfg.RetryMax, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("DD-API-KEY", d.cfg.APIKey)
+		})
+		if err != nil {
+			glog.Errorf("Failed to send event to Datadog: %v", err)
+			deadLetter("datadog", []EventData{evt}, err)
+		}
+	}
+}
+
+// tags returns the Datadog tags for an event.
+func (d *DatadogSink) tags(e *v1.Event) []string {
+	return []string{
+		"source:eventrouter",
+		"cluster:" + d.cfg.ClusterName,
+		"kube_cluster_name:" + d.cfg.ClusterName,
+		"kube_namespace:" + e.InvolvedObject.Namespace,
+		"namespace:" + e.InvolvedObject.Namespace,
+		"reason:" + e.Reason,
+		"kind:" + e.InvolvedObject.Kind,
+		"event_type:" + e.Type,
+	}
+}
+
+// datadogStatus maps the event type to a Datadog log status and alert type.
+func datadogStatus(e *v1.Event) string {
+	if e.Type == v1.EventTypeWarning {
+		return "warning"
+	}
+	return "info"
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+)
+
+var (
+	deadLettersDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_dead_letters_dropped_total",
+		Help: "Total number of dead letters discarded because the dead letter buffer was full",
+	}, []string{"sink"})
+
+	registerDeadLetterMetricsOnce sync.Once
+)
+
+// deadLetters receives the events sinks give up on. It is nil unless a
+// dead letter path is configured.
+var deadLetters *DeadLetterFileSink
+
+// deadLettersStop stops the dead letter archive's Run, which closes
+// deadLettersDone once it has written the records still queued.
+var deadLettersStop, deadLettersDone chan bool
+
+// deadLetter hands events a sink permanently failed to deliver to the dead
+// letter archive, if there is one. sink is the name the sink is configured
+// by.
+func deadLetter(sink string, events []EventData, err error) {
+	if deadLetters == nil || len(events) == 0 {
+		return
+	}
+	deadLetters.record(sink, events, err)
+}
+
+// deadLetterRecord is a line of the dead letter archive. Event is the event
+// data as the sink received it, so it can be replayed.
+type deadLetterRecord struct {
+	FailedAt time.Time `json:"failed_at"`
+	Sink     string    `json:"sink"`
+	Error    string    `json:"error"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Event    EventData `json:"event"`
+}
+
+// DeadLetterFileSinkConfig holds the options used to construct a
+// DeadLetterFileSink.
+type DeadLetterFileSinkConfig struct {
+	// File configures the archive file and its rotation, as for the file
+	// sink.
+	File FileSinkConfig
+
+	ClusterName string
+}
+
+// DeadLetterFileSink archives events that sinks failed to deliver after
+// exhausting their retries, one JSON line per event with the sink, error
+// and time of the failure, so they can be inspected and replayed after an
+// outage instead of only being counted in the logs.
+type DeadLetterFileSink struct {
+	file        *FileSink
+	clusterName string
+	overflow    bool
+	recordCh    channels.Channel
+}
+
+// NewDeadLetterFileSink constructs a new DeadLetterFileSink.
+func NewDeadLetterFileSink(cfg DeadLetterFileSinkConfig) (*DeadLetterFileSink, error) {
+	file, err := NewFileSink(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterFileSink{
+		file:        file,
+		clusterName: cfg.ClusterName,
+		overflow:    cfg.File.Overflow,
+		recordCh:    newEventChannel(cfg.File.Overflow, cfg.File.BufferSize),
+	}, nil
+}
+
+// record queues the records for failed events, to be written by Run. It
+// blocks while the buffer is full, unless the buffer discards, in which
+// case the records that do not fit are counted and logged.
+func (d *DeadLetterFileSink) record(sink string, events []EventData, err error) {
+	now := time.Now().UTC()
+	for _, evt := range events {
+		// The overflowing channel discards what is written to a full buffer.
+		if d.overflow && d.recordCh.Len() >= int(d.recordCh.Cap()) {
+			deadLettersDropped.WithLabelValues(sink).Inc()
+			glog.Errorf("Dropped dead letter for event %s/%s from sink %s: dead letter buffer full", evt.Event.Namespace, evt.Event.Name, sink)
+		}
+		d.recordCh.In() <- deadLetterRecord{
+			FailedAt: now,
+			Sink:     sink,
+			Error:    err.Error(),
+			Cluster:  d.clusterName,
+			Event:    evt,
+		}
+	}
+}
+
+// Run sits in a loop, waiting for records to come in through d.recordCh,
+// and appending them to the archive. Records still queued when stopCh
+// fires are written before returning.
+func (d *DeadLetterFileSink) Run(stopCh <-chan bool) {
+loop:
+	for {
+		select {
+		case r := <-d.recordCh.Out():
+			d.write(append([]interface{}{r}, d.queued()...))
+		case <-stopCh:
+			break loop
+		}
+	}
+	if records := d.queued(); len(records) > 0 {
+		d.write(records)
+	}
+	d.file.close()
+}
+
+// queued takes the records queued in d.recordCh, so everything queued is
+// written with a single flush.
+func (d *DeadLetterFileSink) queued() []interface{} {
+	var records []interface{}
+	for d.recordCh.Len() > 0 {
+		records = append(records, <-d.recordCh.Out())
+	}
+	return records
+}
+
+// write appends records to the archive.
+func (d *DeadLetterFileSink) write(records []interface{}) {
+	lines := make([][]byte, 0, len(records))
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			glog.Warningf("Failed to json serialize dead letter: %v", err)
+			continue
+		}
+		lines = append(lines, append(b, '\n'))
+	}
+	d.file.writeLines(lines)
+}
+
+// manufactureDeadLetters starts the dead letter archive if deadLetterPath
+// is configured.
+func manufactureDeadLetters() {
+	path := viper.GetString("deadLetterPath")
+	if path == "" {
+		return
+	}
+
+	viper.SetDefault("deadLetterMaxSizeBytes", 100<<20)
+	viper.SetDefault("deadLetterRotateInterval", "24h")
+	viper.SetDefault("deadLetterCompress", true)
+	viper.SetDefault("deadLetterMaxBackups", 30)
+	viper.SetDefault("deadLetterBufferSize", 10000)
+	viper.SetDefault("deadLetterDiscardMessages", false)
+
+	d, err := NewDeadLetterFileSink(DeadLetterFileSinkConfig{
+		File: FileSinkConfig{
+			Path:           path,
+			MaxSizeBytes:   viper.GetInt64("deadLetterMaxSizeBytes"),
+			RotateInterval: viper.GetDuration("deadLetterRotateInterval"),
+			Compress:       viper.GetBool("deadLetterCompress"),
+			MaxBackups:     viper.GetInt("deadLetterMaxBackups"),
+			Overflow:       viper.GetBool("deadLetterDiscardMessages"),
+			BufferSize:     viper.GetInt("deadLetterBufferSize"),
+		},
+		ClusterName: viper.GetString("clusterName"),
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	registerDeadLetterMetricsOnce.Do(func() {
+		prometheus.MustRegister(deadLettersDropped)
+	})
+	glog.Infof("Archiving undeliverable events to %s", path)
+	deadLettersStop = make(chan bool)
+	deadLettersDone = make(chan bool)
+	go func() {
+		defer close(deadLettersDone)
+		d.Run(deadLettersStop)
+	}()
+	deadLetters = d
+}
+
+// stopDeadLetters stops the dead letter archive, once the sinks that feed
+// it have returned, and waits for it to write what is queued until timeout
+// fires. It reports whether it finished.
+func stopDeadLetters(timeout <-chan time.Time) bool {
+	if deadLetters == nil {
+		return true
+	}
+	close(deadLettersStop)
+	select {
+	case <-deadLettersDone:
+		return true
+	case <-timeout:
+		return false
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Discord message limits.
+const (
+	discordMaxEmbeds      = 10
+	discordMaxEmbedChars  = 6000
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+	discordMaxFieldValue  = 1024
+	discordWarningColor   = 0xE67E22
+	discordNormalColor    = 0x3498DB
+)
+
+// discordEmbed is a rich embed in a webhook message.
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// DiscordSink posts events to a Discord channel webhook as embeds. Events
+// arriving within FlushInterval of each other are posted together, up to
+// ten embeds per message, so a burst costs a few requests rather than one
+// per event.
+type DiscordSink struct {
+	webhookURL    string
+	username      string
+	filter        eventFilter
+	clusterName   string
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewDiscordSink constructs a new DiscordSink posting the events whose type
+// is in types and, when set, whose reason is in reasons.
+func NewDiscordSink(webhookURL string, username string, types []string, reasons []string, clusterName string, flushInterval time.Duration, retryMax int, overflow bool, bufferSize int) (*DiscordSink, error) {
+	if err := checkFlushInterval("discord", flushInterval); err != nil {
+		return nil, err
+	}
+
+	return &DiscordSink{
+		webhookURL:    webhookURL,
+		username:      username,
+		filter:        newEventFilter(types, reasons, nil),
+		clusterName:   clusterName,
+		flushInterval: flushInterval,
+		retryMax:      retryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (d *DiscordSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !d.filter.match(eNew) {
+		return
+	}
+	d.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through d.eventCh, and
+// posting what arrived every flushInterval.
+func (d *DiscordSink) Run(stopCh <-chan bool) {
+	runTimedBatches(d.eventCh, stopCh, 0, d.flushInterval, d.drainEvents)
+}
+
+// drainEvents posts an array of event data in as few messages as the embed
+// limits allow.
+func (d *DiscordSink) drainEvents(events []EventData) {
+	// batched are the events of embeds.
+	var embeds []discordEmbed
+	var batched []EventData
+	chars := 0
+	for _, evt := range events {
+		embed := d.embed(evt)
+		size := embedChars(embed)
+		if len(embeds) == discordMaxEmbeds || (len(embeds) > 0 && chars+size > discordMaxEmbedChars) {
+			d.post(embeds, batched)
+			embeds, batched = nil, nil
+			chars = 0
+		}
+		embeds = append(embeds, embed)
+		batched = append(batched, evt)
+		chars += size
+	}
+
+	if len(embeds) > 0 {
+		d.post(embeds, batched)
+	}
+}
+
+// embed formats a single event.
+func (d *DiscordSink) embed(evt EventData) discordEmbed {
+	e := evt.Event
+	color := discordNormalColor
+	if e.Type == v1.EventTypeWarning {
+		color = discordWarningColor
+	}
+
+	fields := []discordEmbedField{
+		{Name: "Namespace", Value: orDash(e.InvolvedObject.Namespace), Inline: true},
+		{Name: "Object", Value: truncateRunes(e.InvolvedObject.Kind+"/"+e.InvolvedObject.Name, discordMaxFieldValue), Inline: true},
+		{Name: "Count", Value: fmt.Sprint(e.Count), Inline: true},
+	}
+	if d.clusterName != "" {
+		fields = append([]discordEmbedField{{Name: "Cluster", Value: d.clusterName, Inline: true}}, fields...)
+	}
+
+	return discordEmbed{
+		Title:       truncateRunes(e.Type+": "+e.Reason, discordMaxTitle),
+		Description: truncateRunes(e.Message, discordMaxDescription),
+		Color:       color,
+		Timestamp:   eventTimestamp(e).UTC().Format(time.RFC3339),
+		Fields:      fields,
+	}
+}
+
+// embedChars counts the characters Discord limits across a message's
+// embeds.
+func embedChars(embed discordEmbed) int {
+	n := len([]rune(embed.Title)) + len([]rune(embed.Description))
+	for _, f := range embed.Fields {
+		n += len([]rune(f.Name)) + len([]rune(f.Value))
+	}
+	return n
+}
+
+// orDash substitutes a dash for empty values, which Discord rejects in
+// embed fields.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// post sends a single webhook message. Rate limited requests are retried
+// after the Retry-After Discord sends. The events are dead lettered if it
+// fails.
+func (d *DiscordSink) post(embeds []discordEmbed, events []EventData) {
+	body, err := json.Marshal(map[string]interface{}{
+		"username": d.username,
+		"embeds":   embeds,
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize discord message: %v", err)
+		return
+	}
+
+	_, err = postWithRetry(d.client, d.webhookURL, body, d.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	})
+	if err != nil {
+		glog.Errorf("Failed to post %d events to Discord: %v", len(embeds), err)
+		deadLetter("discord", events, err)
+	}
+}
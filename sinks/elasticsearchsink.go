@@ -0,0 +1,115 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/eapache/channels"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ElasticsearchSinkConfig holds the options used to construct an
+// ElasticsearchSink.
+type ElasticsearchSinkConfig struct {
+	URL string
+
+	// IndexPrefix and IndexDateLayout name the time-based indices; the
+	// layout uses Go time formatting, so "2006.01" gives monthly indices.
+	IndexPrefix     string
+	IndexDateLayout string
+
+	// Username/Password enable basic auth and APIKey enables API key auth.
+	Username string
+	Password string
+	APIKey   string
+
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// ElasticsearchSink indexes events into Elasticsearch through the _bulk API.
+type ElasticsearchSink struct {
+	indexer       *bulkIndexer
+	flushEvents   int
+	flushInterval time.Duration
+	eventCh       channels.Channel
+}
+
+// NewElasticsearchSink constructs a new ElasticsearchSink. Events are sent
+// once FlushEvents have been buffered or every FlushInterval.
+func NewElasticsearchSink(cfg ElasticsearchSinkConfig) (*ElasticsearchSink, error) {
+	if err := checkFlushInterval("elasticsearch", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLSCAFile, "", "", cfg.TLSInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	indexer := &bulkIndexer{
+		sink:            "elasticsearch",
+		url:             cfg.URL,
+		indexPrefix:     cfg.IndexPrefix,
+		indexDateLayout: cfg.IndexDateLayout,
+		retryMax:        cfg.RetryMax,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}
+	switch {
+	case cfg.APIKey != "":
+		indexer.authorize = func(req *http.Request, _ []byte) error {
+			req.Header.Set("Authorization", "ApiKey "+cfg.APIKey)
+			return nil
+		}
+	case cfg.Username != "":
+		indexer.authorize = func(req *http.Request, _ []byte) error {
+			req.SetBasicAuth(cfg.Username, cfg.Password)
+			return nil
+		}
+	}
+
+	return &ElasticsearchSink{
+		indexer:       indexer,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (es *ElasticsearchSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	es.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through es.eventCh,
+// and indexing it in batches.
+func (es *ElasticsearchSink) Run(stopCh <-chan bool) {
+	runTimedBatches(es.eventCh, stopCh, es.flushEvents, es.flushInterval, es.indexer.index)
+}
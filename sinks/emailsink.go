@@ -0,0 +1,358 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// emailDefaultTemplate renders a digest as an HTML table.
+const emailDefaultTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; font-size: 14px;">
+<p>{{len .Events}} Kubernetes event{{if ne (len .Events) 1}}s{{end}}{{if .Cluster}} in cluster <b>{{.Cluster}}</b>{{end}}
+{{- if gt .Dropped 0}}; {{.Dropped}} more were left out of this digest{{end}}.</p>
+<table cellpadding="4" cellspacing="0" border="1" style="border-collapse: collapse;">
+<tr><th>Last seen</th><th>Type</th><th>Reason</th><th>Object</th><th>Count</th><th>Message</th></tr>
+{{- range .Events}}
+<tr{{if eq .Event.Type "Warning"}} style="background: #fff3e0;"{{end}}>
+<td>{{.Timestamp.UTC.Format "2006-01-02 15:04:05"}}</td>
+<td>{{.Event.Type}}</td>
+<td>{{.Event.Reason}}</td>
+<td>{{.Event.InvolvedObject.Namespace}}/{{.Event.InvolvedObject.Kind}}/{{.Event.InvolvedObject.Name}}</td>
+<td>{{.Event.Count}}</td>
+<td>{{.Event.Message}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`
+
+// emailDigest is the data email templates are executed with.
+type emailDigest struct {
+	Cluster string
+	Events  []notification
+
+	// Dropped counts events left out because the digest was full.
+	Dropped int
+}
+
+// EmailSinkConfig holds the options used to construct an EmailSink.
+type EmailSinkConfig struct {
+	Host string
+	Port int
+
+	// TLSMode is "starttls", "tls" for implicit TLS (usually port 465) or
+	// "none".
+	TLSMode               string
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+
+	// Username and Password enable PLAIN authentication.
+	Username string
+	Password string
+
+	From string
+	To   []string
+
+	// Template is an html/template for the body, executed with an
+	// emailDigest; empty uses emailDefaultTemplate.
+	Template string
+
+	// Types, Reasons and Namespaces select the events that are mailed.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// Digests are sent every Interval, holding at most MaxEvents events.
+	Interval  time.Duration
+	MaxEvents int
+
+	// ImmediateReasons are mailed on their own as soon as they arrive.
+	ImmediateReasons []string
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// EmailSink mails periodic digests of selected events over SMTP.
+type EmailSink struct {
+	addr      string
+	host      string
+	tlsMode   string
+	tlsConfig *tls.Config
+	auth      smtp.Auth
+	from      string
+	to        []string
+	tmpl      *template.Template
+
+	filter      eventFilter
+	immediate   map[string]bool
+	interval    time.Duration
+	maxEvents   int
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+}
+
+// NewEmailSink constructs a new EmailSink.
+func NewEmailSink(cfg EmailSinkConfig) (*EmailSink, error) {
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("invalid email digest interval %v, expected a positive duration", cfg.Interval)
+	}
+
+	text := cfg.Template
+	if text == "" {
+		text = emailDefaultTemplate
+	}
+	tmpl, err := template.New("email").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	switch cfg.TLSMode {
+	case "starttls", "tls":
+		tlsConfig, err = newTLSConfig(cfg.TLSCAFile, "", "", cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = cfg.Host
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown smtp TLS mode %q", cfg.TLSMode)
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &EmailSink{
+		addr:        net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host:        cfg.Host,
+		tlsMode:     cfg.TLSMode,
+		tlsConfig:   tlsConfig,
+		auth:        auth,
+		from:        cfg.From,
+		to:          cfg.To,
+		tmpl:        tmpl,
+		filter:      newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		immediate:   stringSet(cfg.ImmediateReasons),
+		interval:    cfg.Interval,
+		maxEvents:   cfg.MaxEvents,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter, and those mailed immediately, to the event
+// channel, which is drained by Run.
+func (m *EmailSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !m.filter.match(eNew) && !m.immediate[eNew.Reason] {
+		return
+	}
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh. Events
+// with an immediate reason are mailed right away; the rest are collected
+// and mailed as a digest every interval. A digest keeps the first maxEvents
+// events and counts the rest.
+func (m *EmailSink) Run(stopCh <-chan bool) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	var digest []notification
+	dropped := 0
+	flush := func() {
+		if len(digest) > 0 {
+			m.sendDigest(digest, dropped)
+		}
+		digest = nil
+		dropped = 0
+	}
+
+	for {
+		select {
+		case e := <-m.eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			n := newNotification(evt, m.clusterName, 0)
+			if m.immediate[evt.Event.Reason] {
+				m.sendDigest([]notification{n}, 0)
+				continue
+			}
+			if m.maxEvents > 0 && len(digest) >= m.maxEvents {
+				dropped++
+				continue
+			}
+			digest = append(digest, n)
+		case <-ticker.C:
+			flush()
+		case <-stopCh:
+			flush()
+			return
+		}
+	}
+}
+
+// sendDigest renders and mails a digest.
+func (m *EmailSink) sendDigest(events []notification, dropped int) {
+	var body bytes.Buffer
+	if err := m.tmpl.Execute(&body, emailDigest{Cluster: m.clusterName, Events: events, Dropped: dropped}); err != nil {
+		glog.Warningf("Failed to render email template: %v", err)
+		return
+	}
+
+	subject := fmt.Sprintf("%d Kubernetes events", len(events)+dropped)
+	if len(events) == 1 && dropped == 0 {
+		e := events[0].Event
+		subject = fmt.Sprintf("%s %s: %s/%s", e.Type, e.Reason, e.InvolvedObject.Namespace, e.InvolvedObject.Name)
+	}
+	if m.clusterName != "" {
+		subject = "[" + m.clusterName + "] " + subject
+	}
+
+	msg, err := m.message(subject, body.Bytes())
+	if err != nil {
+		glog.Warningf("Failed to encode email: %v", err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.send(msg)
+		if err == nil {
+			return
+		}
+		if attempt >= m.retryMax {
+			glog.Errorf("Failed to mail %d events: %v", len(events), err)
+			failed := make([]EventData, 0, len(events))
+			for _, n := range events {
+				failed = append(failed, EventData{Verb: n.Verb, Event: n.Event})
+			}
+			deadLetter("email", failed, err)
+			return
+		}
+		glog.Warningf("Failed to mail events, retrying: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// message builds an HTML email with a quoted-printable body.
+func (m *EmailSink) message(subject string, html []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write(html); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// emailSendTimeout bounds a whole SMTP exchange, so a server that stops
+// responding fails the attempt instead of stalling the sink.
+const emailSendTimeout = 2 * time.Minute
+
+// send delivers msg over a new SMTP connection.
+func (m *EmailSink) send(msg []byte) error {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if m.tlsMode == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", m.addr, m.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", m.addr)
+	}
+	if err != nil {
+		return err
+	}
+	// The deadline carries over to the TLS connection of STARTTLS.
+	if err := conn.SetDeadline(time.Now().Add(emailSendTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.tlsMode == "starttls" {
+		if err := c.StartTLS(m.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
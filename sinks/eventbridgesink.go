@@ -0,0 +1,185 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// eventBridgeMaxBatchEntries and eventBridgeMaxBatchBytes are the
+	// PutEvents request limits.
+	eventBridgeMaxBatchEntries = 10
+	eventBridgeMaxBatchBytes   = 256 * 1024
+)
+
+// EventBridgeSink sends events to an Amazon EventBridge event bus. Each
+// Kubernetes event becomes a PutEvents entry whose detail is the EventData
+// JSON, so rules can match on detail.event.reason, detail.verb and so on.
+type EventBridgeSink struct {
+	client     *eventbridge.Client
+	eventBus   string
+	source     string
+	detailType string
+	retryMax   int
+	eventCh    channels.Channel
+}
+
+// NewEventBridgeSink constructs a new EventBridgeSink. eventBus may be a name
+// or ARN; an empty value targets the account's default bus.
+func NewEventBridgeSink(eventBus string, source string, detailType string, region string, retryMax int, overflow bool, bufferSize int) (*EventBridgeSink, error) {
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventBridgeSink{
+		client:     eventbridge.NewFromConfig(cfg),
+		eventBus:   eventBus,
+		source:     source,
+		detailType: detailType,
+		retryMax:   retryMax,
+		eventCh:    newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *EventBridgeSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and putting it on the event bus.
+func (s *EventBridgeSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents maps an array of event data onto PutEvents entries and sends
+// them in batches that respect the EventBridge request limits.
+func (s *EventBridgeSink) drainEvents(events []EventData) {
+	// batched are the events of entries.
+	var entries []types.PutEventsRequestEntry
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		entry := types.PutEventsRequestEntry{
+			Source:     aws.String(s.source),
+			DetailType: aws.String(s.detailType),
+			Detail:     aws.String(string(eJSONBytes)),
+			Time:       aws.Time(eventTimestamp(evt.Event)),
+		}
+		if s.eventBus != "" {
+			entry.EventBusName = aws.String(s.eventBus)
+		}
+
+		size := eventBridgeEntrySize(entry)
+		if size > eventBridgeMaxBatchBytes {
+			glog.Errorf("Dropping event %s/%s: %d bytes exceeds the EventBridge entry limit", evt.Event.Namespace, evt.Event.Name, size)
+			deadLetter("eventbridge", []EventData{evt}, fmt.Errorf("%d bytes exceeds the EventBridge entry limit", size))
+			continue
+		}
+		if len(entries) == eventBridgeMaxBatchEntries || batchBytes+size > eventBridgeMaxBatchBytes {
+			s.putEvents(entries, batched)
+			entries, batched = nil, nil
+			batchBytes = 0
+		}
+		entries = append(entries, entry)
+		batched = append(batched, evt)
+		batchBytes += size
+	}
+
+	if len(entries) > 0 {
+		s.putEvents(entries, batched)
+	}
+}
+
+// putEvents sends a single PutEvents request. Entries that fail are retried
+// with backoff up to retryMax times, since PutEvents reports partial failure
+// per entry rather than failing the whole request. The events of entries
+// that still fail are dead lettered.
+func (s *EventBridgeSink) putEvents(entries []types.PutEventsRequestEntry, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, 100*time.Millisecond, 5*time.Second))
+		}
+
+		out, err := s.client.PutEvents(context.TODO(), &eventbridge.PutEventsInput{Entries: entries})
+		if err != nil {
+			if attempt >= s.retryMax {
+				glog.Errorf("Failed to put %d events to EventBridge: %v", len(entries), err)
+				deadLetter("eventbridge", events, err)
+				return
+			}
+			glog.Warningf("Failed to put events to EventBridge, retrying: %v", err)
+			continue
+		}
+		if out.FailedEntryCount == 0 {
+			return
+		}
+
+		var failed []types.PutEventsRequestEntry
+		var failedEvents []EventData
+		for i, result := range out.Entries {
+			if result.ErrorCode != nil {
+				if attempt >= s.retryMax {
+					glog.Errorf("Failed to put event to EventBridge: %s: %s", aws.ToString(result.ErrorCode), aws.ToString(result.ErrorMessage))
+					deadLetter("eventbridge", []EventData{events[i]},
+						fmt.Errorf("%s: %s", aws.ToString(result.ErrorCode), aws.ToString(result.ErrorMessage)))
+				}
+				failed = append(failed, entries[i])
+				failedEvents = append(failedEvents, events[i])
+			}
+		}
+		if attempt >= s.retryMax || len(failed) == 0 {
+			return
+		}
+		entries, events = failed, failedEvents
+	}
+}
+
+// eventBridgeEntrySize calculates the size of an entry the same way
+// EventBridge does when enforcing the request size limit.
+func eventBridgeEntrySize(entry types.PutEventsRequestEntry) int {
+	size := 0
+	if entry.Time != nil {
+		size += 14
+	}
+	size += len(aws.ToString(entry.Source))
+	size += len(aws.ToString(entry.DetailType))
+	size += len(aws.ToString(entry.Detail))
+	for _, r := range entry.Resources {
+		size += len(r)
+	}
+	return size
+}
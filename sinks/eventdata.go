@@ -17,6 +17,8 @@ limitations under the License.
 package sinks
 
 import (
+	"time"
+
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -58,3 +60,43 @@ func NewEventData(eNew *v1.Event, eOld *v1.Event) EventData {
 
 	return eData
 }
+
+// eventTimestamp returns when an event last occurred, preferring
+// LastTimestamp, then EventTime, FirstTimestamp and CreationTimestamp.
+func eventTimestamp(e *v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+// flattenEventData returns the commonly queried fields of an event as a flat
+// map, for sinks whose backends work best with columns instead of nested
+// JSON documents.
+func flattenEventData(evt EventData) map[string]interface{} {
+	e := evt.Event
+	return map[string]interface{}{
+		"verb":                      evt.Verb,
+		"namespace":                 e.Namespace,
+		"name":                      e.Name,
+		"uid":                       string(e.UID),
+		"type":                      e.Type,
+		"reason":                    e.Reason,
+		"message":                   e.Message,
+		"count":                     e.Count,
+		"first_timestamp":           e.FirstTimestamp.Time,
+		"last_timestamp":            e.LastTimestamp.Time,
+		"source_component":          e.Source.Component,
+		"source_host":               e.Source.Host,
+		"involved_object_kind":      e.InvolvedObject.Kind,
+		"involved_object_namespace": e.InvolvedObject.Namespace,
+		"involved_object_name":      e.InvolvedObject.Name,
+		"involved_object_uid":       string(e.InvolvedObject.UID),
+	}
+}
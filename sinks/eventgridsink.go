@@ -0,0 +1,282 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// eventGridMaxRequestBytes is the publish request size limit.
+	eventGridMaxRequestBytes = 1024 * 1024
+
+	// eventGridScope is the AAD scope of Event Grid data plane tokens.
+	eventGridScope = "https://eventgrid.azure.net/.default"
+)
+
+// eventGridEvent is an event in the Event Grid schema.
+type eventGridEvent struct {
+	ID          string    `json:"id"`
+	Topic       string    `json:"topic,omitempty"`
+	Subject     string    `json:"subject"`
+	EventType   string    `json:"eventType"`
+	EventTime   string    `json:"eventTime"`
+	DataVersion string    `json:"dataVersion"`
+	Data        EventData `json:"data"`
+}
+
+// EventGridSinkConfig holds the options used to construct an EventGridSink.
+type EventGridSinkConfig struct {
+	// Endpoint is the topic or domain endpoint, e.g.
+	// https://<name>.<region>-1.eventgrid.azure.net/api/events.
+	Endpoint string
+
+	// Schema is "eventgrid" or "cloudevents", and has to match the input
+	// schema the topic or domain was created with.
+	Schema string
+
+	// Key is an access key, sent as aeg-sas-key. Without one the default
+	// Azure credential chain is used, which needs the EventGrid Data Sender
+	// role.
+	Key string
+
+	// Domain enables domain mode, where every event is published to a
+	// domain topic: the one NamespaceTopics maps its namespace to, else the
+	// namespace itself, or DefaultTopic for events without a namespace.
+	Domain          bool
+	NamespaceTopics map[string]string
+	DefaultTopic    string
+
+	// EventType is the Event Grid eventType or CloudEvents type.
+	EventType string
+
+	// TLS configures a custom CA bundle and minimum TLS version.
+	TLS AzureTLSConfig
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// EventGridSink publishes events to an Azure Event Grid topic or domain.
+// Events are published in batches, in the Event Grid or CloudEvents schema.
+type EventGridSink struct {
+	endpoint        string
+	cloudEvents     bool
+	key             string
+	cred            azcore.TokenCredential
+	domain          bool
+	namespaceTopics map[string]string
+	defaultTopic    string
+	eventType       string
+	clusterName     string
+	retryMax        int
+	client          *http.Client
+	eventCh         channels.Channel
+}
+
+// NewEventGridSink constructs a new EventGridSink.
+func NewEventGridSink(cfg EventGridSinkConfig) (*EventGridSink, error) {
+	if cfg.Schema != "eventgrid" && cfg.Schema != "cloudevents" {
+		return nil, fmt.Errorf("unknown event grid schema %q, expected eventgrid or cloudevents", cfg.Schema)
+	}
+	tlsConfig, err := newAzureTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if tlsConfig != nil {
+		client = newAzureHTTPClient(tlsConfig, nil)
+		client.Timeout = 30 * time.Second
+	}
+
+	g := &EventGridSink{
+		endpoint:        cfg.Endpoint,
+		cloudEvents:     cfg.Schema == "cloudevents",
+		key:             cfg.Key,
+		domain:          cfg.Domain,
+		namespaceTopics: lowerKeys(cfg.NamespaceTopics),
+		defaultTopic:    cfg.DefaultTopic,
+		eventType:       cfg.EventType,
+		clusterName:     cfg.ClusterName,
+		retryMax:        cfg.RetryMax,
+		client:          client,
+		eventCh:         newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+	if cfg.Key == "" {
+		cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: azureClientOptions(tlsConfig),
+		})
+		if err != nil {
+			return nil, err
+		}
+		g.cred = cred
+	}
+	return g, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (g *EventGridSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	g.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through g.eventCh,
+// and publishing it to Event Grid.
+func (g *EventGridSink) Run(stopCh <-chan bool) {
+	runBatches(g.eventCh, stopCh, g.drainEvents)
+}
+
+// drainEvents publishes an array of event data in as few requests as the
+// request size limit allows.
+func (g *EventGridSink) drainEvents(events []EventData) {
+	// batched are the events of batch.
+	var batch []json.RawMessage
+	var batched []EventData
+	batchBytes := 2
+	for _, evt := range events {
+		entry, err := json.Marshal(g.newEvent(evt))
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(entry)+2 > eventGridMaxRequestBytes {
+			glog.Errorf("Dropping event %s/%s: %d bytes exceeds the Event Grid request limit", evt.Event.Namespace, evt.Event.Name, len(entry))
+			deadLetter("eventgrid", []EventData{evt}, fmt.Errorf("%d bytes exceeds the Event Grid request limit", len(entry)))
+			continue
+		}
+
+		if len(batch) > 0 && batchBytes+len(entry)+1 > eventGridMaxRequestBytes {
+			g.publish(batch, batched)
+			batch, batched = nil, nil
+			batchBytes = 2
+		}
+		batch = append(batch, entry)
+		batched = append(batched, evt)
+		batchBytes += len(entry) + 1
+	}
+
+	if len(batch) > 0 {
+		g.publish(batch, batched)
+	}
+}
+
+// newEvent wraps event data in the configured schema. In domain mode the
+// domain topic is the topic field, or the source for CloudEvents.
+func (g *EventGridSink) newEvent(evt EventData) interface{} {
+	e := evt.Event
+	id := string(e.UID) + "." + e.ResourceVersion
+	ts := eventTimestamp(e).UTC().Format(time.RFC3339Nano)
+
+	if g.cloudEvents {
+		ce := cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              id,
+			Source:          cloudEventSource(g.clusterName, e),
+			Type:            g.eventType,
+			Subject:         e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+			Time:            ts,
+			DataContentType: "application/json",
+			Reason:          e.Reason,
+			EventType:       e.Type,
+			Data:            evt,
+		}
+		if g.domain {
+			ce.Source = g.topic(e)
+		}
+		return ce
+	}
+
+	subject := e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name
+	if ns := e.InvolvedObject.Namespace; ns != "" {
+		subject = "namespaces/" + ns + "/" + subject
+	}
+	ege := eventGridEvent{
+		ID:          id,
+		Subject:     subject,
+		EventType:   g.eventType,
+		EventTime:   ts,
+		DataVersion: "1.0",
+		Data:        evt,
+	}
+	if g.domain {
+		ege.Topic = g.topic(e)
+	}
+	return ege
+}
+
+// topic returns the domain topic for an event.
+func (g *EventGridSink) topic(e *v1.Event) string {
+	ns := e.InvolvedObject.Namespace
+	if topic, ok := g.namespaceTopics[ns]; ok {
+		return topic
+	}
+	if ns == "" {
+		return g.defaultTopic
+	}
+	return ns
+}
+
+// publish sends a single request, dead lettering events if it fails.
+func (g *EventGridSink) publish(batch []json.RawMessage, events []EventData) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		glog.Warningf("Failed to json serialize event grid request: %v", err)
+		return
+	}
+
+	var token string
+	if g.cred != nil {
+		t, err := g.cred.GetToken(context.TODO(), policy.TokenRequestOptions{Scopes: []string{eventGridScope}})
+		if err != nil {
+			glog.Errorf("Failed to get Event Grid token: %v", err)
+			deadLetter("eventgrid", events, err)
+			return
+		}
+		token = t.Token
+	}
+
+	_, err = postWithRetry(g.client, g.endpoint, body, g.retryMax, func(req *http.Request) {
+		if g.cloudEvents {
+			req.Header.Set("Content-Type", "application/cloudevents-batch+json; charset=utf-8")
+		} else {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if g.key != "" {
+			req.Header.Set("aeg-sas-key", g.key)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to publish %d events to Event Grid: %v", len(batch), err)
+		deadLetter("eventgrid", events, err)
+	}
+}
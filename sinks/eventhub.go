@@ -4,25 +4,397 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azeventhubs/v2"
+	"github.com/coder/websocket"
 	"github.com/eapache/channels"
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EventHubSink sends events to an Azure Event Hub.
+var (
+	eventHubEventsEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_events_enqueued_total",
+		Help: "Total number of events buffered for an event hub",
+	}, []string{"eventhub"})
+	eventHubEventsOverflowed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_events_overflowed_total",
+		Help: "Total number of events discarded because the event hub sink's buffer was full",
+	}, []string{"eventhub"})
+	eventHubEventsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_events_failed_total",
+		Help: "Total number of events dropped after failing to be delivered to an event hub",
+	}, []string{"eventhub"})
+	eventHubBatchesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_batches_sent_total",
+		Help: "Total number of batches sent to an event hub",
+	}, []string{"eventhub"})
+	eventHubSendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_send_errors_total",
+		Help: "Total number of failed attempts to send a batch to an event hub, including retried ones",
+	}, []string{"eventhub"})
+	eventHubBatchEvents = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "heptio_eventrouter_eventhub_batch_events",
+		Help:    "Number of events in the batches sent to an event hub",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"eventhub"})
+	eventHubBatchBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "heptio_eventrouter_eventhub_batch_bytes",
+		Help:    "Size in bytes of the batches sent to an event hub",
+		Buckets: prometheus.ExponentialBuckets(1024, 2, 11),
+	}, []string{"eventhub"})
+	eventHubSendDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "heptio_eventrouter_eventhub_send_duration_seconds",
+		Help:    "Time taken by attempts to send a batch to an event hub",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"eventhub"})
+	eventHubEventsSpooled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_events_spooled_total",
+		Help: "Total number of events spooled to disk for an event hub during outages",
+	}, []string{"eventhub"})
+	eventHubThrottledSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_throttled_seconds_total",
+		Help: "Total time batches for an event hub waited for the sink's rate limits",
+	}, []string{"eventhub"})
+	eventHubReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "heptio_eventrouter_eventhub_reconnects_total",
+		Help: "Total number of times an event hub producer client was recreated after persistent failures",
+	}, []string{"eventhub"})
+
+	registerEventHubMetricsOnce sync.Once
+)
+
+// registerEventHubMetrics registers the event hub sink's metrics with the
+// default Prometheus registry, once however many sinks are created.
+func registerEventHubMetrics() {
+	registerEventHubMetricsOnce.Do(func() {
+		prometheus.MustRegister(
+			eventHubEventsEnqueued,
+			eventHubEventsOverflowed,
+			eventHubEventsFailed,
+			eventHubBatchesSent,
+			eventHubSendErrors,
+			eventHubBatchEvents,
+			eventHubBatchBytes,
+			eventHubSendDuration,
+			eventHubEventsSpooled,
+			eventHubThrottledSeconds,
+			eventHubReconnects,
+		)
+	})
+}
+
+// EventHubSinkConfig holds the options used to construct an EventHubSink.
+type EventHubSinkConfig struct {
+	// Namespace is the fully qualified namespace, e.g.
+	// eventrouter-ns.servicebus.windows.net, and Name the event hub in it.
+	Namespace string
+	Name      string
+
+	// Auth is "connectionstring" to authenticate with ConnectionString, a
+	// shared access policy's connection string, "managedidentity" or
+	// "workloadidentity" for that identity only, or "default" for the
+	// default Azure credential chain, which tries workload identity and
+	// managed identity among others. When empty, ConnectionString is used
+	// if set.
+	Auth string
+
+	// ConnectionString may include the event hub as EntityPath, in which
+	// case Name must be empty.
+	ConnectionString string
+
+	// ClientID selects the user-assigned managed identity, or the
+	// application of a workload identity, so nodes or pods with several
+	// identities use the right one. TenantID and TokenFilePath override
+	// the AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE variables set by
+	// the workload identity webhook.
+	ClientID      string
+	TenantID      string
+	TokenFilePath string
+
+	// WebSockets sends the producer's AMQP connection as WebSockets over
+	// port 443 instead of on ports 5671 and 5672, for clusters whose
+	// egress only allows HTTPS. The WebSocket honours HTTPS_PROXY.
+	WebSockets bool
+
+	// ProxyURL, e.g. http://proxy.corp:3128, sends the producer's AMQP
+	// connection as WebSockets over port 443 through an HTTP proxy, along
+	// with the requests for Microsoft Entra tokens and to the schema
+	// registry. Without it, those requests honour HTTPS_PROXY.
+	ProxyURL string
+
+	// TLS configures a custom CA bundle and minimum TLS version for the
+	// producer's connection and the HTTP requests.
+	TLS AzureTLSConfig
+
+	// Transport, when set, sends the HTTP requests instead of a client for
+	// ProxyURL and TLS, and NewWebSocketConn opens the producer's WebSocket
+	// connections instead of dialing through it, for proxies that need
+	// more than a URL, e.g. authentication.
+	Transport        policy.Transporter
+	NewWebSocketConn func(ctx context.Context, params azeventhubs.WebSocketConnParams) (net.Conn, error)
+
+	// ApplicationID is added to the user agent of the producer's and the
+	// HTTP clients' requests, to identify the deployment in Azure logs.
+	ApplicationID string
+
+	// ClientMaxRetries, ClientRetryDelay and ClientMaxRetryDelay are the
+	// producer client's retry options, for operations on the AMQP
+	// connection within every attempt of RetryMax. Zero values keep the
+	// SDK's defaults, and ClientMaxRetries of -1 disables its retries.
+	ClientMaxRetries    int32
+	ClientRetryDelay    time.Duration
+	ClientMaxRetryDelay time.Duration
+
+	// Routes send the events of matching namespaces to other event hubs in
+	// the same namespace, with the first matching route winning; the other
+	// events go to Name. Each event hub gets its own producer client. With
+	// connection string auth, the connection string must not include an
+	// EntityPath.
+	Routes []EventHubRoute
+
+	// Codec is "json", or "avro" to encode events with avroEventSchema,
+	// registered in the Azure Schema Registry configured by SchemaRegistry.
+	// Avro events only carry the cluster ID as a property.
+	Codec          string
+	SchemaRegistry AzureSchemaRegistryConfig
+
+	// ClusterID identifies the cluster in every event, both in the body and
+	// as the ClusterIDProperty application property, and is the partition
+	// key when partitioning by cluster.
+	ClusterID         string
+	ClusterIDProperty string
+
+	// Properties are static application properties added to every event.
+	Properties map[string]string
+
+	// EnrichProperties, when set, is called with the properties of each
+	// event before it is added to a batch, and may add to or override them,
+	// e.g. with a severity or the owning team's label.
+	EnrichProperties func(evt EventData, properties map[string]any)
+
+	// PartitionKeyBy selects the partition key: "cluster" (the default)
+	// sends all events to one partition, "namespace" or "uid" (the involved
+	// object UID) spread them while keeping the events of a namespace or
+	// object in order, and "roundrobin" spreads them without ordering.
+	PartitionKeyBy string
+
+	// PartitionID, when set, sends all events to that partition of every
+	// event hub, e.g. for consumers pinned to a partition, instead of
+	// partitioning them by PartitionKeyBy.
+	PartitionID string
+
+	// FlushInterval, when set, sends pending events every interval instead
+	// of as soon as the channel is empty. MaxBatchEvents caps the events per
+	// batch and flushes once that many are pending, and MaxBatchBytes caps
+	// the size of a batch below the event hub's limit; zero leaves either
+	// unlimited.
+	FlushInterval  time.Duration
+	MaxBatchEvents int
+	MaxBatchBytes  uint64
+
+	// OversizedPolicy decides what happens to an event too large for a batch
+	// on its own: "truncate" its message, "deadletter" it or "drop" it.
+	OversizedPolicy string
+
+	// RetryMax is how many times creating or sending a batch is retried,
+	// with exponential backoff, before its events are dropped.
+	RetryMax int
+
+	// MaxEventsPerSecond and MaxMBPerSecond, when set, rate limit the
+	// events and megabytes sent to all event hubs, allowing a burst of a
+	// second's worth. Batches wait for the limits instead of being
+	// dropped, so a burst of events doesn't get a namespace with few
+	// throughput units throttled (ServerBusy); a throughput unit allows
+	// 1000 events or 1 MB per second.
+	MaxEventsPerSecond float64
+	MaxMBPerSecond     float64
+
+	// OperationTimeout bounds every attempt to create or send a batch, so
+	// a hung AMQP link fails the attempt instead of blocking the sink; zero
+	// leaves attempts unbounded.
+	OperationTimeout time.Duration
+
+	// SpoolDir, when set, is a directory events are spooled to while they
+	// cannot be sent or the buffer is full, up to SpoolMaxBytes, instead of
+	// being dropped. Spooled events are replayed in order once sends
+	// succeed again, including after a restart, and delivered at least
+	// once.
+	SpoolDir      string
+	SpoolMaxBytes int64
+
+	// HealthCheckInterval, when set, probes every event hub's properties
+	// each interval. After ReconnectAfter consecutive failed probes or
+	// sends to an event hub, its producer client is recreated, and the
+	// sink reports itself unhealthy while the last of them failed.
+	HealthCheckInterval time.Duration
+	ReconnectAfter      int
+
+	// DrainTimeout bounds how long Run keeps sending buffered events once
+	// it is stopped, before aborting the sends in flight and closing the
+	// producer client.
+	DrainTimeout time.Duration
+
+	Overflow   bool
+	BufferSize int
+
+	// newProducer creates the producer client for an event hub, defaulting
+	// to newEventHubProducerClient, so tests can substitute a fake.
+	newProducer func(cfg EventHubSinkConfig) (eventHubProducer, error)
+}
+
+const (
+	// eventHubSpoolReplayInterval is how often spooled events are replayed
+	// when no new events trigger it.
+	eventHubSpoolReplayInterval = 10 * time.Second
+
+	// eventHubSpoolReplayEvents is how many spooled events are replayed at
+	// a time without a maximum batch size in events.
+	eventHubSpoolReplayEvents = 500
+)
+
+// eventHubRetryBackoff and eventHubMaxRetryBackoff are the base and the cap
+// of the backoff between attempts to create or send a batch.
+var (
+	eventHubRetryBackoff    = time.Second
+	eventHubMaxRetryBackoff = 30 * time.Second
+)
+
+// EventHubRoute sends the events of the namespaces matching any of the
+// Namespaces patterns, e.g. team-a-*, to the event hub Name.
+type EventHubRoute struct {
+	Namespaces []string `mapstructure:"namespaces"`
+	Name       string   `mapstructure:"name"`
+}
+
+// eventHubProducer is the part of *azeventhubs.ProducerClient the sink
+// uses, so a fake producer can stand in for it in tests.
+type eventHubProducer interface {
+	NewEventDataBatch(ctx context.Context, options *azeventhubs.EventDataBatchOptions) (eventHubBatch, error)
+	SendEventDataBatch(ctx context.Context, batch eventHubBatch, options *azeventhubs.SendEventDataBatchOptions) error
+	GetEventHubProperties(ctx context.Context, options *azeventhubs.GetEventHubPropertiesOptions) (azeventhubs.EventHubProperties, error)
+	Close(ctx context.Context) error
+}
+
+// eventHubBatch is the part of *azeventhubs.EventDataBatch the sink uses.
+type eventHubBatch interface {
+	AddEventData(ed *azeventhubs.EventData, options *azeventhubs.AddEventDataOptions) error
+	NumEvents() int32
+	NumBytes() uint64
+}
+
+// eventHubProducerClient adapts *azeventhubs.ProducerClient to
+// eventHubProducer.
+type eventHubProducerClient struct {
+	*azeventhubs.ProducerClient
+}
+
+func (c eventHubProducerClient) NewEventDataBatch(ctx context.Context, options *azeventhubs.EventDataBatchOptions) (eventHubBatch, error) {
+	batch, err := c.ProducerClient.NewEventDataBatch(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (c eventHubProducerClient) SendEventDataBatch(ctx context.Context, batch eventHubBatch, options *azeventhubs.SendEventDataBatchOptions) error {
+	return c.ProducerClient.SendEventDataBatch(ctx, batch.(*azeventhubs.EventDataBatch), options)
+}
+
+// eventHubTarget is an event hub events are sent to, with the name used
+// in metrics.
+type eventHubTarget struct {
+	name           string
+	producerClient eventHubProducer
+
+	// newProducerClient recreates the producer client, and failures counts
+	// the consecutive failed probes and sends since it was created. Both
+	// are only used by Run.
+	newProducerClient func() (eventHubProducer, error)
+	failures          int
+
+	// err is why the last probe or send failed, or nil if it succeeded.
+	mu  sync.Mutex
+	err error
+}
+
+// newEventHubTarget creates the producer client for the event hub of cfg.
+func newEventHubTarget(name string, cfg EventHubSinkConfig) (*eventHubTarget, error) {
+	newProducer := cfg.newProducer
+	if newProducer == nil {
+		newProducer = newEventHubProducerClient
+	}
+	client, err := newProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &eventHubTarget{
+		name:           name,
+		producerClient: client,
+		newProducerClient: func() (eventHubProducer, error) {
+			return newProducer(cfg)
+		},
+	}, nil
+}
+
+// setErr records the result of the last probe or send.
+func (t *eventHubTarget) setErr(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
+
+// lastErr returns the error of the last probe or send.
+func (t *eventHubTarget) lastErr() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// EventHubSink sends events to an Azure Event Hub, or to one event hub per
+// group of namespaces.
 type EventHubSink struct {
-	producerClient *azeventhubs.ProducerClient
-	eventCh        channels.Channel
+	target            *eventHubTarget
+	routes            []eventHubRoute
+	clusterID         string
+	clusterIDProperty string
+	properties        map[string]string
+	enrichProperties  func(EventData, map[string]any)
+	partitionKeyBy    string
+	partitionID       string
+	flushInterval     time.Duration
+	maxBatchEvents    int
+	maxBatchBytes     uint64
+	oversizedPolicy   string
+	avro              *azureAvroEncoder
+	spool             *diskSpool
+	eventsLimiter     *rate.Limiter
+	bytesLimiter      *rate.Limiter
+	retryMax          int
+	healthInterval    time.Duration
+	reconnectAfter    int
+	operationTimeout  time.Duration
+	drainTimeout      time.Duration
+	overflow          bool
+	eventCh           channels.Channel
 }
 
-// NewEventHubSink constructs a new EventHubSink given a event hub connection string
-// and buffering options.
+// NewEventHubSink constructs a new EventHubSink given an event hub namespace
+// and name or a connection string, and buffering options.
 //
 // ```
 // export EVENTHUB_RESOURCE_GROUP=eventrouter
@@ -40,161 +412,1011 @@ type EventHubSink struct {
 // cat yaml/eventrouter-azure.yaml | envsubst | kubectl apply -f
 // ```
 //
-// connString expects the Azure Event Hub connection string format:
+// ConnectionString expects the Azure Event Hub connection string format:
 //
 //	`Endpoint=sb://YOUR_ENDPOINT.servicebus.windows.net/;SharedAccessKeyName=YOUR_ACCESS_KEY_NAME;SharedAccessKey=YOUR_ACCESS_KEY;EntityPath=YOUR_EVENT_HUB_NAME`
-func NewEventHubSink(eventHubNamespace string, eventHubName string, overflow bool, bufferSize int) (*EventHubSink, error) {
-	defaultAzureCred, err := azidentity.NewDefaultAzureCredential(nil)
+func NewEventHubSink(cfg EventHubSinkConfig) (*EventHubSink, error) {
+	if cfg.ClusterID == "" {
+		return nil, errors.New("event hub sink requires a cluster ID")
+	}
+	if cfg.ClusterIDProperty == "" {
+		return nil, errors.New("event hub sink requires a cluster ID property name")
+	}
+
+	switch cfg.PartitionKeyBy {
+	case "", "cluster", "namespace", "uid", "roundrobin":
+	default:
+		return nil, fmt.Errorf("invalid event hub partition key selection %q, expected cluster, namespace, uid or roundrobin", cfg.PartitionKeyBy)
+	}
+
+	switch cfg.OversizedPolicy {
+	case "", "truncate", "deadletter", "drop":
+	default:
+		return nil, fmt.Errorf("invalid event hub oversized policy %q, expected truncate, deadletter or drop", cfg.OversizedPolicy)
+	}
+
+	var avro *azureAvroEncoder
+	switch cfg.Codec {
+	case "", "json":
+	case "avro":
+		// The registry needs a Microsoft Entra credential, even when the
+		// event hub is sent to with a connection string.
+		auth := eventHubAuth(cfg)
+		if auth == "connectionstring" {
+			auth = "default"
+		}
+		cred, err := newEventHubCredential(auth, cfg)
+		if err != nil {
+			return nil, err
+		}
+		registry := cfg.SchemaRegistry
+		if registry.Group == "" {
+			return nil, errors.New("event hub avro codec requires a schema group")
+		}
+		if registry.Endpoint == "" {
+			registry.Endpoint = eventHubNamespace(cfg)
+		}
+		clientOpts, err := eventHubClientOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if avro, err = newAzureAvroEncoder(registry, cred, &clientOpts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid event hub codec %q, expected json or avro", cfg.Codec)
+	}
+
+	var spool *diskSpool
+	if cfg.SpoolDir != "" {
+		if cfg.SpoolMaxBytes <= 0 {
+			return nil, errors.New("event hub spool requires a maximum size")
+		}
+		var err error
+		if spool, err = newDiskSpool(cfg.SpoolDir, cfg.SpoolMaxBytes); err != nil {
+			return nil, fmt.Errorf("failed to open event hub spool: %v", err)
+		}
+	}
 
+	target, err := newEventHubTarget(eventHubLabel(cfg), cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	producerClient, err := azeventhubs.NewProducerClient(eventHubNamespace, eventHubName, defaultAzureCred, nil)
+	// Routes to the same event hub share a producer client.
+	targets := map[string]*eventHubTarget{target.name: target}
+	var routes []eventHubRoute
+	for _, r := range cfg.Routes {
+		if r.Name == "" || len(r.Namespaces) == 0 {
+			closeEventHubTargets(context.Background(), targets)
+			return nil, errors.New("event hub routes require a name and namespaces")
+		}
+		for _, pattern := range r.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				closeEventHubTargets(context.Background(), targets)
+				return nil, fmt.Errorf("invalid event hub route namespace pattern %q: %v", pattern, err)
+			}
+		}
+		t, ok := targets[r.Name]
+		if !ok {
+			routeCfg := cfg
+			routeCfg.Name = r.Name
+			if t, err = newEventHubTarget(r.Name, routeCfg); err != nil {
+				closeEventHubTargets(context.Background(), targets)
+				return nil, fmt.Errorf("failed to create producer for event hub %s: %v", r.Name, err)
+			}
+			targets[r.Name] = t
+		}
+		routes = append(routes, eventHubRoute{namespaces: r.Namespaces, target: t})
+	}
+
+	return &EventHubSink{
+		target:            target,
+		routes:            routes,
+		clusterID:         cfg.ClusterID,
+		clusterIDProperty: cfg.ClusterIDProperty,
+		properties:        cfg.Properties,
+		enrichProperties:  cfg.EnrichProperties,
+		partitionKeyBy:    cfg.PartitionKeyBy,
+		partitionID:       cfg.PartitionID,
+		flushInterval:     cfg.FlushInterval,
+		maxBatchEvents:    cfg.MaxBatchEvents,
+		maxBatchBytes:     cfg.MaxBatchBytes,
+		oversizedPolicy:   cfg.OversizedPolicy,
+		avro:              avro,
+		spool:             spool,
+		eventsLimiter:     newEventHubLimiter(cfg.MaxEventsPerSecond),
+		bytesLimiter:      newEventHubLimiter(cfg.MaxMBPerSecond * 1e6),
+		retryMax:          cfg.RetryMax,
+		healthInterval:    cfg.HealthCheckInterval,
+		reconnectAfter:    cfg.ReconnectAfter,
+		operationTimeout:  cfg.OperationTimeout,
+		drainTimeout:      cfg.DrainTimeout,
+		overflow:          cfg.Overflow,
+		eventCh:           newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// newEventHubLimiter returns a limiter allowing perSecond tokens a second,
+// with a burst of a second's worth, or nil if perSecond is not positive.
+func newEventHubLimiter(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), max(int(perSecond), 1))
+}
 
+// eventHubRoute is an EventHubRoute with its producer.
+type eventHubRoute struct {
+	namespaces []string
+	target     *eventHubTarget
+}
+
+// closeEventHubTargets closes the producer clients of targets.
+func closeEventHubTargets(ctx context.Context, targets map[string]*eventHubTarget) {
+	for _, t := range targets {
+		t.producerClient.Close(ctx)
+	}
+}
+
+// eventHubLabel returns the event hub's name for metrics, taking it from
+// the connection string's EntityPath when Name is empty.
+func eventHubLabel(cfg EventHubSinkConfig) string {
+	if cfg.Name != "" || cfg.ConnectionString == "" {
+		return cfg.Name
+	}
+	props, err := azeventhubs.ParseConnectionString(cfg.ConnectionString)
+	if err != nil || props.EntityPath == nil {
+		return ""
+	}
+	return *props.EntityPath
+}
+
+// newEventHubProducerClient creates the producer client with the configured
+// authentication.
+func newEventHubProducerClient(cfg EventHubSinkConfig) (eventHubProducer, error) {
+	opts, err := newEventHubProducerOptions(cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	var eventCh channels.Channel
-	if overflow {
-		eventCh = channels.NewOverflowingChannel(channels.BufferCap(bufferSize))
-	} else {
-		eventCh = channels.NewNativeChannel(channels.BufferCap(bufferSize))
+	var client *azeventhubs.ProducerClient
+	switch auth := eventHubAuth(cfg); auth {
+	case "connectionstring":
+		if cfg.ConnectionString == "" {
+			return nil, errors.New("event hub connectionstring auth requires a connection string")
+		}
+		client, err = azeventhubs.NewProducerClientFromConnectionString(cfg.ConnectionString, cfg.Name, opts)
+	case "default", "managedidentity", "workloadidentity":
+		cred, cErr := newEventHubCredential(auth, cfg)
+		if cErr != nil {
+			return nil, cErr
+		}
+		client, err = azeventhubs.NewProducerClient(cfg.Namespace, cfg.Name, cred, opts)
+	default:
+		return nil, fmt.Errorf("invalid event hub auth %q, expected connectionstring, managedidentity, workloadidentity or default", cfg.Auth)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return eventHubProducerClient{client}, nil
+}
+
+// newEventHubProducerOptions returns the producer client options. The client
+// connects over WebSockets when they are enabled, or a proxy or WebSocket
+// dialer is configured.
+func newEventHubProducerOptions(cfg EventHubSinkConfig) (*azeventhubs.ProducerClientOptions, error) {
+	opts := &azeventhubs.ProducerClientOptions{
+		ApplicationID: cfg.ApplicationID,
+		RetryOptions: azeventhubs.RetryOptions{
+			MaxRetries:    cfg.ClientMaxRetries,
+			RetryDelay:    cfg.ClientRetryDelay,
+			MaxRetryDelay: cfg.ClientMaxRetryDelay,
+		},
+		NewWebSocketConn: cfg.NewWebSocketConn,
+	}
+
+	tlsConfig, err := newAzureTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	opts.TLSConfig = tlsConfig
+
+	if opts.NewWebSocketConn == nil && (cfg.WebSockets || cfg.ProxyURL != "" || cfg.Transport != nil) {
+		transport, err := eventHubTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.NewWebSocketConn = newEventHubWebSocketConn(transport)
+	}
+	return opts, nil
+}
+
+// eventHubTransport returns the configured transport, a client sending
+// through ProxyURL with the TLS options, or nil for the SDK's default
+// client.
+func eventHubTransport(cfg EventHubSinkConfig) (policy.Transporter, error) {
+	if cfg.Transport != nil {
+		return cfg.Transport, nil
+	}
+	tlsConfig, err := newAzureTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ProxyURL == "" && tlsConfig == nil {
+		return nil, nil
+	}
+
+	var proxy *url.URL
+	if cfg.ProxyURL != "" {
+		if proxy, err = url.Parse(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("invalid event hub proxy URL %q: %v", cfg.ProxyURL, err)
+		}
+	}
+	return newAzureHTTPClient(tlsConfig, proxy), nil
+}
+
+// eventHubClientOptions returns the options of the Azure HTTP clients, the
+// credentials and the schema registry, using the configured transport and
+// application ID.
+func eventHubClientOptions(cfg EventHubSinkConfig) (azcore.ClientOptions, error) {
+	transport, err := eventHubTransport(cfg)
+	if err != nil {
+		return azcore.ClientOptions{}, err
+	}
+	return azcore.ClientOptions{
+		Transport: transport,
+		Telemetry: policy.TelemetryOptions{ApplicationID: cfg.ApplicationID},
+	}, nil
+}
+
+// transporterFunc adapts a policy.Transporter to an http.RoundTripper.
+type transporterFunc func(*http.Request) (*http.Response, error)
+
+func (f transporterFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newEventHubWebSocketConn returns a dialer opening the AMQP WebSocket
+// connection with the HTTP client of transport, so the upgrade request
+// goes through its proxy, or with the default client if transport is nil.
+func newEventHubWebSocketConn(transport policy.Transporter) func(context.Context, azeventhubs.WebSocketConnParams) (net.Conn, error) {
+	client, ok := transport.(*http.Client)
+	if !ok {
+		client = http.DefaultClient
+		if transport != nil {
+			client = &http.Client{Transport: transporterFunc(transport.Do)}
+		}
+	}
+	return func(ctx context.Context, params azeventhubs.WebSocketConnParams) (net.Conn, error) {
+		conn, _, err := websocket.Dial(ctx, params.Host, &websocket.DialOptions{
+			Subprotocols: []string{"amqp"},
+			HTTPClient:   client,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return websocket.NetConn(context.Background(), conn, websocket.MessageBinary), nil
+	}
+}
+
+// eventHubAuth returns the configured authentication, defaulting to the
+// connection string if one is set.
+func eventHubAuth(cfg EventHubSinkConfig) string {
+	if cfg.Auth != "" {
+		return cfg.Auth
+	}
+	if cfg.ConnectionString != "" {
+		return "connectionstring"
 	}
+	return "default"
+}
 
-	return &EventHubSink{producerClient: producerClient, eventCh: eventCh}, nil
+// eventHubNamespace returns the fully qualified namespace, taking it from
+// the connection string's Endpoint when Namespace is empty.
+func eventHubNamespace(cfg EventHubSinkConfig) string {
+	if cfg.Namespace != "" || cfg.ConnectionString == "" {
+		return cfg.Namespace
+	}
+	props, err := azeventhubs.ParseConnectionString(cfg.ConnectionString)
+	if err != nil {
+		return ""
+	}
+	return props.FullyQualifiedNamespace
+}
+
+// newEventHubCredential creates the Microsoft Entra credential for auth.
+func newEventHubCredential(auth string, cfg EventHubSinkConfig) (azcore.TokenCredential, error) {
+	clientOpts, err := eventHubClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch auth {
+	case "managedidentity":
+		opts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpts}
+		if cfg.ClientID != "" {
+			opts.ID = azidentity.ClientID(cfg.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(opts)
+	case "workloadidentity":
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions: clientOpts,
+			ClientID:      cfg.ClientID,
+			TenantID:      cfg.TenantID,
+			TokenFilePath: cfg.TokenFilePath,
+		})
+	default:
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: clientOpts,
+			TenantID:      cfg.TenantID,
+		})
+	}
 }
 
 // UpdateEvents implements the EventSinkInterface. It really just writes the
 // event data to the event OverflowingChannel, which should never block.
 // Messages that are buffered beyond the bufferSize specified for this EventHubSink
-// are discarded.
+// are discarded, unless there is a spool to write them to.
 func (h *EventHubSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
 	if eNew.Type != v1.EventTypeWarning {
 		return
 	}
+	full := h.eventCh.Len() >= int(h.eventCh.Cap())
+	// Once events are spooled, new ones are spooled behind them until the
+	// spool is replayed, to keep them in order.
+	if h.spool != nil && (full || h.spool.len() > 0) {
+		h.spill([]EventData{NewEventData(eNew, eOld)})
+		return
+	}
+	// The overflowing channel discards what is written to a full buffer.
+	hub := h.route(eNew).name
+	if h.overflow && full {
+		eventHubEventsOverflowed.WithLabelValues(hub).Inc()
+	} else {
+		eventHubEventsEnqueued.WithLabelValues(hub).Inc()
+	}
 	h.eventCh.In() <- NewEventData(eNew, eOld)
 }
 
 // Run sits in a loop, waiting for data to come in through h.eventCh,
-// and forwarding them to the event hub sink. If multiple events have happened
-// between loop iterations, it puts all of them in one request instead of
-// making a single request per event.
-func (h *EventHubSink) Run(stopCh <-chan bool) {
-	defer h.producerClient.Close(context.TODO())
+// and forwarding them to the event hub sink. Without a flush interval,
+// everything buffered is sent as soon as the channel is empty, so events
+// that happened between loop iterations go in one request instead of a
+// request per event. With a flush interval, events are sent every interval
+// or once maxBatchEvents are pending, whichever comes first. Once ctx is
+// cancelled, the events still buffered are sent before the producer clients
+// are closed.
+//
+// Sends and their retries run under a context derived from ctx that is
+// cancelled drainTimeout after it, so those still in flight then, including
+// hung ones, are aborted rather than holding up shutdown.
+func (h *EventHubSink) Run(ctx context.Context) {
+	sendCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	defer h.close()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-sendCtx.Done():
+			return
+		}
+		select {
+		case <-time.After(h.drainTimeout):
+			cancel()
+		case <-sendCtx.Done():
+		}
+	}()
+
+	var tick <-chan time.Time
+	if h.flushInterval > 0 {
+		ticker := time.NewTicker(h.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var healthTick <-chan time.Time
+	if h.healthInterval > 0 {
+		ticker := time.NewTicker(h.healthInterval)
+		defer ticker.Stop()
+		healthTick = ticker.C
+	}
+
+	var spoolTick <-chan time.Time
+	if h.spool != nil {
+		ticker := time.NewTicker(eventHubSpoolReplayInterval)
+		defer ticker.Stop()
+		spoolTick = ticker.C
+	}
+
+	var pending []EventData
+	flush := func() {
+		if len(pending) > 0 {
+			h.drainEvents(sendCtx, pending)
+			pending = nil
+		}
+	}
+
 loop:
 	for {
 		select {
 		case e := <-h.eventCh.Out():
-			var evt EventData
-			var ok bool
-			evt, ok = e.(EventData)
+			evt, ok := e.(EventData)
 			if !ok {
 				glog.Warningf("Invalid type sent through event channel: %T", e)
 				continue loop
 			}
+			pending = append(pending, evt)
 
-			// Start with just this event...
-			arr := []EventData{evt}
-
-			// Consume all buffered events into an array, in case more have been written
-			// since we last forwarded them
-			numEvents := h.eventCh.Len()
-			for i := 0; i < numEvents; i++ {
-				e := <-h.eventCh.Out()
-				if evt, ok = e.(EventData); ok {
-					arr = append(arr, evt)
-				} else {
-					glog.Warningf("Invalid type sent through event channel: %T", e)
-				}
+			if h.maxBatchEvents > 0 && len(pending) >= h.maxBatchEvents {
+				flush()
+			} else if h.flushInterval <= 0 && h.eventCh.Len() == 0 {
+				flush()
 			}
-
-			h.drainEvents(arr)
-		case <-stopCh:
+		case <-tick:
+			flush()
+		case <-healthTick:
+			h.checkHealth(sendCtx)
+		case <-spoolTick:
+			if h.spool.len() > 0 {
+				h.replay(sendCtx)
+			}
+		case <-ctx.Done():
 			break loop
 		}
 	}
+
+	h.drainBuffered(sendCtx, pending)
 }
 
-// drainEvents takes an array of event data and sends it to the receiving event hub.
-func (h *EventHubSink) drainEvents(events []EventData) {
-	cosmicClusterId := os.Getenv("COSMIC_CLUSTER_ID")
+// drainBuffered sends the pending events and those left in h.eventCh. Run
+// cancels ctx after drainTimeout, so a broken connection cannot hold up
+// shutdown.
+func (h *EventHubSink) drainBuffered(ctx context.Context, arr []EventData) {
+	for h.eventCh.Len() > 0 {
+		e := <-h.eventCh.Out()
+		if evt, ok := e.(EventData); ok {
+			arr = append(arr, evt)
+		} else {
+			glog.Warningf("Invalid type sent through event channel: %T", e)
+		}
+	}
+	if len(arr) == 0 {
+		return
+	}
 
-	newBatchOptions := &azeventhubs.EventDataBatchOptions{
-		PartitionKey: &cosmicClusterId,
+	h.drainEvents(ctx, arr)
+	if h.spool != nil && h.spool.len() > 0 {
+		glog.Warningf("Leaving %d events spooled in %s to be replayed after restarting", h.spool.len(), h.spool.dir)
+	} else if ctx.Err() != nil {
+		glog.Warningf("Timed out after %v sending %d buffered events to event hub before shutting down", h.drainTimeout, len(arr))
+	} else {
+		glog.Infof("Sent %d buffered events to event hub before shutting down", len(arr))
 	}
-	batch, err := h.producerClient.NewEventDataBatch(context.TODO(), newBatchOptions)
-	if err != nil {
-		panic(err)
+}
+
+// close closes the producer clients of all event hubs.
+func (h *EventHubSink) close() {
+	ctx, cancel := h.operationContext(context.Background())
+	defer cancel()
+	closeEventHubTargets(ctx, h.targets())
+}
+
+// targets returns the event hubs events are sent to, by name.
+func (h *EventHubSink) targets() map[string]*eventHubTarget {
+	targets := map[string]*eventHubTarget{h.target.name: h.target}
+	for _, r := range h.routes {
+		targets[r.target.name] = r.target
 	}
+	return targets
+}
 
-	for i := 0; i < len(events); i++ {
-		event := *events[i].Event
-		if event.EventTime.IsZero() {
-			event.EventTime = metav1.MicroTime{Time: event.FirstTimestamp.Time}
+// Healthy implements HealthChecker. The sink is unhealthy while the last
+// probe or send to any of its event hubs failed.
+func (h *EventHubSink) Healthy() error {
+	var errs []error
+	for name, t := range h.targets() {
+		if err := t.lastErr(); err != nil {
+			errs = append(errs, fmt.Errorf("event hub %s: %v", name, err))
 		}
-		if event.FirstTimestamp.IsZero() {
-			event.FirstTimestamp = metav1.Time{Time: event.EventTime.Time}
+	}
+	return errors.Join(errs...)
+}
+
+// checkHealth probes every event hub by getting its properties.
+func (h *EventHubSink) checkHealth(ctx context.Context) {
+	for _, t := range h.targets() {
+		opCtx, cancel := h.operationContext(ctx)
+		_, err := t.producerClient.GetEventHubProperties(opCtx, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return
 		}
-		if event.LastTimestamp.IsZero() {
-			event.LastTimestamp = metav1.Time{Time: event.EventTime.Time}
+		if err != nil {
+			glog.Warningf("Event hub %s health probe failed: %v", t.name, err)
 		}
-		if event.Count == 0 {
-			event.Count = 1
+		h.recordResult(t, err)
+	}
+}
+
+// recordResult records the result of a probe or send, recreating the
+// producer client after reconnectAfter consecutive failures, in case its
+// connection is broken beyond what the client recovers from.
+func (h *EventHubSink) recordResult(t *eventHubTarget, err error) {
+	t.setErr(err)
+	if err == nil {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if h.reconnectAfter <= 0 || t.failures < h.reconnectAfter {
+		return
+	}
+
+	client, cErr := t.newProducerClient()
+	if cErr != nil {
+		glog.Errorf("Failed to recreate producer for event hub %s: %v", t.name, cErr)
+		return
+	}
+	glog.Warningf("Recreated producer for event hub %s after %d consecutive failures", t.name, t.failures)
+	eventHubReconnects.WithLabelValues(t.name).Inc()
+	old := t.producerClient
+	t.producerClient = client
+	t.failures = 0
+
+	ctx, cancel := h.operationContext(context.Background())
+	defer cancel()
+	old.Close(ctx)
+}
+
+// operationContext returns the context for an operation on the event hub,
+// bounded by operationTimeout.
+func (h *EventHubSink) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.operationTimeout > 0 {
+		return context.WithTimeout(ctx, h.operationTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// route returns the event hub for an event: that of the first route
+// matching its involved object's namespace, or the default one.
+func (h *EventHubSink) route(e *v1.Event) *eventHubTarget {
+	ns := e.InvolvedObject.Namespace
+	for _, r := range h.routes {
+		for _, pattern := range r.namespaces {
+			if ok, _ := path.Match(pattern, ns); ok {
+				return r.target
+			}
 		}
-		eJSONBytes, err := json.Marshal(map[string]interface{}{
-			"event":             &event,
-			"cosmic_cluster_id": cosmicClusterId,
-		})
+	}
+	return h.target
+}
+
+// eventHubGroup is the event hub and partition key events are batched by.
+type eventHubGroup struct {
+	target *eventHubTarget
+	key    string
+}
+
+// drainEvents takes an array of event data and sends it to the receiving event hubs,
+// in a batch per event hub and partition key. With a spool, the events that
+// cannot be sent are spooled, and while events are spooled new ones are
+// spooled behind them and the spool is replayed, to keep them in order.
+func (h *EventHubSink) drainEvents(ctx context.Context, events []EventData) {
+	if h.spool != nil && h.spool.len() > 0 {
+		h.spill(events)
+		h.replay(ctx)
+		return
+	}
+	h.deliver(ctx, events, func(t *eventHubTarget, failed []EventData, err error) {
+		if h.spool != nil {
+			glog.Warningf("Spooling %d events for event hub %s: %v", len(failed), t.name, err)
+			h.spill(failed)
+			return
+		}
+		h.drop(t, len(failed), err)
+		deadLetter("eventhub", failed, err)
+	})
+}
+
+// deliver sends events to the receiving event hubs, in a batch per event
+// hub and partition key, handing the events of batches that could not be
+// created or sent to undelivered.
+func (h *EventHubSink) deliver(ctx context.Context, events []EventData, undelivered func(*eventHubTarget, []EventData, error)) {
+	// Group the events, keeping their order within each group and sending
+	// the groups in the order they were first seen.
+	var order []eventHubGroup
+	groups := make(map[eventHubGroup][]EventData)
+	for _, evt := range events {
+		g := eventHubGroup{target: h.route(evt.Event), key: h.partitionKey(evt.Event)}
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], evt)
+	}
+
+	for _, g := range order {
+		newBatchOptions := &azeventhubs.EventDataBatchOptions{MaxBytes: h.maxBatchBytes}
+		if h.partitionID != "" {
+			newBatchOptions.PartitionID = to.Ptr(h.partitionID)
+		} else if h.partitionKeyBy != "roundrobin" {
+			newBatchOptions.PartitionKey = to.Ptr(g.key)
+		}
+		h.sendEvents(ctx, g.target, newBatchOptions, groups[g], undelivered)
+	}
+}
+
+// spill appends events to the spool, dropping those that do not fit.
+func (h *EventHubSink) spill(events []EventData) {
+	n, err := h.spool.append(events)
+	for _, evt := range events[:n] {
+		eventHubEventsSpooled.WithLabelValues(h.route(evt.Event).name).Inc()
+	}
+	if err != nil {
+		for _, evt := range events[n:] {
+			eventHubEventsFailed.WithLabelValues(h.route(evt.Event).name).Inc()
+		}
+		glog.Errorf("Dropped %d events that could not be spooled: %v", len(events)-n, err)
+		deadLetter("eventhub", events[n:], err)
+	}
+}
+
+// replay sends the spooled events in order, removing them from the spool
+// once sent, until it is empty or a send fails. The events of a chunk that
+// was only partly sent are all sent again on the next replay.
+func (h *EventHubSink) replay(ctx context.Context) {
+	chunk := h.maxBatchEvents
+	if chunk <= 0 {
+		chunk = eventHubSpoolReplayEvents
+	}
+	for ctx.Err() == nil {
+		events, n, pos, err := h.spool.read(chunk)
 		if err != nil {
-			glog.Warningf("Failed to flatten json: %v", err)
+			glog.Errorf("Failed to read spooled events: %v", err)
+			return
+		}
+		if n == 0 {
 			return
 		}
-		glog.V(4).Infof("%s", string(eJSONBytes))
 
-		err = batch.AddEventData(&azeventhubs.EventData{
-			Body: eJSONBytes,
-			Properties: map[string]any{
-				"cosmic_cluster_id": cosmicClusterId,
-			},
-			ContentType: to.Ptr("application/json"),
-		}, nil)
+		var sendErr error
+		h.deliver(ctx, events, func(_ *eventHubTarget, _ []EventData, err error) {
+			sendErr = err
+		})
+		if sendErr != nil {
+			glog.Warningf("Failed to replay spooled events, keeping %d spooled: %v", h.spool.len(), sendErr)
+			return
+		}
+		h.spool.commit(n, pos)
+		glog.V(2).Infof("Replayed %d spooled events, %d left", n, h.spool.len())
+	}
+}
+
+// partitionKey returns the partition key for an event. Round robin sends
+// events without a key, leaving the event hub to spread them across its
+// partitions, and events sent to a partition ID need none.
+func (h *EventHubSink) partitionKey(e *v1.Event) string {
+	if h.partitionID != "" {
+		return ""
+	}
+	switch h.partitionKeyBy {
+	case "namespace":
+		return e.InvolvedObject.Namespace
+	case "uid":
+		return string(e.InvolvedObject.UID)
+	case "roundrobin":
+		return ""
+	default:
+		return h.clusterID
+	}
+}
+
+// sendEvents sends events in as few batches as the event hub's maximum
+// batch size allows. Once a batch cannot be created or sent after retryMax
+// retries, its events and all those after it are handed to undelivered,
+// rather than taking the router down, so they can be replayed in order.
+func (h *EventHubSink) sendEvents(ctx context.Context, t *eventHubTarget, newBatchOptions *azeventhubs.EventDataBatchOptions, events []EventData, undelivered func(*eventHubTarget, []EventData, error)) {
+	batch, err := h.newBatch(ctx, t, newBatchOptions)
+	if err != nil {
+		undelivered(t, events, err)
+		return
+	}
+
+	// batched are the events in batch. sendBatch sends it, handing its
+	// events and rest, those not batched yet, to undelivered if it fails.
+	var batched []EventData
+	sendBatch := func(rest []EventData) bool {
+		if err := h.send(ctx, t, batch); err != nil {
+			undelivered(t, append(batched, rest...), err)
+			return false
+		}
+		batched = nil
+		return true
+	}
+
+	for i := 0; i < len(events); i++ {
+		eventData, err := h.newEventData(events[i])
+		if err != nil {
+			glog.Warningf("Failed to encode event %s/%s for event hub: %v", events[i].Event.Namespace, events[i].Event.Name, err)
+			h.drop(t, 1, err)
+			deadLetter("eventhub", events[i:i+1], err)
+			continue
+		}
+
+		if h.maxBatchEvents > 0 && int(batch.NumEvents()) >= h.maxBatchEvents {
+			if !sendBatch(events[i:]) {
+				return
+			}
+			if batch, err = h.newBatch(ctx, t, newBatchOptions); err != nil {
+				undelivered(t, events[i:], err)
+				return
+			}
+		}
+
+		err = batch.AddEventData(eventData, nil)
 
 		if errors.Is(err, azeventhubs.ErrEventDataTooLarge) {
 			if batch.NumEvents() == 0 {
-				// This one event is too large for this batch, even on its own. No matter what we do it
-				// will not be sendable at its current size.
-				panic(err)
+				// This one event is too large for this batch, even on its own. It will not be
+				// sendable at its current size, so it is up to the oversized policy.
+				if h.addOversized(t, batch, events[i], err) {
+					batched = append(batched, events[i])
+				}
+				continue
 			}
 
 			// This batch is full - we can send it and create a new one and continue
 			// packaging and sending events.
-			if err := h.producerClient.SendEventDataBatch(context.TODO(), batch, nil); err != nil {
-				panic(err)
+			if !sendBatch(events[i:]) {
+				return
 			}
 
 			// create the next batch we'll use for events, ensuring that we use the same options
 			// each time so all the messages go the same target.
-			tmpBatch, err := h.producerClient.NewEventDataBatch(context.TODO(), newBatchOptions)
-
-			if err != nil {
-				panic(err)
+			if batch, err = h.newBatch(ctx, t, newBatchOptions); err != nil {
+				undelivered(t, events[i:], err)
+				return
 			}
 
-			batch = tmpBatch
-
 			// rewind so we can retry adding this event to a batch
 			i--
 		} else if err != nil {
-			panic(err)
+			glog.Warningf("Failed to add event %s/%s to event hub batch: %v", events[i].Event.Namespace, events[i].Event.Name, err)
+			h.drop(t, 1, err)
+			deadLetter("eventhub", events[i:i+1], err)
+		} else {
+			batched = append(batched, events[i])
 		}
 	}
 
 	// if we have any events in the last batch, send it
 	if batch.NumEvents() > 0 {
-		if err := h.producerClient.SendEventDataBatch(context.TODO(), batch, nil); err != nil {
-			panic(err)
+		sendBatch(nil)
+	}
+}
+
+// newEventData builds the event hub event for a Kubernetes event, filling in
+// the timestamps and count that events.k8s.io events leave empty.
+func (h *EventHubSink) newEventData(evt EventData) (*azeventhubs.EventData, error) {
+	event := *evt.Event
+	if event.EventTime.IsZero() {
+		event.EventTime = metav1.MicroTime{Time: event.FirstTimestamp.Time}
+	}
+	if event.FirstTimestamp.IsZero() {
+		event.FirstTimestamp = metav1.Time{Time: event.EventTime.Time}
+	}
+	if event.LastTimestamp.IsZero() {
+		event.LastTimestamp = metav1.Time{Time: event.EventTime.Time}
+	}
+	if event.Count == 0 {
+		event.Count = 1
+	}
+
+	if h.avro != nil {
+		evt.Event = &event
+		body, err := h.avro.encode(evt)
+		if err != nil {
+			return nil, err
+		}
+		return &azeventhubs.EventData{
+			Body:        body,
+			Properties:  h.eventProperties(evt),
+			ContentType: to.Ptr(h.avro.contentType),
+		}, nil
+	}
+
+	eJSONBytes, err := json.Marshal(map[string]interface{}{
+		"event":             &event,
+		h.clusterIDProperty: h.clusterID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.V(4).Infof("%s", string(eJSONBytes))
+
+	return &azeventhubs.EventData{
+		Body:        eJSONBytes,
+		Properties:  h.eventProperties(evt),
+		ContentType: to.Ptr("application/json"),
+	}, nil
+}
+
+// addOversized applies the oversized policy to an event that does not fit
+// in the empty batch: "truncate" halves its message until it fits, "deadletter"
+// archives it in the dead letter archive and "drop" only counts it. Events
+// that cannot be truncated enough are dead lettered. It reports whether the event
+// was added to the batch.
+func (h *EventHubSink) addOversized(t *eventHubTarget, batch eventHubBatch, evt EventData, err error) bool {
+	e := evt.Event
+	switch h.oversizedPolicy {
+	case "truncate":
+		event := *e
+		truncated := evt
+		truncated.Event = &event
+		for n := len([]rune(e.Message)) / 2; n > 0; n /= 2 {
+			event.Message = truncateRunes(e.Message, n) + "... (truncated)"
+			eventData, mErr := h.newEventData(truncated)
+			if mErr != nil {
+				break
+			}
+			addErr := batch.AddEventData(eventData, nil)
+			if addErr == nil {
+				glog.Warningf("Truncated the message of event %s/%s to %d characters to fit an event hub batch", e.Namespace, e.Name, n)
+				return true
+			}
+			if !errors.Is(addErr, azeventhubs.ErrEventDataTooLarge) {
+				err = addErr
+				break
+			}
+		}
+		glog.Warningf("Dropping event %s/%s, too large even with its message truncated: %v", e.Namespace, e.Name, err)
+		deadLetter("eventhub", []EventData{evt}, err)
+	case "deadletter":
+		glog.Warningf("Dead lettering event %s/%s: %v", e.Namespace, e.Name, err)
+		deadLetter("eventhub", []EventData{evt}, err)
+	default:
+		glog.Warningf("Dropping event %s/%s: %v", e.Namespace, e.Name, err)
+	}
+	h.drop(t, 1, err)
+	return false
+}
+
+// eventProperties returns the application properties of an event: the
+// static ones and the cluster ID, as enriched by enrichProperties.
+func (h *EventHubSink) eventProperties(evt EventData) map[string]any {
+	props := make(map[string]any, len(h.properties)+1)
+	for k, v := range h.properties {
+		props[k] = v
+	}
+	props[h.clusterIDProperty] = h.clusterID
+	if h.enrichProperties != nil {
+		h.enrichProperties(evt, props)
+	}
+	return props
+}
+
+// newBatch creates an event data batch, retrying with backoff up to retryMax
+// times or until ctx is done.
+func (h *EventHubSink) newBatch(ctx context.Context, t *eventHubTarget, options *azeventhubs.EventDataBatchOptions) (eventHubBatch, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, backoff(attempt, eventHubRetryBackoff, eventHubMaxRetryBackoff)); err != nil {
+				return nil, err
+			}
+		}
+
+		opCtx, cancel := h.operationContext(ctx)
+		batch, err := t.producerClient.NewEventDataBatch(opCtx, options)
+		cancel()
+		if err == nil {
+			return batch, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !eventHubRetriable(err) || attempt >= h.retryMax {
+			h.recordResult(t, err)
+			return nil, err
+		}
+		glog.Warningf("Failed to create event hub %s batch, retrying: %v", t.name, err)
+	}
+}
+
+// send sends a batch, retrying with backoff up to retryMax times or until
+// ctx is done before giving up on it.
+func (h *EventHubSink) send(ctx context.Context, t *eventHubTarget, batch eventHubBatch) error {
+	if err := h.throttle(ctx, t, batch); err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, backoff(attempt, eventHubRetryBackoff, eventHubMaxRetryBackoff)); err != nil {
+				return err
+			}
 		}
+
+		start := time.Now()
+		opCtx, cancel := h.operationContext(ctx)
+		err := t.producerClient.SendEventDataBatch(opCtx, batch, nil)
+		cancel()
+		eventHubSendDuration.WithLabelValues(t.name).Observe(time.Since(start).Seconds())
+		if err == nil {
+			eventHubBatchesSent.WithLabelValues(t.name).Inc()
+			eventHubBatchEvents.WithLabelValues(t.name).Observe(float64(batch.NumEvents()))
+			eventHubBatchBytes.WithLabelValues(t.name).Observe(float64(batch.NumBytes()))
+			h.recordResult(t, nil)
+			return nil
+		}
+		eventHubSendErrors.WithLabelValues(t.name).Inc()
+		if ctx.Err() != nil {
+			return err
+		}
+		if !eventHubRetriable(err) || attempt >= h.retryMax {
+			h.recordResult(t, err)
+			return err
+		}
+		glog.Warningf("Failed to send %d events to event hub %s, retrying: %v", batch.NumEvents(), t.name, err)
+	}
+}
+
+// throttle waits until the rate limits allow sending batch.
+func (h *EventHubSink) throttle(ctx context.Context, t *eventHubTarget, batch eventHubBatch) error {
+	if h.eventsLimiter == nil && h.bytesLimiter == nil {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		eventHubThrottledSeconds.WithLabelValues(t.name).Add(time.Since(start).Seconds())
+	}()
+	if err := waitRate(ctx, h.eventsLimiter, int(batch.NumEvents())); err != nil {
+		return err
+	}
+	return waitRate(ctx, h.bytesLimiter, int(batch.NumBytes()))
+}
+
+// waitRate waits until l allows n tokens, taking them a burst at a time so
+// n may exceed the burst. A nil l never waits.
+func waitRate(ctx context.Context, l *rate.Limiter, n int) error {
+	if l == nil {
+		return nil
+	}
+	for n > 0 {
+		step := min(n, l.Burst())
+		if err := l.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
+
+// drop counts n events that could not be delivered.
+func (h *EventHubSink) drop(t *eventHubTarget, n int, err error) {
+	eventHubEventsFailed.WithLabelValues(t.name).Add(float64(n))
+	glog.Errorf("Dropped %d events for event hub %s: %v", n, t.name, err)
+}
+
+// sleepContext sleeps for d, returning early with ctx's error if it is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// eventHubRetriable reports whether an operation that failed with err may
+// succeed when retried. Rejected credentials won't fix themselves.
+func eventHubRetriable(err error) bool {
+	var ehErr *azeventhubs.Error
+	if errors.As(err, &ehErr) {
+		return ehErr.Code != azeventhubs.ErrorCodeUnauthorizedAccess
 	}
+	return true
 }
@@ -0,0 +1,284 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azeventhubs/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeEventHubBatch is an eventHubBatch holding its events in memory, full
+// once their bodies exceed maxBytes.
+type fakeEventHubBatch struct {
+	options  azeventhubs.EventDataBatchOptions
+	events   []*azeventhubs.EventData
+	numBytes uint64
+}
+
+func (b *fakeEventHubBatch) AddEventData(ed *azeventhubs.EventData, _ *azeventhubs.AddEventDataOptions) error {
+	n := uint64(len(ed.Body))
+	if b.options.MaxBytes > 0 && b.numBytes+n > b.options.MaxBytes {
+		return azeventhubs.ErrEventDataTooLarge
+	}
+	b.events = append(b.events, ed)
+	b.numBytes += n
+	return nil
+}
+
+func (b *fakeEventHubBatch) NumEvents() int32 { return int32(len(b.events)) }
+
+func (b *fakeEventHubBatch) NumBytes() uint64 { return b.numBytes }
+
+// fakeEventHubProducer is an eventHubProducer recording the batches sent.
+// Sends fail with sendErrs, one per attempt, before they succeed.
+type fakeEventHubProducer struct {
+	mu       sync.Mutex
+	sendErrs []error
+	attempts int
+	sent     []*fakeEventHubBatch
+}
+
+func (p *fakeEventHubProducer) NewEventDataBatch(_ context.Context, options *azeventhubs.EventDataBatchOptions) (eventHubBatch, error) {
+	return &fakeEventHubBatch{options: *options}, nil
+}
+
+func (p *fakeEventHubProducer) SendEventDataBatch(_ context.Context, batch eventHubBatch, _ *azeventhubs.SendEventDataBatchOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if len(p.sendErrs) > 0 {
+		err := p.sendErrs[0]
+		p.sendErrs = p.sendErrs[1:]
+		return err
+	}
+	p.sent = append(p.sent, batch.(*fakeEventHubBatch))
+	return nil
+}
+
+func (p *fakeEventHubProducer) GetEventHubProperties(context.Context, *azeventhubs.GetEventHubPropertiesOptions) (azeventhubs.EventHubProperties, error) {
+	return azeventhubs.EventHubProperties{}, nil
+}
+
+func (p *fakeEventHubProducer) Close(context.Context) error { return nil }
+
+// sentNames returns the names of the events sent, in order, per batch.
+func (p *fakeEventHubProducer) sentNames(t *testing.T) [][]string {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names [][]string
+	for _, b := range p.sent {
+		var batch []string
+		for _, ed := range b.events {
+			var body struct {
+				Event v1.Event `json:"event"`
+			}
+			if err := json.Unmarshal(ed.Body, &body); err != nil {
+				t.Fatalf("invalid event body %s: %v", ed.Body, err)
+			}
+			batch = append(batch, body.Event.Name)
+		}
+		names = append(names, batch)
+	}
+	return names
+}
+
+// newFakeEventHubSink creates an event hub sink sending to p, with cfg's
+// batching and delivery options.
+func newFakeEventHubSink(t *testing.T, p *fakeEventHubProducer, cfg EventHubSinkConfig) *EventHubSink {
+	t.Helper()
+	base, max := eventHubRetryBackoff, eventHubMaxRetryBackoff
+	eventHubRetryBackoff, eventHubMaxRetryBackoff = time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		eventHubRetryBackoff, eventHubMaxRetryBackoff = base, max
+	})
+
+	cfg.Name = "events"
+	cfg.ClusterID = "test-cluster"
+	cfg.ClusterIDProperty = "cluster_id"
+	cfg.BufferSize = 100
+	cfg.newProducer = func(EventHubSinkConfig) (eventHubProducer, error) {
+		return p, nil
+	}
+	h, err := NewEventHubSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// newTestEvents returns warning events named event-0 to event-(n-1).
+func newTestEvents(n int) []EventData {
+	var events []EventData
+	for i := 0; i < n; i++ {
+		events = append(events, NewEventData(&v1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("event-%d", i), Namespace: "default"},
+			Type:       v1.EventTypeWarning,
+			Reason:     "BackOff",
+			Message:    "Back-off restarting failed container",
+		}, nil))
+	}
+	return events
+}
+
+func TestEventHubSinkSend(t *testing.T) {
+	p := &fakeEventHubProducer{}
+	h := newFakeEventHubSink(t, p, EventHubSinkConfig{})
+
+	h.drainEvents(context.Background(), newTestEvents(3))
+
+	want := [][]string{{"event-0", "event-1", "event-2"}}
+	if got := p.sentNames(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %v, want %v", got, want)
+	}
+	batch := p.sent[0]
+	if batch.options.PartitionKey == nil || *batch.options.PartitionKey != "test-cluster" {
+		t.Errorf("partition key %v, want test-cluster", batch.options.PartitionKey)
+	}
+	if got := batch.events[0].Properties["cluster_id"]; got != "test-cluster" {
+		t.Errorf("cluster_id property %v, want test-cluster", got)
+	}
+}
+
+func TestEventHubSinkSplitsBatches(t *testing.T) {
+	t.Run("max events", func(t *testing.T) {
+		p := &fakeEventHubProducer{}
+		h := newFakeEventHubSink(t, p, EventHubSinkConfig{MaxBatchEvents: 2})
+
+		h.drainEvents(context.Background(), newTestEvents(5))
+
+		want := [][]string{{"event-0", "event-1"}, {"event-2", "event-3"}, {"event-4"}}
+		if got := p.sentNames(t); !reflect.DeepEqual(got, want) {
+			t.Fatalf("sent %v, want %v", got, want)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		p := &fakeEventHubProducer{}
+		h := newFakeEventHubSink(t, p, EventHubSinkConfig{})
+		events := newTestEvents(5)
+		ed, err := h.newEventData(events[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Room for two events, but not three.
+		h.maxBatchBytes = uint64(len(ed.Body))*5/2 + 1
+
+		h.drainEvents(context.Background(), events)
+
+		want := [][]string{{"event-0", "event-1"}, {"event-2", "event-3"}, {"event-4"}}
+		if got := p.sentNames(t); !reflect.DeepEqual(got, want) {
+			t.Fatalf("sent %v, want %v", got, want)
+		}
+	})
+}
+
+func TestEventHubSinkRetriesSends(t *testing.T) {
+	p := &fakeEventHubProducer{sendErrs: []error{errors.New("server busy"), errors.New("server busy")}}
+	h := newFakeEventHubSink(t, p, EventHubSinkConfig{RetryMax: 2})
+
+	h.drainEvents(context.Background(), newTestEvents(2))
+
+	if p.attempts != 3 {
+		t.Errorf("%d send attempts, want 3", p.attempts)
+	}
+	want := [][]string{{"event-0", "event-1"}}
+	if got := p.sentNames(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %v, want %v", got, want)
+	}
+}
+
+func TestEventHubSinkDropsAfterRetries(t *testing.T) {
+	p := &fakeEventHubProducer{sendErrs: []error{errors.New("server busy"), errors.New("server busy")}}
+	h := newFakeEventHubSink(t, p, EventHubSinkConfig{RetryMax: 1})
+	failed := eventHubEventsFailed.WithLabelValues("events")
+	before := testutil.ToFloat64(failed)
+
+	h.drainEvents(context.Background(), newTestEvents(2))
+
+	if p.attempts != 2 {
+		t.Errorf("%d send attempts, want 2", p.attempts)
+	}
+	if len(p.sent) != 0 {
+		t.Errorf("sent %d batches, want none", len(p.sent))
+	}
+	if got := testutil.ToFloat64(failed) - before; got != 2 {
+		t.Errorf("counted %v failed events, want 2", got)
+	}
+}
+
+func TestEventHubSinkSpillsAndReplays(t *testing.T) {
+	p := &fakeEventHubProducer{sendErrs: []error{errors.New("connection reset")}}
+	h := newFakeEventHubSink(t, p, EventHubSinkConfig{
+		SpoolDir:      t.TempDir(),
+		SpoolMaxBytes: 1 << 20,
+	})
+	events := newTestEvents(3)
+
+	// The failed send is spooled rather than dropped.
+	h.drainEvents(context.Background(), events[:2])
+	if n := h.spool.len(); n != 2 {
+		t.Fatalf("%d events spooled, want 2", n)
+	}
+	if len(p.sent) != 0 {
+		t.Fatalf("sent %d batches, want none", len(p.sent))
+	}
+
+	// New events queue behind the spooled ones, which are replayed first.
+	h.drainEvents(context.Background(), events[2:])
+	if n := h.spool.len(); n != 0 {
+		t.Fatalf("%d events left spooled, want none", n)
+	}
+	var got []string
+	for _, batch := range p.sentNames(t) {
+		got = append(got, batch...)
+	}
+	if want := []string{"event-0", "event-1", "event-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %v, want %v", got, want)
+	}
+}
+
+func TestEventHubSinkStopsAfterFailedBatch(t *testing.T) {
+	p := &fakeEventHubProducer{sendErrs: []error{errors.New("connection reset")}}
+	h := newFakeEventHubSink(t, p, EventHubSinkConfig{MaxBatchEvents: 2})
+	events := newTestEvents(5)
+
+	var undelivered []EventData
+	h.deliver(context.Background(), events, func(_ *eventHubTarget, failed []EventData, _ error) {
+		undelivered = append(undelivered, failed...)
+	})
+
+	// The batches after the failed one are not sent, so all the events can
+	// be replayed in order.
+	if p.attempts != 1 {
+		t.Errorf("%d send attempts, want 1", p.attempts)
+	}
+	if !reflect.DeepEqual(undelivered, events) {
+		t.Fatalf("undelivered %d events, want all %d in order", len(undelivered), len(events))
+	}
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fileRotationTimeFormat names rotated files so they sort chronologically.
+const fileRotationTimeFormat = "20060102T150405.000Z"
+
+// FileSinkConfig holds the options used to construct a FileSink.
+type FileSinkConfig struct {
+	// Path is the file events are appended to, e.g.
+	// /var/log/eventrouter/events.ndjson.
+	Path string
+
+	// MaxSizeBytes and RotateInterval rotate the file once it would grow
+	// beyond the size or has been open for the interval; zero disables
+	// either trigger.
+	MaxSizeBytes   int64
+	RotateInterval time.Duration
+
+	// Compress gzips rotated files and MaxBackups is how many rotated files
+	// are kept, zero keeping all of them.
+	Compress   bool
+	MaxBackups int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// FileSink appends events as newline-delimited JSON to a local file. Rotated
+// files are renamed to <name>-<timestamp><ext> next to it.
+type FileSink struct {
+	path           string
+	maxSize        int64
+	rotateInterval time.Duration
+	compress       bool
+	maxBackups     int
+	eventCh        channels.Channel
+
+	file   *os.File
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+}
+
+// NewFileSink constructs a new FileSink, creating the file's directory and
+// appending to the file if it already exists.
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	f := &FileSink{
+		path:           cfg.Path,
+		maxSize:        cfg.MaxSizeBytes,
+		rotateInterval: cfg.RotateInterval,
+		compress:       cfg.Compress,
+		maxBackups:     cfg.MaxBackups,
+		eventCh:        newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (f *FileSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	f.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through f.eventCh,
+// and appending it to the file.
+func (f *FileSink) Run(stopCh <-chan bool) {
+	runBatches(f.eventCh, stopCh, f.drainEvents)
+	f.close()
+}
+
+// close flushes and closes the file.
+func (f *FileSink) close() {
+	if f.file != nil {
+		f.w.Flush()
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// drainEvents appends an array of event data to the file.
+func (f *FileSink) drainEvents(events []EventData) {
+	lines := make([][]byte, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		lines = append(lines, append(eJSONBytes, '\n'))
+	}
+	f.writeLines(lines)
+}
+
+// writeLines appends lines to the file, rotating it first when needed, and
+// flushes once they are written.
+func (f *FileSink) writeLines(lines [][]byte) {
+	for _, line := range lines {
+		if f.file != nil && f.shouldRotate(len(line)) {
+			f.rotate()
+		}
+		if f.file == nil {
+			// A previous rotation or open failed; try again for every
+			// line rather than giving up on the file.
+			if err := f.open(); err != nil {
+				glog.Errorf("Failed to open %s: %v", f.path, err)
+				continue
+			}
+		}
+
+		n, err := f.w.Write(line)
+		f.size += int64(n)
+		if err != nil {
+			glog.Errorf("Failed to write to %s: %v", f.path, err)
+		}
+	}
+
+	if f.file != nil {
+		if err := f.w.Flush(); err != nil {
+			glog.Errorf("Failed to write to %s: %v", f.path, err)
+		}
+	}
+}
+
+// shouldRotate reports whether the file has to be rotated before n more
+// bytes are written. An empty file is never rotated for size, so single
+// events larger than the limit are still written.
+func (f *FileSink) shouldRotate(n int) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(n) > f.maxSize {
+		return true
+	}
+	return f.rotateInterval > 0 && f.size > 0 && time.Since(f.opened) >= f.rotateInterval
+}
+
+// open opens the file for appending.
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.w = bufio.NewWriter(file)
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// rotate closes the current file, renames it with a timestamp, compresses
+// it if configured and removes the oldest rotated files beyond maxBackups.
+// A new file is opened on the next write.
+func (f *FileSink) rotate() {
+	f.w.Flush()
+	f.file.Close()
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	rotated := strings.TrimSuffix(f.path, ext) + "-" + time.Now().UTC().Format(fileRotationTimeFormat) + ext
+	if err := os.Rename(f.path, rotated); err != nil {
+		glog.Errorf("Failed to rotate %s: %v", f.path, err)
+		return
+	}
+	glog.V(2).Infof("Rotated %s to %s", f.path, rotated)
+
+	if f.compress {
+		if err := gzipFile(rotated); err != nil {
+			glog.Errorf("Failed to compress %s: %v", rotated, err)
+		}
+	}
+	f.removeOldBackups()
+}
+
+// removeOldBackups deletes rotated files beyond the newest maxBackups.
+func (f *FileSink) removeOldBackups() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		glog.Errorf("Failed to list rotated files: %v", err)
+		return
+	}
+	var backups []string
+	for _, name := range matches {
+		ts := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if _, err := time.Parse(fileRotationTimeFormat, strings.TrimPrefix(ts, prefix)); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= f.maxBackups {
+		return
+	}
+
+	// The timestamp makes names sort oldest first, with or without .gz.
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-f.maxBackups] {
+		if err := os.Remove(name); err != nil {
+			glog.Errorf("Failed to remove rotated file %s: %v", name, err)
+		}
+	}
+}
+
+// gzipFile replaces name with name.gz.
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/firehose/types"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// firehoseMaxBatchRecords, firehoseMaxBatchBytes and firehoseMaxRecordBytes
+	// are the PutRecordBatch limits.
+	firehoseMaxBatchRecords = 500
+	firehoseMaxBatchBytes   = 4 * 1024 * 1024
+	firehoseMaxRecordBytes  = 1000 * 1024
+)
+
+// FirehoseSink sends events to an existing Kinesis Data Firehose delivery
+// stream. Records are newline-delimited JSON, so the objects the stream
+// delivers to S3 or Redshift can be read line by line.
+type FirehoseSink struct {
+	client         *firehose.Client
+	deliveryStream string
+	retryMax       int
+	eventCh        channels.Channel
+}
+
+// NewFirehoseSink constructs a new FirehoseSink writing to deliveryStream.
+func NewFirehoseSink(deliveryStream string, region string, retryMax int, overflow bool, bufferSize int) (*FirehoseSink, error) {
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirehoseSink{
+		client:         firehose.NewFromConfig(cfg),
+		deliveryStream: deliveryStream,
+		retryMax:       retryMax,
+		eventCh:        newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (f *FirehoseSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	f.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through f.eventCh,
+// and forwarding it to the delivery stream.
+func (f *FirehoseSink) Run(stopCh <-chan bool) {
+	runBatches(f.eventCh, stopCh, f.drainEvents)
+}
+
+// drainEvents frames an array of event data as NDJSON records and sends them
+// in as few PutRecordBatch calls as the Firehose limits allow.
+func (f *FirehoseSink) drainEvents(events []EventData) {
+	// batched are the events of records.
+	var records []types.Record
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		data := append(eJSONBytes, '\n')
+
+		if len(data) > firehoseMaxRecordBytes {
+			glog.Errorf("Dropping event %s/%s: %d bytes exceeds the Firehose record limit", evt.Event.Namespace, evt.Event.Name, len(data))
+			deadLetter("firehose", []EventData{evt}, fmt.Errorf("%d bytes exceeds the Firehose record limit", len(data)))
+			continue
+		}
+		if len(records) == firehoseMaxBatchRecords || batchBytes+len(data) > firehoseMaxBatchBytes {
+			f.putRecordBatch(records, batched)
+			records, batched = nil, nil
+			batchBytes = 0
+		}
+		records = append(records, types.Record{Data: data})
+		batched = append(batched, evt)
+		batchBytes += len(data)
+	}
+
+	if len(records) > 0 {
+		f.putRecordBatch(records, batched)
+	}
+}
+
+// putRecordBatch sends a single PutRecordBatch request, retrying the records
+// Firehose reports as failed up to retryMax times. The events of records
+// that still fail are dead lettered.
+func (f *FirehoseSink) putRecordBatch(records []types.Record, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, 100*time.Millisecond, 5*time.Second))
+		}
+
+		out, err := f.client.PutRecordBatch(context.TODO(), &firehose.PutRecordBatchInput{
+			DeliveryStreamName: aws.String(f.deliveryStream),
+			Records:            records,
+		})
+		if err != nil {
+			if attempt >= f.retryMax {
+				glog.Errorf("Failed to put %d records to Firehose: %v", len(records), err)
+				deadLetter("firehose", events, err)
+				return
+			}
+			glog.Warningf("Failed to put records to Firehose, retrying: %v", err)
+			continue
+		}
+		if aws.ToInt32(out.FailedPutCount) == 0 {
+			return
+		}
+
+		var failed []types.Record
+		var failedEvents []EventData
+		for i, result := range out.RequestResponses {
+			if result.ErrorCode != nil {
+				if attempt >= f.retryMax {
+					glog.Errorf("Failed to put record to Firehose: %s: %s", aws.ToString(result.ErrorCode), aws.ToString(result.ErrorMessage))
+					deadLetter("firehose", []EventData{events[i]},
+						fmt.Errorf("%s: %s", aws.ToString(result.ErrorCode), aws.ToString(result.ErrorMessage)))
+				}
+				failed = append(failed, records[i])
+				failedEvents = append(failedEvents, events[i])
+			}
+		}
+		if attempt >= f.retryMax || len(failed) == 0 {
+			return
+		}
+		records, events = failed, failedEvents
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/vmihailenco/msgpack/v5"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fluentTimeout bounds dialing, the handshake, writes and waiting for acks.
+const fluentTimeout = 30 * time.Second
+
+func init() {
+	msgpack.RegisterExt(0, (*fluentEventTime)(nil))
+}
+
+// fluentEventTime is the forward protocol's EventTime extension type, which
+// carries nanosecond precision timestamps.
+type fluentEventTime time.Time
+
+func (t *fluentEventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(time.Time(*t).Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(time.Time(*t).Nanosecond()))
+	return b, nil
+}
+
+func (t *fluentEventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid EventTime length %d", len(b))
+	}
+	*t = fluentEventTime(time.Unix(int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:]))))
+	return nil
+}
+
+// FluentForwardSinkConfig holds the options used to construct a
+// FluentForwardSink.
+type FluentForwardSinkConfig struct {
+	// Address is the host:port of a fluentd or fluent-bit forward input.
+	Address string
+	Tag     string
+
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	// SharedKey enables the handshake of a <security> section; Username and
+	// Password additionally authenticate as a user.
+	SharedKey string
+	Username  string
+	Password  string
+
+	// RequireAck waits for the server to acknowledge every chunk.
+	RequireAck bool
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// FluentForwardSink sends events to fluentd or fluent-bit over the forward
+// protocol, one Forward mode message per batch.
+type FluentForwardSink struct {
+	address     string
+	tag         string
+	tlsConfig   *tls.Config
+	sharedKey   string
+	username    string
+	password    string
+	requireAck  bool
+	hostname    string
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+
+	conn net.Conn
+	dec  *msgpack.Decoder
+}
+
+// NewFluentForwardSink constructs a new FluentForwardSink. The connection is
+// opened on the first write and reopened after errors.
+func NewFluentForwardSink(cfg FluentForwardSinkConfig) (*FluentForwardSink, error) {
+	var tlsConfig *tls.Config
+	if cfg.TLSEnabled {
+		var err error
+		tlsConfig, err = newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hostname, _ := os.Hostname()
+
+	return &FluentForwardSink{
+		address:     cfg.Address,
+		tag:         cfg.Tag,
+		tlsConfig:   tlsConfig,
+		sharedKey:   cfg.SharedKey,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		requireAck:  cfg.RequireAck,
+		hostname:    hostname,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (f *FluentForwardSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	f.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through f.eventCh,
+// and forwarding it.
+func (f *FluentForwardSink) Run(stopCh <-chan bool) {
+	runBatches(f.eventCh, stopCh, f.drainEvents)
+	f.close()
+}
+
+// drainEvents sends an array of event data as a single Forward mode
+// message. Records hold the event data as JSON would, plus the cluster.
+func (f *FluentForwardSink) drainEvents(events []EventData) {
+	entries := make([][]interface{}, 0, len(events))
+	forwarded := make([]EventData, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(eJSONBytes, &record); err != nil {
+			glog.Warningf("Failed to convert event to a record: %v", err)
+			continue
+		}
+		if f.clusterName != "" {
+			record["cluster"] = f.clusterName
+		}
+		ts := fluentEventTime(eventTimestamp(evt.Event))
+		entries = append(entries, []interface{}{&ts, record})
+		forwarded = append(forwarded, evt)
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	option := map[string]interface{}{"size": len(entries)}
+	var chunk string
+	if f.requireAck {
+		id := make([]byte, 16)
+		rand.Read(id)
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option["chunk"] = chunk
+	}
+	msg, err := msgpack.Marshal([]interface{}{f.tag, entries, option})
+	if err != nil {
+		glog.Warningf("Failed to encode forward message: %v", err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := f.send(msg, chunk)
+		if err == nil {
+			return
+		}
+		f.close()
+		if attempt >= f.retryMax {
+			glog.Errorf("Failed to forward %d events to %s: %v", len(entries), f.address, err)
+			deadLetter("fluentforward", forwarded, err)
+			return
+		}
+		glog.Warningf("Failed to forward events, reconnecting: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// send writes a message and, when chunk is set, waits for its ack.
+func (f *FluentForwardSink) send(msg []byte, chunk string) error {
+	if f.conn == nil {
+		if err := f.connect(); err != nil {
+			return err
+		}
+	}
+
+	f.conn.SetDeadline(time.Now().Add(fluentTimeout))
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	var resp struct {
+		Ack string `msgpack:"ack"`
+	}
+	if err := f.dec.Decode(&resp); err != nil {
+		return fmt.Errorf("reading ack: %v", err)
+	}
+	if resp.Ack != chunk {
+		return fmt.Errorf("ack for chunk %q, expected %q", resp.Ack, chunk)
+	}
+	return nil
+}
+
+// connect dials the server and performs the shared key handshake if one is
+// configured.
+func (f *FluentForwardSink) connect() error {
+	dialer := &net.Dialer{Timeout: fluentTimeout}
+	var conn net.Conn
+	var err error
+	if f.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", f.address, f.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", f.address)
+	}
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	f.dec = msgpack.NewDecoder(conn)
+
+	if f.sharedKey != "" {
+		conn.SetDeadline(time.Now().Add(fluentTimeout))
+		if err := f.handshake(); err != nil {
+			f.close()
+			return fmt.Errorf("forward handshake: %v", err)
+		}
+	}
+	return nil
+}
+
+// handshake answers the server's HELO with a PING and checks its PONG.
+func (f *FluentForwardSink) handshake() error {
+	var helo []interface{}
+	if err := f.dec.Decode(&helo); err != nil {
+		return err
+	}
+	if len(helo) != 2 || fmt.Sprint(helo[0]) != "HELO" {
+		return fmt.Errorf("unexpected message %v", helo)
+	}
+	opts, _ := helo[1].(map[string]interface{})
+	nonce := msgpackString(opts["nonce"])
+	authSalt := msgpackString(opts["auth"])
+
+	saltBytes := make([]byte, 16)
+	rand.Read(saltBytes)
+	salt := hex.EncodeToString(saltBytes)
+
+	passwordDigest := ""
+	if f.username != "" {
+		passwordDigest = sha512Hex(authSalt + f.username + f.password)
+	}
+	ping, err := msgpack.Marshal([]interface{}{
+		"PING", f.hostname, salt, sha512Hex(salt + f.hostname + nonce + f.sharedKey), f.username, passwordDigest,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := f.conn.Write(ping); err != nil {
+		return err
+	}
+
+	var pong []interface{}
+	if err := f.dec.Decode(&pong); err != nil {
+		return err
+	}
+	if len(pong) != 5 || fmt.Sprint(pong[0]) != "PONG" {
+		return fmt.Errorf("unexpected message %v", pong)
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		return fmt.Errorf("authentication failed: %v", pong[2])
+	}
+	serverHostname := msgpackString(pong[3])
+	if msgpackString(pong[4]) != sha512Hex(salt+serverHostname+nonce+f.sharedKey) {
+		return fmt.Errorf("server %s failed shared key verification", serverHostname)
+	}
+	return nil
+}
+
+// close drops the current connection, if any.
+func (f *FluentForwardSink) close() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+		f.dec = nil
+	}
+}
+
+// msgpackString returns a str or bin value as a string.
+func msgpackString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return ""
+}
+
+func sha512Hex(s string) string {
+	sum := sha512.Sum512([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
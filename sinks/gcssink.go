@@ -0,0 +1,82 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/storage"
+	v1 "k8s.io/api/core/v1"
+)
+
+// GCSSink archives events to a Google Cloud Storage bucket as gzip-compressed
+// NDJSON objects, partitioned by cluster and hour like the S3Sink.
+type GCSSink struct {
+	client  *storage.Client
+	bucket  *storage.BucketHandle
+	archive *eventArchive
+}
+
+// NewGCSSink constructs a new GCSSink. Credentials come from Application
+// Default Credentials, which covers GKE workload identity. An object is
+// written whenever flushEvents events have been buffered or flushInterval
+// has passed, whichever comes first.
+func NewGCSSink(bucket string, prefix string, clusterName string, flushEvents int, flushInterval time.Duration, retryMax int, overflow bool, bufferSize int) (*GCSSink, error) {
+	if clusterName == "" {
+		return nil, errors.New("gcs sink requires a cluster name")
+	}
+
+	client, err := storage.NewClient(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	g := &GCSSink{
+		client: client,
+		bucket: client.Bucket(bucket),
+	}
+	if g.archive, err = newEventArchive("gcs", prefix, clusterName, flushEvents, flushInterval, retryMax, overflow, bufferSize, g.writeObject); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (g *GCSSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	g.archive.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run buffers events and uploads them to the bucket until stopCh fires.
+func (g *GCSSink) Run(stopCh <-chan bool) {
+	defer g.client.Close()
+	g.archive.run(stopCh)
+}
+
+// writeObject uploads a finished archive object.
+func (g *GCSSink) writeObject(key string, body []byte) error {
+	w := g.bucket.Object(key).NewWriter(context.TODO())
+	w.ContentType = "application/gzip"
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
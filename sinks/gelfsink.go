@@ -0,0 +1,301 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// gelfMaxChunks is the most chunks Graylog reassembles into a message.
+	gelfMaxChunks = 128
+
+	// gelfChunkHeaderSize is the size of the magic bytes, message ID and
+	// sequence number and count preceding each chunk.
+	gelfChunkHeaderSize = 12
+)
+
+// gelfInvalidFieldChars matches characters not allowed in additional field
+// names.
+var gelfInvalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// GELFSinkConfig holds the options used to construct a GELFSink.
+type GELFSinkConfig struct {
+	// Network is "udp", "tcp" or "tls"; Address is host:port of a GELF
+	// input.
+	Network string
+	Address string
+
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	// ChunkSize is the largest UDP datagram sent; bigger messages are split
+	// into chunks. Compress gzips UDP messages, TCP inputs do not support
+	// compression.
+	ChunkSize int
+	Compress  bool
+
+	// Host defaults to the cluster name, then the pod's hostname.
+	Host        string
+	ClusterName string
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// GELFSink sends events to Graylog as GELF 1.1 messages. The event message is
+// the short message and every other event field is an additional field.
+type GELFSink struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	chunkSize int
+	compress  bool
+	host      string
+	cluster   string
+	retryMax  int
+	eventCh   channels.Channel
+
+	conn net.Conn
+}
+
+// NewGELFSink constructs a new GELFSink. The connection is opened on the
+// first write and reopened after errors.
+func NewGELFSink(cfg GELFSinkConfig) (*GELFSink, error) {
+	var tlsConfig *tls.Config
+	switch cfg.Network {
+	case "udp":
+		if cfg.ChunkSize <= gelfChunkHeaderSize {
+			return nil, fmt.Errorf("gelf chunk size %d is too small", cfg.ChunkSize)
+		}
+	case "tcp":
+	case "tls":
+		var err error
+		tlsConfig, err = newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown gelf network %q", cfg.Network)
+	}
+
+	host := cfg.Host
+	if host == "" {
+		host = cfg.ClusterName
+	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	return &GELFSink{
+		network:   cfg.Network,
+		address:   cfg.Address,
+		tlsConfig: tlsConfig,
+		chunkSize: cfg.ChunkSize,
+		compress:  cfg.Compress && cfg.Network == "udp",
+		host:      host,
+		cluster:   cfg.ClusterName,
+		retryMax:  cfg.RetryMax,
+		eventCh:   newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (g *GELFSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	g.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through g.eventCh,
+// and sending it to Graylog.
+func (g *GELFSink) Run(stopCh <-chan bool) {
+	runBatches(g.eventCh, stopCh, g.drainEvents)
+	if g.conn != nil {
+		g.conn.Close()
+	}
+}
+
+// drainEvents sends one GELF message per event. UDP messages are split into
+// chunks when needed and TCP messages are null byte delimited.
+func (g *GELFSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		msg, err := json.Marshal(g.message(evt))
+		if err != nil {
+			glog.Warningf("Failed to json serialize gelf message: %v", err)
+			continue
+		}
+
+		if g.network != "udp" {
+			g.write(evt, [][]byte{append(msg, 0)})
+			continue
+		}
+		if g.compress {
+			if msg, err = gzipBytes(msg); err != nil {
+				glog.Warningf("Failed to compress gelf message: %v", err)
+				continue
+			}
+		}
+		datagrams, err := gelfChunks(msg, g.chunkSize)
+		if err != nil {
+			glog.Warningf("Dropping event %s/%s: %v", evt.Event.Namespace, evt.Event.Name, err)
+			deadLetter("gelf", []EventData{evt}, err)
+			continue
+		}
+		g.write(evt, datagrams)
+	}
+}
+
+// message builds the GELF message for an event. Levels are syslog
+// severities, as in the syslog sink.
+func (g *GELFSink) message(evt EventData) map[string]interface{} {
+	e := evt.Event
+	level := 5
+	if e.Type == v1.EventTypeWarning {
+		level = 4
+	}
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          g.host,
+		"short_message": e.Message,
+		"level":         level,
+	}
+	if ts := eventTimestamp(e); !ts.IsZero() {
+		msg["timestamp"] = float64(ts.UnixNano()) / float64(time.Second)
+	}
+
+	fields := flattenEventData(evt)
+	fields["cluster"] = g.cluster
+	fields["involved_object_api_version"] = e.InvolvedObject.APIVersion
+	fields["involved_object_field_path"] = e.InvolvedObject.FieldPath
+	fields["action"] = e.Action
+	fields["reporting_controller"] = e.ReportingController
+	fields["reporting_instance"] = e.ReportingInstance
+	for k, v := range e.Labels {
+		fields["label_"+k] = v
+	}
+
+	for k, v := range fields {
+		switch val := v.(type) {
+		case string:
+			if val == "" {
+				continue
+			}
+		case time.Time:
+			if val.IsZero() {
+				continue
+			}
+			v = val.Format(time.RFC3339Nano)
+		}
+		msg["_"+gelfInvalidFieldChars.ReplaceAllString(k, "_")] = v
+	}
+	return msg
+}
+
+// gelfChunks splits a message into datagrams of at most size bytes. Messages
+// that fit are sent unchunked.
+func gelfChunks(msg []byte, size int) ([][]byte, error) {
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+
+	dataSize := size - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf message of %d bytes needs %d chunks, more than %d", len(msg), count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeaderSize+end-i*dataSize)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*dataSize:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// write sends the datagrams or stream frames of an event's message,
+// reconnecting with backoff after errors, and dead letters the event if it
+// still fails.
+func (g *GELFSink) write(evt EventData, frames [][]byte) {
+	for attempt := 0; ; attempt++ {
+		err := g.tryWrite(frames)
+		if err == nil {
+			return
+		}
+		if g.conn != nil {
+			g.conn.Close()
+			g.conn = nil
+		}
+		if attempt >= g.retryMax {
+			glog.Errorf("Failed to write event to gelf %s: %v", g.address, err)
+			deadLetter("gelf", []EventData{evt}, err)
+			return
+		}
+		glog.Warningf("Failed to write event to gelf, reconnecting: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+func (g *GELFSink) tryWrite(frames [][]byte) error {
+	if g.conn == nil {
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		var conn net.Conn
+		var err error
+		if g.network == "tls" {
+			conn, err = tls.DialWithDialer(dialer, "tcp", g.address, g.tlsConfig)
+		} else {
+			conn, err = dialer.Dial(g.network, g.address)
+		}
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	for _, frame := range frames {
+		if _, err := g.conn.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
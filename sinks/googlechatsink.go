@@ -0,0 +1,219 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Google Chat card limits and colors.
+const (
+	googleChatMaxText      = 4000
+	googleChatWarningColor = "#E67E22"
+)
+
+// googleChatMessage is a webhook message with a single card.
+type googleChatMessage struct {
+	CardsV2 []googleChatCardWithID `json:"cardsV2"`
+	Thread  *googleChatThread      `json:"thread,omitempty"`
+}
+
+type googleChatThread struct {
+	ThreadKey string `json:"threadKey"`
+}
+
+type googleChatCardWithID struct {
+	CardID string         `json:"cardId"`
+	Card   googleChatCard `json:"card"`
+}
+
+type googleChatCard struct {
+	Header   googleChatCardHeader `json:"header"`
+	Sections []googleChatSection  `json:"sections"`
+}
+
+type googleChatCardHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type googleChatSection struct {
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *googleChatTextParagraph `json:"textParagraph,omitempty"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+}
+
+type googleChatTextParagraph struct {
+	Text string `json:"text"`
+}
+
+// GoogleChatSinkConfig holds the options used to construct a
+// GoogleChatSink.
+type GoogleChatSinkConfig struct {
+	// WebhookURL is the space's incoming webhook URL, including its key and
+	// token.
+	WebhookURL string
+
+	// Types, Reasons and Namespaces select the events that are posted.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// DedupWindow limits how often repeats of an event are posted.
+	DedupWindow time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// GoogleChatSink posts events to a Google Chat space as cards. Messages
+// about the same involved object are replies in one thread, so a
+// crashlooping pod fills a thread instead of the space.
+type GoogleChatSink struct {
+	webhookURL  string
+	filter      eventFilter
+	dedup       *deduplicator
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+}
+
+// NewGoogleChatSink constructs a new GoogleChatSink.
+func NewGoogleChatSink(cfg GoogleChatSinkConfig) (*GoogleChatSink, error) {
+	u, err := url.Parse(cfg.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google chat webhook url: %v", err)
+	}
+	q := u.Query()
+	q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	u.RawQuery = q.Encode()
+
+	return &GoogleChatSink{
+		webhookURL:  u.String(),
+		filter:      newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		dedup:       newDeduplicator(cfg.DedupWindow),
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (g *GoogleChatSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !g.filter.match(eNew) {
+		return
+	}
+	g.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through g.eventCh,
+// and posting it to Google Chat.
+func (g *GoogleChatSink) Run(stopCh <-chan bool) {
+	runBatches(g.eventCh, stopCh, g.drainEvents)
+}
+
+// drainEvents posts one message per event.
+func (g *GoogleChatSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		send, suppressed := g.dedup.observe(dedupKey(evt.Event), time.Now())
+		if !send {
+			continue
+		}
+
+		body, err := json.Marshal(g.message(evt, suppressed))
+		if err != nil {
+			glog.Warningf("Failed to json serialize google chat message: %v", err)
+			continue
+		}
+		_, err = postWithRetry(g.client, g.webhookURL, body, g.retryMax, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+		})
+		if err != nil {
+			glog.Errorf("Failed to post event %s/%s to Google Chat: %v", evt.Event.Namespace, evt.Event.Name, err)
+			deadLetter("googlechat", []EventData{evt}, err)
+		}
+	}
+}
+
+// message formats a single event as a card in its object's thread.
+func (g *GoogleChatSink) message(evt EventData, suppressed int) googleChatMessage {
+	e := evt.Event
+	o := e.InvolvedObject
+
+	reason := html.EscapeString(e.Reason)
+	if e.Type == v1.EventTypeWarning {
+		reason = `<font color="` + googleChatWarningColor + `">` + reason + `</font>`
+	}
+	widgets := []googleChatWidget{
+		{DecoratedText: &googleChatDecoratedText{TopLabel: "Reason", Text: reason}},
+		{DecoratedText: &googleChatDecoratedText{TopLabel: "Namespace", Text: html.EscapeString(orDash(o.Namespace))}},
+		{DecoratedText: &googleChatDecoratedText{TopLabel: "Count", Text: fmt.Sprint(e.Count)}},
+		{TextParagraph: &googleChatTextParagraph{Text: html.EscapeString(truncateRunes(e.Message, googleChatMaxText))}},
+	}
+	if suppressed > 0 {
+		widgets = append(widgets, googleChatWidget{
+			TextParagraph: &googleChatTextParagraph{Text: fmt.Sprintf("<i>%d repeats suppressed</i>", suppressed)},
+		})
+	}
+
+	subtitle := e.Type
+	if g.clusterName != "" {
+		subtitle = g.clusterName + " · " + subtitle
+	}
+	return googleChatMessage{
+		CardsV2: []googleChatCardWithID{{
+			CardID: string(e.UID),
+			Card: googleChatCard{
+				Header:   googleChatCardHeader{Title: o.Kind + " " + o.Name, Subtitle: subtitle},
+				Sections: []googleChatSection{{Widgets: widgets}},
+			},
+		}},
+		Thread: &googleChatThread{ThreadKey: g.threadKey(e)},
+	}
+}
+
+// threadKey identifies the thread of an involved object.
+func (g *GoogleChatSink) threadKey(e *v1.Event) string {
+	o := e.InvolvedObject
+	sum := sha1.Sum([]byte(g.clusterName + "/" + o.Namespace + "/" + o.Kind + "/" + o.Name))
+	return "eventrouter-" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// grafanaAnnotation is a create annotation request.
+type grafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	PanelID      int64    `json:"panelId,omitempty"`
+	Time         int64    `json:"time"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// GrafanaAnnotationSinkConfig holds the options used to construct a
+// GrafanaAnnotationSink.
+type GrafanaAnnotationSinkConfig struct {
+	// URL is the Grafana base URL; Token a service account token with the
+	// annotations:write permission.
+	URL   string
+	Token string
+
+	// OrgID selects the organization for tokens with access to several.
+	OrgID string
+
+	// DashboardUID and PanelID scope annotations to a dashboard or panel.
+	// Without them annotations are organization wide, and are shown by
+	// dashboards that query them by tag.
+	DashboardUID string
+	PanelID      int64
+
+	// Types, Reasons and Namespaces select the events that are annotated.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// Tags are added to every annotation.
+	Tags []string
+
+	// DedupWindow limits how often repeats of an event are annotated.
+	DedupWindow time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// GrafanaAnnotationSink creates Grafana annotations for selected events,
+// tagged with the cluster, namespace, kind and reason, so rollouts and
+// failures show up on the dashboards graphing their effects.
+type GrafanaAnnotationSink struct {
+	annotationsURL string
+	token          string
+	orgID          string
+	dashboardUID   string
+	panelID        int64
+	filter         eventFilter
+	tags           []string
+	dedup          *deduplicator
+	clusterName    string
+	retryMax       int
+	client         *http.Client
+	eventCh        channels.Channel
+}
+
+// NewGrafanaAnnotationSink constructs a new GrafanaAnnotationSink.
+func NewGrafanaAnnotationSink(cfg GrafanaAnnotationSinkConfig) *GrafanaAnnotationSink {
+	return &GrafanaAnnotationSink{
+		annotationsURL: strings.TrimSuffix(cfg.URL, "/") + "/api/annotations",
+		token:          cfg.Token,
+		orgID:          cfg.OrgID,
+		dashboardUID:   cfg.DashboardUID,
+		panelID:        cfg.PanelID,
+		filter:         newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		tags:           cfg.Tags,
+		dedup:          newDeduplicator(cfg.DedupWindow),
+		clusterName:    cfg.ClusterName,
+		retryMax:       cfg.RetryMax,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		eventCh:        newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes matching
+// events to the event channel, which is drained by Run.
+func (g *GrafanaAnnotationSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !g.filter.match(eNew) {
+		return
+	}
+	g.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through g.eventCh,
+// and sending it to Grafana.
+func (g *GrafanaAnnotationSink) Run(stopCh <-chan bool) {
+	runBatches(g.eventCh, stopCh, g.drainEvents)
+}
+
+// drainEvents creates one annotation per event.
+func (g *GrafanaAnnotationSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		send, suppressed := g.dedup.observe(dedupKey(evt.Event), time.Now())
+		if !send {
+			continue
+		}
+
+		body, err := json.Marshal(g.annotation(evt, suppressed))
+		if err != nil {
+			glog.Warningf("Failed to json serialize grafana annotation: %v", err)
+			continue
+		}
+		_, err = postWithRetry(g.client, g.annotationsURL, body, g.retryMax, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+g.token)
+			if g.orgID != "" {
+				req.Header.Set("X-Grafana-Org-Id", g.orgID)
+			}
+		})
+		if err != nil {
+			glog.Errorf("Failed to create Grafana annotation for %s: %v", dedupKey(evt.Event), err)
+			deadLetter("grafana", []EventData{evt}, err)
+		}
+	}
+}
+
+// annotation builds the annotation for an event.
+func (g *GrafanaAnnotationSink) annotation(evt EventData, suppressed int) grafanaAnnotation {
+	e := evt.Event
+	tags := append([]string{}, g.tags...)
+	for _, tag := range []string{g.clusterName, e.InvolvedObject.Namespace, e.InvolvedObject.Kind, e.Reason, e.Type} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	text := e.InvolvedObject.Kind + " " + e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name + ": " + e.Reason + "\n" + e.Message
+	if suppressed > 0 {
+		text += fmt.Sprintf("\n(%d repeats suppressed)", suppressed)
+	}
+
+	return grafanaAnnotation{
+		DashboardUID: g.dashboardUID,
+		PanelID:      g.panelID,
+		Time:         eventTimestamp(e).UnixMilli(),
+		Tags:         tags,
+		Text:         text,
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	eventrouterv1 "github.com/heptiolabs/eventrouter/proto/eventrouter/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	v1 "k8s.io/api/core/v1"
+)
+
+// grpcMaxRequestBytes keeps requests below gRPC's default 4MiB message limit.
+const grpcMaxRequestBytes = 4<<20 - 64<<10
+
+// grpcServiceConfig balances streams across all resolved addresses, so a
+// dns:/// target spreads clusters over a headless service's endpoints.
+const grpcServiceConfig = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+// GRPCSinkConfig holds the options used to construct a GRPCSink.
+type GRPCSinkConfig struct {
+	// Target is a gRPC target such as dns:///consumer.observability:9090.
+	Target string
+
+	// TLS is enabled when TLSEnabled is set; TLSCertFile and TLSKeyFile
+	// additionally enable mutual TLS.
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	// KeepaliveTime is how often an idle connection is pinged.
+	KeepaliveTime time.Duration
+
+	// Timeout bounds each attempt to publish a batch, from opening the
+	// stream to the consumer's response.
+	Timeout time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// GRPCSink streams events to a consumer implementing the
+// eventrouter.v1.EventSink service, defined in
+// proto/eventrouter/v1/event.proto.
+type GRPCSink struct {
+	conn        *grpc.ClientConn
+	client      eventrouterv1.EventSinkClient
+	timeout     time.Duration
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+}
+
+// NewGRPCSink constructs a new GRPCSink. Connections are established
+// lazily, so the consumer does not have to be up when eventrouter starts.
+func NewGRPCSink(cfg GRPCSinkConfig) (*GRPCSink, error) {
+	creds := insecure.NewCredentials()
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(cfg.Target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(grpcServiceConfig),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCSink{
+		conn:        conn,
+		client:      eventrouterv1.NewEventSinkClient(conn),
+		timeout:     cfg.Timeout,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (g *GRPCSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	g.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through g.eventCh,
+// and publishing it to the consumer.
+func (g *GRPCSink) Run(stopCh <-chan bool) {
+	defer g.conn.Close()
+	runBatches(g.eventCh, stopCh, g.drainEvents)
+}
+
+// drainEvents publishes an array of event data on a single Publish stream,
+// in as few requests as the message size limit allows.
+func (g *GRPCSink) drainEvents(events []EventData) {
+	var reqs []*eventrouterv1.PublishRequest
+	req := &eventrouterv1.PublishRequest{}
+	size := 0
+	for _, evt := range events {
+		pe := newProtoEvent(evt, g.clusterName)
+		n := proto.Size(pe) + 8
+		if len(req.Events) > 0 && size+n > grpcMaxRequestBytes {
+			reqs = append(reqs, req)
+			req = &eventrouterv1.PublishRequest{}
+			size = 0
+		}
+		req.Events = append(req.Events, pe)
+		size += n
+	}
+	if len(req.Events) > 0 {
+		reqs = append(reqs, req)
+	}
+
+	if len(reqs) > 0 {
+		g.send(reqs, events)
+	}
+}
+
+// send publishes the requests for events, retrying with backoff until the
+// consumer accepts all of them. The events are dead lettered if it still
+// fails.
+func (g *GRPCSink) send(reqs []*eventrouterv1.PublishRequest, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		err := g.trySend(reqs, len(events))
+		if err == nil {
+			return
+		}
+		if attempt >= g.retryMax {
+			glog.Errorf("Failed to send %d events to gRPC consumer: %v", len(events), err)
+			deadLetter("grpc", events, err)
+			return
+		}
+		glog.Warningf("Failed to send events to gRPC consumer, retrying: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// trySend publishes the requests on a new stream, bounded by g.timeout,
+// and checks that the consumer accepted all n events once the stream is
+// closed. Sends only reach gRPC's buffers, so the events are not delivered
+// until the consumer responds.
+func (g *GRPCSink) trySend(reqs []*eventrouterv1.PublishRequest, n int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	stream, err := g.client.Publish(ctx, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			// Send only reports io.EOF for a stream the server ended; the
+			// actual status comes from receiving.
+			if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+				return recvErr
+			}
+			return err
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if accepted := resp.GetAccepted(); accepted < uint64(n) {
+		return fmt.Errorf("consumer accepted %d of %d events", accepted, n)
+	}
+	return nil
+}
+
+// newProtoEvent converts event data to its eventrouter.v1 representation.
+func newProtoEvent(evt EventData, clusterName string) *eventrouterv1.Event {
+	e := evt.Event
+	o := e.InvolvedObject
+	return &eventrouterv1.Event{
+		Verb:            evt.Verb,
+		Cluster:         clusterName,
+		Namespace:       e.Namespace,
+		Name:            e.Name,
+		Uid:             string(e.UID),
+		ResourceVersion: e.ResourceVersion,
+		InvolvedObject: &eventrouterv1.ObjectReference{
+			Kind:            o.Kind,
+			Namespace:       o.Namespace,
+			Name:            o.Name,
+			Uid:             string(o.UID),
+			ApiVersion:      o.APIVersion,
+			ResourceVersion: o.ResourceVersion,
+			FieldPath:       o.FieldPath,
+		},
+		Reason:              e.Reason,
+		Message:             e.Message,
+		Type:                e.Type,
+		Count:               e.Count,
+		FirstTimestamp:      protoTimestamp(e.FirstTimestamp.Time),
+		LastTimestamp:       protoTimestamp(e.LastTimestamp.Time),
+		EventTime:           protoTimestamp(e.EventTime.Time),
+		SourceComponent:     e.Source.Component,
+		SourceHost:          e.Source.Host,
+		ReportingController: e.ReportingController,
+		ReportingInstance:   e.ReportingInstance,
+		Action:              e.Action,
+		Labels:              e.Labels,
+		Annotations:         e.Annotations,
+	}
+}
+
+// protoTimestamp maps the zero time to an unset timestamp.
+func protoTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
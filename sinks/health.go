@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// HealthChecker is implemented by sinks that can tell whether they are able
+// to deliver events.
+type HealthChecker interface {
+	// Healthy returns nil, or why the sink cannot deliver events.
+	Healthy() error
+}
+
+// healthCheck is a HealthChecker with the name of the sink it checks.
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+}
+
+var (
+	healthChecksMu sync.Mutex
+	healthChecks   []healthCheck
+)
+
+// registerHealthCheck adds a sink to those checked by Healthy. name is the
+// name the sink is configured by.
+func registerHealthCheck(name string, c HealthChecker) {
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+	healthChecks = append(healthChecks, healthCheck{name: name, checker: c})
+}
+
+// Healthy checks the sinks that report their health, returning the errors
+// of those that are unhealthy.
+func Healthy() error {
+	healthChecksMu.Lock()
+	checks := healthChecks
+	healthChecksMu.Unlock()
+
+	var errs []error
+	for _, c := range checks {
+		if err := c.checker.Healthy(); err != nil {
+			errs = append(errs, fmt.Errorf("%s sink: %v", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadinessHandler serves readiness probes: 200 when every sink is healthy,
+// and 503 with the errors of the unhealthy ones otherwise.
+func ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
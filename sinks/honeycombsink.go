@@ -0,0 +1,146 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// honeycombMaxBatchBytes is the batch endpoint's request size limit.
+const honeycombMaxBatchBytes = 5 * 1000 * 1000
+
+// honeycombEvent is a single entry of a batch request.
+type honeycombEvent struct {
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// HoneycombSink sends each Kubernetes event to Honeycomb as an event with
+// flattened fields, using the batch endpoint.
+type HoneycombSink struct {
+	batchURL    string
+	apiKey      string
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+}
+
+// NewHoneycombSink constructs a new HoneycombSink writing to dataset. apiURL
+// is https://api.honeycomb.io, or https://api.eu1.honeycomb.io for EU teams.
+func NewHoneycombSink(apiURL string, apiKey string, dataset string, clusterName string, retryMax int, overflow bool, bufferSize int) *HoneycombSink {
+	return &HoneycombSink{
+		batchURL:    strings.TrimSuffix(apiURL, "/") + "/1/batch/" + url.PathEscape(dataset),
+		apiKey:      apiKey,
+		clusterName: clusterName,
+		retryMax:    retryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (h *HoneycombSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	h.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through h.eventCh,
+// and sending it to Honeycomb.
+func (h *HoneycombSink) Run(stopCh <-chan bool) {
+	runBatches(h.eventCh, stopCh, h.drainEvents)
+}
+
+// drainEvents sends an array of event data in as few batch requests as the
+// request size limit allows.
+func (h *HoneycombSink) drainEvents(events []EventData) {
+	// batched are the events of batch.
+	var batch []json.RawMessage
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		data := flattenEventData(evt)
+		data["cluster"] = h.clusterName
+		entry, err := json.Marshal(honeycombEvent{
+			Time: eventTimestamp(evt.Event),
+			Data: data,
+		})
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(batch) > 0 && batchBytes+len(entry)+1 > honeycombMaxBatchBytes {
+			h.send(batch, batched)
+			batch, batched = nil, nil
+			batchBytes = 0
+		}
+		batch = append(batch, entry)
+		batched = append(batched, evt)
+		batchBytes += len(entry) + 1
+	}
+
+	if len(batch) > 0 {
+		h.send(batch, batched)
+	}
+}
+
+// send posts a single batch request and logs and dead letters the events
+// that failed or Honeycomb rejected.
+func (h *HoneycombSink) send(batch []json.RawMessage, events []EventData) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		glog.Warningf("Failed to json serialize honeycomb batch: %v", err)
+		return
+	}
+
+	respBody, err := postWithRetry(h.client, h.batchURL, body, h.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Honeycomb-Team", h.apiKey)
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to Honeycomb: %v", len(batch), err)
+		deadLetter("honeycomb", events, err)
+		return
+	}
+
+	var results []struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		glog.Warningf("Failed to decode honeycomb batch response: %v", err)
+		return
+	}
+	for i, r := range results {
+		if r.Status >= 300 {
+			glog.Errorf("Honeycomb rejected event: status %d: %s", r.Status, r.Error)
+			if i < len(events) {
+				deadLetter("honeycomb", []EventData{events[i]}, fmt.Errorf("status %d: %s", r.Status, r.Error))
+			}
+		}
+	}
+}
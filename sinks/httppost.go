@@ -0,0 +1,124 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// httpStatusError is returned by postWithRetry for non-2xx responses.
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// postWithRetry posts body to url and returns the response body. Transport
+// errors and 429 or 5xx responses are retried with backoff up to retryMax
+// times, honoring Retry-After when the server sends one. setHeaders is
+// called on every attempt to add content type and credentials.
+func postWithRetry(client *http.Client, url string, body []byte, retryMax int, setHeaders func(*http.Request)) ([]byte, error) {
+	return requestWithRetry(client, http.MethodPost, url, body, retryMax, setHeaders)
+}
+
+// requestWithRetry is postWithRetry for other methods. A nil body sends no
+// request body.
+func requestWithRetry(client *http.Client, method string, url string, body []byte, retryMax int, setHeaders func(*http.Request)) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retryMax; attempt++ {
+		if attempt > 0 {
+			wait := backoff(attempt, time.Second, 30*time.Second)
+			if statusErr, ok := lastErr.(*retryAfterError); ok && statusErr.after > 0 {
+				wait = statusErr.after
+			}
+			glog.V(2).Infof("Retrying %s %s in %v: %v", method, url, wait, lastErr)
+			time.Sleep(wait)
+		}
+
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, reqBody)
+		if err != nil {
+			return nil, err
+		}
+		if setHeaders != nil {
+			setHeaders(req)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return respBody, nil
+		}
+		statusErr := &httpStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return respBody, statusErr
+		}
+		lastErr = &retryAfterError{httpStatusError: statusErr, after: retryAfter(resp)}
+	}
+	if statusErr, ok := lastErr.(*retryAfterError); ok {
+		return nil, statusErr.httpStatusError
+	}
+	return nil, lastErr
+}
+
+// retryAfterError remembers how long the server asked us to wait.
+type retryAfterError struct {
+	*httpStatusError
+	after time.Duration
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// gzipBytes compresses an HTTP request body.
+func gzipBytes(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
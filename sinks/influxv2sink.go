@@ -0,0 +1,177 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// influxMeasurement is the measurement events are written to.
+const influxMeasurement = "kubernetes_events"
+
+var (
+	// influxTagEscaper escapes tag keys, tag values and field keys.
+	influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+	// influxStringEscaper escapes string field values.
+	influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// InfluxV2SinkConfig holds the options used to construct an InfluxV2Sink.
+type InfluxV2SinkConfig struct {
+	URL    string
+	Token  string
+	Org    string
+	Bucket string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// InfluxV2Sink writes events to an InfluxDB 2.x bucket as line protocol
+// through the v2 write API. Each event is a point tagged by cluster,
+// namespace, reason, kind and type.
+type InfluxV2Sink struct {
+	writeURL      string
+	token         string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewInfluxV2Sink constructs a new InfluxV2Sink.
+func NewInfluxV2Sink(cfg InfluxV2SinkConfig) (*InfluxV2Sink, error) {
+	if err := checkFlushInterval("influxdb", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("org", cfg.Org)
+	query.Set("bucket", cfg.Bucket)
+	query.Set("precision", "ns")
+
+	return &InfluxV2Sink{
+		writeURL:      strings.TrimSuffix(cfg.URL, "/") + "/api/v2/write?" + query.Encode(),
+		token:         cfg.Token,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (i *InfluxV2Sink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	i.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through i.eventCh, and
+// writing it to InfluxDB in batches of up to flushEvents points.
+func (i *InfluxV2Sink) Run(stopCh <-chan bool) {
+	runTimedBatches(i.eventCh, stopCh, i.flushEvents, i.flushInterval, i.drainEvents)
+}
+
+// drainEvents writes an array of event data with a single gzipped request.
+func (i *InfluxV2Sink) drainEvents(events []EventData) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		i.writePoint(&buf, evt)
+	}
+
+	body, err := gzipBytes(buf.Bytes())
+	if err != nil {
+		glog.Warningf("Failed to compress influxdb write request: %v", err)
+		return
+	}
+
+	_, err = postWithRetry(i.client, i.writeURL, body, i.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Authorization", "Token "+i.token)
+	})
+	if err != nil {
+		glog.Errorf("Failed to write %d events to InfluxDB: %v", len(events), err)
+		deadLetter("influxv2", events, err)
+	}
+}
+
+// writePoint appends the line protocol for a single event to buf. Tags with
+// empty values are left out, as line protocol does not allow them.
+func (i *InfluxV2Sink) writePoint(buf *bytes.Buffer, evt EventData) {
+	e := evt.Event
+
+	buf.WriteString(influxMeasurement)
+	writeInfluxTag(buf, "cluster", i.clusterName)
+	writeInfluxTag(buf, "kind", e.InvolvedObject.Kind)
+	writeInfluxTag(buf, "namespace", e.InvolvedObject.Namespace)
+	writeInfluxTag(buf, "reason", e.Reason)
+	writeInfluxTag(buf, "type", e.Type)
+
+	buf.WriteString(" count=")
+	buf.WriteString(strconv.FormatInt(int64(e.Count), 10))
+	buf.WriteByte('i')
+	writeInfluxStringField(buf, "message", e.Message)
+	writeInfluxStringField(buf, "name", e.InvolvedObject.Name)
+	writeInfluxStringField(buf, "source_component", e.Source.Component)
+	writeInfluxStringField(buf, "uid", string(e.InvolvedObject.UID))
+	writeInfluxStringField(buf, "verb", evt.Verb)
+
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(eventTimestamp(e).UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+// writeInfluxTag appends ",key=value" to buf unless value is empty.
+func writeInfluxTag(buf *bytes.Buffer, key, value string) {
+	if value == "" {
+		return
+	}
+	buf.WriteByte(',')
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(influxTagEscaper.Replace(value))
+}
+
+// writeInfluxStringField appends `,key="value"` to buf.
+func writeInfluxStringField(buf *bytes.Buffer, key, value string) {
+	buf.WriteByte(',')
+	buf.WriteString(key)
+	buf.WriteString(`="`)
+	buf.WriteString(influxStringEscaper.Replace(value))
+	buf.WriteByte('"')
+}
@@ -18,6 +18,8 @@ package sinks
 
 import (
 	"errors"
+	"os"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/spf13/viper"
@@ -29,20 +31,30 @@ type EventSinkInterface interface {
 	UpdateEvents(eNew *v1.Event, eOld *v1.Event)
 }
 
-// ManufactureSink will manufacture a sink according to viper configs
-// TODO: Determine if it should return an array of sinks
-func ManufactureSink() (e EventSinkInterface) {
+// ManufactureSink will manufacture a sink according to viper configs. Use
+// the multi sink to send events to several destinations. If deadLetterPath
+// is set, events sinks fail to deliver are archived there. The sinks run
+// until stopCh is closed; use WaitForShutdown to wait for them to drain.
+func ManufactureSink(stopCh <-chan struct{}) (e EventSinkInterface) {
+	sinkStop = stopCh
 	s := viper.GetString("sink")
 	glog.Infof("Sink is [%v]", s)
+	manufactureDeadLetters()
+	return manufactureSink(s)
+}
+
+// manufactureSink manufactures the sink named s.
+func manufactureSink(s string) (e EventSinkInterface) {
 	switch s {
 	case "eventhub":
+		eventhubConnString := viper.GetString("eventHubConnectionString")
 		eventhubNamespace := viper.GetString("eventHubNamespace")
-		if eventhubNamespace == "" {
-			panic("eventhub sink specified but eventHubNamespace not specified")
+		if eventhubNamespace == "" && eventhubConnString == "" {
+			panic("eventhub sink specified but neither eventHubNamespace nor eventHubConnectionString specified")
 		}
 
 		eventhubName := viper.GetString("eventHubName")
-		if eventhubName == "" {
+		if eventhubName == "" && eventhubConnString == "" {
 			panic("eventhub sink specified but eventHubName not specified")
 		}
 
@@ -50,18 +62,2407 @@ func ManufactureSink() (e EventSinkInterface) {
 		// 1500 have come in without getting consumed
 		viper.SetDefault("eventHubSinkBufferSize", 1500)
 		viper.SetDefault("eventHubSinkDiscardMessages", true)
+		viper.SetDefault("eventHubPartitionKeyBy", "cluster")
+		viper.SetDefault("eventHubClusterIdProperty", "cosmic_cluster_id")
+		// Deployments that predate eventHubClusterId set the environment.
+		viper.SetDefault("eventHubClusterId", os.Getenv("COSMIC_CLUSTER_ID"))
+		viper.SetDefault("eventHubOversizedPolicy", "drop")
+		viper.SetDefault("eventHubCodec", "json")
+		viper.SetDefault("eventHubSchemaRegistryAutoRegister", true)
+		viper.SetDefault("eventHubRetryMax", 5)
+		viper.SetDefault("eventHubSpoolMaxBytes", 256<<20)
+		viper.SetDefault("eventHubHealthCheckInterval", "1m")
+		viper.SetDefault("eventHubReconnectAfter", 3)
+		viper.SetDefault("eventHubOperationTimeout", "60s")
+		viper.SetDefault("eventHubDrainTimeout", "30s")
+
+		if viper.GetBool("enable-prometheus") {
+			registerEventHubMetrics()
+		}
 
-		bufferSize := viper.GetInt("eventHubSinkBufferSize")
-		overflow := viper.GetBool("eventHubSinkDiscardMessages")
-		eh, err := NewEventHubSink(eventhubNamespace, eventhubName, overflow, bufferSize)
+		var eventhubRoutes []EventHubRoute
+		if err := viper.UnmarshalKey("eventHubRoutes", &eventhubRoutes); err != nil {
+			panic("invalid eventHubRoutes: " + err.Error())
+		}
+
+		eh, err := NewEventHubSink(EventHubSinkConfig{
+			Namespace:           eventhubNamespace,
+			Name:                eventhubName,
+			Auth:                viper.GetString("eventHubAuth"),
+			ConnectionString:    eventhubConnString,
+			ClientID:            viper.GetString("eventHubClientId"),
+			TenantID:            viper.GetString("eventHubTenantId"),
+			TokenFilePath:       viper.GetString("eventHubTokenFilePath"),
+			WebSockets:          viper.GetBool("eventHubWebSockets"),
+			ProxyURL:            viper.GetString("eventHubProxyUrl"),
+			TLS:                 azureTLSConfigFromViper(),
+			ApplicationID:       viper.GetString("eventHubApplicationId"),
+			ClientMaxRetries:    viper.GetInt32("eventHubClientMaxRetries"),
+			ClientRetryDelay:    viper.GetDuration("eventHubClientRetryDelay"),
+			ClientMaxRetryDelay: viper.GetDuration("eventHubClientMaxRetryDelay"),
+			Routes:              eventhubRoutes,
+			Codec:               viper.GetString("eventHubCodec"),
+			SchemaRegistry: AzureSchemaRegistryConfig{
+				Endpoint:     viper.GetString("eventHubSchemaRegistryEndpoint"),
+				Group:        viper.GetString("eventHubSchemaRegistryGroup"),
+				AutoRegister: viper.GetBool("eventHubSchemaRegistryAutoRegister"),
+			},
+			ClusterID:           viper.GetString("eventHubClusterId"),
+			ClusterIDProperty:   viper.GetString("eventHubClusterIdProperty"),
+			Properties:          viper.GetStringMapString("eventHubProperties"),
+			PartitionKeyBy:      viper.GetString("eventHubPartitionKeyBy"),
+			PartitionID:         viper.GetString("eventHubPartitionId"),
+			FlushInterval:       viper.GetDuration("eventHubFlushInterval"),
+			MaxBatchEvents:      viper.GetInt("eventHubMaxBatchEvents"),
+			MaxBatchBytes:       viper.GetUint64("eventHubMaxBatchBytes"),
+			OversizedPolicy:     viper.GetString("eventHubOversizedPolicy"),
+			RetryMax:            viper.GetInt("eventHubRetryMax"),
+			SpoolDir:            viper.GetString("eventHubSpoolDir"),
+			SpoolMaxBytes:       viper.GetInt64("eventHubSpoolMaxBytes"),
+			HealthCheckInterval: viper.GetDuration("eventHubHealthCheckInterval"),
+			ReconnectAfter:      viper.GetInt("eventHubReconnectAfter"),
+			MaxEventsPerSecond:  viper.GetFloat64("eventHubMaxEventsPerSecond"),
+			MaxMBPerSecond:      viper.GetFloat64("eventHubMaxMBPerSecond"),
+			OperationTimeout:    viper.GetDuration("eventHubOperationTimeout"),
+			DrainTimeout:        viper.GetDuration("eventHubDrainTimeout"),
+			Overflow:            viper.GetBool("eventHubSinkDiscardMessages"),
+			BufferSize:          viper.GetInt("eventHubSinkBufferSize"),
+		})
 		if err != nil {
 			panic(err.Error())
 		}
-		go eh.Run(make(chan bool))
+		registerHealthCheck("eventhub", eh)
+		// Run drains for up to the drain timeout, then closes the clients.
+		extendShutdownTimeout(viper.GetDuration("eventHubDrainTimeout") + viper.GetDuration("eventHubOperationTimeout"))
+		runSinkContext(eh.Run)
 		return eh
+	case "kafka":
+		viper.SetDefault("kafkaBrokers", []string{"kafka:9092"})
+		viper.SetDefault("kafkaTopic", "eventrouter")
+		viper.SetDefault("kafkaKeyBy", "uid")
+		viper.SetDefault("kafkaRetryMax", 5)
+		viper.SetDefault("kafkaSaslMechanism", "PLAIN")
+		viper.SetDefault("kafkaCodec", "json")
+		viper.SetDefault("kafkaSchemaRegistrySubjectStrategy", "topic")
+		viper.SetDefault("kafkaSchemaRegistryAutoRegister", true)
+		viper.SetDefault("kafkaSinkBufferSize", 1500)
+		viper.SetDefault("kafkaSinkDiscardMessages", true)
+
+		k, err := NewKafkaSink(KafkaSinkConfig{
+			Brokers:  viper.GetStringSlice("kafkaBrokers"),
+			Topic:    viper.GetString("kafkaTopic"),
+			KeyBy:    viper.GetString("kafkaKeyBy"),
+			RetryMax: viper.GetInt("kafkaRetryMax"),
+			Codec:    viper.GetString("kafkaCodec"),
+			SchemaRegistry: SchemaRegistryConfig{
+				URL:             viper.GetString("kafkaSchemaRegistryUrl"),
+				Username:        viper.GetString("kafkaSchemaRegistryUsername"),
+				Password:        viper.GetString("kafkaSchemaRegistryPassword"),
+				SubjectStrategy: viper.GetString("kafkaSchemaRegistrySubjectStrategy"),
+				AutoRegister:    viper.GetBool("kafkaSchemaRegistryAutoRegister"),
+				Compatibility:   viper.GetString("kafkaSchemaRegistryCompatibility"),
+				RetryMax:        viper.GetInt("kafkaRetryMax"),
+			},
+			SASLMechanism:         viper.GetString("kafkaSaslMechanism"),
+			SASLUser:              viper.GetString("kafkaSaslUser"),
+			SASLPassword:          viper.GetString("kafkaSaslPwd"),
+			TLSEnabled:            viper.GetBool("kafkaTls"),
+			TLSCAFile:             viper.GetString("kafkaTlsCaFile"),
+			TLSCertFile:           viper.GetString("kafkaTlsCertFile"),
+			TLSKeyFile:            viper.GetString("kafkaTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("kafkaTlsInsecureSkipVerify"),
+			Overflow:              viper.GetBool("kafkaSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("kafkaSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(k.Run)
+		return k
+	case "sns":
+		topicARN := viper.GetString("snsTopicArn")
+		if topicARN == "" {
+			panic("sns sink specified but snsTopicArn not specified")
+		}
+
+		viper.SetDefault("snsSinkBufferSize", 1500)
+		viper.SetDefault("snsSinkDiscardMessages", true)
+
+		s, err := NewSNSSink(topicARN, viper.GetString("awsRegion"), viper.GetBool("snsSinkDiscardMessages"), viper.GetInt("snsSinkBufferSize"))
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "eventbridge":
+		viper.SetDefault("eventBridgeSource", "eventrouter")
+		viper.SetDefault("eventBridgeDetailType", "Kubernetes Event")
+		viper.SetDefault("eventBridgeRetryMax", 3)
+		viper.SetDefault("eventBridgeSinkBufferSize", 1500)
+		viper.SetDefault("eventBridgeSinkDiscardMessages", true)
+
+		s, err := NewEventBridgeSink(
+			viper.GetString("eventBridgeBusName"),
+			viper.GetString("eventBridgeSource"),
+			viper.GetString("eventBridgeDetailType"),
+			viper.GetString("awsRegion"),
+			viper.GetInt("eventBridgeRetryMax"),
+			viper.GetBool("eventBridgeSinkDiscardMessages"),
+			viper.GetInt("eventBridgeSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "firehose":
+		deliveryStream := viper.GetString("firehoseDeliveryStream")
+		if deliveryStream == "" {
+			panic("firehose sink specified but firehoseDeliveryStream not specified")
+		}
+
+		viper.SetDefault("firehoseRetryMax", 3)
+		viper.SetDefault("firehoseSinkBufferSize", 1500)
+		viper.SetDefault("firehoseSinkDiscardMessages", true)
+
+		f, err := NewFirehoseSink(
+			deliveryStream,
+			viper.GetString("awsRegion"),
+			viper.GetInt("firehoseRetryMax"),
+			viper.GetBool("firehoseSinkDiscardMessages"),
+			viper.GetInt("firehoseSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(f.Run)
+		return f
+	case "cloudwatchlogs":
+		logGroup := viper.GetString("cloudWatchLogsGroup")
+		if logGroup == "" {
+			panic("cloudwatchlogs sink specified but cloudWatchLogsGroup not specified")
+		}
+
+		viper.SetDefault("cloudWatchLogsStreamBy", "cluster")
+		viper.SetDefault("cloudWatchLogsRetryMax", 5)
+		viper.SetDefault("cloudWatchLogsSinkBufferSize", 1500)
+		viper.SetDefault("cloudWatchLogsSinkDiscardMessages", true)
+
+		c, err := NewCloudWatchLogsSink(
+			logGroup,
+			viper.GetString("cloudWatchLogsStreamBy"),
+			viper.GetString("clusterName"),
+			viper.GetString("awsRegion"),
+			viper.GetInt("cloudWatchLogsRetryMax"),
+			viper.GetBool("cloudWatchLogsSinkDiscardMessages"),
+			viper.GetInt("cloudWatchLogsSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(c.Run)
+		return c
+	case "s3":
+		bucket := viper.GetString("s3SinkBucket")
+		if bucket == "" {
+			panic("s3 sink specified but s3SinkBucket not specified")
+		}
+
+		viper.SetDefault("s3SinkBucketDir", "")
+		viper.SetDefault("s3SinkFlushEvents", 10000)
+		viper.SetDefault("s3SinkUploadInterval", 120)
+		viper.SetDefault("s3SinkRetryMax", 3)
+		viper.SetDefault("s3SinkBufferSize", 1500)
+		viper.SetDefault("s3SinkDiscardMessages", true)
+
+		region := viper.GetString("s3SinkRegion")
+		if region == "" {
+			region = viper.GetString("awsRegion")
+		}
+		s, err := NewS3Sink(
+			bucket,
+			viper.GetString("s3SinkBucketDir"),
+			viper.GetString("clusterName"),
+			region,
+			viper.GetInt("s3SinkFlushEvents"),
+			time.Duration(viper.GetInt("s3SinkUploadInterval"))*time.Second,
+			viper.GetInt("s3SinkRetryMax"),
+			viper.GetBool("s3SinkDiscardMessages"),
+			viper.GetInt("s3SinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "pubsub":
+		topic := viper.GetString("pubSubTopic")
+		if topic == "" {
+			panic("pubsub sink specified but pubSubTopic not specified")
+		}
+
+		viper.SetDefault("pubSubSinkBufferSize", 1500)
+		viper.SetDefault("pubSubSinkDiscardMessages", true)
+
+		p, err := NewPubSubSink(
+			viper.GetString("gcpProject"),
+			topic,
+			viper.GetBool("pubSubSinkDiscardMessages"),
+			viper.GetInt("pubSubSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(p.Run)
+		return p
+	case "bigquery":
+		dataset := viper.GetString("bigQueryDataset")
+		if dataset == "" {
+			panic("bigquery sink specified but bigQueryDataset not specified")
+		}
+
+		viper.SetDefault("bigQueryTable", "events")
+		viper.SetDefault("bigQuerySinkBufferSize", 1500)
+		viper.SetDefault("bigQuerySinkDiscardMessages", true)
+
+		b, err := NewBigQuerySink(
+			viper.GetString("gcpProject"),
+			dataset,
+			viper.GetString("bigQueryTable"),
+			viper.GetString("clusterName"),
+			viper.GetBool("bigQuerySinkDiscardMessages"),
+			viper.GetInt("bigQuerySinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(b.Run)
+		return b
+	case "gcs":
+		bucket := viper.GetString("gcsSinkBucket")
+		if bucket == "" {
+			panic("gcs sink specified but gcsSinkBucket not specified")
+		}
+
+		viper.SetDefault("gcsSinkBucketDir", "")
+		viper.SetDefault("gcsSinkFlushEvents", 10000)
+		viper.SetDefault("gcsSinkUploadInterval", 120)
+		viper.SetDefault("gcsSinkRetryMax", 3)
+		viper.SetDefault("gcsSinkBufferSize", 1500)
+		viper.SetDefault("gcsSinkDiscardMessages", true)
+
+		g, err := NewGCSSink(
+			bucket,
+			viper.GetString("gcsSinkBucketDir"),
+			viper.GetString("clusterName"),
+			viper.GetInt("gcsSinkFlushEvents"),
+			time.Duration(viper.GetInt("gcsSinkUploadInterval"))*time.Second,
+			viper.GetInt("gcsSinkRetryMax"),
+			viper.GetBool("gcsSinkDiscardMessages"),
+			viper.GetInt("gcsSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(g.Run)
+		return g
+	case "nats":
+		viper.SetDefault("natsUrl", "nats://nats:4222")
+		viper.SetDefault("natsSubject", "k8s.events.{namespace}.{reason}")
+		viper.SetDefault("natsSinkBufferSize", 1500)
+		viper.SetDefault("natsSinkDiscardMessages", true)
+
+		n, err := NewNATSSink(NATSSinkConfig{
+			URL:         viper.GetString("natsUrl"),
+			Subject:     viper.GetString("natsSubject"),
+			CredsFile:   viper.GetString("natsCredsFile"),
+			Token:       viper.GetString("natsToken"),
+			User:        viper.GetString("natsUser"),
+			Password:    viper.GetString("natsPassword"),
+			TLSEnabled:  viper.GetBool("natsTls"),
+			TLSCAFile:   viper.GetString("natsTlsCaFile"),
+			TLSCertFile: viper.GetString("natsTlsCertFile"),
+			TLSKeyFile:  viper.GetString("natsTlsKeyFile"),
+			Overflow:    viper.GetBool("natsSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("natsSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(n.Run)
+		return n
+	case "rabbitmq":
+		url := viper.GetString("rabbitMQUrl")
+		if url == "" {
+			panic("rabbitmq sink specified but rabbitMQUrl not specified")
+		}
+
+		viper.SetDefault("rabbitMQExchange", "eventrouter")
+		viper.SetDefault("rabbitMQRoutingKey", "{namespace}.{reason}")
+		viper.SetDefault("rabbitMQRetryMax", 5)
+		viper.SetDefault("rabbitMQSinkBufferSize", 1500)
+		viper.SetDefault("rabbitMQSinkDiscardMessages", true)
+
+		r, err := NewRabbitMQSink(
+			url,
+			viper.GetString("rabbitMQExchange"),
+			viper.GetString("rabbitMQRoutingKey"),
+			viper.GetInt("rabbitMQRetryMax"),
+			viper.GetString("rabbitMQTlsCaFile"),
+			viper.GetString("rabbitMQTlsCertFile"),
+			viper.GetString("rabbitMQTlsKeyFile"),
+			viper.GetBool("rabbitMQSinkDiscardMessages"),
+			viper.GetInt("rabbitMQSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(r.Run)
+		return r
+	case "redis":
+		viper.SetDefault("redisAddrs", []string{"redis:6379"})
+		viper.SetDefault("redisStream", "eventrouter")
+		viper.SetDefault("redisStreamMaxLen", 100000)
+		viper.SetDefault("redisSinkBufferSize", 1500)
+		viper.SetDefault("redisSinkDiscardMessages", true)
+
+		r, err := NewRedisStreamSink(RedisStreamSinkConfig{
+			Addrs:       viper.GetStringSlice("redisAddrs"),
+			ClusterMode: viper.GetBool("redisClusterMode"),
+			Username:    viper.GetString("redisUsername"),
+			Password:    viper.GetString("redisPassword"),
+			DB:          viper.GetInt("redisDB"),
+			Stream:      viper.GetString("redisStream"),
+			MaxLen:      viper.GetInt64("redisStreamMaxLen"),
+			TLSEnabled:  viper.GetBool("redisTls"),
+			TLSCAFile:   viper.GetString("redisTlsCaFile"),
+			TLSCertFile: viper.GetString("redisTlsCertFile"),
+			TLSKeyFile:  viper.GetString("redisTlsKeyFile"),
+			Overflow:    viper.GetBool("redisSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("redisSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(r.Run)
+		return r
+	case "elasticsearch":
+		url := viper.GetString("elasticsearchUrl")
+		if url == "" {
+			panic("elasticsearch sink specified but elasticsearchUrl not specified")
+		}
+
+		viper.SetDefault("elasticsearchIndexPrefix", "k8s-events")
+		viper.SetDefault("elasticsearchIndexDateLayout", "2006.01")
+		viper.SetDefault("elasticsearchFlushEvents", 500)
+		viper.SetDefault("elasticsearchFlushInterval", 5)
+		viper.SetDefault("elasticsearchRetryMax", 5)
+		viper.SetDefault("elasticsearchSinkBufferSize", 1500)
+		viper.SetDefault("elasticsearchSinkDiscardMessages", true)
+
+		es, err := NewElasticsearchSink(ElasticsearchSinkConfig{
+			URL:                   url,
+			IndexPrefix:           viper.GetString("elasticsearchIndexPrefix"),
+			IndexDateLayout:       viper.GetString("elasticsearchIndexDateLayout"),
+			Username:              viper.GetString("elasticsearchUsername"),
+			Password:              viper.GetString("elasticsearchPassword"),
+			APIKey:                viper.GetString("elasticsearchApiKey"),
+			TLSCAFile:             viper.GetString("elasticsearchTlsCaFile"),
+			TLSInsecureSkipVerify: viper.GetBool("elasticsearchTlsInsecureSkipVerify"),
+			FlushEvents:           viper.GetInt("elasticsearchFlushEvents"),
+			FlushInterval:         time.Duration(viper.GetInt("elasticsearchFlushInterval")) * time.Second,
+			RetryMax:              viper.GetInt("elasticsearchRetryMax"),
+			Overflow:              viper.GetBool("elasticsearchSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("elasticsearchSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(es.Run)
+		return es
+	case "opensearch":
+		url := viper.GetString("openSearchUrl")
+		if url == "" {
+			panic("opensearch sink specified but openSearchUrl not specified")
+		}
+
+		viper.SetDefault("openSearchService", "es")
+		viper.SetDefault("openSearchIndexPrefix", "k8s-events")
+		viper.SetDefault("openSearchIndexDateLayout", "2006.01.02")
+		viper.SetDefault("openSearchFlushEvents", 500)
+		viper.SetDefault("openSearchFlushInterval", 5)
+		viper.SetDefault("openSearchRetryMax", 5)
+		viper.SetDefault("openSearchSinkBufferSize", 1500)
+		viper.SetDefault("openSearchSinkDiscardMessages", true)
+
+		o, err := NewOpenSearchSink(OpenSearchSinkConfig{
+			URL:             url,
+			Region:          viper.GetString("awsRegion"),
+			Service:         viper.GetString("openSearchService"),
+			IndexPrefix:     viper.GetString("openSearchIndexPrefix"),
+			IndexDateLayout: viper.GetString("openSearchIndexDateLayout"),
+			FlushEvents:     viper.GetInt("openSearchFlushEvents"),
+			FlushInterval:   time.Duration(viper.GetInt("openSearchFlushInterval")) * time.Second,
+			RetryMax:        viper.GetInt("openSearchRetryMax"),
+			Overflow:        viper.GetBool("openSearchSinkDiscardMessages"),
+			BufferSize:      viper.GetInt("openSearchSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(o.Run)
+		return o
+	case "loki":
+		url := viper.GetString("lokiUrl")
+		if url == "" {
+			panic("loki sink specified but lokiUrl not specified")
+		}
+
+		// Loki's defaults for per_stream_rate_limit and per_stream_rate_limit_burst
+		viper.SetDefault("lokiStreamRateBytes", 3*1024*1024)
+		viper.SetDefault("lokiStreamBurstBytes", 15*1024*1024)
+		viper.SetDefault("lokiRetryMax", 5)
+		viper.SetDefault("lokiSinkBufferSize", 1500)
+		viper.SetDefault("lokiSinkDiscardMessages", true)
+
+		l := NewLokiSink(LokiSinkConfig{
+			URL:              url,
+			TenantID:         viper.GetString("lokiTenantId"),
+			Username:         viper.GetString("lokiUsername"),
+			Password:         viper.GetString("lokiPassword"),
+			ClusterName:      viper.GetString("clusterName"),
+			StreamRateBytes:  viper.GetInt("lokiStreamRateBytes"),
+			StreamBurstBytes: viper.GetInt("lokiStreamBurstBytes"),
+			RetryMax:         viper.GetInt("lokiRetryMax"),
+			Overflow:         viper.GetBool("lokiSinkDiscardMessages"),
+			BufferSize:       viper.GetInt("lokiSinkBufferSize"),
+		})
+		runSink(l.Run)
+		return l
+	case "splunk":
+		url := viper.GetString("splunkHecUrl")
+		if url == "" {
+			panic("splunk sink specified but splunkHecUrl not specified")
+		}
+		token := viper.GetString("splunkHecToken")
+		if token == "" {
+			panic("splunk sink specified but splunkHecToken not specified")
+		}
+
+		viper.SetDefault("splunkHecSourceType", "kube:event")
+		viper.SetDefault("splunkHecSource", "eventrouter")
+		viper.SetDefault("splunkHecGzip", true)
+		viper.SetDefault("splunkHecAckTimeout", 60)
+		viper.SetDefault("splunkHecRetryMax", 5)
+		viper.SetDefault("splunkSinkBufferSize", 1500)
+		viper.SetDefault("splunkSinkDiscardMessages", true)
+
+		s, err := NewSplunkHECSink(SplunkHECSinkConfig{
+			URL:                   url,
+			Token:                 token,
+			Index:                 viper.GetString("splunkHecIndex"),
+			SourceType:            viper.GetString("splunkHecSourceType"),
+			Source:                viper.GetString("splunkHecSource"),
+			Host:                  viper.GetString("clusterName"),
+			Gzip:                  viper.GetBool("splunkHecGzip"),
+			UseAck:                viper.GetBool("splunkHecUseAck"),
+			AckTimeout:            time.Duration(viper.GetInt("splunkHecAckTimeout")) * time.Second,
+			TLSCAFile:             viper.GetString("splunkHecTlsCaFile"),
+			TLSInsecureSkipVerify: viper.GetBool("splunkHecTlsInsecureSkipVerify"),
+			RetryMax:              viper.GetInt("splunkHecRetryMax"),
+			Overflow:              viper.GetBool("splunkSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("splunkSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "datadog":
+		apiKey := viper.GetString("datadogApiKey")
+		if apiKey == "" {
+			panic("datadog sink specified but datadogApiKey not specified")
+		}
+
+		viper.SetDefault("datadogSite", "datadoghq.com")
+		viper.SetDefault("datadogApi", "logs")
+		viper.SetDefault("datadogService", "eventrouter")
+		viper.SetDefault("datadogRetryMax", 5)
+		viper.SetDefault("datadogSinkBufferSize", 1500)
+		viper.SetDefault("datadogSinkDiscardMessages", true)
+
+		d, err := NewDatadogSink(DatadogSinkConfig{
+			APIKey:      apiKey,
+			Site:        viper.GetString("datadogSite"),
+			API:         viper.GetString("datadogApi"),
+			ClusterName: viper.GetString("clusterName"),
+			Service:     viper.GetString("datadogService"),
+			RetryMax:    viper.GetInt("datadogRetryMax"),
+			Overflow:    viper.GetBool("datadogSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("datadogSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(d.Run)
+		return d
+	case "newrelic":
+		licenseKey := viper.GetString("newRelicLicenseKey")
+		if licenseKey == "" {
+			panic("newrelic sink specified but newRelicLicenseKey not specified")
+		}
+
+		viper.SetDefault("newRelicEndpoint", "https://log-api.newrelic.com/log/v1")
+		viper.SetDefault("newRelicRetryMax", 5)
+		viper.SetDefault("newRelicSinkBufferSize", 1500)
+		viper.SetDefault("newRelicSinkDiscardMessages", true)
+
+		n := NewNewRelicSink(NewRelicSinkConfig{
+			LicenseKey:  licenseKey,
+			Endpoint:    viper.GetString("newRelicEndpoint"),
+			ClusterName: viper.GetString("clusterName"),
+			RetryMax:    viper.GetInt("newRelicRetryMax"),
+			Overflow:    viper.GetBool("newRelicSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("newRelicSinkBufferSize"),
+		})
+		runSink(n.Run)
+		return n
+	case "honeycomb":
+		apiKey := viper.GetString("honeycombApiKey")
+		if apiKey == "" {
+			panic("honeycomb sink specified but honeycombApiKey not specified")
+		}
+
+		viper.SetDefault("honeycombApiUrl", "https://api.honeycomb.io")
+		viper.SetDefault("honeycombDataset", "kubernetes-events")
+		viper.SetDefault("honeycombRetryMax", 5)
+		viper.SetDefault("honeycombSinkBufferSize", 1500)
+		viper.SetDefault("honeycombSinkDiscardMessages", true)
+
+		h := NewHoneycombSink(
+			viper.GetString("honeycombApiUrl"),
+			apiKey,
+			viper.GetString("honeycombDataset"),
+			viper.GetString("clusterName"),
+			viper.GetInt("honeycombRetryMax"),
+			viper.GetBool("honeycombSinkDiscardMessages"),
+			viper.GetInt("honeycombSinkBufferSize"),
+		)
+		runSink(h.Run)
+		return h
+	case "clickhouse":
+		url := viper.GetString("clickHouseUrl")
+		if url == "" {
+			panic("clickhouse sink specified but clickHouseUrl not specified")
+		}
+
+		viper.SetDefault("clickHouseDatabase", "default")
+		viper.SetDefault("clickHouseTable", "k8s_events")
+		viper.SetDefault("clickHouseFlushEvents", 1000)
+		viper.SetDefault("clickHouseFlushInterval", 10)
+		viper.SetDefault("clickHouseRetryMax", 5)
+		viper.SetDefault("clickHouseSinkBufferSize", 5000)
+		viper.SetDefault("clickHouseSinkDiscardMessages", true)
+
+		c, err := NewClickHouseSink(ClickHouseSinkConfig{
+			URL:           url,
+			Database:      viper.GetString("clickHouseDatabase"),
+			Table:         viper.GetString("clickHouseTable"),
+			Username:      viper.GetString("clickHouseUsername"),
+			Password:      viper.GetString("clickHousePassword"),
+			AsyncInsert:   viper.GetBool("clickHouseAsyncInsert"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("clickHouseFlushEvents"),
+			FlushInterval: time.Duration(viper.GetInt("clickHouseFlushInterval")) * time.Second,
+			RetryMax:      viper.GetInt("clickHouseRetryMax"),
+			Overflow:      viper.GetBool("clickHouseSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("clickHouseSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(c.Run)
+		return c
+	case "postgres":
+		dsn := viper.GetString("postgresDsn")
+		if dsn == "" {
+			panic("postgres sink specified but postgresDsn not specified")
+		}
+
+		viper.SetDefault("postgresTable", "k8s_events")
+		viper.SetDefault("postgresSinkBufferSize", 1500)
+		viper.SetDefault("postgresSinkDiscardMessages", true)
+
+		p, err := NewPostgresSink(
+			dsn,
+			viper.GetString("postgresTable"),
+			viper.GetString("clusterName"),
+			viper.GetBool("postgresSinkDiscardMessages"),
+			viper.GetInt("postgresSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(p.Run)
+		return p
+	case "mysql":
+		dsn := viper.GetString("mysqlDsn")
+		if dsn == "" {
+			panic("mysql sink specified but mysqlDsn not specified")
+		}
+
+		viper.SetDefault("mysqlTable", "k8s_events")
+		viper.SetDefault("mysqlRetryMax", 5)
+		viper.SetDefault("mysqlSinkBufferSize", 1500)
+		viper.SetDefault("mysqlSinkDiscardMessages", true)
+
+		m, err := NewMySQLSink(
+			dsn,
+			viper.GetString("mysqlTable"),
+			viper.GetString("clusterName"),
+			viper.GetInt("mysqlRetryMax"),
+			viper.GetBool("mysqlSinkDiscardMessages"),
+			viper.GetInt("mysqlSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(m.Run)
+		return m
+	case "mongo", "mongodb":
+		uri := viper.GetString("mongoUri")
+		if uri == "" {
+			panic("mongo sink specified but mongoUri not specified")
+		}
+
+		viper.SetDefault("mongoDatabase", "eventrouter")
+		viper.SetDefault("mongoCollection", "events")
+		viper.SetDefault("mongoSinkBufferSize", 1500)
+		viper.SetDefault("mongoSinkDiscardMessages", true)
+
+		m, err := NewMongoSink(MongoSinkConfig{
+			URI:                   uri,
+			Database:              viper.GetString("mongoDatabase"),
+			Collection:            viper.GetString("mongoCollection"),
+			ClusterName:           viper.GetString("clusterName"),
+			CappedSizeBytes:       viper.GetInt64("mongoCappedSizeBytes"),
+			TTL:                   viper.GetDuration("mongoTtl"),
+			WriteConcern:          viper.GetString("mongoWriteConcern"),
+			Journal:               viper.GetBool("mongoJournal"),
+			TLSCAFile:             viper.GetString("mongoTlsCaFile"),
+			TLSCertFile:           viper.GetString("mongoTlsCertFile"),
+			TLSKeyFile:            viper.GetString("mongoTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("mongoTlsInsecureSkipVerify"),
+			Overflow:              viper.GetBool("mongoSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("mongoSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(m.Run)
+		return m
+	case "cassandra", "scylla":
+		hosts := viper.GetStringSlice("cassandraHosts")
+		if len(hosts) == 0 {
+			panic("cassandra sink specified but cassandraHosts not specified")
+		}
+		keyspace := viper.GetString("cassandraKeyspace")
+		if keyspace == "" {
+			panic("cassandra sink specified but cassandraKeyspace not specified")
+		}
+
+		viper.SetDefault("cassandraTable", "k8s_events")
+		viper.SetDefault("cassandraConsistency", "LOCAL_QUORUM")
+		viper.SetDefault("cassandraSinkBufferSize", 1500)
+		viper.SetDefault("cassandraSinkDiscardMessages", true)
+
+		c, err := NewCassandraSink(CassandraSinkConfig{
+			Hosts:                 hosts,
+			Keyspace:              keyspace,
+			Table:                 viper.GetString("cassandraTable"),
+			ClusterName:           viper.GetString("clusterName"),
+			Consistency:           viper.GetString("cassandraConsistency"),
+			LocalDC:               viper.GetString("cassandraLocalDc"),
+			TTL:                   viper.GetDuration("cassandraTtl"),
+			Username:              viper.GetString("cassandraUsername"),
+			Password:              viper.GetString("cassandraPassword"),
+			TLSEnabled:            viper.GetBool("cassandraTls"),
+			TLSCAFile:             viper.GetString("cassandraTlsCaFile"),
+			TLSCertFile:           viper.GetString("cassandraTlsCertFile"),
+			TLSKeyFile:            viper.GetString("cassandraTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("cassandraTlsInsecureSkipVerify"),
+			Overflow:              viper.GetBool("cassandraSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("cassandraSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(c.Run)
+		return c
+	case "influxv2", "influxdb":
+		url := viper.GetString("influxUrl")
+		if url == "" {
+			panic("influxv2 sink specified but influxUrl not specified")
+		}
+		token := viper.GetString("influxToken")
+		if token == "" {
+			panic("influxv2 sink specified but influxToken not specified")
+		}
+		org := viper.GetString("influxOrg")
+		if org == "" {
+			panic("influxv2 sink specified but influxOrg not specified")
+		}
+
+		viper.SetDefault("influxBucket", "eventrouter")
+		viper.SetDefault("influxFlushEvents", 5000)
+		viper.SetDefault("influxFlushInterval", 10)
+		viper.SetDefault("influxRetryMax", 5)
+		viper.SetDefault("influxSinkBufferSize", 5000)
+		viper.SetDefault("influxSinkDiscardMessages", true)
+
+		i, err := NewInfluxV2Sink(InfluxV2SinkConfig{
+			URL:           url,
+			Token:         token,
+			Org:           org,
+			Bucket:        viper.GetString("influxBucket"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("influxFlushEvents"),
+			FlushInterval: time.Duration(viper.GetInt("influxFlushInterval")) * time.Second,
+			RetryMax:      viper.GetInt("influxRetryMax"),
+			Overflow:      viper.GetBool("influxSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("influxSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(i.Run)
+		return i
+	case "timescale", "timescaledb":
+		dsn := viper.GetString("timescaleDsn")
+		if dsn == "" {
+			panic("timescale sink specified but timescaleDsn not specified")
+		}
+
+		viper.SetDefault("timescaleTable", "k8s_events")
+		viper.SetDefault("timescaleChunkInterval", "24h")
+		viper.SetDefault("timescaleCompressAfter", "168h")
+		viper.SetDefault("timescaleSinkBufferSize", 1500)
+		viper.SetDefault("timescaleSinkDiscardMessages", true)
+
+		t, err := NewTimescaleSink(TimescaleSinkConfig{
+			DSN:           dsn,
+			Table:         viper.GetString("timescaleTable"),
+			ClusterName:   viper.GetString("clusterName"),
+			ChunkInterval: viper.GetDuration("timescaleChunkInterval"),
+			CompressAfter: viper.GetDuration("timescaleCompressAfter"),
+			Retention:     viper.GetDuration("timescaleRetention"),
+			Overflow:      viper.GetBool("timescaleSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("timescaleSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(t.Run)
+		return t
+	case "servicebus":
+		namespace := viper.GetString("serviceBusNamespace")
+		connString := viper.GetString("serviceBusConnectionString")
+		if namespace == "" && connString == "" {
+			panic("servicebus sink specified but neither serviceBusNamespace nor serviceBusConnectionString specified")
+		}
+		entity := viper.GetString("serviceBusEntity")
+		if entity == "" {
+			panic("servicebus sink specified but serviceBusEntity not specified")
+		}
+
+		viper.SetDefault("serviceBusSinkBufferSize", 1500)
+		viper.SetDefault("serviceBusSinkDiscardMessages", true)
+
+		s, err := NewServiceBusSink(
+			namespace,
+			connString,
+			entity,
+			viper.GetString("clusterName"),
+			azureTLSConfigFromViper(),
+			viper.GetBool("serviceBusSinkDiscardMessages"),
+			viper.GetInt("serviceBusSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "loganalytics":
+		endpoint := viper.GetString("logAnalyticsEndpoint")
+		if endpoint == "" {
+			panic("loganalytics sink specified but logAnalyticsEndpoint not specified")
+		}
+		ruleID := viper.GetString("logAnalyticsRuleId")
+		if ruleID == "" {
+			panic("loganalytics sink specified but logAnalyticsRuleId not specified")
+		}
+		streamName := viper.GetString("logAnalyticsStreamName")
+		if streamName == "" {
+			panic("loganalytics sink specified but logAnalyticsStreamName not specified")
+		}
+
+		viper.SetDefault("logAnalyticsRetryMax", 5)
+		viper.SetDefault("logAnalyticsSinkBufferSize", 1500)
+		viper.SetDefault("logAnalyticsSinkDiscardMessages", true)
+
+		l, err := NewLogAnalyticsSink(
+			endpoint,
+			ruleID,
+			streamName,
+			viper.GetString("clusterName"),
+			viper.GetInt("logAnalyticsRetryMax"),
+			azureTLSConfigFromViper(),
+			viper.GetBool("logAnalyticsSinkDiscardMessages"),
+			viper.GetInt("logAnalyticsSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(l.Run)
+		return l
+	case "kusto":
+		endpoint := viper.GetString("kustoEndpoint")
+		if endpoint == "" {
+			panic("kusto sink specified but kustoEndpoint not specified")
+		}
+		database := viper.GetString("kustoDatabase")
+		if database == "" {
+			panic("kusto sink specified but kustoDatabase not specified")
+		}
+
+		viper.SetDefault("kustoTable", "K8sEvents")
+		viper.SetDefault("kustoMode", "queued")
+		viper.SetDefault("kustoFlushEvents", 5000)
+		viper.SetDefault("kustoFlushInterval", 30)
+		viper.SetDefault("kustoSinkBufferSize", 5000)
+		viper.SetDefault("kustoSinkDiscardMessages", true)
+
+		k, err := NewKustoSink(KustoSinkConfig{
+			Endpoint:      endpoint,
+			Database:      database,
+			Table:         viper.GetString("kustoTable"),
+			Mode:          viper.GetString("kustoMode"),
+			MappingRef:    viper.GetString("kustoMappingRef"),
+			Mapping:       viper.GetString("kustoMapping"),
+			TLS:           azureTLSConfigFromViper(),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("kustoFlushEvents"),
+			FlushInterval: time.Duration(viper.GetInt("kustoFlushInterval")) * time.Second,
+			Overflow:      viper.GetBool("kustoSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("kustoSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(k.Run)
+		return k
+	case "slack":
+		viper.SetDefault("slackTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("slackDedupWindow", "10m")
+		viper.SetDefault("slackRateLimit", 1.0)
+		viper.SetDefault("slackRateBurst", 5)
+		viper.SetDefault("slackRetryMax", 3)
+		viper.SetDefault("slackSinkBufferSize", 1500)
+		viper.SetDefault("slackSinkDiscardMessages", true)
+
+		destination := viper.GetString("slackWebhookUrl")
+		if token := viper.GetString("slackBotToken"); token != "" {
+			destination = viper.GetString("slackChannel")
+		}
+		routes := viper.GetStringMapString("slackRoutes")
+		if destination == "" && len(routes) == 0 {
+			panic("slack sink specified but none of slackWebhookUrl, slackChannel or slackRoutes specified")
+		}
+
+		s, err := NewSlackSink(SlackSinkConfig{
+			Default:     destination,
+			Routes:      routes,
+			BotToken:    viper.GetString("slackBotToken"),
+			Template:    viper.GetString("slackTemplate"),
+			Types:       viper.GetStringSlice("slackTypes"),
+			Reasons:     viper.GetStringSlice("slackReasons"),
+			Namespaces:  viper.GetStringSlice("slackNamespaces"),
+			DedupWindow: viper.GetDuration("slackDedupWindow"),
+			RateLimit:   viper.GetFloat64("slackRateLimit"),
+			RateBurst:   viper.GetInt("slackRateBurst"),
+			ClusterName: viper.GetString("clusterName"),
+			RetryMax:    viper.GetInt("slackRetryMax"),
+			Overflow:    viper.GetBool("slackSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("slackSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "teams":
+		webhookURL := viper.GetString("teamsWebhookUrl")
+		routes := viper.GetStringMapString("teamsRoutes")
+		if webhookURL == "" && len(routes) == 0 {
+			panic("teams sink specified but neither teamsWebhookUrl nor teamsRoutes specified")
+		}
+
+		viper.SetDefault("teamsTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("teamsFlushInterval", "30s")
+		viper.SetDefault("teamsDedupWindow", "10m")
+		viper.SetDefault("teamsRetryMax", 3)
+		viper.SetDefault("teamsSinkBufferSize", 1500)
+		viper.SetDefault("teamsSinkDiscardMessages", true)
+
+		t, err := NewTeamsSink(TeamsSinkConfig{
+			WebhookURL:    webhookURL,
+			Routes:        routes,
+			Types:         viper.GetStringSlice("teamsTypes"),
+			Reasons:       viper.GetStringSlice("teamsReasons"),
+			Namespaces:    viper.GetStringSlice("teamsNamespaces"),
+			FlushInterval: viper.GetDuration("teamsFlushInterval"),
+			DedupWindow:   viper.GetDuration("teamsDedupWindow"),
+			ClusterName:   viper.GetString("clusterName"),
+			RetryMax:      viper.GetInt("teamsRetryMax"),
+			Overflow:      viper.GetBool("teamsSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("teamsSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(t.Run)
+		return t
+	case "pagerduty":
+		routingKey := viper.GetString("pagerDutyRoutingKey")
+		routingKeys := viper.GetStringMapString("pagerDutyNamespaceRoutingKeys")
+		if routingKey == "" && len(routingKeys) == 0 {
+			panic("pagerduty sink specified but neither pagerDutyRoutingKey nor pagerDutyNamespaceRoutingKeys specified")
+		}
+
+		viper.SetDefault("pagerDutyTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("pagerDutyDedupWindow", "5m")
+		viper.SetDefault("pagerDutyRetryMax", 5)
+		viper.SetDefault("pagerDutySinkBufferSize", 1500)
+		viper.SetDefault("pagerDutySinkDiscardMessages", true)
+
+		p := NewPagerDutySink(PagerDutySinkConfig{
+			RoutingKey:           routingKey,
+			NamespaceRoutingKeys: routingKeys,
+			Types:                viper.GetStringSlice("pagerDutyTypes"),
+			Reasons:              viper.GetStringSlice("pagerDutyReasons"),
+			Severities:           viper.GetStringMapString("pagerDutySeverities"),
+			AcknowledgeReasons:   viper.GetStringMapString("pagerDutyAcknowledgeReasons"),
+			ResolveReasons:       viper.GetStringMapString("pagerDutyResolveReasons"),
+			DedupWindow:          viper.GetDuration("pagerDutyDedupWindow"),
+			ClusterName:          viper.GetString("clusterName"),
+			RetryMax:             viper.GetInt("pagerDutyRetryMax"),
+			Overflow:             viper.GetBool("pagerDutySinkDiscardMessages"),
+			BufferSize:           viper.GetInt("pagerDutySinkBufferSize"),
+		})
+		runSink(p.Run)
+		return p
+	case "opsgenie":
+		apiKey := viper.GetString("opsgenieApiKey")
+		if apiKey == "" {
+			panic("opsgenie sink specified but opsgenieApiKey not specified")
+		}
+
+		viper.SetDefault("opsgenieApiUrl", "https://api.opsgenie.com")
+		viper.SetDefault("opsgenieDedupWindow", "5m")
+		viper.SetDefault("opsgenieRetryMax", 5)
+		viper.SetDefault("opsgenieSinkBufferSize", 1500)
+		viper.SetDefault("opsgenieSinkDiscardMessages", true)
+
+		o := NewOpsgenieSink(OpsgenieSinkConfig{
+			APIURL:       viper.GetString("opsgenieApiUrl"),
+			APIKey:       apiKey,
+			Reasons:      viper.GetStringSlice("opsgenieReasons"),
+			Priorities:   viper.GetStringMapString("opsgeniePriorities"),
+			CloseReasons: viper.GetStringMapString("opsgenieCloseReasons"),
+			Tags:         viper.GetStringSlice("opsgenieTags"),
+			DedupWindow:  viper.GetDuration("opsgenieDedupWindow"),
+			ClusterName:  viper.GetString("clusterName"),
+			RetryMax:     viper.GetInt("opsgenieRetryMax"),
+			Overflow:     viper.GetBool("opsgenieSinkDiscardMessages"),
+			BufferSize:   viper.GetInt("opsgenieSinkBufferSize"),
+		})
+		runSink(o.Run)
+		return o
+	case "discord":
+		webhookURL := viper.GetString("discordWebhookUrl")
+		if webhookURL == "" {
+			panic("discord sink specified but discordWebhookUrl not specified")
+		}
+
+		viper.SetDefault("discordUsername", "eventrouter")
+		viper.SetDefault("discordTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("discordFlushInterval", "5s")
+		viper.SetDefault("discordRetryMax", 5)
+		viper.SetDefault("discordSinkBufferSize", 1500)
+		viper.SetDefault("discordSinkDiscardMessages", true)
+
+		d, err := NewDiscordSink(
+			webhookURL,
+			viper.GetString("discordUsername"),
+			viper.GetStringSlice("discordTypes"),
+			viper.GetStringSlice("discordReasons"),
+			viper.GetString("clusterName"),
+			viper.GetDuration("discordFlushInterval"),
+			viper.GetInt("discordRetryMax"),
+			viper.GetBool("discordSinkDiscardMessages"),
+			viper.GetInt("discordSinkBufferSize"),
+		)
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(d.Run)
+		return d
+	case "telegram":
+		botToken := viper.GetString("telegramBotToken")
+		if botToken == "" {
+			panic("telegram sink specified but telegramBotToken not specified")
+		}
+		chatID := viper.GetString("telegramChatId")
+		chats := viper.GetStringMapString("telegramNamespaceChats")
+		if chatID == "" && len(chats) == 0 {
+			panic("telegram sink specified but neither telegramChatId nor telegramNamespaceChats specified")
+		}
+
+		viper.SetDefault("telegramTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("telegramRetryMax", 3)
+		viper.SetDefault("telegramSinkBufferSize", 1500)
+		viper.SetDefault("telegramSinkDiscardMessages", true)
+
+		t := NewTelegramSink(TelegramSinkConfig{
+			BotToken:       botToken,
+			ChatID:         chatID,
+			NamespaceChats: chats,
+			Types:          viper.GetStringSlice("telegramTypes"),
+			Reasons:        viper.GetStringSlice("telegramReasons"),
+			Namespaces:     viper.GetStringSlice("telegramNamespaces"),
+			ClusterName:    viper.GetString("clusterName"),
+			RetryMax:       viper.GetInt("telegramRetryMax"),
+			Overflow:       viper.GetBool("telegramSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("telegramSinkBufferSize"),
+		})
+		runSink(t.Run)
+		return t
+	case "email", "smtp":
+		host := viper.GetString("smtpHost")
+		if host == "" {
+			panic("email sink specified but smtpHost not specified")
+		}
+		from := viper.GetString("emailFrom")
+		if from == "" {
+			panic("email sink specified but emailFrom not specified")
+		}
+		to := viper.GetStringSlice("emailTo")
+		if len(to) == 0 {
+			panic("email sink specified but emailTo not specified")
+		}
+
+		viper.SetDefault("smtpPort", 587)
+		viper.SetDefault("smtpTlsMode", "starttls")
+		viper.SetDefault("emailTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("emailInterval", "1h")
+		viper.SetDefault("emailMaxEvents", 500)
+		viper.SetDefault("emailRetryMax", 3)
+		viper.SetDefault("emailSinkBufferSize", 1500)
+		viper.SetDefault("emailSinkDiscardMessages", true)
+
+		m, err := NewEmailSink(EmailSinkConfig{
+			Host:                  host,
+			Port:                  viper.GetInt("smtpPort"),
+			TLSMode:               viper.GetString("smtpTlsMode"),
+			TLSCAFile:             viper.GetString("smtpTlsCaFile"),
+			TLSInsecureSkipVerify: viper.GetBool("smtpTlsInsecureSkipVerify"),
+			Username:              viper.GetString("smtpUsername"),
+			Password:              viper.GetString("smtpPassword"),
+			From:                  from,
+			To:                    to,
+			Template:              viper.GetString("emailTemplate"),
+			Types:                 viper.GetStringSlice("emailTypes"),
+			Reasons:               viper.GetStringSlice("emailReasons"),
+			Namespaces:            viper.GetStringSlice("emailNamespaces"),
+			Interval:              viper.GetDuration("emailInterval"),
+			MaxEvents:             viper.GetInt("emailMaxEvents"),
+			ImmediateReasons:      viper.GetStringSlice("emailImmediateReasons"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("emailRetryMax"),
+			Overflow:              viper.GetBool("emailSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("emailSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(m.Run)
+		return m
+	case "grpc":
+		target := viper.GetString("grpcTarget")
+		if target == "" {
+			panic("grpc sink specified but grpcTarget not specified")
+		}
+
+		viper.SetDefault("grpcKeepaliveTime", "30s")
+		viper.SetDefault("grpcTimeout", "30s")
+		viper.SetDefault("grpcRetryMax", 5)
+		viper.SetDefault("grpcSinkBufferSize", 1500)
+		viper.SetDefault("grpcSinkDiscardMessages", true)
+
+		g, err := NewGRPCSink(GRPCSinkConfig{
+			Target:                target,
+			TLSEnabled:            viper.GetBool("grpcTls"),
+			TLSCAFile:             viper.GetString("grpcTlsCaFile"),
+			TLSCertFile:           viper.GetString("grpcTlsCertFile"),
+			TLSKeyFile:            viper.GetString("grpcTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("grpcTlsInsecureSkipVerify"),
+			KeepaliveTime:         viper.GetDuration("grpcKeepaliveTime"),
+			Timeout:               viper.GetDuration("grpcTimeout"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("grpcRetryMax"),
+			Overflow:              viper.GetBool("grpcSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("grpcSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(g.Run)
+		return g
+	case "mqtt":
+		brokers := viper.GetStringSlice("mqttBrokers")
+		if len(brokers) == 0 {
+			panic("mqtt sink specified but mqttBrokers not specified")
+		}
+
+		viper.SetDefault("mqttClientId", "eventrouter")
+		viper.SetDefault("mqttTopic", "k8s/{cluster}/{namespace}/events")
+		viper.SetDefault("mqttQos", 1)
+		viper.SetDefault("mqttSinkBufferSize", 1500)
+		viper.SetDefault("mqttSinkDiscardMessages", true)
+
+		m, err := NewMQTTSink(MQTTSinkConfig{
+			Brokers:               brokers,
+			ClientID:              viper.GetString("mqttClientId"),
+			Topic:                 viper.GetString("mqttTopic"),
+			QoS:                   byte(viper.GetInt("mqttQos")),
+			Username:              viper.GetString("mqttUsername"),
+			Password:              viper.GetString("mqttPassword"),
+			TLSCAFile:             viper.GetString("mqttTlsCaFile"),
+			TLSCertFile:           viper.GetString("mqttTlsCertFile"),
+			TLSKeyFile:            viper.GetString("mqttTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("mqttTlsInsecureSkipVerify"),
+			ClusterName:           viper.GetString("clusterName"),
+			Overflow:              viper.GetBool("mqttSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("mqttSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(m.Run)
+		return m
+	case "syslog":
+		address := viper.GetString("syslogAddress")
+		if address == "" {
+			panic("syslog sink specified but syslogAddress not specified")
+		}
+
+		viper.SetDefault("syslogNetwork", "udp")
+		viper.SetDefault("syslogFacility", "local0")
+		viper.SetDefault("syslogSdId", "k8s@32473")
+		viper.SetDefault("syslogRetryMax", 3)
+		viper.SetDefault("syslogSinkBufferSize", 1500)
+		viper.SetDefault("syslogSinkDiscardMessages", true)
+
+		s, err := NewSyslogSink(SyslogSinkConfig{
+			Network:               viper.GetString("syslogNetwork"),
+			Address:               address,
+			TLSCAFile:             viper.GetString("syslogTlsCaFile"),
+			TLSCertFile:           viper.GetString("syslogTlsCertFile"),
+			TLSKeyFile:            viper.GetString("syslogTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("syslogTlsInsecureSkipVerify"),
+			Facility:              viper.GetString("syslogFacility"),
+			SDID:                  viper.GetString("syslogSdId"),
+			Hostname:              viper.GetString("syslogHostname"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("syslogRetryMax"),
+			Overflow:              viper.GetBool("syslogSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("syslogSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "fluentforward", "fluentd":
+		address := viper.GetString("fluentAddress")
+		if address == "" {
+			panic("fluentforward sink specified but fluentAddress not specified")
+		}
+
+		viper.SetDefault("fluentTag", "kubernetes.events")
+		viper.SetDefault("fluentRetryMax", 5)
+		viper.SetDefault("fluentSinkBufferSize", 1500)
+		viper.SetDefault("fluentSinkDiscardMessages", true)
+
+		f, err := NewFluentForwardSink(FluentForwardSinkConfig{
+			Address:               address,
+			Tag:                   viper.GetString("fluentTag"),
+			TLSEnabled:            viper.GetBool("fluentTls"),
+			TLSCAFile:             viper.GetString("fluentTlsCaFile"),
+			TLSCertFile:           viper.GetString("fluentTlsCertFile"),
+			TLSKeyFile:            viper.GetString("fluentTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("fluentTlsInsecureSkipVerify"),
+			SharedKey:             viper.GetString("fluentSharedKey"),
+			Username:              viper.GetString("fluentUsername"),
+			Password:              viper.GetString("fluentPassword"),
+			RequireAck:            viper.GetBool("fluentRequireAck"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("fluentRetryMax"),
+			Overflow:              viper.GetBool("fluentSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("fluentSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(f.Run)
+		return f
+	case "otlp":
+		endpoint := viper.GetString("otlpEndpoint")
+		if endpoint == "" {
+			panic("otlp sink specified but otlpEndpoint not specified")
+		}
+
+		viper.SetDefault("otlpProtocol", "grpc")
+		viper.SetDefault("otlpBatchSize", 512)
+		viper.SetDefault("otlpFlushInterval", "1s")
+		viper.SetDefault("otlpRetryMax", 5)
+		viper.SetDefault("otlpSinkBufferSize", 1500)
+		viper.SetDefault("otlpSinkDiscardMessages", true)
+
+		o, err := NewOTLPSink(OTLPSinkConfig{
+			Protocol:              viper.GetString("otlpProtocol"),
+			Endpoint:              endpoint,
+			Headers:               viper.GetStringMapString("otlpHeaders"),
+			Gzip:                  viper.GetBool("otlpGzip"),
+			TLSEnabled:            viper.GetBool("otlpTls"),
+			TLSCAFile:             viper.GetString("otlpTlsCaFile"),
+			TLSCertFile:           viper.GetString("otlpTlsCertFile"),
+			TLSKeyFile:            viper.GetString("otlpTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("otlpTlsInsecureSkipVerify"),
+			ClusterName:           viper.GetString("clusterName"),
+			BatchSize:             viper.GetInt("otlpBatchSize"),
+			FlushInterval:         viper.GetDuration("otlpFlushInterval"),
+			RetryMax:              viper.GetInt("otlpRetryMax"),
+			Overflow:              viper.GetBool("otlpSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("otlpSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(o.Run)
+		return o
+	case "sentry":
+		dsn := viper.GetString("sentryDsn")
+		if dsn == "" {
+			panic("sentry sink specified but sentryDsn not specified")
+		}
+
+		viper.SetDefault("sentrySampleRate", 1.0)
+		viper.SetDefault("sentrySinkBufferSize", 1500)
+		viper.SetDefault("sentrySinkDiscardMessages", true)
+
+		s, err := NewSentrySink(SentrySinkConfig{
+			DSN:         dsn,
+			Environment: viper.GetString("sentryEnvironment"),
+			Release:     viper.GetString("sentryRelease"),
+			SampleRate:  viper.GetFloat64("sentrySampleRate"),
+			Reasons:     viper.GetStringSlice("sentryReasons"),
+			Namespaces:  viper.GetStringSlice("sentryNamespaces"),
+			ClusterName: viper.GetString("clusterName"),
+			Overflow:    viper.GetBool("sentrySinkDiscardMessages"),
+			BufferSize:  viper.GetInt("sentrySinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "gelf":
+		address := viper.GetString("gelfAddress")
+		if address == "" {
+			panic("gelf sink specified but gelfAddress not specified")
+		}
+
+		viper.SetDefault("gelfNetwork", "udp")
+		viper.SetDefault("gelfChunkSize", 1420)
+		viper.SetDefault("gelfRetryMax", 5)
+		viper.SetDefault("gelfSinkBufferSize", 1500)
+		viper.SetDefault("gelfSinkDiscardMessages", true)
+
+		g, err := NewGELFSink(GELFSinkConfig{
+			Network:               viper.GetString("gelfNetwork"),
+			Address:               address,
+			TLSCAFile:             viper.GetString("gelfTlsCaFile"),
+			TLSCertFile:           viper.GetString("gelfTlsCertFile"),
+			TLSKeyFile:            viper.GetString("gelfTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("gelfTlsInsecureSkipVerify"),
+			ChunkSize:             viper.GetInt("gelfChunkSize"),
+			Compress:              viper.GetBool("gelfCompress"),
+			Host:                  viper.GetString("gelfHost"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("gelfRetryMax"),
+			Overflow:              viper.GetBool("gelfSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("gelfSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(g.Run)
+		return g
+	case "file":
+		path := viper.GetString("filePath")
+		if path == "" {
+			panic("file sink specified but filePath not specified")
+		}
+
+		viper.SetDefault("fileMaxSizeBytes", 100<<20)
+		viper.SetDefault("fileRotateInterval", "24h")
+		viper.SetDefault("fileCompress", true)
+		viper.SetDefault("fileMaxBackups", 7)
+		viper.SetDefault("fileSinkBufferSize", 1500)
+		viper.SetDefault("fileSinkDiscardMessages", true)
+
+		f, err := NewFileSink(FileSinkConfig{
+			Path:           path,
+			MaxSizeBytes:   viper.GetInt64("fileMaxSizeBytes"),
+			RotateInterval: viper.GetDuration("fileRotateInterval"),
+			Compress:       viper.GetBool("fileCompress"),
+			MaxBackups:     viper.GetInt("fileMaxBackups"),
+			Overflow:       viper.GetBool("fileSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("fileSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(f.Run)
+		return f
+	case "unixsocket":
+		path := viper.GetString("unixSocketPath")
+		if path == "" {
+			panic("unixsocket sink specified but unixSocketPath not specified")
+		}
+
+		viper.SetDefault("unixSocketMaxPending", 10000)
+		viper.SetDefault("unixSocketReconnectInterval", "5s")
+		viper.SetDefault("unixSocketSinkBufferSize", 1500)
+		viper.SetDefault("unixSocketSinkDiscardMessages", true)
+
+		u, err := NewUnixSocketSink(UnixSocketSinkConfig{
+			Path:              path,
+			MaxPending:        viper.GetInt("unixSocketMaxPending"),
+			ReconnectInterval: viper.GetDuration("unixSocketReconnectInterval"),
+			Overflow:          viper.GetBool("unixSocketSinkDiscardMessages"),
+			BufferSize:        viper.GetInt("unixSocketSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(u.Run)
+		return u
+	case "snowflake":
+		account := viper.GetString("snowflakeAccount")
+		if account == "" {
+			panic("snowflake sink specified but snowflakeAccount not specified")
+		}
+		user := viper.GetString("snowflakeUser")
+		if user == "" {
+			panic("snowflake sink specified but snowflakeUser not specified")
+		}
+		keyFile := viper.GetString("snowflakePrivateKeyFile")
+		if keyFile == "" {
+			panic("snowflake sink specified but snowflakePrivateKeyFile not specified")
+		}
+		table := viper.GetString("snowflakeTable")
+		if table == "" && viper.GetString("snowflakePipe") == "" {
+			panic("snowflake sink specified but snowflakeTable not specified")
+		}
+
+		viper.SetDefault("snowflakeDatabase", "EVENTROUTER")
+		viper.SetDefault("snowflakeSchema", "PUBLIC")
+		viper.SetDefault("snowflakeChannel", "eventrouter-"+viper.GetString("clusterName"))
+		viper.SetDefault("snowflakeRetryMax", 5)
+		viper.SetDefault("snowflakeSinkBufferSize", 1500)
+		viper.SetDefault("snowflakeSinkDiscardMessages", true)
+
+		s, err := NewSnowflakeSink(SnowflakeSinkConfig{
+			Account:        account,
+			URL:            viper.GetString("snowflakeUrl"),
+			User:           user,
+			PrivateKeyFile: keyFile,
+			Database:       viper.GetString("snowflakeDatabase"),
+			Schema:         viper.GetString("snowflakeSchema"),
+			Table:          table,
+			Pipe:           viper.GetString("snowflakePipe"),
+			Channel:        viper.GetString("snowflakeChannel"),
+			ClusterName:    viper.GetString("clusterName"),
+			RetryMax:       viper.GetInt("snowflakeRetryMax"),
+			Overflow:       viper.GetBool("snowflakeSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("snowflakeSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "vector":
+		address := viper.GetString("vectorAddress")
+		if address == "" {
+			panic("vector sink specified but vectorAddress not specified")
+		}
+
+		viper.SetDefault("vectorTimeout", "60s")
+		viper.SetDefault("vectorRetryMax", 5)
+		viper.SetDefault("vectorSinkBufferSize", 1500)
+		viper.SetDefault("vectorSinkDiscardMessages", true)
+
+		v, err := NewVectorSink(VectorSinkConfig{
+			Address:               address,
+			TLSEnabled:            viper.GetBool("vectorTls"),
+			TLSCAFile:             viper.GetString("vectorTlsCaFile"),
+			TLSCertFile:           viper.GetString("vectorTlsCertFile"),
+			TLSKeyFile:            viper.GetString("vectorTlsKeyFile"),
+			TLSInsecureSkipVerify: viper.GetBool("vectorTlsInsecureSkipVerify"),
+			Compression:           viper.GetBool("vectorCompression"),
+			Timeout:               viper.GetDuration("vectorTimeout"),
+			ClusterName:           viper.GetString("clusterName"),
+			RetryMax:              viper.GetInt("vectorRetryMax"),
+			Overflow:              viper.GetBool("vectorSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("vectorSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(v.Run)
+		return v
+	case "parquet":
+		bucket := viper.GetString("parquetBucket")
+		if bucket == "" {
+			panic("parquet sink specified but parquetBucket not specified")
+		}
+
+		viper.SetDefault("parquetStore", "s3")
+		viper.SetDefault("parquetCompression", "snappy")
+		viper.SetDefault("parquetFlushEvents", 100000)
+		viper.SetDefault("parquetFlushInterval", "15m")
+		viper.SetDefault("parquetRetryMax", 3)
+		viper.SetDefault("parquetSinkBufferSize", 1500)
+		viper.SetDefault("parquetSinkDiscardMessages", true)
+
+		region := viper.GetString("parquetRegion")
+		if region == "" {
+			region = viper.GetString("awsRegion")
+		}
+		p, err := NewParquetSink(ParquetSinkConfig{
+			Store:                 viper.GetString("parquetStore"),
+			Bucket:                bucket,
+			Prefix:                viper.GetString("parquetPrefix"),
+			Region:                region,
+			AzureAccountURL:       viper.GetString("parquetAzureAccountUrl"),
+			AzureConnectionString: viper.GetString("parquetAzureConnectionString"),
+			AzureTLS:              azureTLSConfigFromViper(),
+			HivePartitioning:      viper.GetBool("parquetHivePartitioning"),
+			Compression:           viper.GetString("parquetCompression"),
+			ClusterName:           viper.GetString("clusterName"),
+			FlushEvents:           viper.GetInt("parquetFlushEvents"),
+			FlushInterval:         viper.GetDuration("parquetFlushInterval"),
+			RetryMax:              viper.GetInt("parquetRetryMax"),
+			Overflow:              viper.GetBool("parquetSinkDiscardMessages"),
+			BufferSize:            viper.GetInt("parquetSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(p.Run)
+		return p
+	case "jira":
+		jiraURL := viper.GetString("jiraUrl")
+		if jiraURL == "" {
+			panic("jira sink specified but jiraUrl not specified")
+		}
+		project := viper.GetString("jiraProject")
+		if project == "" {
+			panic("jira sink specified but jiraProject not specified")
+		}
+		reasons := viper.GetStringSlice("jiraReasons")
+		if len(reasons) == 0 {
+			panic("jira sink specified but jiraReasons not specified")
+		}
+
+		viper.SetDefault("jiraIssueType", "Bug")
+		viper.SetDefault("jiraMinDuration", "10m")
+		viper.SetDefault("jiraCommentInterval", "1h")
+		viper.SetDefault("jiraRetryMax", 5)
+		viper.SetDefault("jiraSinkBufferSize", 1500)
+		viper.SetDefault("jiraSinkDiscardMessages", true)
+
+		j := NewJiraSink(JiraSinkConfig{
+			URL:                 jiraURL,
+			Email:               viper.GetString("jiraEmail"),
+			APIToken:            viper.GetString("jiraApiToken"),
+			Token:               viper.GetString("jiraToken"),
+			Reasons:             reasons,
+			MinDuration:         viper.GetDuration("jiraMinDuration"),
+			Project:             project,
+			IssueType:           viper.GetString("jiraIssueType"),
+			NamespaceProjects:   viper.GetStringMapString("jiraNamespaceProjects"),
+			NamespaceIssueTypes: viper.GetStringMapString("jiraNamespaceIssueTypes"),
+			Labels:              viper.GetStringSlice("jiraLabels"),
+			CommentInterval:     viper.GetDuration("jiraCommentInterval"),
+			ClusterName:         viper.GetString("clusterName"),
+			RetryMax:            viper.GetInt("jiraRetryMax"),
+			Overflow:            viper.GetBool("jiraSinkDiscardMessages"),
+			BufferSize:          viper.GetInt("jiraSinkBufferSize"),
+		})
+		runSink(j.Run)
+		return j
+	case "servicenow":
+		instanceURL := viper.GetString("servicenowUrl")
+		if instanceURL == "" {
+			panic("servicenow sink specified but servicenowUrl not specified")
+		}
+
+		viper.SetDefault("servicenowUrgency", "3")
+		viper.SetDefault("servicenowImpact", "3")
+		viper.SetDefault("servicenowUpdateInterval", "1h")
+		viper.SetDefault("servicenowRetryMax", 5)
+		viper.SetDefault("servicenowSinkBufferSize", 1500)
+		viper.SetDefault("servicenowSinkDiscardMessages", true)
+
+		s := NewServiceNowSink(ServiceNowSinkConfig{
+			URL:              instanceURL,
+			Username:         viper.GetString("servicenowUsername"),
+			Password:         viper.GetString("servicenowPassword"),
+			Token:            viper.GetString("servicenowToken"),
+			Reasons:          viper.GetStringSlice("servicenowReasons"),
+			Namespaces:       viper.GetStringSlice("servicenowNamespaces"),
+			AssignmentGroups: viper.GetStringMapString("servicenowAssignmentGroups"),
+			AssignmentGroup:  viper.GetString("servicenowAssignmentGroup"),
+			Urgencies:        viper.GetStringMapString("servicenowUrgencies"),
+			Urgency:          viper.GetString("servicenowUrgency"),
+			Impact:           viper.GetString("servicenowImpact"),
+			Category:         viper.GetString("servicenowCategory"),
+			CallerID:         viper.GetString("servicenowCallerId"),
+			UpdateInterval:   viper.GetDuration("servicenowUpdateInterval"),
+			ClusterName:      viper.GetString("clusterName"),
+			RetryMax:         viper.GetInt("servicenowRetryMax"),
+			Overflow:         viper.GetBool("servicenowSinkDiscardMessages"),
+			BufferSize:       viper.GetInt("servicenowSinkBufferSize"),
+		})
+		runSink(s.Run)
+		return s
+	case "mattermost":
+		webhookURL := viper.GetString("mattermostWebhookUrl")
+		if webhookURL == "" {
+			panic("mattermost sink specified but mattermostWebhookUrl not specified")
+		}
+
+		viper.SetDefault("mattermostUsername", "eventrouter")
+		viper.SetDefault("mattermostTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("mattermostFlushInterval", "30s")
+		viper.SetDefault("mattermostDedupWindow", "10m")
+		viper.SetDefault("mattermostRetryMax", 3)
+		viper.SetDefault("mattermostSinkBufferSize", 1500)
+		viper.SetDefault("mattermostSinkDiscardMessages", true)
+
+		m, err := NewMattermostSink(MattermostSinkConfig{
+			WebhookURL:        webhookURL,
+			Channel:           viper.GetString("mattermostChannel"),
+			NamespaceChannels: viper.GetStringMapString("mattermostNamespaceChannels"),
+			Username:          viper.GetString("mattermostUsername"),
+			IconURL:           viper.GetString("mattermostIconUrl"),
+			Types:             viper.GetStringSlice("mattermostTypes"),
+			Reasons:           viper.GetStringSlice("mattermostReasons"),
+			Namespaces:        viper.GetStringSlice("mattermostNamespaces"),
+			FlushInterval:     viper.GetDuration("mattermostFlushInterval"),
+			DedupWindow:       viper.GetDuration("mattermostDedupWindow"),
+			ClusterName:       viper.GetString("clusterName"),
+			RetryMax:          viper.GetInt("mattermostRetryMax"),
+			Overflow:          viper.GetBool("mattermostSinkDiscardMessages"),
+			BufferSize:        viper.GetInt("mattermostSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(m.Run)
+		return m
+	case "matrix":
+		homeserverURL := viper.GetString("matrixHomeserverUrl")
+		if homeserverURL == "" {
+			panic("matrix sink specified but matrixHomeserverUrl not specified")
+		}
+		accessToken := viper.GetString("matrixAccessToken")
+		if accessToken == "" {
+			panic("matrix sink specified but matrixAccessToken not specified")
+		}
+		roomID := viper.GetString("matrixRoomId")
+		if roomID == "" {
+			panic("matrix sink specified but matrixRoomId not specified")
+		}
+
+		viper.SetDefault("matrixMsgType", "m.notice")
+		viper.SetDefault("matrixTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("matrixDedupWindow", "10m")
+		viper.SetDefault("matrixRetryMax", 5)
+		viper.SetDefault("matrixSinkBufferSize", 1500)
+		viper.SetDefault("matrixSinkDiscardMessages", true)
+
+		m := NewMatrixSink(MatrixSinkConfig{
+			HomeserverURL: homeserverURL,
+			AccessToken:   accessToken,
+			RoomID:        roomID,
+			MsgType:       viper.GetString("matrixMsgType"),
+			Types:         viper.GetStringSlice("matrixTypes"),
+			Reasons:       viper.GetStringSlice("matrixReasons"),
+			Namespaces:    viper.GetStringSlice("matrixNamespaces"),
+			DedupWindow:   viper.GetDuration("matrixDedupWindow"),
+			ClusterName:   viper.GetString("clusterName"),
+			RetryMax:      viper.GetInt("matrixRetryMax"),
+			Overflow:      viper.GetBool("matrixSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("matrixSinkBufferSize"),
+		})
+		runSink(m.Run)
+		return m
+	case "cloudevents":
+		sinkURL := viper.GetString("cloudEventsUrl")
+		if sinkURL == "" {
+			panic("cloudevents sink specified but cloudEventsUrl not specified")
+		}
+
+		viper.SetDefault("cloudEventsMode", "binary")
+		viper.SetDefault("cloudEventsType", "io.k8s.event")
+		viper.SetDefault("cloudEventsRetryMax", 5)
+		viper.SetDefault("cloudEventsSinkBufferSize", 1500)
+		viper.SetDefault("cloudEventsSinkDiscardMessages", true)
+
+		c, err := NewCloudEventsSink(CloudEventsSinkConfig{
+			URL:         sinkURL,
+			Mode:        viper.GetString("cloudEventsMode"),
+			Type:        viper.GetString("cloudEventsType"),
+			BearerToken: viper.GetString("cloudEventsBearerToken"),
+			ClusterName: viper.GetString("clusterName"),
+			RetryMax:    viper.GetInt("cloudEventsRetryMax"),
+			Overflow:    viper.GetBool("cloudEventsSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("cloudEventsSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(c.Run)
+		return c
+	case "victorialogs":
+		vlURL := viper.GetString("victoriaLogsUrl")
+		if vlURL == "" {
+			panic("victorialogs sink specified but victoriaLogsUrl not specified")
+		}
+
+		viper.SetDefault("victoriaLogsFlushEvents", 1000)
+		viper.SetDefault("victoriaLogsFlushInterval", "5s")
+		viper.SetDefault("victoriaLogsRetryMax", 5)
+		viper.SetDefault("victoriaLogsSinkBufferSize", 1500)
+		viper.SetDefault("victoriaLogsSinkDiscardMessages", true)
+
+		v, err := NewVictoriaLogsSink(VictoriaLogsSinkConfig{
+			URL:           vlURL,
+			AccountID:     viper.GetString("victoriaLogsAccountId"),
+			ProjectID:     viper.GetString("victoriaLogsProjectId"),
+			Username:      viper.GetString("victoriaLogsUsername"),
+			Password:      viper.GetString("victoriaLogsPassword"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("victoriaLogsFlushEvents"),
+			FlushInterval: viper.GetDuration("victoriaLogsFlushInterval"),
+			RetryMax:      viper.GetInt("victoriaLogsRetryMax"),
+			Overflow:      viper.GetBool("victoriaLogsSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("victoriaLogsSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(v.Run)
+		return v
+	case "quickwit":
+		qwURL := viper.GetString("quickwitUrl")
+		if qwURL == "" {
+			panic("quickwit sink specified but quickwitUrl not specified")
+		}
+
+		viper.SetDefault("quickwitIndex", "kubernetes-events")
+		viper.SetDefault("quickwitCommit", "auto")
+		viper.SetDefault("quickwitFlushEvents", 5000)
+		viper.SetDefault("quickwitFlushInterval", "10s")
+		viper.SetDefault("quickwitRetryMax", 5)
+		viper.SetDefault("quickwitSinkBufferSize", 1500)
+		viper.SetDefault("quickwitSinkDiscardMessages", true)
+
+		q, err := NewQuickwitSink(QuickwitSinkConfig{
+			URL:           qwURL,
+			Index:         viper.GetString("quickwitIndex"),
+			Commit:        viper.GetString("quickwitCommit"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("quickwitFlushEvents"),
+			FlushInterval: viper.GetDuration("quickwitFlushInterval"),
+			RetryMax:      viper.GetInt("quickwitRetryMax"),
+			Overflow:      viper.GetBool("quickwitSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("quickwitSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(q.Run)
+		return q
+	case "logscale":
+		lsURL := viper.GetString("logScaleUrl")
+		if lsURL == "" {
+			panic("logscale sink specified but logScaleUrl not specified")
+		}
+		token := viper.GetString("logScaleToken")
+		if token == "" {
+			panic("logscale sink specified but logScaleToken not specified")
+		}
+
+		viper.SetDefault("logScaleApi", "structured")
+		viper.SetDefault("logScaleFlushEvents", 1000)
+		viper.SetDefault("logScaleFlushInterval", "5s")
+		viper.SetDefault("logScaleRetryMax", 5)
+		viper.SetDefault("logScaleSinkBufferSize", 1500)
+		viper.SetDefault("logScaleSinkDiscardMessages", true)
+
+		l, err := NewLogScaleSink(LogScaleSinkConfig{
+			URL:           lsURL,
+			Token:         token,
+			API:           viper.GetString("logScaleApi"),
+			Tags:          viper.GetStringMapString("logScaleTags"),
+			SourceType:    viper.GetString("logScaleSourceType"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("logScaleFlushEvents"),
+			FlushInterval: viper.GetDuration("logScaleFlushInterval"),
+			RetryMax:      viper.GetInt("logScaleRetryMax"),
+			Overflow:      viper.GetBool("logScaleSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("logScaleSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(l.Run)
+		return l
+	case "sumologic":
+		sumoURL := viper.GetString("sumoLogicUrl")
+		if sumoURL == "" {
+			panic("sumologic sink specified but sumoLogicUrl not specified")
+		}
+
+		viper.SetDefault("sumoLogicFlushEvents", 1000)
+		viper.SetDefault("sumoLogicFlushInterval", "5s")
+		viper.SetDefault("sumoLogicRetryMax", 5)
+		viper.SetDefault("sumoLogicSinkBufferSize", 1500)
+		viper.SetDefault("sumoLogicSinkDiscardMessages", true)
+
+		s, err := NewSumoLogicSink(SumoLogicSinkConfig{
+			URL:           sumoURL,
+			Category:      viper.GetString("sumoLogicCategory"),
+			Host:          viper.GetString("sumoLogicHost"),
+			Name:          viper.GetString("sumoLogicName"),
+			Fields:        viper.GetStringMapString("sumoLogicFields"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("sumoLogicFlushEvents"),
+			FlushInterval: viper.GetDuration("sumoLogicFlushInterval"),
+			RetryMax:      viper.GetInt("sumoLogicRetryMax"),
+			Overflow:      viper.GetBool("sumoLogicSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("sumoLogicSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "logzio":
+		token := viper.GetString("logzioToken")
+		if token == "" {
+			panic("logzio sink specified but logzioToken not specified")
+		}
+
+		viper.SetDefault("logzioRegion", "us")
+		viper.SetDefault("logzioType", "eventrouter")
+		viper.SetDefault("logzioFlushEvents", 1000)
+		viper.SetDefault("logzioFlushInterval", "5s")
+		viper.SetDefault("logzioRetryMax", 5)
+		viper.SetDefault("logzioSinkBufferSize", 1500)
+		viper.SetDefault("logzioSinkDiscardMessages", true)
+
+		l, err := NewLogzioSink(LogzioSinkConfig{
+			Token:         token,
+			Region:        viper.GetString("logzioRegion"),
+			ListenerURL:   viper.GetString("logzioListenerUrl"),
+			Type:          viper.GetString("logzioType"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("logzioFlushEvents"),
+			FlushInterval: viper.GetDuration("logzioFlushInterval"),
+			RetryMax:      viper.GetInt("logzioRetryMax"),
+			Overflow:      viper.GetBool("logzioSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("logzioSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(l.Run)
+		return l
+	case "axiom":
+		dataset := viper.GetString("axiomDataset")
+		if dataset == "" {
+			panic("axiom sink specified but axiomDataset not specified")
+		}
+		token := viper.GetString("axiomToken")
+		if token == "" {
+			panic("axiom sink specified but axiomToken not specified")
+		}
+
+		viper.SetDefault("axiomUrl", "https://api.axiom.co")
+		viper.SetDefault("axiomFlushEvents", 1000)
+		viper.SetDefault("axiomFlushInterval", "5s")
+		viper.SetDefault("axiomRetryMax", 5)
+		viper.SetDefault("axiomSinkBufferSize", 1500)
+		viper.SetDefault("axiomSinkDiscardMessages", true)
+
+		a, err := NewAxiomSink(AxiomSinkConfig{
+			URL:           viper.GetString("axiomUrl"),
+			Dataset:       dataset,
+			Token:         token,
+			OrgID:         viper.GetString("axiomOrgId"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("axiomFlushEvents"),
+			FlushInterval: viper.GetDuration("axiomFlushInterval"),
+			RetryMax:      viper.GetInt("axiomRetryMax"),
+			Overflow:      viper.GetBool("axiomSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("axiomSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(a.Run)
+		return a
+	case "coralogix":
+		privateKey := viper.GetString("coralogixPrivateKey")
+		if privateKey == "" {
+			panic("coralogix sink specified but coralogixPrivateKey not specified")
+		}
+
+		viper.SetDefault("coralogixDomain", "coralogix.com")
+		viper.SetDefault("coralogixSubsystemName", "cluster")
+		viper.SetDefault("coralogixFlushEvents", 1000)
+		viper.SetDefault("coralogixFlushInterval", "5s")
+		viper.SetDefault("coralogixRetryMax", 5)
+		viper.SetDefault("coralogixSinkBufferSize", 1500)
+		viper.SetDefault("coralogixSinkDiscardMessages", true)
+
+		c, err := NewCoralogixSink(CoralogixSinkConfig{
+			Domain:          viper.GetString("coralogixDomain"),
+			PrivateKey:      privateKey,
+			ApplicationName: viper.GetString("coralogixApplicationName"),
+			SubsystemName:   viper.GetString("coralogixSubsystemName"),
+			ClusterName:     viper.GetString("clusterName"),
+			FlushEvents:     viper.GetInt("coralogixFlushEvents"),
+			FlushInterval:   viper.GetDuration("coralogixFlushInterval"),
+			RetryMax:        viper.GetInt("coralogixRetryMax"),
+			Overflow:        viper.GetBool("coralogixSinkDiscardMessages"),
+			BufferSize:      viper.GetInt("coralogixSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(c.Run)
+		return c
+	case "alertmanager":
+		urls := viper.GetStringSlice("alertmanagerUrls")
+		if len(urls) == 0 {
+			panic("alertmanager sink specified but alertmanagerUrls not specified")
+		}
+
+		viper.SetDefault("alertmanagerAlertTTL", "1h")
+		viper.SetDefault("alertmanagerRetryMax", 5)
+		viper.SetDefault("alertmanagerSinkBufferSize", 1500)
+		viper.SetDefault("alertmanagerSinkDiscardMessages", true)
+
+		a := NewAlertmanagerSink(AlertmanagerSinkConfig{
+			URLs:           urls,
+			BearerToken:    viper.GetString("alertmanagerBearerToken"),
+			Reasons:        viper.GetStringSlice("alertmanagerReasons"),
+			ResolveReasons: viper.GetStringMapString("alertmanagerResolveReasons"),
+			AlertTTL:       viper.GetDuration("alertmanagerAlertTTL"),
+			Labels:         viper.GetStringMapString("alertmanagerLabels"),
+			ClusterName:    viper.GetString("clusterName"),
+			RetryMax:       viper.GetInt("alertmanagerRetryMax"),
+			Overflow:       viper.GetBool("alertmanagerSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("alertmanagerSinkBufferSize"),
+		})
+		runSink(a.Run)
+		return a
+	case "grafana":
+		grafanaURL := viper.GetString("grafanaUrl")
+		if grafanaURL == "" {
+			panic("grafana sink specified but grafanaUrl not specified")
+		}
+		token := viper.GetString("grafanaToken")
+		if token == "" {
+			panic("grafana sink specified but grafanaToken not specified")
+		}
+
+		viper.SetDefault("grafanaDedupWindow", "10m")
+		viper.SetDefault("grafanaRetryMax", 5)
+		viper.SetDefault("grafanaSinkBufferSize", 1500)
+		viper.SetDefault("grafanaSinkDiscardMessages", true)
+
+		g := NewGrafanaAnnotationSink(GrafanaAnnotationSinkConfig{
+			URL:          grafanaURL,
+			Token:        token,
+			OrgID:        viper.GetString("grafanaOrgId"),
+			DashboardUID: viper.GetString("grafanaDashboardUid"),
+			PanelID:      viper.GetInt64("grafanaPanelId"),
+			Types:        viper.GetStringSlice("grafanaTypes"),
+			Reasons:      viper.GetStringSlice("grafanaReasons"),
+			Namespaces:   viper.GetStringSlice("grafanaNamespaces"),
+			Tags:         viper.GetStringSlice("grafanaTags"),
+			DedupWindow:  viper.GetDuration("grafanaDedupWindow"),
+			ClusterName:  viper.GetString("clusterName"),
+			RetryMax:     viper.GetInt("grafanaRetryMax"),
+			Overflow:     viper.GetBool("grafanaSinkDiscardMessages"),
+			BufferSize:   viper.GetInt("grafanaSinkBufferSize"),
+		})
+		runSink(g.Run)
+		return g
+	case "statsd", "dogstatsd":
+		address := viper.GetString("statsdAddress")
+		if address == "" {
+			panic("statsd sink specified but statsdAddress not specified")
+		}
+
+		viper.SetDefault("statsdNetwork", "udp")
+		viper.SetDefault("statsdFlavor", s)
+		viper.SetDefault("statsdPrefix", "k8s")
+		viper.SetDefault("statsdSinkBufferSize", 1500)
+		viper.SetDefault("statsdSinkDiscardMessages", true)
+
+		st, err := NewStatsdSink(StatsdSinkConfig{
+			Network:     viper.GetString("statsdNetwork"),
+			Address:     address,
+			Flavor:      viper.GetString("statsdFlavor"),
+			Prefix:      viper.GetString("statsdPrefix"),
+			Tags:        viper.GetStringSlice("statsdTags"),
+			ClusterName: viper.GetString("clusterName"),
+			Overflow:    viper.GetBool("statsdSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("statsdSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(st.Run)
+		return st
+	case "null", "blackhole":
+		viper.SetDefault("nullRetryMax", 0)
+		viper.SetDefault("nullStatsInterval", "1m")
+		viper.SetDefault("nullSinkBufferSize", 1500)
+		viper.SetDefault("nullSinkDiscardMessages", true)
+
+		n := NewNullSink(NullSinkConfig{
+			Latency:       viper.GetDuration("nullLatency"),
+			Jitter:        viper.GetDuration("nullJitter"),
+			ErrorRate:     viper.GetFloat64("nullErrorRate"),
+			RetryMax:      viper.GetInt("nullRetryMax"),
+			StatsInterval: viper.GetDuration("nullStatsInterval"),
+			Overflow:      viper.GetBool("nullSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("nullSinkBufferSize"),
+		})
+		runSink(n.Run)
+		return n
+	case "multi", "tee":
+		names := viper.GetStringSlice("multiSinks")
+		if len(names) == 0 {
+			panic("multi sink specified but multiSinks not specified")
+		}
+
+		viper.SetDefault("multiSinkBufferSize", 1500)
+		viper.SetDefault("multiSinkDiscardMessages", true)
+
+		m := manufactureMultiSink(names, viper.GetBool("multiSinkDiscardMessages"), viper.GetInt("multiSinkBufferSize"))
+		runSink(m.Run)
+		return m
+	case "websocket":
+		viper.SetDefault("webSocketListenAddress", ":8081")
+		viper.SetDefault("webSocketPath", "/events/ws")
+		viper.SetDefault("webSocketClientBuffer", 256)
+		viper.SetDefault("webSocketSinkBufferSize", 1500)
+		viper.SetDefault("webSocketSinkDiscardMessages", true)
+
+		w := NewWebSocketSink(WebSocketSinkConfig{
+			ListenAddress:  viper.GetString("webSocketListenAddress"),
+			Path:           viper.GetString("webSocketPath"),
+			Token:          viper.GetString("webSocketToken"),
+			AllowedOrigins: viper.GetStringSlice("webSocketAllowedOrigins"),
+			ClientBuffer:   viper.GetInt("webSocketClientBuffer"),
+			Overflow:       viper.GetBool("webSocketSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("webSocketSinkBufferSize"),
+		})
+		runSink(w.Run)
+		return w
+	case "sse":
+		viper.SetDefault("sseListenAddress", ":8082")
+		viper.SetDefault("sseHistorySize", 1000)
+		viper.SetDefault("sseClientBuffer", 256)
+		viper.SetDefault("sseSinkBufferSize", 1500)
+		viper.SetDefault("sseSinkDiscardMessages", true)
+
+		s := NewSSESink(SSESinkConfig{
+			ListenAddress: viper.GetString("sseListenAddress"),
+			Token:         viper.GetString("sseToken"),
+			HistorySize:   viper.GetInt("sseHistorySize"),
+			ClientBuffer:  viper.GetInt("sseClientBuffer"),
+			Overflow:      viper.GetBool("sseSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("sseSinkBufferSize"),
+		})
+		runSink(s.Run)
+		return s
+	case "timestream":
+		database := viper.GetString("timestreamDatabase")
+		if database == "" {
+			panic("timestream sink specified but timestreamDatabase not specified")
+		}
+		table := viper.GetString("timestreamTable")
+		if table == "" {
+			panic("timestream sink specified but timestreamTable not specified")
+		}
+
+		viper.SetDefault("timestreamMeasureName", "event")
+		viper.SetDefault("timestreamFlushEvents", 100)
+		viper.SetDefault("timestreamFlushInterval", "5s")
+		viper.SetDefault("timestreamRetryMax", 3)
+		viper.SetDefault("timestreamSinkBufferSize", 1500)
+		viper.SetDefault("timestreamSinkDiscardMessages", true)
+
+		t, err := NewTimestreamSink(TimestreamSinkConfig{
+			Database:      database,
+			Table:         table,
+			MeasureName:   viper.GetString("timestreamMeasureName"),
+			Region:        viper.GetString("awsRegion"),
+			ClusterName:   viper.GetString("clusterName"),
+			FlushEvents:   viper.GetInt("timestreamFlushEvents"),
+			FlushInterval: viper.GetDuration("timestreamFlushInterval"),
+			RetryMax:      viper.GetInt("timestreamRetryMax"),
+			Overflow:      viper.GetBool("timestreamSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("timestreamSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(t.Run)
+		return t
+	case "kafkarest":
+		restURL := viper.GetString("kafkaRestUrl")
+		if restURL == "" {
+			panic("kafkarest sink specified but kafkaRestUrl not specified")
+		}
+
+		viper.SetDefault("kafkaRestTopic", "eventrouter")
+		viper.SetDefault("kafkaRestApiVersion", "v2")
+		viper.SetDefault("kafkaRestKeyBy", "uid")
+		viper.SetDefault("kafkaRestRetryMax", 5)
+		viper.SetDefault("kafkaRestSinkBufferSize", 1500)
+		viper.SetDefault("kafkaRestSinkDiscardMessages", true)
+
+		k, err := NewKafkaRESTSink(KafkaRESTSinkConfig{
+			URL:        restURL,
+			Topic:      viper.GetString("kafkaRestTopic"),
+			APIVersion: viper.GetString("kafkaRestApiVersion"),
+			ClusterID:  viper.GetString("kafkaRestClusterId"),
+			KeyBy:      viper.GetString("kafkaRestKeyBy"),
+			Username:   viper.GetString("kafkaRestUsername"),
+			Password:   viper.GetString("kafkaRestPassword"),
+			RetryMax:   viper.GetInt("kafkaRestRetryMax"),
+			Overflow:   viper.GetBool("kafkaRestSinkDiscardMessages"),
+			BufferSize: viper.GetInt("kafkaRestSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(k.Run)
+		return k
+	case "eventgrid":
+		endpoint := viper.GetString("eventGridEndpoint")
+		if endpoint == "" {
+			panic("eventgrid sink specified but eventGridEndpoint not specified")
+		}
+
+		viper.SetDefault("eventGridSchema", "cloudevents")
+		viper.SetDefault("eventGridDefaultTopic", "cluster")
+		viper.SetDefault("eventGridEventType", "io.k8s.event")
+		viper.SetDefault("eventGridRetryMax", 5)
+		viper.SetDefault("eventGridSinkBufferSize", 1500)
+		viper.SetDefault("eventGridSinkDiscardMessages", true)
+
+		g, err := NewEventGridSink(EventGridSinkConfig{
+			Endpoint:        endpoint,
+			Schema:          viper.GetString("eventGridSchema"),
+			Key:             viper.GetString("eventGridKey"),
+			Domain:          viper.GetBool("eventGridDomain"),
+			NamespaceTopics: viper.GetStringMapString("eventGridNamespaceTopics"),
+			DefaultTopic:    viper.GetString("eventGridDefaultTopic"),
+			EventType:       viper.GetString("eventGridEventType"),
+			TLS:             azureTLSConfigFromViper(),
+			ClusterName:     viper.GetString("clusterName"),
+			RetryMax:        viper.GetInt("eventGridRetryMax"),
+			Overflow:        viper.GetBool("eventGridSinkDiscardMessages"),
+			BufferSize:      viper.GetInt("eventGridSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(g.Run)
+		return g
+	case "googlechat":
+		webhookURL := viper.GetString("googleChatWebhookUrl")
+		if webhookURL == "" {
+			panic("googlechat sink specified but googleChatWebhookUrl not specified")
+		}
+
+		viper.SetDefault("googleChatTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("googleChatDedupWindow", "10m")
+		viper.SetDefault("googleChatRetryMax", 5)
+		viper.SetDefault("googleChatSinkBufferSize", 1500)
+		viper.SetDefault("googleChatSinkDiscardMessages", true)
+
+		g, err := NewGoogleChatSink(GoogleChatSinkConfig{
+			WebhookURL:  webhookURL,
+			Types:       viper.GetStringSlice("googleChatTypes"),
+			Reasons:     viper.GetStringSlice("googleChatReasons"),
+			Namespaces:  viper.GetStringSlice("googleChatNamespaces"),
+			DedupWindow: viper.GetDuration("googleChatDedupWindow"),
+			ClusterName: viper.GetString("clusterName"),
+			RetryMax:    viper.GetInt("googleChatRetryMax"),
+			Overflow:    viper.GetBool("googleChatSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("googleChatSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(g.Run)
+		return g
+	case "webex":
+		botToken := viper.GetString("webexBotToken")
+		if botToken == "" {
+			panic("webex sink specified but webexBotToken not specified")
+		}
+		roomID := viper.GetString("webexRoomId")
+		if roomID == "" {
+			panic("webex sink specified but webexRoomId not specified")
+		}
+
+		viper.SetDefault("webexApiUrl", "https://webexapis.com")
+		viper.SetDefault("webexTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("webexFlushInterval", "30s")
+		viper.SetDefault("webexDedupWindow", "10m")
+		viper.SetDefault("webexRetryMax", 3)
+		viper.SetDefault("webexSinkBufferSize", 1500)
+		viper.SetDefault("webexSinkDiscardMessages", true)
+
+		w, err := NewWebexSink(WebexSinkConfig{
+			APIURL:         viper.GetString("webexApiUrl"),
+			BotToken:       botToken,
+			RoomID:         roomID,
+			NamespaceRooms: viper.GetStringMapString("webexNamespaceRooms"),
+			Types:          viper.GetStringSlice("webexTypes"),
+			Reasons:        viper.GetStringSlice("webexReasons"),
+			Namespaces:     viper.GetStringSlice("webexNamespaces"),
+			FlushInterval:  viper.GetDuration("webexFlushInterval"),
+			DedupWindow:    viper.GetDuration("webexDedupWindow"),
+			ClusterName:    viper.GetString("clusterName"),
+			RetryMax:       viper.GetInt("webexRetryMax"),
+			Overflow:       viper.GetBool("webexSinkDiscardMessages"),
+			BufferSize:     viper.GetInt("webexSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(w.Run)
+		return w
+	case "zabbix":
+		server := viper.GetString("zabbixServer")
+		if server == "" {
+			panic("zabbix sink specified but zabbixServer not specified")
+		}
+		host := viper.GetString("zabbixHost")
+		if host == "" {
+			panic("zabbix sink specified but zabbixHost not specified")
+		}
+
+		viper.SetDefault("zabbixCountKey", "k8s.events.count")
+		viper.SetDefault("zabbixMessageKey", "k8s.events.message")
+		viper.SetDefault("zabbixMessageTypes", []string{v1.EventTypeWarning})
+		viper.SetDefault("zabbixFlushInterval", "1m")
+		viper.SetDefault("zabbixRetryMax", 3)
+		viper.SetDefault("zabbixSinkBufferSize", 1500)
+		viper.SetDefault("zabbixSinkDiscardMessages", true)
+
+		z, err := NewZabbixSink(ZabbixSinkConfig{
+			Server:        server,
+			Host:          host,
+			CountKey:      viper.GetString("zabbixCountKey"),
+			MessageKey:    viper.GetString("zabbixMessageKey"),
+			MessageTypes:  viper.GetStringSlice("zabbixMessageTypes"),
+			FlushInterval: viper.GetDuration("zabbixFlushInterval"),
+			ClusterName:   viper.GetString("clusterName"),
+			RetryMax:      viper.GetInt("zabbixRetryMax"),
+			Overflow:      viper.GetBool("zabbixSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("zabbixSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(z.Run)
+		return z
+	case "sqlite":
+		path := viper.GetString("sqlitePath")
+		if path == "" {
+			panic("sqlite sink specified but sqlitePath not specified")
+		}
+
+		viper.SetDefault("sqliteTable", "k8s_events")
+		viper.SetDefault("sqliteMaxAge", "168h")
+		viper.SetDefault("sqlitePruneInterval", "10m")
+		viper.SetDefault("sqliteSinkBufferSize", 1500)
+		viper.SetDefault("sqliteSinkDiscardMessages", true)
+
+		s, err := NewSQLiteSink(SQLiteSinkConfig{
+			Path:          path,
+			Table:         viper.GetString("sqliteTable"),
+			MaxAge:        viper.GetDuration("sqliteMaxAge"),
+			MaxRows:       viper.GetInt("sqliteMaxRows"),
+			PruneInterval: viper.GetDuration("sqlitePruneInterval"),
+			ClusterName:   viper.GetString("clusterName"),
+			Overflow:      viper.GetBool("sqliteSinkDiscardMessages"),
+			BufferSize:    viper.GetInt("sqliteSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(s.Run)
+		return s
+	case "ocistreaming":
+		viper.SetDefault("ociStreamingProtocol", "native")
+		viper.SetDefault("ociStreamingAuth", "instance_principal")
+		viper.SetDefault("ociStreamingKeyBy", "uid")
+		viper.SetDefault("ociStreamingRetryMax", 5)
+		viper.SetDefault("ociStreamingSinkBufferSize", 1500)
+		viper.SetDefault("ociStreamingSinkDiscardMessages", true)
+
+		if region := viper.GetString("ociStreamingRegion"); region != "" {
+			host := "cell-1.streaming." + region + ".oci.oraclecloud.com"
+			viper.SetDefault("ociStreamingEndpoint", "https://"+host)
+			viper.SetDefault("ociStreamingBrokers", []string{host + ":9092"})
+		}
+
+		switch protocol := viper.GetString("ociStreamingProtocol"); protocol {
+		case "native":
+			streamID := viper.GetString("ociStreamingStreamId")
+			if streamID == "" {
+				panic("ocistreaming sink specified but ociStreamingStreamId not specified")
+			}
+			endpoint := viper.GetString("ociStreamingEndpoint")
+			if endpoint == "" {
+				panic("ocistreaming sink specified but neither ociStreamingEndpoint nor ociStreamingRegion specified")
+			}
+
+			o, err := NewOCIStreamingSink(OCIStreamingSinkConfig{
+				StreamID:   streamID,
+				Endpoint:   endpoint,
+				Auth:       viper.GetString("ociStreamingAuth"),
+				ConfigFile: viper.GetString("ociStreamingConfigFile"),
+				Profile:    viper.GetString("ociStreamingProfile"),
+				KeyBy:      viper.GetString("ociStreamingKeyBy"),
+				RetryMax:   viper.GetInt("ociStreamingRetryMax"),
+				Overflow:   viper.GetBool("ociStreamingSinkDiscardMessages"),
+				BufferSize: viper.GetInt("ociStreamingSinkBufferSize"),
+			})
+			if err != nil {
+				panic(err.Error())
+			}
+			runSink(o.Run)
+			return o
+		case "kafka":
+			// The Kafka-compatible endpoint authenticates with SASL PLAIN as
+			// <tenancy name>/<user name>/<stream pool OCID> and an auth token.
+			streamName := viper.GetString("ociStreamingStreamName")
+			if streamName == "" {
+				panic("ocistreaming sink specified but ociStreamingStreamName not specified")
+			}
+			streamPoolID := viper.GetString("ociStreamingStreamPoolId")
+			if streamPoolID == "" {
+				panic("ocistreaming sink specified but ociStreamingStreamPoolId not specified")
+			}
+			brokers := viper.GetStringSlice("ociStreamingBrokers")
+			if len(brokers) == 0 {
+				panic("ocistreaming sink specified but neither ociStreamingBrokers nor ociStreamingRegion specified")
+			}
+
+			k, err := NewKafkaSink(KafkaSinkConfig{
+				Brokers:       brokers,
+				Topic:         streamName,
+				KeyBy:         viper.GetString("ociStreamingKeyBy"),
+				RetryMax:      viper.GetInt("ociStreamingRetryMax"),
+				Codec:         "json",
+				SASLMechanism: "PLAIN",
+				SASLUser:      viper.GetString("ociStreamingTenancyName") + "/" + viper.GetString("ociStreamingUserName") + "/" + streamPoolID,
+				SASLPassword:  viper.GetString("ociStreamingAuthToken"),
+				TLSEnabled:    true,
+				Overflow:      viper.GetBool("ociStreamingSinkDiscardMessages"),
+				BufferSize:    viper.GetInt("ociStreamingSinkBufferSize"),
+			})
+			if err != nil {
+				panic(err.Error())
+			}
+			runSink(k.Run)
+			return k
+		default:
+			panic("invalid ociStreamingProtocol " + protocol + ", expected native or kafka")
+		}
+	case "rocketmq":
+		nameServers := viper.GetStringSlice("rocketMQNameServers")
+		if len(nameServers) == 0 {
+			panic("rocketmq sink specified but rocketMQNameServers not specified")
+		}
+
+		viper.SetDefault("rocketMQTopic", "eventrouter")
+		viper.SetDefault("rocketMQGroup", "eventrouter")
+		viper.SetDefault("rocketMQRetryMax", 2)
+		viper.SetDefault("rocketMQMaxInflight", 16)
+		viper.SetDefault("rocketMQSinkBufferSize", 1500)
+		viper.SetDefault("rocketMQSinkDiscardMessages", true)
+
+		r, err := NewRocketMQSink(RocketMQSinkConfig{
+			NameServers: nameServers,
+			Topic:       viper.GetString("rocketMQTopic"),
+			Group:       viper.GetString("rocketMQGroup"),
+			Namespace:   viper.GetString("rocketMQNamespace"),
+			AccessKey:   viper.GetString("rocketMQAccessKey"),
+			SecretKey:   viper.GetString("rocketMQSecretKey"),
+			RetryMax:    viper.GetInt("rocketMQRetryMax"),
+			MaxInflight: viper.GetInt("rocketMQMaxInflight"),
+			Overflow:    viper.GetBool("rocketMQSinkDiscardMessages"),
+			BufferSize:  viper.GetInt("rocketMQSinkBufferSize"),
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+		runSink(r.Run)
+		return r
 	default:
 		err := errors.New("Invalid Sink Specified")
 		panic(err.Error())
 	}
-	return e
 }
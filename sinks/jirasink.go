@@ -0,0 +1,335 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// jiraMaxSummary is the longest summary Jira accepts.
+const jiraMaxSummary = 255
+
+// JiraSinkConfig holds the options used to construct a JiraSink.
+type JiraSinkConfig struct {
+	// URL is the base URL of the Jira site, e.g. https://example.atlassian.net.
+	URL string
+
+	// Jira Cloud authenticates with Email and an API token; Jira Server and
+	// Data Center with a personal access token in Token.
+	Email    string
+	APIToken string
+	Token    string
+
+	// Reasons are the critical reasons that open issues. It is required, as
+	// opening issues for every Warning is rarely wanted.
+	Reasons []string
+
+	// MinDuration is how long an event has to have been recurring, from its
+	// first to its last timestamp, before an issue is opened.
+	MinDuration time.Duration
+
+	// Project and IssueType are used unless NamespaceProjects or
+	// NamespaceIssueTypes map the involved object's namespace.
+	Project             string
+	IssueType           string
+	NamespaceProjects   map[string]string
+	NamespaceIssueTypes map[string]string
+
+	// Labels are added to every issue.
+	Labels []string
+
+	// CommentInterval limits how often an open issue is commented on when
+	// the event keeps recurring.
+	CommentInterval time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// JiraSink opens Jira issues for persisting Warning events with critical
+// reasons. Each issue carries a label derived from the cluster, involved
+// object and reason; while an issue with that label is open, recurrences
+// comment on it instead of opening another one.
+type JiraSink struct {
+	baseURL             string
+	authorization       string
+	filter              eventFilter
+	minDuration         time.Duration
+	project             string
+	issueType           string
+	namespaceProjects   map[string]string
+	namespaceIssueTypes map[string]string
+	labels              []string
+	dedup               *deduplicator
+	clusterName         string
+	retryMax            int
+	client              *http.Client
+	eventCh             channels.Channel
+
+	// searchPath falls back to the classic search endpoint on Jira
+	// versions without /search/jql.
+	searchPath string
+}
+
+// NewJiraSink constructs a new JiraSink. Namespace keys are matched case
+// insensitively, as configuration maps lose their case.
+func NewJiraSink(cfg JiraSinkConfig) *JiraSink {
+	authorization := "Bearer " + cfg.Token
+	if cfg.Token == "" {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Email+":"+cfg.APIToken))
+	}
+
+	return &JiraSink{
+		baseURL:             strings.TrimSuffix(cfg.URL, "/"),
+		authorization:       authorization,
+		filter:              newEventFilter([]string{v1.EventTypeWarning}, cfg.Reasons, nil),
+		minDuration:         cfg.MinDuration,
+		project:             cfg.Project,
+		issueType:           cfg.IssueType,
+		namespaceProjects:   lowerKeys(cfg.NamespaceProjects),
+		namespaceIssueTypes: lowerKeys(cfg.NamespaceIssueTypes),
+		labels:              cfg.Labels,
+		dedup:               newDeduplicator(cfg.CommentInterval),
+		clusterName:         cfg.ClusterName,
+		retryMax:            cfg.RetryMax,
+		client:              &http.Client{Timeout: 30 * time.Second},
+		eventCh:             newEventChannel(cfg.Overflow, cfg.BufferSize),
+		searchPath:          "/rest/api/2/search/jql",
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes events that
+// have persisted for long enough to the event channel, which is drained by
+// Run.
+func (j *JiraSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !j.filter.match(eNew) || !j.persisted(eNew) {
+		return
+	}
+	j.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// persisted reports whether e has been recurring for at least minDuration.
+func (j *JiraSink) persisted(e *v1.Event) bool {
+	if j.minDuration <= 0 {
+		return true
+	}
+	first := e.FirstTimestamp.Time
+	if first.IsZero() {
+		first = e.EventTime.Time
+	}
+	return !first.IsZero() && eventTimestamp(e).Sub(first) >= j.minDuration
+}
+
+// Run sits in a loop, waiting for data to come in through j.eventCh,
+// and opening or commenting on issues.
+func (j *JiraSink) Run(stopCh <-chan bool) {
+	runBatches(j.eventCh, stopCh, j.drainEvents)
+}
+
+// drainEvents handles one event at a time, skipping repeats within the
+// comment interval.
+func (j *JiraSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		if send, _ := j.dedup.observe(dedupKey(evt.Event), time.Now()); !send {
+			continue
+		}
+		j.handle(evt)
+	}
+}
+
+// handle comments on the open issue for evt, or opens one.
+func (j *JiraSink) handle(evt EventData) {
+	e := evt.Event
+	project := j.project
+	if p, ok := j.namespaceProjects[strings.ToLower(e.InvolvedObject.Namespace)]; ok {
+		project = p
+	}
+	label := j.issueLabel(e)
+
+	key, err := j.findOpenIssue(project, label)
+	if err != nil {
+		glog.Errorf("Failed to search Jira for %s: %v", label, err)
+		deadLetter("jira", []EventData{evt}, err)
+		return
+	}
+	if key != "" {
+		j.comment(key, evt)
+		return
+	}
+	j.create(project, label, evt)
+}
+
+// issueLabel identifies the issues for an event's object and reason. Jira
+// labels cannot contain spaces, so it is a hash.
+func (j *JiraSink) issueLabel(e *v1.Event) string {
+	sum := sha1.Sum([]byte(j.clusterName + "/" + dedupKey(e)))
+	return "eventrouter-" + hex.EncodeToString(sum[:6])
+}
+
+// findOpenIssue returns the key of an unresolved issue with label, if any.
+func (j *JiraSink) findOpenIssue(project, label string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jql":        fmt.Sprintf(`project = %q AND labels = %q AND statusCategory != Done ORDER BY created DESC`, project, label),
+		"fields":     []string{"key"},
+		"maxResults": 1,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	respBody, err := j.post(j.searchPath, body)
+	if statusErr, ok := err.(*httpStatusError); ok && statusErr.StatusCode == http.StatusNotFound && j.searchPath != "/rest/api/2/search" {
+		j.searchPath = "/rest/api/2/search"
+		respBody, err = j.post(j.searchPath, body)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Issues) == 0 {
+		return "", nil
+	}
+	return resp.Issues[0].Key, nil
+}
+
+// create opens an issue for evt.
+func (j *JiraSink) create(project, label string, evt EventData) {
+	e := evt.Event
+	o := e.InvolvedObject
+	issueType := j.issueType
+	if t, ok := j.namespaceIssueTypes[strings.ToLower(o.Namespace)]; ok {
+		issueType = t
+	}
+
+	summary := fmt.Sprintf("%s %s/%s: %s", o.Kind, o.Namespace, o.Name, e.Reason)
+	if j.clusterName != "" {
+		summary = "[" + j.clusterName + "] " + summary
+	}
+	labels := append([]string{"eventrouter", label}, j.labels...)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": project},
+			"issuetype":   map[string]string{"name": issueType},
+			"summary":     truncateRunes(summary, jiraMaxSummary),
+			"description": j.description(evt),
+			"labels":      labels,
+		},
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize jira issue: %v", err)
+		return
+	}
+
+	respBody, err := j.post("/rest/api/2/issue", body)
+	if err != nil {
+		glog.Errorf("Failed to create Jira issue for %s: %v", dedupKey(e), err)
+		deadLetter("jira", []EventData{evt}, err)
+		return
+	}
+	var resp struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(respBody, &resp)
+	glog.Infof("Created Jira issue %s for %s", resp.Key, dedupKey(e))
+}
+
+// comment adds the latest occurrence of evt to an open issue.
+func (j *JiraSink) comment(key string, evt EventData) {
+	e := evt.Event
+	body, err := json.Marshal(map[string]string{
+		"body": fmt.Sprintf("Still occurring: %d times, last at %s.\n\n{noformat}%s{noformat}",
+			e.Count, eventTimestamp(e).UTC().Format(time.RFC3339), e.Message),
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize jira comment: %v", err)
+		return
+	}
+	if _, err := j.post("/rest/api/2/issue/"+url.PathEscape(key)+"/comment", body); err != nil {
+		glog.Errorf("Failed to comment on Jira issue %s: %v", key, err)
+		deadLetter("jira", []EventData{evt}, err)
+	}
+}
+
+// description renders the event as Jira wiki markup.
+func (j *JiraSink) description(evt EventData) string {
+	var b strings.Builder
+	b.WriteString("{noformat}")
+	b.WriteString(evt.Event.Message)
+	b.WriteString("{noformat}\n\n||Field||Value||\n")
+
+	fields := flattenEventData(evt)
+	if j.clusterName != "" {
+		fields["cluster"] = j.clusterName
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value string
+		switch v := fields[name].(type) {
+		case string:
+			value = v
+		case time.Time:
+			if !v.IsZero() {
+				value = v.UTC().Format(time.RFC3339)
+			}
+		default:
+			value = fmt.Sprint(v)
+		}
+		if value == "" || name == "message" {
+			continue
+		}
+		fmt.Fprintf(&b, "|%s|%s|\n", name, strings.ReplaceAll(value, "|", "\\|"))
+	}
+	return b.String()
+}
+
+// post sends an authenticated request to the Jira REST API.
+func (j *JiraSink) post(path string, body []byte) ([]byte, error) {
+	return postWithRetry(j.client, j.baseURL+path, body, j.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", j.authorization)
+	})
+}
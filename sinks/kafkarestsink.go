@@ -0,0 +1,231 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// kafkaRESTV2Record is a record of a v2 produce request.
+type kafkaRESTV2Record struct {
+	Key   string    `json:"key,omitempty"`
+	Value EventData `json:"value"`
+}
+
+// kafkaRESTV3Record is a v3 produce request.
+type kafkaRESTV3Record struct {
+	Key   *kafkaRESTV3Data `json:"key,omitempty"`
+	Value kafkaRESTV3Data  `json:"value"`
+}
+
+type kafkaRESTV3Data struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// KafkaRESTSinkConfig holds the options used to construct a KafkaRESTSink.
+type KafkaRESTSinkConfig struct {
+	// URL is the REST Proxy base URL.
+	URL   string
+	Topic string
+
+	// APIVersion is "v2", which produces batches, or "v3", which Confluent
+	// Cloud serves and which needs ClusterID.
+	APIVersion string
+	ClusterID  string
+
+	// KeyBy selects the record key, either "namespace" or "uid", as for
+	// the kafka sink.
+	KeyBy string
+
+	// Username and Password are sent with basic authentication; for
+	// Confluent Cloud they are an API key and secret.
+	Username string
+	Password string
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// KafkaRESTSink produces events to a Kafka topic through a Confluent REST
+// Proxy, for clusters that can only be reached over HTTPS. Records are the
+// EventData JSON, keyed like the kafka sink's messages.
+type KafkaRESTSink struct {
+	produceURL string
+	v3         bool
+	keyBy      string
+	authHeader string
+	retryMax   int
+	client     *http.Client
+	eventCh    channels.Channel
+}
+
+// NewKafkaRESTSink constructs a new KafkaRESTSink.
+func NewKafkaRESTSink(cfg KafkaRESTSinkConfig) (*KafkaRESTSink, error) {
+	if cfg.KeyBy != "namespace" && cfg.KeyBy != "uid" {
+		return nil, fmt.Errorf("invalid kafka key selection %q, expected namespace or uid", cfg.KeyBy)
+	}
+
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+	var produceURL string
+	switch cfg.APIVersion {
+	case "v2":
+		produceURL = baseURL + "/topics/" + url.PathEscape(cfg.Topic)
+	case "v3":
+		if cfg.ClusterID == "" {
+			return nil, fmt.Errorf("kafka rest api v3 requires a cluster id")
+		}
+		produceURL = baseURL + "/kafka/v3/clusters/" + url.PathEscape(cfg.ClusterID) + "/topics/" + url.PathEscape(cfg.Topic) + "/records"
+	default:
+		return nil, fmt.Errorf("unknown kafka rest api version %q, expected v2 or v3", cfg.APIVersion)
+	}
+
+	var authHeader string
+	if cfg.Username != "" {
+		authHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+cfg.Password))
+	}
+
+	return &KafkaRESTSink{
+		produceURL: produceURL,
+		v3:         cfg.APIVersion == "v3",
+		keyBy:      cfg.KeyBy,
+		authHeader: authHeader,
+		retryMax:   cfg.RetryMax,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		eventCh:    newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (k *KafkaRESTSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	k.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through k.eventCh,
+// and producing it through the REST Proxy.
+func (k *KafkaRESTSink) Run(stopCh <-chan bool) {
+	runBatches(k.eventCh, stopCh, k.drainEvents)
+}
+
+// drainEvents produces an array of event data, with a single request for
+// v2 and a request per record for v3.
+func (k *KafkaRESTSink) drainEvents(events []EventData) {
+	if !k.v3 {
+		k.produceV2(events)
+		return
+	}
+	for _, evt := range events {
+		k.produceV3(evt)
+	}
+}
+
+// produceV2 produces a batch and logs the records the proxy failed to
+// produce.
+func (k *KafkaRESTSink) produceV2(events []EventData) {
+	records := make([]kafkaRESTV2Record, 0, len(events))
+	for _, evt := range events {
+		records = append(records, kafkaRESTV2Record{
+			Key:   kafkaMessageKey(k.keyBy, evt.Event),
+			Value: evt,
+		})
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		glog.Warningf("Failed to json serialize kafka rest request: %v", err)
+		return
+	}
+
+	respBody, err := k.post(body, "application/vnd.kafka.json.v2+json")
+	if err != nil {
+		glog.Errorf("Failed to produce %d events through the Kafka REST Proxy: %v", len(events), err)
+		deadLetter("kafkarest", events, err)
+		return
+	}
+
+	var resp struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		glog.Warningf("Failed to decode kafka rest response: %v", err)
+		return
+	}
+	for i, o := range resp.Offsets {
+		if o.ErrorCode != nil {
+			glog.Errorf("Failed to produce event through the Kafka REST Proxy: error %d: %s", *o.ErrorCode, o.Error)
+			if i < len(events) {
+				deadLetter("kafkarest", []EventData{events[i]}, fmt.Errorf("error %d: %s", *o.ErrorCode, o.Error))
+			}
+		}
+	}
+}
+
+// produceV3 produces a single record.
+func (k *KafkaRESTSink) produceV3(evt EventData) {
+	record := kafkaRESTV3Record{Value: kafkaRESTV3Data{Type: "JSON", Data: evt}}
+	if key := kafkaMessageKey(k.keyBy, evt.Event); key != "" {
+		record.Key = &kafkaRESTV3Data{Type: "STRING", Data: key}
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		glog.Warningf("Failed to json serialize kafka rest request: %v", err)
+		return
+	}
+
+	respBody, err := k.post(body, "application/json")
+	if err != nil {
+		glog.Errorf("Failed to produce event through the Kafka REST Proxy: %v", err)
+		deadLetter("kafkarest", []EventData{evt}, err)
+		return
+	}
+
+	// The v3 API reports produce failures in the body of a 200 response.
+	var resp struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err == nil && resp.ErrorCode >= 300 {
+		glog.Errorf("Failed to produce event through the Kafka REST Proxy: error %d: %s", resp.ErrorCode, resp.Message)
+		deadLetter("kafkarest", []EventData{evt}, fmt.Errorf("error %d: %s", resp.ErrorCode, resp.Message))
+	}
+}
+
+// post sends a produce request.
+func (k *KafkaRESTSink) post(body []byte, contentType string) ([]byte, error) {
+	return postWithRetry(k.client, k.produceURL, body, k.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "application/json")
+		if k.authHeader != "" {
+			req.Header.Set("Authorization", k.authHeader)
+		}
+	})
+}
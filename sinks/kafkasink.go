@@ -0,0 +1,238 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/xdg-go/scram"
+	v1 "k8s.io/api/core/v1"
+)
+
+// KafkaSinkConfig holds the options used to construct a KafkaSink.
+type KafkaSinkConfig struct {
+	Brokers []string
+	Topic   string
+
+	// KeyBy selects the message key, either "namespace" or "uid" (the involved
+	// object UID). Messages with the same key land on the same partition, so
+	// consumers see them in order.
+	KeyBy    string
+	RetryMax int
+
+	// Codec is "json", or "avro" to encode events with the schema
+	// registered in the Schema Registry configured by SchemaRegistry.
+	Codec          string
+	SchemaRegistry SchemaRegistryConfig
+
+	// SASLMechanism is one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512. SASL is
+	// disabled when SASLUser is empty.
+	SASLMechanism string
+	SASLUser      string
+	SASLPassword  string
+
+	// TLS is enabled when TLSEnabled is set; TLSCertFile and TLSKeyFile
+	// additionally enable mutual TLS.
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	Overflow   bool
+	BufferSize int
+}
+
+// KafkaSink sends batches of events to a Kafka topic.
+type KafkaSink struct {
+	topic    string
+	keyBy    string
+	avro     *avroEncoder
+	producer sarama.SyncProducer
+	eventCh  channels.Channel
+}
+
+// NewKafkaSink constructs a new KafkaSink and connects to the configured brokers.
+func NewKafkaSink(cfg KafkaSinkConfig) (*KafkaSink, error) {
+	if cfg.KeyBy != "namespace" && cfg.KeyBy != "uid" {
+		return nil, fmt.Errorf("invalid kafka key selection %q, expected namespace or uid", cfg.KeyBy)
+	}
+
+	var avro *avroEncoder
+	switch cfg.Codec {
+	case "json":
+	case "avro":
+		var err error
+		avro, err = newAvroEncoder(cfg.SchemaRegistry, cfg.Topic)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid kafka codec %q, expected json or avro", cfg.Codec)
+	}
+
+	kafkaConfig := sarama.NewConfig()
+	kafkaConfig.ClientID = "eventrouter"
+	kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	kafkaConfig.Producer.Retry.Max = cfg.RetryMax
+	kafkaConfig.Producer.Return.Successes = true
+
+	if cfg.SASLUser != "" {
+		kafkaConfig.Net.SASL.Enable = true
+		kafkaConfig.Net.SASL.User = cfg.SASLUser
+		kafkaConfig.Net.SASL.Password = cfg.SASLPassword
+
+		switch cfg.SASLMechanism {
+		case "", sarama.SASLTypePlaintext:
+			kafkaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case sarama.SASLTypeSCRAMSHA256:
+			kafkaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			kafkaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{hashGenerator: scram.SHA256}
+			}
+		case sarama.SASLTypeSCRAMSHA512:
+			kafkaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			kafkaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{hashGenerator: scram.SHA512}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported kafka SASL mechanism %q", cfg.SASLMechanism)
+		}
+	}
+
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		kafkaConfig.Net.TLS.Enable = true
+		kafkaConfig.Net.TLS.Config = tlsConfig
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaSink{
+		topic:    cfg.Topic,
+		keyBy:    cfg.KeyBy,
+		avro:     avro,
+		producer: producer,
+		eventCh:  newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (k *KafkaSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	k.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through k.eventCh,
+// and producing it to the configured topic.
+func (k *KafkaSink) Run(stopCh <-chan bool) {
+	defer k.producer.Close()
+	runBatches(k.eventCh, stopCh, k.drainEvents)
+}
+
+// drainEvents produces an array of event data to Kafka in a single request.
+func (k *KafkaSink) drainEvents(events []EventData) {
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, evt := range events {
+		value, err := k.encode(evt)
+		if err != nil {
+			glog.Warningf("Failed to serialize event: %v", err)
+			deadLetter("kafka", []EventData{evt}, err)
+			continue
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic:    k.topic,
+			Key:      sarama.StringEncoder(k.messageKey(evt.Event)),
+			Value:    sarama.ByteEncoder(value),
+			Metadata: evt,
+		})
+	}
+
+	err := k.producer.SendMessages(msgs)
+	var produceErrs sarama.ProducerErrors
+	if errors.As(err, &produceErrs) {
+		for _, perr := range produceErrs {
+			glog.Errorf("Failed to produce event to kafka: %v", perr.Err)
+			if evt, ok := perr.Msg.Metadata.(EventData); ok {
+				deadLetter("kafka", []EventData{evt}, perr.Err)
+			}
+		}
+	} else if err != nil {
+		glog.Errorf("Failed to produce events to kafka: %v", err)
+		failed := make([]EventData, 0, len(msgs))
+		for _, msg := range msgs {
+			failed = append(failed, msg.Metadata.(EventData))
+		}
+		deadLetter("kafka", failed, err)
+	}
+}
+
+// encode serializes an event with the configured codec.
+func (k *KafkaSink) encode(evt EventData) ([]byte, error) {
+	if k.avro != nil {
+		return k.avro.encode(evt)
+	}
+	return json.Marshal(evt)
+}
+
+// messageKey returns the partitioning key for an event.
+func (k *KafkaSink) messageKey(e *v1.Event) string {
+	return kafkaMessageKey(k.keyBy, e)
+}
+
+// kafkaMessageKey returns the partitioning key for an event, either its
+// namespace or its involved object UID.
+func kafkaMessageKey(keyBy string, e *v1.Event) string {
+	if keyBy == "namespace" {
+		return e.InvolvedObject.Namespace
+	}
+	return string(e.InvolvedObject.UID)
+}
+
+// scramClient adapts xdg-go/scram to the sarama.SCRAMClient interface.
+type scramClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/azkustodata"
+	"github.com/Azure/azure-kusto-go/azkustoingest"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// KustoSinkConfig holds the options used to construct a KustoSink.
+type KustoSinkConfig struct {
+	// Endpoint is the cluster URI, e.g.
+	// https://mycluster.westus2.kusto.windows.net. The ingest- prefix is
+	// added for queued ingestion.
+	Endpoint string
+	Database string
+	Table    string
+
+	// Mode is "queued", "streaming" (which requires the streaming ingestion
+	// policy on the table) or "managed" (streaming, falling back to queued).
+	Mode string
+
+	// MappingRef names a JSON ingestion mapping created on the table; when
+	// empty, JSON properties are matched to columns by name. Mapping is an
+	// inline JSON mapping used instead, which queued ingestion alone
+	// supports.
+	MappingRef string
+	Mapping    string
+
+	// TLS configures a custom CA bundle and minimum TLS version.
+	TLS AzureTLSConfig
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+
+	Overflow   bool
+	BufferSize int
+}
+
+// KustoSink ingests events into an Azure Data Explorer table as JSON
+// records holding the flattened event fields, timestamp, cluster and the
+// complete event as a dynamic value. A matching table is:
+//
+//	.create table K8sEvents (timestamp: datetime, cluster: string, verb: string,
+//	    namespace: string, name: string, uid: string, type: string, reason: string,
+//	    message: string, count: int, first_timestamp: datetime, last_timestamp: datetime,
+//	    source_component: string, source_host: string, involved_object_kind: string,
+//	    involved_object_namespace: string, involved_object_name: string,
+//	    involved_object_uid: string, event: dynamic)
+type KustoSink struct {
+	ingestor      azkustoingest.Ingestor
+	options       []azkustoingest.FileOption
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	eventCh       channels.Channel
+}
+
+// NewKustoSink constructs a new KustoSink, authenticating with the default
+// Azure credential chain. The identity needs the Database Ingestor role.
+func NewKustoSink(cfg KustoSinkConfig) (*KustoSink, error) {
+	if err := checkFlushInterval("kusto", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newAzureTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: azureClientOptions(tlsConfig),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kcsb := azkustodata.NewConnectionStringBuilder(cfg.Endpoint).WithTokenCredential(cred)
+	ingestOpts := []azkustoingest.Option{
+		azkustoingest.WithDefaultDatabase(cfg.Database),
+		azkustoingest.WithDefaultTable(cfg.Table),
+	}
+	if tlsConfig != nil {
+		ingestOpts = append(ingestOpts, azkustoingest.WithHttpClient(newAzureHTTPClient(tlsConfig, nil)))
+	}
+
+	var ingestor azkustoingest.Ingestor
+	switch cfg.Mode {
+	case "queued":
+		ingestor, err = azkustoingest.New(kcsb, ingestOpts...)
+	case "streaming":
+		ingestor, err = azkustoingest.NewStreaming(kcsb, ingestOpts...)
+	case "managed":
+		ingestor, err = azkustoingest.NewManaged(kcsb, ingestOpts...)
+	default:
+		return nil, fmt.Errorf("unknown kusto ingestion mode %q", cfg.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options := []azkustoingest.FileOption{azkustoingest.FileFormat(azkustoingest.JSON)}
+	switch {
+	case cfg.MappingRef != "":
+		options = append(options, azkustoingest.IngestionMappingRef(cfg.MappingRef, azkustoingest.JSON))
+	case cfg.Mapping != "":
+		options = append(options, azkustoingest.IngestionMapping(cfg.Mapping, azkustoingest.JSON))
+	}
+
+	return &KustoSink{
+		ingestor:      ingestor,
+		options:       options,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (k *KustoSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	k.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through k.eventCh, and
+// ingesting it in batches of up to flushEvents records. Queued ingestion
+// stages every batch as a blob, so fewer, larger batches are cheaper.
+func (k *KustoSink) Run(stopCh <-chan bool) {
+	defer k.ingestor.Close()
+	runTimedBatches(k.eventCh, stopCh, k.flushEvents, k.flushInterval, k.drainEvents)
+}
+
+// drainEvents ingests an array of event data as newline delimited JSON.
+func (k *KustoSink) drainEvents(events []EventData) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		record := flattenEventData(evt)
+		record["timestamp"] = eventTimestamp(evt.Event)
+		record["cluster"] = k.clusterName
+		record["event"] = evt.Event
+		line, err := json.Marshal(record)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := k.ingestor.FromReader(context.TODO(), &buf, k.options...); err != nil {
+		glog.Errorf("Failed to ingest %d events into Kusto: %v", len(events), err)
+		deadLetter("kusto", events, err)
+	}
+}
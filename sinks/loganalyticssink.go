@@ -0,0 +1,153 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/monitor/ingestion/azlogs"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// logAnalyticsMaxRequestBytes is the Logs Ingestion API request size limit.
+const logAnalyticsMaxRequestBytes = 1000 * 1000
+
+// LogAnalyticsSink sends events to a Log Analytics workspace through the
+// Azure Monitor Logs Ingestion API. Each event becomes a row with the
+// flattened event fields plus TimeGenerated and cluster, so the data
+// collection rule's stream declaration should list those columns, e.g.
+// TimeGenerated (datetime), cluster, verb, namespace, name, reason, type,
+// message (string), count (int) and so on.
+type LogAnalyticsSink struct {
+	client      *azlogs.Client
+	ruleID      string
+	streamName  string
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewLogAnalyticsSink constructs a new LogAnalyticsSink. endpoint is the
+// logs ingestion endpoint of the data collection endpoint or rule, ruleID
+// the immutable ID of the data collection rule (dcr-...) and streamName its
+// input stream (Custom-...). The default Azure credential chain must resolve
+// to an identity with the Monitoring Metrics Publisher role on the rule.
+// Requests throttled with 429 are retried up to retryMax times, honoring
+// Retry-After.
+func NewLogAnalyticsSink(endpoint string, ruleID string, streamName string, clusterName string, retryMax int, tlsCfg AzureTLSConfig, overflow bool, bufferSize int) (*LogAnalyticsSink, error) {
+	tlsConfig, err := newAzureTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts := azureClientOptions(tlsConfig)
+
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: clientOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts.Retry = policy.RetryOptions{
+		MaxRetries:    int32(retryMax),
+		RetryDelay:    time.Second,
+		MaxRetryDelay: 30 * time.Second,
+	}
+	client, err := azlogs.NewClient(endpoint, cred, &azlogs.ClientOptions{
+		ClientOptions: clientOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogAnalyticsSink{
+		client:      client,
+		ruleID:      ruleID,
+		streamName:  streamName,
+		clusterName: clusterName,
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (l *LogAnalyticsSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	l.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through l.eventCh,
+// and uploading it to Log Analytics.
+func (l *LogAnalyticsSink) Run(stopCh <-chan bool) {
+	runBatches(l.eventCh, stopCh, l.drainEvents)
+}
+
+// drainEvents uploads an array of event data in as few requests as the
+// request size limit allows.
+func (l *LogAnalyticsSink) drainEvents(events []EventData) {
+	// batched are the events of batch.
+	var batch []json.RawMessage
+	var batched []EventData
+	batchBytes := 2
+	for _, evt := range events {
+		row := flattenEventData(evt)
+		row["TimeGenerated"] = eventTimestamp(evt.Event)
+		row["cluster"] = l.clusterName
+		entry, err := json.Marshal(row)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(entry)+2 > logAnalyticsMaxRequestBytes {
+			glog.Warningf("Dropping event %s/%s: too large for the logs ingestion API", evt.Event.Namespace, evt.Event.Name)
+			deadLetter("loganalytics", []EventData{evt}, fmt.Errorf("%d bytes exceeds the logs ingestion API request limit", len(entry)))
+			continue
+		}
+
+		if len(batch) > 0 && batchBytes+len(entry)+1 > logAnalyticsMaxRequestBytes {
+			l.upload(batch, batched)
+			batch, batched = nil, nil
+			batchBytes = 2
+		}
+		batch = append(batch, entry)
+		batched = append(batched, evt)
+		batchBytes += len(entry) + 1
+	}
+
+	if len(batch) > 0 {
+		l.upload(batch, batched)
+	}
+}
+
+// upload sends a single request, dead lettering events if it fails.
+func (l *LogAnalyticsSink) upload(batch []json.RawMessage, events []EventData) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		glog.Warningf("Failed to json serialize log analytics request: %v", err)
+		return
+	}
+
+	if _, err := l.client.Upload(context.TODO(), l.ruleID, l.streamName, body, nil); err != nil {
+		glog.Errorf("Failed to upload %d events to Log Analytics: %v", len(batch), err)
+		deadLetter("loganalytics", events, err)
+	}
+}
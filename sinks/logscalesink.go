@@ -0,0 +1,209 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// logScaleStructuredRequest is one element of a structured ingest request:
+// events sharing a set of tags.
+type logScaleStructuredRequest struct {
+	Tags   map[string]string        `json:"tags,omitempty"`
+	Events []logScaleStructuredItem `json:"events"`
+}
+
+type logScaleStructuredItem struct {
+	Timestamp  string                 `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// logScaleHECEvent is a single event for the HEC compatible endpoint.
+type logScaleHECEvent struct {
+	Time       float64           `json:"time"`
+	SourceType string            `json:"sourcetype,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	Event      EventData         `json:"event"`
+}
+
+// LogScaleSinkConfig holds the options used to construct a LogScaleSink.
+type LogScaleSinkConfig struct {
+	// URL is the base URL of the LogScale cluster, e.g.
+	// https://cloud.community.humio.com.
+	URL string
+
+	// Token is an ingest token; its parser applies to structured events.
+	Token string
+
+	// API is "structured" or "hec".
+	API string
+
+	// Tags are added to every event. LogScale stores events in a datasource
+	// per combination of tag values, so they should have few values.
+	Tags map[string]string
+
+	// SourceType is the HEC sourcetype, which selects the parser there.
+	SourceType string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// LogScaleSink sends events to Falcon LogScale, formerly Humio, through its
+// structured ingest API or its HEC compatible endpoint. Batches are gzip
+// compressed.
+type LogScaleSink struct {
+	ingestURL     string
+	token         string
+	hec           bool
+	tags          map[string]string
+	sourceType    string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewLogScaleSink constructs a new LogScaleSink. The cluster name, if set,
+// is added as the cluster tag.
+func NewLogScaleSink(cfg LogScaleSinkConfig) (*LogScaleSink, error) {
+	if err := checkFlushInterval("logscale", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+	var ingestURL string
+	switch cfg.API {
+	case "structured":
+		ingestURL = baseURL + "/api/v1/ingest/humio-structured"
+	case "hec":
+		ingestURL = baseURL + "/api/v1/ingest/hec"
+	default:
+		return nil, fmt.Errorf("unknown logscale api %q, expected structured or hec", cfg.API)
+	}
+
+	tags := make(map[string]string, len(cfg.Tags)+1)
+	for k, v := range cfg.Tags {
+		tags[k] = v
+	}
+	if cfg.ClusterName != "" {
+		tags["cluster"] = cfg.ClusterName
+	}
+
+	return &LogScaleSink{
+		ingestURL:     ingestURL,
+		token:         cfg.Token,
+		hec:           cfg.API == "hec",
+		tags:          tags,
+		sourceType:    cfg.SourceType,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (l *LogScaleSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	l.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through l.eventCh, and
+// sending it to LogScale in batches of up to flushEvents events.
+func (l *LogScaleSink) Run(stopCh <-chan bool) {
+	runTimedBatches(l.eventCh, stopCh, l.flushEvents, l.flushInterval, l.drainEvents)
+}
+
+// drainEvents sends an array of event data with a single gzipped request.
+func (l *LogScaleSink) drainEvents(events []EventData) {
+	var body []byte
+	var err error
+	if l.hec {
+		body, err = l.hecBody(events)
+	} else {
+		body, err = l.structuredBody(events)
+	}
+	if err != nil {
+		glog.Warningf("Failed to json serialize logscale request: %v", err)
+		return
+	}
+	if body, err = gzipBytes(body); err != nil {
+		glog.Warningf("Failed to compress logscale request: %v", err)
+		return
+	}
+
+	_, err = postWithRetry(l.client, l.ingestURL, body, l.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to LogScale: %v", len(events), err)
+		deadLetter("logscale", events, err)
+	}
+}
+
+// structuredBody builds a structured ingest request with the flattened
+// event fields as attributes.
+func (l *LogScaleSink) structuredBody(events []EventData) ([]byte, error) {
+	req := logScaleStructuredRequest{Tags: l.tags}
+	for _, evt := range events {
+		req.Events = append(req.Events, logScaleStructuredItem{
+			Timestamp:  eventTimestamp(evt.Event).UTC().Format(time.RFC3339Nano),
+			Attributes: flattenEventData(evt),
+		})
+	}
+	return json.Marshal([]logScaleStructuredRequest{req})
+}
+
+// hecBody builds a HEC request: concatenated events with the tags as
+// fields.
+func (l *LogScaleSink) hecBody(events []EventData) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		b, err := json.Marshal(logScaleHECEvent{
+			Time:       float64(eventTimestamp(evt.Event).UnixNano()) / float64(time.Second),
+			SourceType: l.sourceType,
+			Fields:     l.tags,
+			Event:      evt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
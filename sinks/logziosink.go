@@ -0,0 +1,192 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Logz.io bulk listener limits.
+const (
+	logzioMaxRequestBytes = 10 * 1000 * 1000
+	logzioMaxLineBytes    = 500 * 1000
+)
+
+// LogzioSinkConfig holds the options used to construct a LogzioSink.
+type LogzioSinkConfig struct {
+	// Token is the account's log shipping token.
+	Token string
+
+	// Region is the account's region code, e.g. "us", "eu" or "uk". It
+	// selects the listener host unless ListenerURL is set.
+	Region string
+
+	// ListenerURL overrides the listener, e.g. for a proxy.
+	ListenerURL string
+
+	// Type is the log type Logz.io parses the events as.
+	Type string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// LogzioSink ships events as JSON lines to the Logz.io bulk listener. Rate
+// limited requests are retried with backoff, like server errors.
+type LogzioSink struct {
+	url           string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewLogzioSink constructs a new LogzioSink.
+func NewLogzioSink(cfg LogzioSinkConfig) (*LogzioSink, error) {
+	if err := checkFlushInterval("logz.io", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	listener := cfg.ListenerURL
+	if listener == "" {
+		listener = logzioListenerURL(cfg.Region)
+	}
+	u, err := url.Parse(listener)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logz.io listener url: %v", err)
+	}
+	q := u.Query()
+	q.Set("token", cfg.Token)
+	if cfg.Type != "" {
+		q.Set("type", cfg.Type)
+	}
+	u.RawQuery = q.Encode()
+
+	return &LogzioSink{
+		url:           u.String(),
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// logzioListenerURL returns the bulk listener for a region. US accounts use
+// the unsuffixed host.
+func logzioListenerURL(region string) string {
+	if region == "" || region == "us" {
+		return "https://listener.logz.io:8071"
+	}
+	return "https://listener-" + region + ".logz.io:8071"
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (l *LogzioSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	l.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through l.eventCh, and
+// sending it to Logz.io in batches of up to flushEvents events.
+func (l *LogzioSink) Run(stopCh <-chan bool) {
+	runTimedBatches(l.eventCh, stopCh, l.flushEvents, l.flushInterval, l.drainEvents)
+}
+
+// drainEvents sends an array of event data as JSON lines, split into
+// requests the listener accepts.
+func (l *LogzioSink) drainEvents(events []EventData) {
+	// batched are the events in buf.
+	var buf bytes.Buffer
+	var batched []EventData
+	for _, evt := range events {
+		line, err := json.Marshal(l.document(evt))
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(line) > logzioMaxLineBytes {
+			glog.Warningf("Dropping event %s/%s: %d bytes exceeds the logz.io line limit", evt.Event.Namespace, evt.Event.Name, len(line))
+			deadLetter("logzio", []EventData{evt}, fmt.Errorf("%d bytes exceeds the logz.io line limit", len(line)))
+			continue
+		}
+
+		if len(batched) > 0 && buf.Len()+len(line)+1 > logzioMaxRequestBytes {
+			l.send(buf.Bytes(), batched)
+			buf.Reset()
+			batched = nil
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		batched = append(batched, evt)
+	}
+
+	if len(batched) > 0 {
+		l.send(buf.Bytes(), batched)
+	}
+}
+
+// document builds the log line for an event. The event type is renamed, as
+// type is the Logz.io log type.
+func (l *LogzioSink) document(evt EventData) map[string]interface{} {
+	doc := flattenEventData(evt)
+	doc["event_type"] = doc["type"]
+	delete(doc, "type")
+	doc["@timestamp"] = eventTimestamp(evt.Event).UTC().Format(time.RFC3339Nano)
+	if l.clusterName != "" {
+		doc["cluster"] = l.clusterName
+	}
+	return doc
+}
+
+// send posts a single gzipped request of the lines of events, dead
+// lettering them if it fails.
+func (l *LogzioSink) send(lines []byte, events []EventData) {
+	body, err := gzipBytes(lines)
+	if err != nil {
+		glog.Warningf("Failed to compress logz.io request: %v", err)
+		deadLetter("logzio", events, err)
+		return
+	}
+
+	_, err = postWithRetry(l.client, l.url, body, l.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to Logz.io: %v", len(events), err)
+		deadLetter("logzio", events, err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+)
+
+// LokiSinkConfig holds the options used to construct a LokiSink.
+type LokiSinkConfig struct {
+	// URL is the base URL of Loki or its gateway, e.g. http://loki:3100.
+	URL string
+
+	// TenantID is sent as X-Scope-OrgID for multi-tenant installations.
+	TenantID string
+	Username string
+	Password string
+
+	ClusterName string
+
+	// StreamRateBytes and StreamBurstBytes mirror Loki's
+	// per_stream_rate_limit and per_stream_rate_limit_burst, so pushes are
+	// paced instead of being rejected.
+	StreamRateBytes  int
+	StreamBurstBytes int
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// lokiStream is a single stream of a push request.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// LokiSink pushes events to Grafana Loki. Each event is a JSON log line in a
+// stream labelled with the cluster, namespace, event type and involved
+// object kind.
+type LokiSink struct {
+	pushURL     string
+	tenantID    string
+	username    string
+	password    string
+	clusterName string
+	streamRate  rate.Limit
+	streamBurst int
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+
+	limiters map[string]*rate.Limiter
+}
+
+// NewLokiSink constructs a new LokiSink.
+func NewLokiSink(cfg LokiSinkConfig) *LokiSink {
+	return &LokiSink{
+		pushURL:     strings.TrimSuffix(cfg.URL, "/") + "/loki/api/v1/push",
+		tenantID:    cfg.TenantID,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		clusterName: cfg.ClusterName,
+		streamRate:  rate.Limit(cfg.StreamRateBytes),
+		streamBurst: cfg.StreamBurstBytes,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+		limiters:    map[string]*rate.Limiter{},
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (l *LokiSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	l.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through l.eventCh,
+// and pushing it to Loki.
+func (l *LokiSink) Run(stopCh <-chan bool) {
+	runBatches(l.eventCh, stopCh, l.drainEvents)
+}
+
+// drainEvents groups an array of event data by stream and pushes each
+// stream separately, waiting on the stream's rate limiter before each push.
+func (l *LokiSink) drainEvents(events []EventData) {
+	streams := map[string][]EventData{}
+	labels := map[string]map[string]string{}
+	var keys []string
+	for _, evt := range events {
+		lbls := l.labels(evt.Event)
+		key := lokiStreamKey(lbls)
+		if _, ok := streams[key]; !ok {
+			labels[key] = lbls
+			keys = append(keys, key)
+		}
+		streams[key] = append(streams[key], evt)
+	}
+
+	for _, key := range keys {
+		evts := streams[key]
+		sort.SliceStable(evts, func(i, j int) bool {
+			return eventTimestamp(evts[i].Event).Before(eventTimestamp(evts[j].Event))
+		})
+
+		// batched are the events of s.Values.
+		s := lokiStream{Stream: labels[key]}
+		var batched []EventData
+		for _, evt := range evts {
+			eJSONBytes, err := json.Marshal(evt)
+			if err != nil {
+				glog.Warningf("Failed to json serialize event: %v", err)
+				continue
+			}
+			ts := strconv.FormatInt(eventTimestamp(evt.Event).UnixNano(), 10)
+			s.Values = append(s.Values, [2]string{ts, string(eJSONBytes)})
+			batched = append(batched, evt)
+		}
+
+		// Split the stream so no single push exceeds the burst size.
+		start, size := 0, 0
+		for i, v := range s.Values {
+			if i > start && size+len(v[1]) > l.streamBurst {
+				l.push(key, lokiStream{Stream: s.Stream, Values: s.Values[start:i]}, batched[start:i], size)
+				start, size = i, 0
+			}
+			size += len(v[1])
+		}
+		if start < len(s.Values) {
+			l.push(key, lokiStream{Stream: s.Stream, Values: s.Values[start:]}, batched[start:], size)
+		}
+	}
+}
+
+// push sends one stream to Loki once its rate limiter allows size bytes,
+// dead lettering its events if it fails.
+func (l *LokiSink) push(key string, s lokiStream, events []EventData, size int) {
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.streamRate, l.streamBurst)
+		l.limiters[key] = limiter
+	}
+	if size > l.streamBurst {
+		size = l.streamBurst
+	}
+	if err := limiter.WaitN(context.TODO(), size); err != nil {
+		glog.Warningf("Loki stream rate limiter: %v", err)
+	}
+
+	body, err := json.Marshal(map[string][]lokiStream{"streams": {s}})
+	if err != nil {
+		glog.Warningf("Failed to json serialize loki push: %v", err)
+		return
+	}
+	_, err = postWithRetry(l.client, l.pushURL, body, l.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		if l.tenantID != "" {
+			req.Header.Set("X-Scope-OrgID", l.tenantID)
+		}
+		if l.username != "" {
+			req.SetBasicAuth(l.username, l.password)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to push %d events to Loki: %v", len(s.Values), err)
+		deadLetter("loki", events, err)
+	}
+}
+
+// labels returns the stream labels for an event. Loki drops labels with
+// empty values.
+func (l *LokiSink) labels(e *v1.Event) map[string]string {
+	return map[string]string{
+		"job":       "eventrouter",
+		"cluster":   l.clusterName,
+		"namespace": e.InvolvedObject.Namespace,
+		"type":      e.Type,
+		"kind":      e.InvolvedObject.Kind,
+	}
+}
+
+// lokiStreamKey returns a stable identity for a label set.
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
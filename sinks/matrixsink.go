@@ -0,0 +1,182 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// matrixMessage is an m.room.message event with an HTML body.
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// MatrixSinkConfig holds the options used to construct a MatrixSink.
+type MatrixSinkConfig struct {
+	// HomeserverURL is the client-server API base URL, e.g.
+	// https://matrix.example.org.
+	HomeserverURL string
+	AccessToken   string
+
+	// RoomID is the room's ID, e.g. !abc:example.org; the user the access
+	// token belongs to has to have joined it.
+	RoomID string
+
+	// MsgType is m.notice, which clients render as a bot message, or
+	// m.text.
+	MsgType string
+
+	// Types, Reasons and Namespaces select the events that are sent.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// DedupWindow limits how often repeats of an event are sent.
+	DedupWindow time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// MatrixSink sends selected events to a Matrix room as HTML formatted
+// messages through the client-server API.
+type MatrixSink struct {
+	sendURL     string
+	accessToken string
+	msgType     string
+	filter      eventFilter
+	dedup       *deduplicator
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+
+	// txnPrefix and txnSeq make transaction IDs unique across restarts, so
+	// the homeserver only deduplicates retries of the same message.
+	txnPrefix string
+	txnSeq    int
+}
+
+// NewMatrixSink constructs a new MatrixSink.
+func NewMatrixSink(cfg MatrixSinkConfig) *MatrixSink {
+	return &MatrixSink{
+		sendURL:     strings.TrimSuffix(cfg.HomeserverURL, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(cfg.RoomID) + "/send/m.room.message/",
+		accessToken: cfg.AccessToken,
+		msgType:     cfg.MsgType,
+		filter:      newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		dedup:       newDeduplicator(cfg.DedupWindow),
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+		txnPrefix:   fmt.Sprintf("eventrouter-%d-", time.Now().UnixNano()),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (m *MatrixSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !m.filter.match(eNew) {
+		return
+	}
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh,
+// and sending it to the room.
+func (m *MatrixSink) Run(stopCh <-chan bool) {
+	runBatches(m.eventCh, stopCh, m.drainEvents)
+}
+
+// drainEvents sends one message per event, skipping repeats within the
+// dedup window.
+func (m *MatrixSink) drainEvents(events []EventData) {
+	now := time.Now()
+	for _, evt := range events {
+		send, suppressed := m.dedup.observe(dedupKey(evt.Event), now)
+		if !send {
+			continue
+		}
+		if err := m.send(m.message(evt, suppressed)); err != nil {
+			glog.Errorf("Failed to send event to Matrix: %v", err)
+			deadLetter("matrix", []EventData{evt}, err)
+		}
+	}
+}
+
+// message formats an event, mentioning how many repeats were suppressed
+// since it was last sent.
+func (m *MatrixSink) message(evt EventData, suppressed int) matrixMessage {
+	e := evt.Event
+	o := e.InvolvedObject
+	icon := "ℹ️"
+	if e.Type == v1.EventTypeWarning {
+		icon = "⚠️"
+	}
+
+	object := fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+	details := []string{fmt.Sprintf("count %d", e.Count)}
+	if m.clusterName != "" {
+		details = append([]string{m.clusterName}, details...)
+	}
+	if suppressed > 0 {
+		details = append(details, fmt.Sprintf("%d repeats suppressed", suppressed))
+	}
+
+	return matrixMessage{
+		MsgType: m.msgType,
+		Body: fmt.Sprintf("%s %s: %s\n%s\n%s",
+			icon, e.Reason, object, e.Message, strings.Join(details, " · ")),
+		Format: "org.matrix.custom.html",
+		FormattedBody: fmt.Sprintf("%s <strong>%s</strong>: <code>%s</code><br>%s<br><em>%s</em>",
+			icon, html.EscapeString(e.Reason), html.EscapeString(object),
+			html.EscapeString(e.Message), html.EscapeString(strings.Join(details, " · "))),
+	}
+}
+
+// send puts a message into the room. Retries reuse the transaction ID, so
+// the homeserver drops duplicates of a message it already accepted.
+func (m *MatrixSink) send(msg matrixMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	m.txnSeq++
+	txnID := m.txnPrefix + fmt.Sprint(m.txnSeq)
+
+	_, err = requestWithRetry(m.client, http.MethodPut, m.sendURL+url.PathEscape(txnID), body, m.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	})
+	return err
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// mattermostMaxAttachments keeps a post well below Mattermost's message
+// size limit; bursts with more distinct events are split across posts.
+const mattermostMaxAttachments = 20
+
+// mattermostAttachment is a Slack compatible message attachment.
+type mattermostAttachment struct {
+	Fallback string            `json:"fallback"`
+	Color    string            `json:"color"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Fields   []mattermostField `json:"fields"`
+}
+
+type mattermostField struct {
+	Short bool   `json:"short"`
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// mattermostPost is an incoming webhook request.
+type mattermostPost struct {
+	Channel     string                 `json:"channel,omitempty"`
+	Username    string                 `json:"username,omitempty"`
+	IconURL     string                 `json:"icon_url,omitempty"`
+	Text        string                 `json:"text"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+// MattermostSinkConfig holds the options used to construct a
+// MattermostSink.
+type MattermostSinkConfig struct {
+	WebhookURL string
+
+	// Channel overrides the webhook's default channel, and
+	// NamespaceChannels does so per namespace. Overrides only work when
+	// the webhook is not locked to its channel.
+	Channel           string
+	NamespaceChannels map[string]string
+
+	Username string
+	IconURL  string
+
+	// Types, Reasons and Namespaces select the events that are posted.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// Events arriving within FlushInterval are coalesced into one post per
+	// channel, and an event is posted at most once per DedupWindow.
+	FlushInterval time.Duration
+	DedupWindow   time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// MattermostSink posts selected events to Mattermost incoming webhooks.
+// Bursts are coalesced: everything that arrives within a flush interval is
+// posted as one message per channel, with one attachment per distinct
+// event.
+type MattermostSink struct {
+	webhookURL        string
+	channel           string
+	namespaceChannels map[string]string
+	username          string
+	iconURL           string
+	filter            eventFilter
+	flushInterval     time.Duration
+	dedup             *deduplicator
+	clusterName       string
+	retryMax          int
+	client            *http.Client
+	eventCh           channels.Channel
+}
+
+// mattermostGroup is a run of repeats of one event within a flush
+// interval.
+type mattermostGroup struct {
+	latest EventData
+	count  int
+}
+
+// NewMattermostSink constructs a new MattermostSink. Namespace keys are
+// matched case insensitively, as configuration maps lose their case.
+func NewMattermostSink(cfg MattermostSinkConfig) (*MattermostSink, error) {
+	if err := checkFlushInterval("mattermost", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	return &MattermostSink{
+		webhookURL:        cfg.WebhookURL,
+		channel:           cfg.Channel,
+		namespaceChannels: lowerKeys(cfg.NamespaceChannels),
+		username:          cfg.Username,
+		iconURL:           cfg.IconURL,
+		filter:            newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		flushInterval:     cfg.FlushInterval,
+		dedup:             newDeduplicator(cfg.DedupWindow),
+		clusterName:       cfg.ClusterName,
+		retryMax:          cfg.RetryMax,
+		client:            &http.Client{Timeout: 30 * time.Second},
+		eventCh:           newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (m *MattermostSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !m.filter.match(eNew) {
+		return
+	}
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh, and
+// posting what arrived every flushInterval.
+func (m *MattermostSink) Run(stopCh <-chan bool) {
+	runTimedBatches(m.eventCh, stopCh, 0, m.flushInterval, m.drainEvents)
+}
+
+// drainEvents coalesces repeats of the same event, then posts the distinct
+// events to their channels.
+func (m *MattermostSink) drainEvents(events []EventData) {
+	groups := map[string]*mattermostGroup{}
+	var keys []string
+	for _, evt := range events {
+		key := dedupKey(evt.Event)
+		g, ok := groups[key]
+		if !ok {
+			g = &mattermostGroup{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.latest = evt
+		g.count++
+	}
+
+	now := time.Now()
+	// posted are the events of the attachments in byChannel.
+	byChannel := map[string][]mattermostAttachment{}
+	posted := map[string][]EventData{}
+	var channelOrder []string
+	for _, key := range keys {
+		g := groups[key]
+		send, suppressed := m.dedup.observe(key, now)
+		if !send {
+			continue
+		}
+		channel := m.destination(g.latest.Event)
+		if _, ok := byChannel[channel]; !ok {
+			channelOrder = append(channelOrder, channel)
+		}
+		byChannel[channel] = append(byChannel[channel], m.attachment(g.latest, g.count+suppressed))
+		posted[channel] = append(posted[channel], g.latest)
+	}
+
+	for _, channel := range channelOrder {
+		attachments := byChannel[channel]
+		for start := 0; start < len(attachments); start += mattermostMaxAttachments {
+			end := start + mattermostMaxAttachments
+			if end > len(attachments) {
+				end = len(attachments)
+			}
+			if err := m.post(channel, attachments[start:end]); err != nil {
+				glog.Errorf("Failed to post events to Mattermost: %v", err)
+				deadLetter("mattermost", posted[channel][start:end], err)
+			}
+		}
+	}
+}
+
+// destination returns the channel an event is posted to, empty meaning the
+// webhook's own channel.
+func (m *MattermostSink) destination(e *v1.Event) string {
+	if channel, ok := m.namespaceChannels[strings.ToLower(e.InvolvedObject.Namespace)]; ok {
+		return channel
+	}
+	return m.channel
+}
+
+// attachment formats an event seen occurrences times since it was last
+// posted.
+func (m *MattermostSink) attachment(evt EventData, occurrences int) mattermostAttachment {
+	e := evt.Event
+	o := e.InvolvedObject
+	color := "#2eb886"
+	if e.Type == v1.EventTypeWarning {
+		color = "#daa038"
+	}
+
+	title := fmt.Sprintf("%s: %s %s/%s", e.Reason, o.Kind, o.Namespace, o.Name)
+	fields := []mattermostField{
+		{Short: true, Title: "Type", Value: e.Type},
+		{Short: true, Title: "Count", Value: fmt.Sprint(e.Count)},
+		{Short: true, Title: "Last seen", Value: eventTimestamp(e).UTC().Format(time.RFC3339)},
+	}
+	if m.clusterName != "" {
+		fields = append([]mattermostField{{Short: true, Title: "Cluster", Value: m.clusterName}}, fields...)
+	}
+	if occurrences > 1 {
+		fields = append(fields, mattermostField{Short: true, Title: "Repeats", Value: fmt.Sprintf("%d since the last post", occurrences)})
+	}
+
+	return mattermostAttachment{
+		Fallback: title + ": " + e.Message,
+		Color:    color,
+		Title:    title,
+		Text:     e.Message,
+		Fields:   fields,
+	}
+}
+
+// post sends attachments to the webhook, in channel unless it is empty.
+func (m *MattermostSink) post(channel string, attachments []mattermostAttachment) error {
+	text := fmt.Sprintf("%d Kubernetes events", len(attachments))
+	if len(attachments) == 1 {
+		text = "Kubernetes event"
+	}
+	if m.clusterName != "" {
+		text += " in " + m.clusterName
+	}
+
+	body, err := json.Marshal(mattermostPost{
+		Channel:     channel,
+		Username:    m.username,
+		IconURL:     m.iconURL,
+		Text:        text,
+		Attachments: attachments,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = postWithRetry(m.client, m.webhookURL, body, m.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	})
+	return err
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	v1 "k8s.io/api/core/v1"
+)
+
+// mongoNamespaceExists is the server error code returned when creating a
+// collection that already exists.
+const mongoNamespaceExists = 48
+
+// MongoSinkConfig holds the options used to construct a MongoSink.
+type MongoSinkConfig struct {
+	// URI is a mongodb:// or mongodb+srv:// connection string.
+	URI        string
+	Database   string
+	Collection string
+
+	ClusterName string
+
+	// CappedSizeBytes creates the collection as a capped collection of that
+	// size when it does not exist yet. Otherwise TTL, if set, creates a TTL
+	// index expiring documents that long after the event timestamp. Capped
+	// collections cannot carry TTL indexes, so the two are exclusive.
+	CappedSizeBytes int64
+	TTL             time.Duration
+
+	// WriteConcern is "majority" or a number of acknowledging members; empty
+	// keeps the connection string's setting. Journal requests journaled acks.
+	WriteConcern string
+	Journal      bool
+
+	// TLS settings supplement tls=true in the URI, e.g. for a private CA.
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	Overflow   bool
+	BufferSize int
+}
+
+// MongoSink inserts events into a MongoDB collection with unordered bulk
+// writes.
+type MongoSink struct {
+	client      *mongo.Client
+	coll        *mongo.Collection
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewMongoSink connects to MongoDB, prepares the collection and constructs a
+// new MongoSink.
+func NewMongoSink(cfg MongoSinkConfig) (*MongoSink, error) {
+	clientOpts := options.Client().ApplyURI(cfg.URI).SetAppName("eventrouter")
+	if cfg.TLSCAFile != "" || cfg.TLSCertFile != "" || cfg.TLSInsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts.SetTLSConfig(tlsConfig)
+	}
+
+	client, err := mongo.Connect(clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	collOpts := options.Collection()
+	if cfg.WriteConcern != "" || cfg.Journal {
+		wc, err := newMongoWriteConcern(cfg.WriteConcern, cfg.Journal)
+		if err != nil {
+			client.Disconnect(context.TODO())
+			return nil, err
+		}
+		collOpts.SetWriteConcern(wc)
+	}
+
+	db := client.Database(cfg.Database)
+	if err := prepareMongoCollection(db, cfg); err != nil {
+		client.Disconnect(context.TODO())
+		return nil, err
+	}
+
+	return &MongoSink{
+		client:      client,
+		coll:        db.Collection(cfg.Collection, collOpts),
+		clusterName: cfg.ClusterName,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// newMongoWriteConcern parses w as either "majority" (or another tag set
+// name) or a member count.
+func newMongoWriteConcern(w string, journal bool) (*writeconcern.WriteConcern, error) {
+	wc := &writeconcern.WriteConcern{}
+	if journal {
+		wc.Journal = &journal
+	}
+	if w == "" {
+		return wc, nil
+	}
+	if n, err := strconv.Atoi(w); err == nil {
+		if n < 0 {
+			return nil, errors.New("mongo write concern must not be negative")
+		}
+		wc.W = n
+	} else {
+		wc.W = w
+	}
+	return wc, nil
+}
+
+// prepareMongoCollection creates the capped collection or the TTL index
+// requested by cfg. Both operations are idempotent.
+func prepareMongoCollection(db *mongo.Database, cfg MongoSinkConfig) error {
+	ctx := context.TODO()
+	if cfg.CappedSizeBytes > 0 {
+		err := db.CreateCollection(ctx, cfg.Collection, options.CreateCollection().SetCapped(true).SetSizeInBytes(cfg.CappedSizeBytes))
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == mongoNamespaceExists {
+			return nil
+		}
+		return err
+	}
+
+	if cfg.TTL > 0 {
+		_, err := db.Collection(cfg.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "timestamp", Value: 1}},
+			Options: options.Index().SetName("timestamp_ttl").SetExpireAfterSeconds(int32(cfg.TTL / time.Second)),
+		})
+		return err
+	}
+	return nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (m *MongoSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh,
+// and writing it to the collection.
+func (m *MongoSink) Run(stopCh <-chan bool) {
+	defer m.client.Disconnect(context.TODO())
+	runBatches(m.eventCh, stopCh, m.drainEvents)
+}
+
+// drainEvents inserts an array of event data with a single unordered bulk
+// write, so one rejected document does not hold back the rest.
+func (m *MongoSink) drainEvents(events []EventData) {
+	models := make([]mongo.WriteModel, 0, len(events))
+	written := make([]EventData, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(eJSONBytes, false, &doc); err != nil {
+			glog.Warningf("Failed to convert event to BSON: %v", err)
+			continue
+		}
+
+		// timestamp is a BSON date so the TTL index and range queries work.
+		doc = append(doc,
+			bson.E{Key: "cluster", Value: m.clusterName},
+			bson.E{Key: "timestamp", Value: eventTimestamp(evt.Event)},
+		)
+		models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+		written = append(written, evt)
+	}
+	if len(models) == 0 {
+		return
+	}
+
+	_, err := m.coll.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		glog.Errorf("Failed to write %d events to MongoDB: %v", len(models), err)
+		// Only the rejected documents failed if the server reports them.
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+			for _, we := range bulkErr.WriteErrors {
+				deadLetter("mongodb", []EventData{written[we.Index]}, we)
+			}
+			return
+		}
+		deadLetter("mongodb", written, err)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// mqttPublishTimeout bounds how long a batch waits for its acknowledgements.
+const mqttPublishTimeout = 30 * time.Second
+
+// MQTTSinkConfig holds the options used to construct an MQTTSink.
+type MQTTSinkConfig struct {
+	// Brokers are URLs such as tcp://mosquitto:1883 or ssl://broker:8883.
+	Brokers []string
+
+	// ClientID identifies the persistent session, so it must be stable
+	// across restarts and unique per broker.
+	ClientID string
+
+	// Topic is a template such as "k8s/{cluster}/{namespace}/events"; see
+	// expandTopic for the supported placeholders.
+	Topic string
+
+	// QoS is 0, 1 or 2.
+	QoS byte
+
+	Username string
+	Password string
+
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	ClusterName string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// MQTTSink publishes events to an MQTT broker. The client uses a persistent
+// session and reconnects automatically; QoS 1 and 2 messages published
+// while disconnected are queued and delivered on reconnect.
+type MQTTSink struct {
+	client      mqtt.Client
+	topic       string
+	qos         byte
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewMQTTSink constructs a new MQTTSink and starts connecting to the
+// brokers in the background.
+func NewMQTTSink(cfg MQTTSinkConfig) (*MQTTSink, error) {
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid mqtt QoS %d", cfg.QoS)
+	}
+
+	opts := mqtt.NewClientOptions().
+		SetClientID(cfg.ClientID).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOrderMatters(false).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetOnConnectHandler(func(mqtt.Client) {
+			glog.Infof("Connected to MQTT broker")
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			glog.Warningf("Lost connection to MQTT broker: %v", err)
+		})
+	for _, broker := range cfg.Brokers {
+		opts.AddBroker(broker)
+	}
+	if cfg.TLSCAFile != "" || cfg.TLSCertFile != "" || cfg.TLSInsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client := mqtt.NewClient(opts)
+	// With ConnectRetry the token only completes once connected, so it is
+	// not waited on here.
+	client.Connect()
+
+	return &MQTTSink{
+		client:      client,
+		topic:       cfg.Topic,
+		qos:         cfg.QoS,
+		clusterName: cfg.ClusterName,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (m *MQTTSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh,
+// and publishing it to the broker.
+func (m *MQTTSink) Run(stopCh <-chan bool) {
+	defer m.client.Disconnect(250)
+	runBatches(m.eventCh, stopCh, m.drainEvents)
+}
+
+// drainEvents publishes an array of event data and waits for the broker to
+// acknowledge it.
+func (m *MQTTSink) drainEvents(events []EventData) {
+	// published are the events of tokens.
+	tokens := make([]mqtt.Token, 0, len(events))
+	published := make([]EventData, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		tokens = append(tokens, m.client.Publish(expandTopic(m.topic, m.clusterName, evt.Event), m.qos, false, eJSONBytes))
+		published = append(published, evt)
+	}
+
+	failed := 0
+	var lastErr error
+	deadline := time.Now().Add(mqttPublishTimeout)
+	for i, token := range tokens {
+		if !token.WaitTimeout(time.Until(deadline)) {
+			failed++
+			lastErr = fmt.Errorf("timed out waiting for acknowledgement")
+			deadLetter("mqtt", []EventData{published[i]}, lastErr)
+			continue
+		}
+		if err := token.Error(); err != nil {
+			failed++
+			lastErr = err
+			deadLetter("mqtt", []EventData{published[i]}, err)
+		}
+	}
+	if failed > 0 {
+		glog.Errorf("Failed to publish %d of %d events to MQTT: %v", failed, len(tokens), lastErr)
+	}
+}
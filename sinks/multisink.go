@@ -0,0 +1,139 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// multiChild is a sink of a MultiSink with the buffer feeding it.
+type multiChild struct {
+	name    string
+	sink    EventSinkInterface
+	eventCh channels.Channel
+}
+
+// MultiSink sends every event to several child sinks. Each child is fed
+// from its own buffer by its own goroutine, so a child whose UpdateEvents
+// panics, or blocks while its buffer discards, only loses its own events.
+// This does not cover the children's own Run goroutines: a panic there
+// still takes down the router.
+type MultiSink struct {
+	children []*multiChild
+}
+
+// NewMultiSink constructs a new MultiSink. overflow and bufferSize apply to
+// the buffer in front of each child, independent of the child's own.
+func NewMultiSink(names []string, sinks []EventSinkInterface, overflow bool, bufferSize int) *MultiSink {
+	m := &MultiSink{}
+	for i, sink := range sinks {
+		m.children = append(m.children, &multiChild{
+			name:    names[i],
+			sink:    sink,
+			eventCh: newEventChannel(overflow, bufferSize),
+		})
+	}
+	return m
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the event data
+// to every child's buffer, which are drained by Run.
+func (m *MultiSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	evt := NewEventData(eNew, eOld)
+	for _, c := range m.children {
+		c.eventCh.In() <- evt
+	}
+}
+
+// Run starts a goroutine per child, passing events from its buffer to it,
+// and waits for stopCh.
+func (m *MultiSink) Run(stopCh <-chan bool) {
+	done := make(chan bool)
+	for _, c := range m.children {
+		go c.run(done)
+	}
+	<-stopCh
+	close(done)
+}
+
+// run hands events to the child until done is closed.
+func (c *multiChild) run(done <-chan bool) {
+	for {
+		select {
+		case e := <-c.eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			c.update(evt)
+		case <-done:
+			return
+		}
+	}
+}
+
+// update passes one event to the child, recovering from panics so they do
+// not take down the router or the other children.
+func (c *multiChild) update(evt EventData) {
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Errorf("Sink %s panicked handling event %s/%s: %v", c.name, evt.Event.Namespace, evt.Event.Name, r)
+		}
+	}()
+	c.sink.UpdateEvents(evt.Event, evt.OldEvent)
+}
+
+// manufactureMultiSink manufactures the named child sinks. A child that
+// fails to start, e.g. because its destination is unreachable, is logged and
+// left out, unless none start.
+func manufactureMultiSink(names []string, overflow bool, bufferSize int) *MultiSink {
+	var started []string
+	var children []EventSinkInterface
+	for _, name := range names {
+		if name == "multi" || name == "tee" {
+			panic("multi sink cannot contain another multi sink")
+		}
+		sink, err := manufactureChild(name)
+		if err != nil {
+			glog.Errorf("Failed to start sink %s: %v", name, err)
+			continue
+		}
+		started = append(started, name)
+		children = append(children, sink)
+	}
+	if len(children) == 0 {
+		panic("multi sink specified but none of its sinks started")
+	}
+	glog.Infof("Multi sink sending to %v", started)
+	return NewMultiSink(started, children, overflow, bufferSize)
+}
+
+// manufactureChild manufactures the sink named name, turning a panic into an
+// error.
+func manufactureChild(name string) (sink EventSinkInterface, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return manufactureSink(name), nil
+}
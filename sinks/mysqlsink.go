@@ -0,0 +1,160 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// mysqlMaxRowsPerInsert bounds the size of a multi-row INSERT statement.
+const mysqlMaxRowsPerInsert = 500
+
+// mysqlSchema creates the events table. The filter columns are generated
+// from the JSON document, so they can never drift from it. %s is the
+// quoted table name.
+const mysqlSchema = "CREATE TABLE IF NOT EXISTS %s (" +
+	"id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY," +
+	"cluster VARCHAR(255) NOT NULL," +
+	"last_timestamp DATETIME(3) NOT NULL," +
+	"event JSON NOT NULL," +
+	"verb VARCHAR(16) AS (event->>'$.verb') STORED," +
+	"namespace VARCHAR(253) AS (event->>'$.event.involvedObject.namespace') STORED," +
+	"kind VARCHAR(255) AS (event->>'$.event.involvedObject.kind') STORED," +
+	"name VARCHAR(253) AS (event->>'$.event.involvedObject.name') STORED," +
+	"reason VARCHAR(255) AS (event->>'$.event.reason') STORED," +
+	"type VARCHAR(32) AS (event->>'$.event.type') STORED," +
+	"INDEX namespace_idx (namespace, last_timestamp)," +
+	"INDEX reason_idx (reason, last_timestamp)," +
+	"INDEX type_idx (type, last_timestamp)," +
+	"INDEX last_timestamp_idx (last_timestamp)" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+// MySQLSink stores events in a MySQL table with a JSON column, using
+// multi-row inserts.
+type MySQLSink struct {
+	db          *sql.DB
+	table       string
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+}
+
+// NewMySQLSink connects to dsn and constructs a new MySQLSink, creating the
+// table if it does not exist. dsn uses the go-sql-driver format, e.g.
+// user:password@tcp(mysql:3306)/events.
+func NewMySQLSink(dsn string, table string, clusterName string, retryMax int, overflow bool, bufferSize int) (*MySQLSink, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ParseTime = true
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	// Recycle connections before MySQL's wait_timeout closes them underneath us.
+	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(4)
+
+	m := &MySQLSink{
+		db:          db,
+		table:       "`" + strings.ReplaceAll(table, "`", "``") + "`",
+		clusterName: clusterName,
+		retryMax:    retryMax,
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}
+	if _, err := db.ExecContext(context.TODO(), fmt.Sprintf(mysqlSchema, m.table)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (m *MySQLSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	m.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through m.eventCh,
+// and inserting it into the table.
+func (m *MySQLSink) Run(stopCh <-chan bool) {
+	defer m.db.Close()
+	runBatches(m.eventCh, stopCh, m.drainEvents)
+}
+
+// drainEvents inserts an array of event data using multi-row inserts of up
+// to mysqlMaxRowsPerInsert rows each.
+func (m *MySQLSink) drainEvents(events []EventData) {
+	// batched are the events of args.
+	var args []interface{}
+	var batched []EventData
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		args = append(args, m.clusterName, eventTimestamp(evt.Event).UTC(), string(eJSONBytes))
+		batched = append(batched, evt)
+		if len(batched) == mysqlMaxRowsPerInsert {
+			m.insert(batched, args)
+			args, batched = nil, nil
+		}
+	}
+
+	if len(batched) > 0 {
+		m.insert(batched, args)
+	}
+}
+
+// insert runs a single multi-row INSERT. database/sql transparently replaces
+// broken connections; failures are retried with backoff so a restarting
+// server does not lose the batch. The events are dead lettered if it still
+// fails.
+func (m *MySQLSink) insert(events []EventData, args []interface{}) {
+	rows := len(events)
+	query := "INSERT INTO " + m.table + " (cluster, last_timestamp, event) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?),", rows), ",")
+
+	for attempt := 0; ; attempt++ {
+		_, err := m.db.ExecContext(context.TODO(), query, args...)
+		if err == nil {
+			return
+		}
+		if attempt >= m.retryMax {
+			glog.Errorf("Failed to insert %d events into MySQL: %v", rows, err)
+			deadLetter("mysql", events, err)
+			return
+		}
+		glog.Warningf("Failed to insert events into MySQL, retrying: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
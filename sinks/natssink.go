@@ -0,0 +1,171 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	v1 "k8s.io/api/core/v1"
+)
+
+// natsPublishTimeout bounds how long a batch waits for its JetStream acks.
+const natsPublishTimeout = 30 * time.Second
+
+// NATSSinkConfig holds the options used to construct a NATSSink.
+type NATSSinkConfig struct {
+	URL string
+
+	// Subject is a template such as "k8s.events.{namespace}.{reason}"; see
+	// expandSubject for the supported placeholders. A JetStream stream must
+	// already capture the resulting subjects.
+	Subject string
+
+	// CredsFile, Token and User/Password are alternative ways to authenticate.
+	CredsFile string
+	Token     string
+	User      string
+	Password  string
+
+	TLSEnabled  bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// NATSSink publishes events to NATS JetStream.
+type NATSSink struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+	eventCh channels.Channel
+}
+
+// NewNATSSink connects to NATS and constructs a new NATSSink. The connection
+// reconnects indefinitely; messages published while disconnected are held
+// in the client's reconnect buffer.
+func NewNATSSink(cfg NATSSinkConfig) (*NATSSink, error) {
+	opts := []nats.Option{
+		nats.Name("eventrouter"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			glog.Warningf("Disconnected from NATS: %v", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			glog.Infof("Reconnected to NATS at %s", nc.ConnectedUrl())
+		}),
+	}
+	switch {
+	case cfg.CredsFile != "":
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	case cfg.Token != "":
+		opts = append(opts, nats.Token(cfg.Token))
+	case cfg.User != "":
+		opts = append(opts, nats.UserInfo(cfg.User, cfg.Password))
+	}
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, false)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn, jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, msg *nats.Msg, err error) {
+		glog.Errorf("Failed to publish event to NATS subject %s: %v", msg.Subject, err)
+	}))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATSSink{
+		conn:    conn,
+		js:      js,
+		subject: cfg.Subject,
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (n *NATSSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	n.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through n.eventCh,
+// and publishing it to JetStream.
+func (n *NATSSink) Run(stopCh <-chan bool) {
+	defer n.conn.Close()
+	runBatches(n.eventCh, stopCh, n.drainEvents)
+}
+
+// drainEvents publishes an array of event data asynchronously, then waits
+// for JetStream to acknowledge the whole batch. Failed acks are logged by
+// the error handler registered in NewNATSSink, and dead lettered here.
+func (n *NATSSink) drainEvents(events []EventData) {
+	// published are the events of acks.
+	var acks []jetstream.PubAckFuture
+	var published []EventData
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		subject := expandSubject(n.subject, evt.Event)
+		ack, err := n.js.PublishAsync(subject, eJSONBytes)
+		if err != nil {
+			glog.Errorf("Failed to publish event to NATS subject %s: %v", subject, err)
+			deadLetter("nats", []EventData{evt}, err)
+			continue
+		}
+		acks = append(acks, ack)
+		published = append(published, evt)
+	}
+
+	select {
+	case <-n.js.PublishAsyncComplete():
+	case <-time.After(natsPublishTimeout):
+		glog.Errorf("Timed out waiting for %d JetStream acks", n.js.PublishAsyncPending())
+	}
+
+	for i, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			deadLetter("nats", []EventData{published[i]}, err)
+		default:
+			deadLetter("nats", []EventData{published[i]}, fmt.Errorf("timed out waiting for acknowledgement"))
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// newRelicMaxBatchBytes keeps the uncompressed payload well under the Log
+// API's 1MB compressed limit even for poorly compressible events.
+const newRelicMaxBatchBytes = 1000 * 1000
+
+// NewRelicSinkConfig holds the options used to construct a NewRelicSink.
+type NewRelicSinkConfig struct {
+	LicenseKey string
+
+	// Endpoint is the Log API URL; EU accounts use
+	// https://log-api.eu.newrelic.com/log/v1.
+	Endpoint    string
+	ClusterName string
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// newRelicLog is a single entry of a Log API request.
+type newRelicLog struct {
+	Timestamp  int64                  `json:"timestamp"`
+	Message    string                 `json:"message"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// newRelicPayload is one block of a Log API request.
+type newRelicPayload struct {
+	Common struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	} `json:"common"`
+	Logs []newRelicLog `json:"logs"`
+}
+
+// NewRelicSink sends events to the New Relic Log API. Event fields are mapped
+// onto the attribute names used by the New Relic Kubernetes integration, so
+// events can be correlated with the cluster's APM and infrastructure data.
+type NewRelicSink struct {
+	cfg     NewRelicSinkConfig
+	client  *http.Client
+	eventCh channels.Channel
+}
+
+// NewNewRelicSink constructs a new NewRelicSink.
+func NewNewRelicSink(cfg NewRelicSinkConfig) *NewRelicSink {
+	return &NewRelicSink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (n *NewRelicSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	n.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through n.eventCh,
+// and sending it to New Relic.
+func (n *NewRelicSink) Run(stopCh <-chan bool) {
+	runBatches(n.eventCh, stopCh, n.drainEvents)
+}
+
+// drainEvents sends an array of event data in as few Log API requests as the
+// payload limit allows.
+func (n *NewRelicSink) drainEvents(events []EventData) {
+	// batched are the events of logs.
+	var logs []newRelicLog
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(logs) > 0 && batchBytes+len(eJSONBytes) > newRelicMaxBatchBytes {
+			n.send(logs, batched)
+			logs, batched = nil, nil
+			batchBytes = 0
+		}
+		e := evt.Event
+		logs = append(logs, newRelicLog{
+			Timestamp: eventTimestamp(e).UnixMilli(),
+			Message:   string(eJSONBytes),
+			Attributes: map[string]interface{}{
+				"namespaceName":                  e.InvolvedObject.Namespace,
+				"event.verb":                     evt.Verb,
+				"event.type":                     e.Type,
+				"event.reason":                   e.Reason,
+				"event.message":                  e.Message,
+				"event.count":                    e.Count,
+				"event.involvedObject.kind":      e.InvolvedObject.Kind,
+				"event.involvedObject.name":      e.InvolvedObject.Name,
+				"event.involvedObject.namespace": e.InvolvedObject.Namespace,
+				"event.involvedObject.uid":       string(e.InvolvedObject.UID),
+				"event.source.component":         e.Source.Component,
+				"event.source.host":              e.Source.Host,
+			},
+		})
+		batched = append(batched, evt)
+		batchBytes += len(eJSONBytes)
+	}
+
+	if len(logs) > 0 {
+		n.send(logs, batched)
+	}
+}
+
+// send posts a single Log API request, dead lettering events if it fails.
+func (n *NewRelicSink) send(logs []newRelicLog, events []EventData) {
+	payload := newRelicPayload{Logs: logs}
+	payload.Common.Attributes = map[string]interface{}{
+		"logtype":     "kubernetes_event",
+		"clusterName": n.cfg.ClusterName,
+		"plugin.type": "eventrouter",
+	}
+
+	body, err := json.Marshal([]newRelicPayload{payload})
+	if err != nil {
+		glog.Warningf("Failed to json serialize new relic logs: %v", err)
+		return
+	}
+	body, err = gzipBytes(body)
+	if err != nil {
+		glog.Errorf("Failed to compress new relic logs: %v", err)
+		deadLetter("newrelic", events, err)
+		return
+	}
+
+	_, err = postWithRetry(n.client, n.cfg.Endpoint, body, n.cfg.RetryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("X-License-Key", n.cfg.LicenseKey)
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d logs to New Relic: %v", len(logs), err)
+		deadLetter("newrelic", events, err)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// eventFilter selects the events a notification sink forwards. An empty set
+// matches everything.
+type eventFilter struct {
+	types      map[string]bool
+	reasons    map[string]bool
+	namespaces map[string]bool
+}
+
+// newEventFilter constructs an eventFilter from lists of allowed event
+// types, reasons and involved object namespaces.
+func newEventFilter(types, reasons, namespaces []string) eventFilter {
+	return eventFilter{
+		types:      stringSet(types),
+		reasons:    stringSet(reasons),
+		namespaces: stringSet(namespaces),
+	}
+}
+
+// match reports whether e passes the filter.
+func (f eventFilter) match(e *v1.Event) bool {
+	return matchSet(f.types, e.Type) &&
+		matchSet(f.reasons, e.Reason) &&
+		matchSet(f.namespaces, e.InvolvedObject.Namespace)
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func matchSet(set map[string]bool, value string) bool {
+	return len(set) == 0 || set[value]
+}
+
+// lowerKeys returns a copy of m with lower cased keys, for maps read from
+// configuration, whose keys lose their case.
+func lowerKeys(m map[string]string) map[string]string {
+	lower := make(map[string]string, len(m))
+	for k, v := range m {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower
+}
+
+// deduplicator suppresses repeats of the same event within a window, so a
+// crashlooping pod produces one notification per window rather than one per
+// restart. It is only used from a sink's Run goroutine and is not safe for
+// concurrent use.
+type deduplicator struct {
+	window    time.Duration
+	entries   map[string]*dedupEntry
+	lastPrune time.Time
+}
+
+type dedupEntry struct {
+	sent       time.Time
+	suppressed int
+}
+
+// newDeduplicator constructs a deduplicator. A zero window disables
+// deduplication.
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{window: window, entries: map[string]*dedupEntry{}}
+}
+
+// observe records an occurrence of key at now. It reports whether it should
+// be sent, and if so how many occurrences were suppressed since the last one
+// that was.
+func (d *deduplicator) observe(key string, now time.Time) (bool, int) {
+	if d.window <= 0 {
+		return true, 0
+	}
+	if now.Sub(d.lastPrune) > d.window {
+		for k, entry := range d.entries {
+			if now.Sub(entry.sent) > d.window {
+				delete(d.entries, k)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	entry, ok := d.entries[key]
+	if ok && now.Sub(entry.sent) < d.window {
+		entry.suppressed++
+		return false, 0
+	}
+	suppressed := 0
+	if ok {
+		suppressed = entry.suppressed
+	}
+	d.entries[key] = &dedupEntry{sent: now}
+	return true, suppressed
+}
+
+// dedupKey identifies repeats of an event: the same reason reported for the
+// same object.
+func dedupKey(e *v1.Event) string {
+	o := e.InvolvedObject
+	return strings.Join([]string{o.Namespace, o.Kind, o.Name, e.Reason}, "/")
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// notification is the data notification templates are executed with.
+type notification struct {
+	Cluster   string
+	Verb      string
+	Event     *v1.Event
+	Timestamp time.Time
+
+	// Suppressed counts repeats of this event dropped by deduplication
+	// since the previous notification for it.
+	Suppressed int
+}
+
+// newNotification constructs the template data for evt.
+func newNotification(evt EventData, clusterName string, suppressed int) notification {
+	return notification{
+		Cluster:    clusterName,
+		Verb:       evt.Verb,
+		Event:      evt.Event,
+		Timestamp:  eventTimestamp(evt.Event),
+		Suppressed: suppressed,
+	}
+}
+
+// notificationFuncs are available to notification templates in addition to
+// the text/template builtins.
+var notificationFuncs = template.FuncMap{
+	// json encodes a value, e.g. a string that is spliced into a JSON
+	// payload.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// truncate shortens s to at most n runes.
+	"truncate": func(n int, s string) string {
+		r := []rune(s)
+		if len(r) <= n {
+			return s
+		}
+		return string(r[:n-1]) + "…"
+	},
+}
+
+// newNotificationTemplate parses a notification template.
+func newNotificationTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(notificationFuncs).Parse(text)
+}
+
+// executeTemplate renders tmpl for n.
+func executeTemplate(tmpl *template.Template, n notification) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// errNullInjected is the error NullSink fails batches with.
+var errNullInjected = errors.New("injected failure")
+
+// NullSinkConfig holds the options used to construct a NullSink.
+type NullSinkConfig struct {
+	// Latency is slept per batch, with up to Jitter added, to stand in for
+	// a destination's round trip.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ErrorRate is the fraction of batches, between 0 and 1, that fail.
+	// Failed batches are retried up to RetryMax times like a real sink's.
+	ErrorRate float64
+	RetryMax  int
+
+	// StatsInterval is how often totals are logged.
+	StatsInterval time.Duration
+
+	Overflow   bool
+	BufferSize int
+}
+
+// NullSink discards events after doing the work every sink does: buffering
+// them, and serializing and deserializing them as JSON. It is meant for
+// benchmarking the router and testing its behavior under a slow or failing
+// destination without running one.
+type NullSink struct {
+	latency       time.Duration
+	jitter        time.Duration
+	errorRate     float64
+	retryMax      int
+	statsInterval time.Duration
+	eventCh       channels.Channel
+
+	events, bytes, failed int
+	lastStats             time.Time
+}
+
+// NewNullSink constructs a new NullSink.
+func NewNullSink(cfg NullSinkConfig) *NullSink {
+	return &NullSink{
+		latency:       cfg.Latency,
+		jitter:        cfg.Jitter,
+		errorRate:     cfg.ErrorRate,
+		retryMax:      cfg.RetryMax,
+		statsInterval: cfg.StatsInterval,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+		lastStats:     time.Now(),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (n *NullSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	n.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through n.eventCh,
+// and discarding it.
+func (n *NullSink) Run(stopCh <-chan bool) {
+	runBatches(n.eventCh, stopCh, n.drainEvents)
+	n.logStats()
+}
+
+// drainEvents round trips an array of event data through JSON and then
+// drops it, after the configured latency and failures.
+func (n *NullSink) drainEvents(events []EventData) {
+	size := 0
+	for _, evt := range events {
+		b, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		var decoded EventData
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			glog.Warningf("Failed to json deserialize event: %v", err)
+			continue
+		}
+		size += len(b)
+	}
+
+	var err error
+	for attempt := 0; attempt <= n.retryMax; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+		if err = n.deliver(); err == nil {
+			break
+		}
+		glog.V(2).Infof("Null sink batch of %d events failed on attempt %d: %v", len(events), attempt+1, err)
+	}
+	if err != nil {
+		glog.Errorf("Failed to send %d events to the null sink: %v", len(events), err)
+		deadLetter("null", events, err)
+		n.failed += len(events)
+	} else {
+		n.events += len(events)
+		n.bytes += size
+	}
+
+	if n.statsInterval > 0 && time.Since(n.lastStats) >= n.statsInterval {
+		n.logStats()
+	}
+}
+
+// deliver simulates sending a batch.
+func (n *NullSink) deliver() error {
+	if d := n.latency; d > 0 || n.jitter > 0 {
+		if n.jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(n.jitter)))
+		}
+		time.Sleep(d)
+	}
+	if n.errorRate > 0 && rand.Float64() < n.errorRate {
+		return errNullInjected
+	}
+	return nil
+}
+
+// logStats logs the totals since the last call and resets them.
+func (n *NullSink) logStats() {
+	elapsed := time.Since(n.lastStats)
+	glog.Infof("Null sink discarded %d events (%d bytes of JSON, %.1f events/s), %d failed, in %v",
+		n.events, n.bytes, float64(n.events)/elapsed.Seconds(), n.failed, elapsed.Round(time.Millisecond))
+	n.events, n.bytes, n.failed = 0, 0, 0
+	n.lastStats = time.Now()
+}
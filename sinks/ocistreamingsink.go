@@ -0,0 +1,211 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	"github.com/oracle/oci-go-sdk/v65/streaming"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ociStreamingMaxRequestBytes is the most a single PutMessages request may
+// carry, which is also the limit for a single message.
+const ociStreamingMaxRequestBytes = 1024 * 1024
+
+// OCIStreamingSinkConfig holds the options used to construct an
+// OCIStreamingSink.
+type OCIStreamingSinkConfig struct {
+	// StreamID is the stream's OCID.
+	StreamID string
+
+	// Endpoint is the stream's messages endpoint, e.g.
+	// https://cell-1.streaming.us-ashburn-1.oci.oraclecloud.com.
+	Endpoint string
+
+	// Auth is "instance_principal", "workload_identity" for OKE workload
+	// identity, or "config_file" to read ConfigFile's Profile, which
+	// defaults to ~/.oci/config.
+	Auth       string
+	ConfigFile string
+	Profile    string
+
+	// KeyBy selects the message key, either "namespace" or "uid".
+	KeyBy    string
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// OCIStreamingSink sends batches of events to an Oracle Cloud
+// Infrastructure Streaming stream through the native PutMessages API, so
+// OKE clusters can authenticate as the node's instance principal or the
+// pod's workload identity instead of with a user's auth token. Streams can
+// also be reached through their Kafka-compatible endpoint with the Kafka
+// sink, which the "ocistreaming" sink configures with protocol "kafka".
+type OCIStreamingSink struct {
+	streamID string
+	keyBy    string
+	retryMax int
+	client   streaming.StreamClient
+	eventCh  channels.Channel
+}
+
+// NewOCIStreamingSink constructs a new OCIStreamingSink.
+func NewOCIStreamingSink(cfg OCIStreamingSinkConfig) (*OCIStreamingSink, error) {
+	if cfg.KeyBy != "namespace" && cfg.KeyBy != "uid" {
+		return nil, fmt.Errorf("invalid ocistreaming key selection %q, expected namespace or uid", cfg.KeyBy)
+	}
+
+	var provider common.ConfigurationProvider
+	var err error
+	switch cfg.Auth {
+	case "instance_principal":
+		provider, err = auth.InstancePrincipalConfigurationProvider()
+	case "workload_identity":
+		provider, err = auth.OkeWorkloadIdentityConfigurationProvider()
+	case "config_file":
+		if cfg.ConfigFile == "" {
+			provider = common.DefaultConfigProvider()
+		} else {
+			provider, err = common.ConfigurationProviderFromFileWithProfile(cfg.ConfigFile, cfg.Profile, "")
+		}
+	default:
+		return nil, fmt.Errorf("invalid ocistreaming auth %q, expected instance_principal, workload_identity or config_file", cfg.Auth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := streaming.NewStreamClientWithConfigurationProvider(provider, cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OCIStreamingSink{
+		streamID: cfg.StreamID,
+		keyBy:    cfg.KeyBy,
+		retryMax: cfg.RetryMax,
+		client:   client,
+		eventCh:  newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (o *OCIStreamingSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	o.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through o.eventCh,
+// and sending it to the stream.
+func (o *OCIStreamingSink) Run(stopCh <-chan bool) {
+	runBatches(o.eventCh, stopCh, o.drainEvents)
+}
+
+// drainEvents sends an array of event data in as few PutMessages requests
+// as the request size limit allows.
+func (o *OCIStreamingSink) drainEvents(events []EventData) {
+	// batched are the events of batch.
+	var batch []streaming.PutMessagesDetailsEntry
+	var batched []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		key := []byte(kafkaMessageKey(o.keyBy, evt.Event))
+		size := len(eJSONBytes) + len(key)
+		if size > ociStreamingMaxRequestBytes {
+			glog.Warningf("Dropping event %s/%s of %d bytes, larger than an OCI Streaming message may be", evt.Event.Namespace, evt.Event.Name, size)
+			deadLetter("ocistreaming", []EventData{evt}, fmt.Errorf("%d bytes exceeds the OCI Streaming request limit", size))
+			continue
+		}
+		if batchBytes+size > ociStreamingMaxRequestBytes {
+			o.put(batch, batched)
+			batch, batched, batchBytes = nil, nil, 0
+		}
+		batch = append(batch, streaming.PutMessagesDetailsEntry{Key: key, Value: eJSONBytes})
+		batched = append(batched, evt)
+		batchBytes += size
+	}
+	if len(batch) > 0 {
+		o.put(batch, batched)
+	}
+}
+
+// put sends a single PutMessages request, retrying the request or just the
+// messages that failed with backoff up to retryMax times. The events of the
+// messages are dead lettered if they still fail.
+func (o *OCIStreamingSink) put(messages []streaming.PutMessagesDetailsEntry, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+
+		resp, err := o.client.PutMessages(context.TODO(), streaming.PutMessagesRequest{
+			StreamId:           common.String(o.streamID),
+			PutMessagesDetails: streaming.PutMessagesDetails{Messages: messages},
+		})
+		if err != nil {
+			if attempt >= o.retryMax {
+				glog.Errorf("Failed to put %d events to OCI Streaming: %v", len(messages), err)
+				deadLetter("ocistreaming", events, err)
+				return
+			}
+			glog.Warningf("Failed to put events to OCI Streaming, retrying: %v", err)
+			continue
+		}
+		if resp.Failures == nil || *resp.Failures == 0 {
+			return
+		}
+
+		// Entries are in the same order as the messages; the failed ones
+		// carry an error code.
+		var failed []streaming.PutMessagesDetailsEntry
+		var failedEvents []EventData
+		var lastErr string
+		for i, entry := range resp.Entries {
+			if entry.Error != nil && i < len(messages) {
+				failed = append(failed, messages[i])
+				failedEvents = append(failedEvents, events[i])
+				lastErr = *entry.Error
+				if entry.ErrorMessage != nil {
+					lastErr += ": " + *entry.ErrorMessage
+				}
+			}
+		}
+		if attempt >= o.retryMax {
+			glog.Errorf("Failed to put %d events to OCI Streaming: %s", len(failed), lastErr)
+			deadLetter("ocistreaming", failedEvents, errors.New(lastErr))
+			return
+		}
+		glog.Warningf("Failed to put %d events to OCI Streaming, retrying: %s", len(failed), lastErr)
+		messages, events = failed, failedEvents
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/eapache/channels"
+	v1 "k8s.io/api/core/v1"
+)
+
+// OpenSearchSinkConfig holds the options used to construct an OpenSearchSink.
+type OpenSearchSinkConfig struct {
+	// URL is the domain endpoint, e.g. https://search-xyz.us-east-1.es.amazonaws.com.
+	URL    string
+	Region string
+
+	// Service is the SigV4 signing name: "es" for managed domains or "aoss"
+	// for OpenSearch Serverless collections.
+	Service string
+
+	// IndexPrefix and IndexDateLayout name the time-based indices. Daily
+	// indices sharing a prefix can be managed by a single ISM policy with an
+	// index pattern such as "k8s-events-*".
+	IndexPrefix     string
+	IndexDateLayout string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// OpenSearchSink indexes events into Amazon OpenSearch Service through the
+// _bulk API, signing every request with SigV4.
+type OpenSearchSink struct {
+	indexer       *bulkIndexer
+	flushEvents   int
+	flushInterval time.Duration
+	eventCh       channels.Channel
+}
+
+// NewOpenSearchSink constructs a new OpenSearchSink. Credentials are taken
+// from the default AWS credential chain.
+func NewOpenSearchSink(cfg OpenSearchSinkConfig) (*OpenSearchSink, error) {
+	if err := checkFlushInterval("opensearch", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	awsConfig, err := newAWSConfig(cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	signer := v4.NewSigner()
+
+	indexer := &bulkIndexer{
+		sink:            "opensearch",
+		url:             cfg.URL,
+		indexPrefix:     cfg.IndexPrefix,
+		indexDateLayout: cfg.IndexDateLayout,
+		retryMax:        cfg.RetryMax,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		authorize: func(req *http.Request, body []byte) error {
+			creds, err := awsConfig.Credentials.Retrieve(context.TODO())
+			if err != nil {
+				return err
+			}
+			hash := sha256.Sum256(body)
+			payloadHash := hex.EncodeToString(hash[:])
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+			return signer.SignHTTP(context.TODO(), creds, req, payloadHash, cfg.Service, awsConfig.Region, time.Now())
+		},
+	}
+
+	return &OpenSearchSink{
+		indexer:       indexer,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (o *OpenSearchSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	o.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through o.eventCh,
+// and indexing it in batches.
+func (o *OpenSearchSink) Run(stopCh <-chan bool) {
+	runTimedBatches(o.eventCh, stopCh, o.flushEvents, o.flushInterval, o.indexer.index)
+}
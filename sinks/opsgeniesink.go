@@ -0,0 +1,243 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Opsgenie field limits.
+const (
+	opsgenieMaxMessage     = 130
+	opsgenieMaxAlias       = 512
+	opsgenieMaxDescription = 15000
+)
+
+// opsgenieAlert is a create alert request.
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+}
+
+// OpsgenieSinkConfig holds the options used to construct an OpsgenieSink.
+type OpsgenieSinkConfig struct {
+	// APIURL is https://api.opsgenie.com, or https://api.eu.opsgenie.com
+	// for EU accounts.
+	APIURL string
+	APIKey string
+
+	// Reasons, when set, limits the Warning events that create alerts.
+	Reasons []string
+
+	// Priorities maps reasons to P1 through P5; other alerts are P3.
+	Priorities map[string]string
+
+	// CloseReasons maps the reason of a Normal event to the reason of the
+	// alert it closes on the same object, e.g. NodeReady closes
+	// NodeNotReady.
+	CloseReasons map[string]string
+
+	// Tags are added to every alert, next to the namespace and kind.
+	Tags []string
+
+	// DedupWindow limits how often repeats of an event are re-sent.
+	// Opsgenie itself folds repeats into the open alert with the same alias.
+	DedupWindow time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// OpsgenieSink creates Opsgenie alerts from Warning events and closes them
+// when a corresponding Normal event arrives.
+type OpsgenieSink struct {
+	alertsURL    string
+	apiKey       string
+	filter       eventFilter
+	priorities   map[string]string
+	closeReasons map[string]string
+	tags         []string
+	dedup        *deduplicator
+	clusterName  string
+	retryMax     int
+	client       *http.Client
+	eventCh      channels.Channel
+}
+
+// NewOpsgenieSink constructs a new OpsgenieSink. Reason keys are matched
+// case insensitively, as configuration maps lose their case.
+func NewOpsgenieSink(cfg OpsgenieSinkConfig) *OpsgenieSink {
+	return &OpsgenieSink{
+		alertsURL:    strings.TrimSuffix(cfg.APIURL, "/") + "/v2/alerts",
+		apiKey:       cfg.APIKey,
+		filter:       newEventFilter([]string{v1.EventTypeWarning}, cfg.Reasons, nil),
+		priorities:   lowerKeys(cfg.Priorities),
+		closeReasons: lowerKeys(cfg.CloseReasons),
+		tags:         cfg.Tags,
+		dedup:        newDeduplicator(cfg.DedupWindow),
+		clusterName:  cfg.ClusterName,
+		retryMax:     cfg.RetryMax,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		eventCh:      newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes events that
+// create or close alerts to the event channel, which is drained by Run.
+func (o *OpsgenieSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !o.filter.match(eNew) && o.closes(eNew) == "" {
+		return
+	}
+	o.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through o.eventCh,
+// and sending it to Opsgenie.
+func (o *OpsgenieSink) Run(stopCh <-chan bool) {
+	runBatches(o.eventCh, stopCh, o.drainEvents)
+}
+
+// closes returns the reason of the alert a Normal event closes, if any.
+func (o *OpsgenieSink) closes(e *v1.Event) string {
+	if e.Type != v1.EventTypeNormal {
+		return ""
+	}
+	return o.closeReasons[strings.ToLower(e.Reason)]
+}
+
+// alias identifies the alert for reason on e's involved object.
+func (o *OpsgenieSink) alias(e *v1.Event, reason string) string {
+	obj := e.InvolvedObject
+	alias := strings.Join([]string{o.clusterName, obj.Namespace, obj.Kind, obj.Name, strings.ToLower(reason)}, "/")
+	if len(alias) > opsgenieMaxAlias {
+		alias = alias[:opsgenieMaxAlias]
+	}
+	return alias
+}
+
+// drainEvents sends one request per event.
+func (o *OpsgenieSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		if reason := o.closes(evt.Event); reason != "" {
+			o.close(evt, reason)
+			continue
+		}
+
+		alias := o.alias(evt.Event, evt.Event.Reason)
+		if send, _ := o.dedup.observe(alias, time.Now()); !send {
+			continue
+		}
+		o.create(evt, alias)
+	}
+}
+
+// create opens an alert, or adds an occurrence to the open one with the
+// same alias.
+func (o *OpsgenieSink) create(evt EventData, alias string) {
+	e := evt.Event
+	priority, ok := o.priorities[strings.ToLower(e.Reason)]
+	if !ok {
+		priority = "P3"
+	}
+
+	details := map[string]string{}
+	for k, v := range flattenEventData(evt) {
+		if s, ok := v.(string); ok && s != "" && k != "message" {
+			details[k] = s
+		}
+	}
+	if o.clusterName != "" {
+		details["cluster"] = o.clusterName
+	}
+
+	tags := append([]string{}, o.tags...)
+	for _, tag := range []string{e.InvolvedObject.Namespace, e.InvolvedObject.Kind} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	body, err := json.Marshal(opsgenieAlert{
+		Message:     truncateRunes(e.InvolvedObject.Kind+" "+e.InvolvedObject.Namespace+"/"+e.InvolvedObject.Name+": "+e.Reason, opsgenieMaxMessage),
+		Alias:       alias,
+		Description: truncateRunes(e.Message, opsgenieMaxDescription),
+		Tags:        tags,
+		Details:     details,
+		Entity:      e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+		Source:      "eventrouter",
+		Priority:    priority,
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize opsgenie alert: %v", err)
+		return
+	}
+	if err := o.post(o.alertsURL, body); err != nil {
+		glog.Errorf("Failed to create Opsgenie alert %s: %v", alias, err)
+		deadLetter("opsgenie", []EventData{evt}, err)
+	}
+}
+
+// close closes the alert for reason on evt's involved object.
+func (o *OpsgenieSink) close(evt EventData, reason string) {
+	e := evt.Event
+	alias := o.alias(e, reason)
+	body, err := json.Marshal(map[string]string{
+		"source": "eventrouter",
+		"note":   e.Reason + ": " + e.Message,
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize opsgenie close request: %v", err)
+		return
+	}
+
+	closeURL := o.alertsURL + "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+	err = o.post(closeURL, body)
+	if statusErr, ok := err.(*httpStatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		// No open alert for this object; nothing to close.
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to close Opsgenie alert %s: %v", alias, err)
+		deadLetter("opsgenie", []EventData{evt}, err)
+	}
+}
+
+// post sends an authenticated request to the alert API.
+func (o *OpsgenieSink) post(url string, body []byte) error {
+	_, err := postWithRetry(o.client, url, body, o.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "GenieKey "+o.apiKey)
+	})
+	return err
+}
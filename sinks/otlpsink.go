@@ -0,0 +1,353 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+)
+
+// otlpScopeName identifies eventrouter as the instrumentation scope of the
+// exported log records.
+const otlpScopeName = "github.com/heptiolabs/eventrouter"
+
+// otlpKindAttributes are the semantic convention resource attributes for
+// involved object kinds that have them. Other kinds only get the generic
+// k8s.object.* attributes.
+var otlpKindAttributes = map[string]string{
+	"Pod":         "k8s.pod",
+	"Node":        "k8s.node",
+	"Namespace":   "k8s.namespace",
+	"Deployment":  "k8s.deployment",
+	"ReplicaSet":  "k8s.replicaset",
+	"StatefulSet": "k8s.statefulset",
+	"DaemonSet":   "k8s.daemonset",
+	"Job":         "k8s.job",
+	"CronJob":     "k8s.cronjob",
+}
+
+// OTLPSinkConfig holds the options used to construct an OTLPSink.
+type OTLPSinkConfig struct {
+	// Protocol is "grpc" or "http", the latter being OTLP/HTTP with
+	// protobuf payloads.
+	Protocol string
+
+	// Endpoint is a gRPC target such as collector:4317, or the base URL of
+	// an OTLP/HTTP receiver such as http://collector:4318.
+	Endpoint string
+
+	// Headers are sent with every export, e.g. for authentication.
+	Headers map[string]string
+	Gzip    bool
+
+	// TLS is only used for gRPC; OTLP/HTTP takes it from an https URL.
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	ClusterName string
+
+	// BatchSize and FlushInterval match the defaults of the OpenTelemetry
+	// batch log record processor.
+	BatchSize     int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// OTLPSink exports events as OpenTelemetry log records to any OTLP receiver,
+// typically an OpenTelemetry collector. Records are grouped into one
+// resource per involved object.
+type OTLPSink struct {
+	conn          *grpc.ClientConn
+	client        collogspb.LogsServiceClient
+	httpClient    *http.Client
+	url           string
+	headers       map[string]string
+	gzip          bool
+	clusterName   string
+	batchSize     int
+	flushInterval time.Duration
+	retryMax      int
+	eventCh       channels.Channel
+}
+
+// NewOTLPSink constructs a new OTLPSink.
+func NewOTLPSink(cfg OTLPSinkConfig) (*OTLPSink, error) {
+	if err := checkFlushInterval("otlp", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	o := &OTLPSink{
+		headers:       cfg.Headers,
+		gzip:          cfg.Gzip,
+		clusterName:   cfg.ClusterName,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+
+	switch cfg.Protocol {
+	case "grpc":
+		creds := insecure.NewCredentials()
+		if cfg.TLSEnabled {
+			tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+			if err != nil {
+				return nil, err
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		o.conn = conn
+		o.client = collogspb.NewLogsServiceClient(conn)
+	case "http":
+		o.url = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/logs"
+		o.httpClient = &http.Client{Timeout: 30 * time.Second}
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, expected grpc or http", cfg.Protocol)
+	}
+	return o, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (o *OTLPSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	o.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through o.eventCh,
+// and exporting it in batches.
+func (o *OTLPSink) Run(stopCh <-chan bool) {
+	runTimedBatches(o.eventCh, stopCh, o.batchSize, o.flushInterval, o.drainEvents)
+	if o.conn != nil {
+		o.conn.Close()
+	}
+}
+
+// drainEvents exports an array of event data in a single request.
+func (o *OTLPSink) drainEvents(events []EventData) {
+	req := o.newExportRequest(events, time.Now())
+
+	var resp *collogspb.ExportLogsServiceResponse
+	var err error
+	if o.client != nil {
+		resp, err = o.exportGRPC(req)
+	} else {
+		resp, err = o.exportHTTP(req)
+	}
+	if err != nil {
+		glog.Errorf("Failed to export %d events over OTLP: %v", len(events), err)
+		deadLetter("otlp", events, err)
+		return
+	}
+	if ps := resp.GetPartialSuccess(); ps.GetRejectedLogRecords() > 0 {
+		glog.Warningf("OTLP receiver rejected %d of %d events: %s", ps.GetRejectedLogRecords(), len(events), ps.GetErrorMessage())
+	}
+}
+
+// exportGRPC calls LogsService.Export, retrying the status codes the OTLP
+// specification marks as retryable.
+func (o *OTLPSink) exportGRPC(req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	ctx := context.Background()
+	if len(o.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.headers))
+	}
+	var opts []grpc.CallOption
+	if o.gzip {
+		opts = append(opts, grpc.UseCompressor(gzip.Name))
+	}
+
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		resp, err := o.client.Export(callCtx, req, opts...)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+			codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+		default:
+			return nil, err
+		}
+		if attempt >= o.retryMax {
+			return nil, err
+		}
+		glog.V(2).Infof("Retrying OTLP export: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// exportHTTP posts the request as binary protobuf to the /v1/logs endpoint.
+func (o *OTLPSink) exportHTTP(req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if o.gzip {
+		if body, err = gzipBytes(body); err != nil {
+			return nil, err
+		}
+	}
+
+	respBody, err := postWithRetry(o.httpClient, o.url, body, o.retryMax, func(r *http.Request) {
+		r.Header.Set("Content-Type", "application/x-protobuf")
+		if o.gzip {
+			r.Header.Set("Content-Encoding", "gzip")
+		}
+		for k, v := range o.headers {
+			r.Header.Set(k, v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if err := proto.Unmarshal(respBody, resp); err != nil {
+		glog.V(2).Infof("Ignoring undecodable OTLP response: %v", err)
+	}
+	return resp, nil
+}
+
+// newExportRequest converts event data to log records, with one
+// ResourceLogs per involved object.
+func (o *OTLPSink) newExportRequest(events []EventData, observed time.Time) *collogspb.ExportLogsServiceRequest {
+	req := &collogspb.ExportLogsServiceRequest{}
+	scopes := map[string]*logspb.ScopeLogs{}
+	for _, evt := range events {
+		obj := evt.Event.InvolvedObject
+		key := obj.Kind + "/" + obj.Namespace + "/" + obj.Name + "/" + string(obj.UID)
+		sl, ok := scopes[key]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: otlpScopeName}}
+			scopes[key] = sl
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{
+				Resource:  &resourcepb.Resource{Attributes: o.resourceAttributes(obj)},
+				ScopeLogs: []*logspb.ScopeLogs{sl},
+			})
+		}
+		sl.LogRecords = append(sl.LogRecords, newOTLPLogRecord(evt, observed))
+	}
+	return req
+}
+
+// resourceAttributes describes the cluster and the event's involved object.
+func (o *OTLPSink) resourceAttributes(obj v1.ObjectReference) []*commonpb.KeyValue {
+	attrs := []*commonpb.KeyValue{otlpString("service.name", "eventrouter")}
+	if o.clusterName != "" {
+		attrs = append(attrs, otlpString("k8s.cluster.name", o.clusterName))
+	}
+	if obj.Namespace != "" && obj.Kind != "Namespace" {
+		attrs = append(attrs, otlpString("k8s.namespace.name", obj.Namespace))
+	}
+	if prefix, ok := otlpKindAttributes[obj.Kind]; ok {
+		attrs = append(attrs, otlpString(prefix+".name", obj.Name))
+		if obj.UID != "" {
+			attrs = append(attrs, otlpString(prefix+".uid", string(obj.UID)))
+		}
+	}
+	attrs = append(attrs,
+		otlpString("k8s.object.kind", obj.Kind),
+		otlpString("k8s.object.name", obj.Name),
+	)
+	if obj.UID != "" {
+		attrs = append(attrs, otlpString("k8s.object.uid", string(obj.UID)))
+	}
+	if obj.APIVersion != "" {
+		attrs = append(attrs, otlpString("k8s.object.api_version", obj.APIVersion))
+	}
+	return attrs
+}
+
+// newOTLPLogRecord converts a single event to a log record whose body is
+// the event message. Warning events map to WARN and everything else to INFO.
+func newOTLPLogRecord(evt EventData, observed time.Time) *logspb.LogRecord {
+	e := evt.Event
+	severity := logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	if e.Type == v1.EventTypeWarning {
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	}
+
+	attrs := []*commonpb.KeyValue{
+		otlpString("k8s.event.name", e.Name),
+		otlpString("k8s.event.uid", string(e.UID)),
+		otlpString("k8s.event.reason", e.Reason),
+		otlpString("k8s.event.verb", evt.Verb),
+		{Key: "k8s.event.count", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(e.Count)}}},
+	}
+	if e.Action != "" {
+		attrs = append(attrs, otlpString("k8s.event.action", e.Action))
+	}
+	if e.InvolvedObject.FieldPath != "" {
+		attrs = append(attrs, otlpString("k8s.object.field_path", e.InvolvedObject.FieldPath))
+	}
+	if e.Source.Component != "" {
+		attrs = append(attrs, otlpString("k8s.event.source.component", e.Source.Component))
+	}
+	if e.Source.Host != "" {
+		attrs = append(attrs, otlpString("k8s.event.source.host", e.Source.Host))
+	}
+	if e.ReportingController != "" {
+		attrs = append(attrs, otlpString("k8s.event.reporting_controller", e.ReportingController))
+	}
+
+	// A zero TimeUnixNano tells the receiver the time is unknown.
+	var ts uint64
+	if t := eventTimestamp(e); !t.IsZero() {
+		ts = uint64(t.UnixNano())
+	}
+
+	return &logspb.LogRecord{
+		TimeUnixNano:         ts,
+		ObservedTimeUnixNano: uint64(observed.UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         e.Type,
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: e.Message}},
+		Attributes:           attrs,
+	}
+}
+
+func otlpString(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
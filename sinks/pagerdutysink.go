@@ -0,0 +1,237 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// pagerDutyEnqueueURL is the Events API v2 endpoint.
+const pagerDutyEnqueueURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyMaxDedupKey is the longest dedup key PagerDuty accepts.
+const pagerDutyMaxDedupKey = 255
+
+// pagerDutyEvent is an Events API v2 request.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// pagerDutyPayload describes the alert of a trigger event.
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// PagerDutySinkConfig holds the options used to construct a PagerDutySink.
+type PagerDutySinkConfig struct {
+	// RoutingKey is the integration key of the default service;
+	// NamespaceRoutingKeys overrides it per involved object namespace.
+	RoutingKey           string
+	NamespaceRoutingKeys map[string]string
+
+	// Types and Reasons select the events that trigger incidents.
+	Types   []string
+	Reasons []string
+
+	// Severities maps reasons to critical, error, warning or info. Other
+	// Warning events are "warning" and everything else "info".
+	Severities map[string]string
+
+	// AcknowledgeReasons and ResolveReasons map a reason to the reason of
+	// the incident it acknowledges or resolves on the same object, e.g.
+	// NodeReady resolves NodeNotReady.
+	AcknowledgeReasons map[string]string
+	ResolveReasons     map[string]string
+
+	// DedupWindow limits how often repeats of an event re-trigger.
+	DedupWindow time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// PagerDutySink triggers, acknowledges and resolves PagerDuty incidents from
+// events. Incidents are keyed by the involved object and the triggering
+// reason, so repeats of an event update one incident.
+type PagerDutySink struct {
+	routingKey     string
+	routingKeys    map[string]string
+	filter         eventFilter
+	severities     map[string]string
+	ackReasons     map[string]string
+	resolveReasons map[string]string
+	dedup          *deduplicator
+	clusterName    string
+	retryMax       int
+	client         *http.Client
+	eventCh        channels.Channel
+}
+
+// NewPagerDutySink constructs a new PagerDutySink. Reason keys are matched
+// case insensitively, as configuration maps lose their case.
+func NewPagerDutySink(cfg PagerDutySinkConfig) *PagerDutySink {
+	return &PagerDutySink{
+		routingKey:     cfg.RoutingKey,
+		routingKeys:    cfg.NamespaceRoutingKeys,
+		filter:         newEventFilter(cfg.Types, cfg.Reasons, nil),
+		severities:     lowerKeys(cfg.Severities),
+		ackReasons:     lowerKeys(cfg.AcknowledgeReasons),
+		resolveReasons: lowerKeys(cfg.ResolveReasons),
+		dedup:          newDeduplicator(cfg.DedupWindow),
+		clusterName:    cfg.ClusterName,
+		retryMax:       cfg.RetryMax,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		eventCh:        newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes events that
+// trigger, acknowledge or resolve incidents to the event channel, which is
+// drained by Run.
+func (p *PagerDutySink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if _, ok := p.action(eNew); !ok {
+		return
+	}
+	p.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through p.eventCh,
+// and sending it to PagerDuty.
+func (p *PagerDutySink) Run(stopCh <-chan bool) {
+	runBatches(p.eventCh, stopCh, p.drainEvents)
+}
+
+// action returns the event action for e and the reason of the incident it
+// applies to.
+func (p *PagerDutySink) action(e *v1.Event) (pagerDutyEvent, bool) {
+	reason := strings.ToLower(e.Reason)
+	if target, ok := p.resolveReasons[reason]; ok {
+		return pagerDutyEvent{EventAction: "resolve", DedupKey: p.dedupKey(e, target)}, true
+	}
+	if target, ok := p.ackReasons[reason]; ok {
+		return pagerDutyEvent{EventAction: "acknowledge", DedupKey: p.dedupKey(e, target)}, true
+	}
+	if p.filter.match(e) {
+		return pagerDutyEvent{EventAction: "trigger", DedupKey: p.dedupKey(e, e.Reason)}, true
+	}
+	return pagerDutyEvent{}, false
+}
+
+// dedupKey identifies the incident for reason on e's involved object.
+func (p *PagerDutySink) dedupKey(e *v1.Event, reason string) string {
+	o := e.InvolvedObject
+	key := strings.Join([]string{p.clusterName, o.Namespace, o.Kind, o.Name, strings.ToLower(reason)}, "/")
+	if len(key) > pagerDutyMaxDedupKey {
+		key = key[:pagerDutyMaxDedupKey]
+	}
+	return key
+}
+
+// drainEvents sends one Events API request per event, skipping triggers
+// repeated within the dedup window.
+func (p *PagerDutySink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		e := evt.Event
+		req, ok := p.action(e)
+		if !ok {
+			continue
+		}
+		if req.EventAction == "trigger" {
+			if send, _ := p.dedup.observe(req.DedupKey, time.Now()); !send {
+				continue
+			}
+			req.Payload = p.payload(evt)
+		}
+
+		req.RoutingKey = p.routingKey
+		if key, ok := p.routingKeys[e.InvolvedObject.Namespace]; ok {
+			req.RoutingKey = key
+		}
+		if req.RoutingKey == "" {
+			continue
+		}
+
+		body, err := json.Marshal(req)
+		if err != nil {
+			glog.Warningf("Failed to json serialize pagerduty event: %v", err)
+			continue
+		}
+		_, err = postWithRetry(p.client, pagerDutyEnqueueURL, body, p.retryMax, func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json")
+		})
+		if err != nil {
+			glog.Errorf("Failed to %s PagerDuty incident %s: %v", req.EventAction, req.DedupKey, err)
+			deadLetter("pagerduty", []EventData{evt}, err)
+		}
+	}
+}
+
+// payload describes the alert for a triggering event.
+func (p *PagerDutySink) payload(evt EventData) *pagerDutyPayload {
+	e := evt.Event
+	severity, ok := p.severities[strings.ToLower(e.Reason)]
+	if !ok {
+		severity = "info"
+		if e.Type == v1.EventTypeWarning {
+			severity = "warning"
+		}
+	}
+
+	source := e.Source.Host
+	if source == "" {
+		source = p.clusterName
+	}
+	if source == "" {
+		source = "kubernetes"
+	}
+
+	summary := e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name + ": " + e.Reason + ": " + e.Message
+	if r := []rune(summary); len(r) > 1024 {
+		summary = string(r[:1024])
+	}
+
+	return &pagerDutyPayload{
+		Summary:       summary,
+		Source:        source,
+		Severity:      severity,
+		Timestamp:     eventTimestamp(e).UTC().Format(time.RFC3339),
+		Component:     e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+		Group:         e.InvolvedObject.Namespace,
+		Class:         e.Reason,
+		CustomDetails: flattenEventData(evt),
+	}
+}
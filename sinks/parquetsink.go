@@ -0,0 +1,361 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	v1 "k8s.io/api/core/v1"
+)
+
+// parquetCodecs are the supported compression codecs by name.
+var parquetCodecs = map[string]compress.Codec{
+	"none":   &parquet.Uncompressed,
+	"snappy": &parquet.Snappy,
+	"gzip":   &parquet.Gzip,
+	"zstd":   &parquet.Zstd,
+}
+
+// parquetEvent is the schema of the Parquet files. Columns are only ever
+// appended, so files written by older versions stay readable as one table.
+type parquetEvent struct {
+	Cluster                 string            `parquet:"cluster,dict"`
+	Verb                    string            `parquet:"verb,dict"`
+	Namespace               string            `parquet:"namespace,dict"`
+	Name                    string            `parquet:"name"`
+	UID                     string            `parquet:"uid"`
+	Type                    string            `parquet:"type,dict"`
+	Reason                  string            `parquet:"reason,dict"`
+	Message                 string            `parquet:"message"`
+	Count                   int32             `parquet:"count"`
+	FirstTimestamp          *time.Time        `parquet:"first_timestamp,timestamp(microsecond)"`
+	LastTimestamp           *time.Time        `parquet:"last_timestamp,timestamp(microsecond)"`
+	EventTime               *time.Time        `parquet:"event_time,timestamp(microsecond)"`
+	SourceComponent         string            `parquet:"source_component,dict"`
+	SourceHost              string            `parquet:"source_host,dict"`
+	ReportingController     string            `parquet:"reporting_controller,dict"`
+	Action                  string            `parquet:"action,dict"`
+	InvolvedObjectKind      string            `parquet:"involved_object_kind,dict"`
+	InvolvedObjectNamespace string            `parquet:"involved_object_namespace,dict"`
+	InvolvedObjectName      string            `parquet:"involved_object_name"`
+	InvolvedObjectUID       string            `parquet:"involved_object_uid"`
+	InvolvedObjectAPI       string            `parquet:"involved_object_api_version,dict"`
+	InvolvedObjectFieldPath string            `parquet:"involved_object_field_path"`
+	Labels                  map[string]string `parquet:"labels"`
+}
+
+// ParquetSinkConfig holds the options used to construct a ParquetSink.
+type ParquetSinkConfig struct {
+	// Store is "s3", "gcs" or "azblob". Bucket is the bucket, or the
+	// container for Azure Blob Storage.
+	Store  string
+	Bucket string
+	Prefix string
+
+	// Region is used for S3.
+	Region string
+
+	// AzureAccountURL, e.g. https://<account>.blob.core.windows.net, is used
+	// with the default Azure credential chain unless
+	// AzureConnectionString is set. AzureTLS configures a custom CA bundle
+	// and minimum TLS version.
+	AzureAccountURL       string
+	AzureConnectionString string
+	AzureTLS              AzureTLSConfig
+
+	// HivePartitioning writes files under cluster=<c>/dt=<date>/hour=<HH>/
+	// instead of <c>/YYYY/MM/DD/HH/.
+	HivePartitioning bool
+
+	// Compression is "none", "snappy", "gzip" or "zstd".
+	Compression string
+
+	ClusterName   string
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// ParquetSink buffers events in memory and periodically writes them as
+// Parquet files to an object store, for querying with Athena, BigQuery,
+// Spark, DuckDB and the like. With HivePartitioning the files can be
+// registered as a partitioned Hive table or added to an Iceberg table
+// without rewriting them.
+type ParquetSink struct {
+	prefix        string
+	clusterName   string
+	hive          bool
+	codec         compress.Codec
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	upload        archiveUploader
+	eventCh       channels.Channel
+
+	// events are the event data of rows, dead lettered if they cannot be
+	// written.
+	rows   []parquetEvent
+	events []EventData
+	seq    int
+}
+
+// NewParquetSink constructs a new ParquetSink. A file is written whenever
+// FlushEvents events have been buffered or FlushInterval has passed,
+// whichever comes first.
+func NewParquetSink(cfg ParquetSinkConfig) (*ParquetSink, error) {
+	if err := checkFlushInterval("parquet", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	if cfg.ClusterName == "" {
+		return nil, errors.New("parquet sink requires a cluster name")
+	}
+	codec, ok := parquetCodecs[cfg.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown parquet compression %q", cfg.Compression)
+	}
+
+	p := &ParquetSink{
+		prefix:        cfg.Prefix,
+		clusterName:   cfg.ClusterName,
+		hive:          cfg.HivePartitioning,
+		codec:         codec,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+
+	var err error
+	switch cfg.Store {
+	case "s3":
+		p.upload, err = newS3ParquetUploader(cfg.Bucket, cfg.Region)
+	case "gcs":
+		p.upload, err = newGCSParquetUploader(cfg.Bucket)
+	case "azblob":
+		p.upload, err = newAzblobParquetUploader(cfg.AzureAccountURL, cfg.AzureConnectionString, cfg.AzureTLS, cfg.Bucket)
+	default:
+		err = fmt.Errorf("unknown parquet store %q, expected s3, gcs or azblob", cfg.Store)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newS3ParquetUploader(bucket, region string) (archiveUploader, error) {
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg)
+	return func(key string, body []byte) error {
+		_, err := client.PutObject(context.TODO(), &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("application/vnd.apache.parquet"),
+		})
+		return err
+	}, nil
+}
+
+func newGCSParquetUploader(bucket string) (archiveUploader, error) {
+	client, err := storage.NewClient(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	handle := client.Bucket(bucket)
+	return func(key string, body []byte) error {
+		w := handle.Object(key).NewWriter(context.TODO())
+		w.ContentType = "application/vnd.apache.parquet"
+		if _, err := w.Write(body); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}, nil
+}
+
+func newAzblobParquetUploader(accountURL, connString string, tlsCfg AzureTLSConfig, container string) (archiveUploader, error) {
+	tlsConfig, err := newAzureTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts := azureClientOptions(tlsConfig)
+	opts := &azblob.ClientOptions{ClientOptions: clientOpts}
+
+	var client *azblob.Client
+	if connString != "" {
+		client, err = azblob.NewClientFromConnectionString(connString, opts)
+	} else {
+		cred, credErr := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: clientOpts,
+		})
+		if credErr != nil {
+			return nil, credErr
+		}
+		client, err = azblob.NewClient(accountURL, cred, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(key string, body []byte) error {
+		_, err := client.UploadBuffer(context.TODO(), container, key, body, nil)
+		return err
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (p *ParquetSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	p.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, buffering events from p.eventCh and writing a file
+// when enough have accumulated or the interval passed. Whatever is buffered
+// when stopCh fires is written before returning.
+func (p *ParquetSink) Run(stopCh <-chan bool) {
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-p.eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			p.rows = append(p.rows, p.row(evt))
+			p.events = append(p.events, evt)
+			if len(p.rows) >= p.flushEvents {
+				p.flush()
+			}
+		case <-ticker.C:
+			p.flush()
+		case <-stopCh:
+			p.flush()
+			return
+		}
+	}
+}
+
+// row converts event data to a Parquet row.
+func (p *ParquetSink) row(evt EventData) parquetEvent {
+	e := evt.Event
+	o := e.InvolvedObject
+	return parquetEvent{
+		Cluster:                 p.clusterName,
+		Verb:                    evt.Verb,
+		Namespace:               e.Namespace,
+		Name:                    e.Name,
+		UID:                     string(e.UID),
+		Type:                    e.Type,
+		Reason:                  e.Reason,
+		Message:                 e.Message,
+		Count:                   e.Count,
+		FirstTimestamp:          parquetTime(e.FirstTimestamp.Time),
+		LastTimestamp:           parquetTime(e.LastTimestamp.Time),
+		EventTime:               parquetTime(e.EventTime.Time),
+		SourceComponent:         e.Source.Component,
+		SourceHost:              e.Source.Host,
+		ReportingController:     e.ReportingController,
+		Action:                  e.Action,
+		InvolvedObjectKind:      o.Kind,
+		InvolvedObjectNamespace: o.Namespace,
+		InvolvedObjectName:      o.Name,
+		InvolvedObjectUID:       string(o.UID),
+		InvolvedObjectAPI:       o.APIVersion,
+		InvolvedObjectFieldPath: o.FieldPath,
+		Labels:                  e.Labels,
+	}
+}
+
+// parquetTime maps the zero time to NULL.
+func parquetTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// flush encodes the buffered rows as a Parquet file and uploads it. Uploads
+// are retried with backoff up to retryMax times before the file is dropped.
+func (p *ParquetSink) flush() {
+	if len(p.rows) == 0 {
+		return
+	}
+	defer func() { p.rows, p.events = p.rows[:0], nil }()
+
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetEvent](&buf, parquet.Compression(p.codec))
+	if _, err := w.Write(p.rows); err != nil {
+		glog.Errorf("Failed to encode %d events as parquet: %v", len(p.rows), err)
+		deadLetter("parquet", p.events, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		glog.Errorf("Failed to encode %d events as parquet: %v", len(p.rows), err)
+		deadLetter("parquet", p.events, err)
+		return
+	}
+
+	key := p.objectKey(time.Now().UTC())
+	for attempt := 0; ; attempt++ {
+		err := p.upload(key, buf.Bytes())
+		if err == nil {
+			glog.V(4).Infof("Wrote %d events to %s", len(p.rows), key)
+			return
+		}
+		if attempt >= p.retryMax {
+			glog.Errorf("Failed to write %d events to %s: %v", len(p.rows), key, err)
+			deadLetter("parquet", p.events, err)
+			return
+		}
+		glog.Warningf("Failed to write events to %s, retrying: %v", key, err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// objectKey returns the time-partitioned key for a file written at t.
+func (p *ParquetSink) objectKey(t time.Time) string {
+	p.seq++
+	name := fmt.Sprintf("%s-%06d.parquet", t.Format("20060102T150405Z"), p.seq)
+	if p.hive {
+		return path.Join(p.prefix, "cluster="+p.clusterName, "dt="+t.Format("2006-01-02"), "hour="+t.Format("15"), name)
+	}
+	return path.Join(p.prefix, p.clusterName, t.Format("2006/01/02/15"), name)
+}
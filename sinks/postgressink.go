@@ -0,0 +1,172 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	v1 "k8s.io/api/core/v1"
+)
+
+// postgresColumns are the columns PostgresSink and TimescaleSink copy into.
+var postgresColumns = []string{
+	"cluster", "verb", "namespace", "name", "uid", "type", "reason", "message",
+	"count", "first_timestamp", "last_timestamp", "involved_object_kind",
+	"involved_object_namespace", "involved_object_name", "event",
+}
+
+// postgresSchema creates the events table and its indexes. %[1]s is the
+// quoted table name and %[2]s the escaped name used to prefix index names.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+  id BIGSERIAL PRIMARY KEY,
+  cluster TEXT NOT NULL,
+  verb TEXT NOT NULL,
+  namespace TEXT NOT NULL,
+  name TEXT NOT NULL,
+  uid TEXT NOT NULL,
+  type TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  message TEXT NOT NULL,
+  count INTEGER NOT NULL,
+  first_timestamp TIMESTAMPTZ,
+  last_timestamp TIMESTAMPTZ,
+  involved_object_kind TEXT NOT NULL,
+  involved_object_namespace TEXT NOT NULL,
+  involved_object_name TEXT NOT NULL,
+  event JSONB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "%[2]s_namespace_idx" ON %[1]s (namespace, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_reason_idx" ON %[1]s (reason, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_type_idx" ON %[1]s (type, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_last_timestamp_idx" ON %[1]s (last_timestamp);
+`
+
+// PostgresSink writes events into a PostgreSQL table with COPY. The complete
+// event is kept in a JSONB column, and the fields most queries filter on are
+// copied into indexed columns.
+type PostgresSink struct {
+	pool        *pgxpool.Pool
+	table       string
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewPostgresSink connects to dsn and constructs a new PostgresSink. dsn is a
+// libpq connection string or URL; pool_max_conns and friends may be added to
+// tune the connection pool. The table is created if it does not exist.
+func NewPostgresSink(dsn string, table string, clusterName string, overflow bool, bufferSize int) (*PostgresSink, error) {
+	pool, err := newPostgresPool(dsn, postgresTableSchema(postgresSchema, table))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresSink{
+		pool:        pool,
+		table:       table,
+		clusterName: clusterName,
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (p *PostgresSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	p.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through p.eventCh,
+// and copying it into the table.
+func (p *PostgresSink) Run(stopCh <-chan bool) {
+	defer p.pool.Close()
+	runBatches(p.eventCh, stopCh, func(events []EventData) {
+		copyEvents("postgres", p.pool, p.table, p.clusterName, events)
+	})
+}
+
+// newPostgresPool opens a connection pool and applies schema.
+func newPostgresPool(dsn string, schema string) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(context.TODO(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(context.TODO(), schema); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// postgresTableSchema fills the table and index names of a schema template.
+func postgresTableSchema(schema string, table string) string {
+	return fmt.Sprintf(schema, pgx.Identifier{table}.Sanitize(), strings.ReplaceAll(table, `"`, `""`))
+}
+
+// copyEvents writes an array of event data into table with a single COPY,
+// dead lettering them as the sink named sink if it fails.
+func copyEvents(sink string, pool *pgxpool.Pool, table string, clusterName string, events []EventData) {
+	rows := make([][]interface{}, 0, len(events))
+	copied := make([]EventData, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		e := evt.Event
+		rows = append(rows, []interface{}{
+			clusterName,
+			evt.Verb,
+			e.Namespace,
+			e.Name,
+			string(e.UID),
+			e.Type,
+			e.Reason,
+			e.Message,
+			e.Count,
+			nullTime(e.FirstTimestamp.Time),
+			nullTime(eventTimestamp(e)),
+			e.InvolvedObject.Kind,
+			e.InvolvedObject.Namespace,
+			e.InvolvedObject.Name,
+			string(eJSONBytes),
+		})
+		copied = append(copied, evt)
+	}
+
+	if _, err := pool.CopyFrom(context.TODO(), pgx.Identifier{table}, postgresColumns, pgx.CopyFromRows(rows)); err != nil {
+		glog.Errorf("Failed to copy %d events into %s: %v", len(rows), table, err)
+		deadLetter(sink, copied, err)
+	}
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PubSubSink publishes events to a Google Cloud Pub/Sub topic. Messages carry
+// namespace, reason and type attributes for subscription filters, and use
+// the involved object UID as ordering key so each object's events arrive in
+// order on subscriptions with message ordering enabled.
+type PubSubSink struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+	eventCh   channels.Channel
+}
+
+// NewPubSubSink constructs a new PubSubSink. Credentials come from
+// Application Default Credentials, which covers GKE workload identity. An
+// empty projectID is detected from the environment, and topic may be either
+// a topic ID or a full projects/<project>/topics/<topic> name.
+func NewPubSubSink(projectID string, topic string, overflow bool, bufferSize int) (*PubSubSink, error) {
+	if projectID == "" {
+		projectID = pubsub.DetectProjectID
+	}
+
+	client, err := pubsub.NewClient(context.TODO(), projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	publisher := client.Publisher(topic)
+	publisher.EnableMessageOrdering = true
+
+	return &PubSubSink{
+		client:    client,
+		publisher: publisher,
+		eventCh:   newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (p *PubSubSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	p.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through p.eventCh,
+// and publishing it to the topic.
+func (p *PubSubSink) Run(stopCh <-chan bool) {
+	defer p.client.Close()
+	defer p.publisher.Stop()
+	runBatches(p.eventCh, stopCh, p.drainEvents)
+}
+
+// drainEvents publishes an array of event data and waits for the results.
+// The publisher bundles the messages into as few requests as it can.
+func (p *PubSubSink) drainEvents(events []EventData) {
+	type pending struct {
+		evt         EventData
+		orderingKey string
+		result      *pubsub.PublishResult
+	}
+
+	results := make([]pending, 0, len(events))
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		orderingKey := string(evt.Event.InvolvedObject.UID)
+		results = append(results, pending{
+			evt:         evt,
+			orderingKey: orderingKey,
+			result: p.publisher.Publish(context.TODO(), &pubsub.Message{
+				Data: eJSONBytes,
+				Attributes: map[string]string{
+					"namespace": evt.Event.InvolvedObject.Namespace,
+					"reason":    evt.Event.Reason,
+					"type":      evt.Event.Type,
+				},
+				OrderingKey: orderingKey,
+			}),
+		})
+	}
+
+	for _, r := range results {
+		if _, err := r.result.Get(context.TODO()); err != nil {
+			glog.Errorf("Failed to publish event to pubsub: %v", err)
+			deadLetter("pubsub", []EventData{r.evt}, err)
+			// A failed publish pauses its ordering key until it is resumed.
+			if r.orderingKey != "" {
+				p.publisher.ResumePublish(r.orderingKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// quickwitMaxRequestBytes keeps ingest requests below Quickwit's default
+// 10MiB content length limit.
+const quickwitMaxRequestBytes = 8 << 20
+
+// QuickwitSinkConfig holds the options used to construct a QuickwitSink.
+type QuickwitSinkConfig struct {
+	// URL is the base URL of a Quickwit node, e.g. http://quickwit:7280.
+	URL   string
+	Index string
+
+	// Commit is "auto", "wait_for" or "force". With wait_for and force
+	// requests return once the events are searchable, force also cutting
+	// the commit timeout short.
+	Commit string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// QuickwitSink ingests events into a Quickwit index as NDJSON documents of
+// the flattened event fields. The index's timestamp field should be
+// "timestamp".
+type QuickwitSink struct {
+	ingestURL     string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewQuickwitSink constructs a new QuickwitSink.
+func NewQuickwitSink(cfg QuickwitSinkConfig) (*QuickwitSink, error) {
+	if err := checkFlushInterval("quickwit", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Commit {
+	case "auto", "wait_for", "force":
+	default:
+		return nil, fmt.Errorf("unknown quickwit commit mode %q, expected auto, wait_for or force", cfg.Commit)
+	}
+
+	return &QuickwitSink{
+		ingestURL:     strings.TrimSuffix(cfg.URL, "/") + "/api/v1/" + url.PathEscape(cfg.Index) + "/ingest?commit=" + cfg.Commit,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 60 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (q *QuickwitSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	q.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through q.eventCh, and
+// ingesting it in batches of up to flushEvents events.
+func (q *QuickwitSink) Run(stopCh <-chan bool) {
+	runTimedBatches(q.eventCh, stopCh, q.flushEvents, q.flushInterval, q.drainEvents)
+}
+
+// drainEvents ingests an array of event data in as few requests as the
+// request size limit allows.
+func (q *QuickwitSink) drainEvents(events []EventData) {
+	// batched are the events in buf.
+	var buf bytes.Buffer
+	var batched []EventData
+	for _, evt := range events {
+		doc := flattenEventData(evt)
+		doc["cluster"] = q.clusterName
+		doc["timestamp"] = eventTimestamp(evt.Event)
+		eJSONBytes, err := json.Marshal(doc)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(batched) > 0 && buf.Len()+len(eJSONBytes)+1 > quickwitMaxRequestBytes {
+			q.ingest(buf.Bytes(), batched)
+			buf.Reset()
+			batched = nil
+		}
+		buf.Write(eJSONBytes)
+		buf.WriteByte('\n')
+		batched = append(batched, evt)
+	}
+
+	if len(batched) > 0 {
+		q.ingest(buf.Bytes(), batched)
+	}
+}
+
+// ingest posts a single NDJSON request of events, dead lettering them if it
+// fails, and logs documents Quickwit did not accept. Quickwit does not say
+// which documents it rejected, so those are only counted.
+func (q *QuickwitSink) ingest(body []byte, events []EventData) {
+	docs := len(events)
+	respBody, err := postWithRetry(q.client, q.ingestURL, body, q.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	})
+	if err != nil {
+		glog.Errorf("Failed to ingest %d events into Quickwit: %v", docs, err)
+		deadLetter("quickwit", events, err)
+		return
+	}
+
+	var resp struct {
+		NumDocsForProcessing int `json:"num_docs_for_processing"`
+		NumRejectedDocs      int `json:"num_rejected_docs"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		glog.V(2).Infof("Ignoring undecodable Quickwit response: %v", err)
+		return
+	}
+	if resp.NumRejectedDocs > 0 {
+		glog.Warningf("Quickwit rejected %d of %d events", resp.NumRejectedDocs, docs)
+	}
+}
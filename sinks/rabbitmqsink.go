@@ -0,0 +1,200 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	amqp "github.com/rabbitmq/amqp091-go"
+	v1 "k8s.io/api/core/v1"
+)
+
+// rabbitMQConfirmTimeout bounds how long a batch waits for publisher confirms.
+const rabbitMQConfirmTimeout = 30 * time.Second
+
+// RabbitMQSink publishes events to a RabbitMQ exchange using publisher
+// confirms. The connection and channel are re-established automatically
+// whenever the broker closes them.
+type RabbitMQSink struct {
+	url         string
+	exchange    string
+	routingKey  string
+	retryMax    int
+	tlsCAFile   string
+	tlsCertFile string
+	tlsKeyFile  string
+	eventCh     channels.Channel
+
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// NewRabbitMQSink constructs a new RabbitMQSink and connects to url, which
+// uses the amqp:// or amqps:// scheme. routingKey is a subject template such
+// as "{namespace}.{reason}"; see expandSubject for the placeholders.
+func NewRabbitMQSink(url string, exchange string, routingKey string, retryMax int, tlsCAFile string, tlsCertFile string, tlsKeyFile string, overflow bool, bufferSize int) (*RabbitMQSink, error) {
+	r := &RabbitMQSink{
+		url:         url,
+		exchange:    exchange,
+		routingKey:  routingKey,
+		retryMax:    retryMax,
+		tlsCAFile:   tlsCAFile,
+		tlsCertFile: tlsCertFile,
+		tlsKeyFile:  tlsKeyFile,
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (r *RabbitMQSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	r.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through r.eventCh,
+// and publishing it to the exchange.
+func (r *RabbitMQSink) Run(stopCh <-chan bool) {
+	defer r.close()
+	runBatches(r.eventCh, stopCh, r.drainEvents)
+}
+
+// drainEvents publishes an array of event data and waits for the broker to
+// confirm it. If the connection is lost, it is re-established and the
+// unconfirmed events are published again, up to retryMax times.
+func (r *RabbitMQSink) drainEvents(events []EventData) {
+	for attempt := 0; len(events) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > r.retryMax {
+				glog.Errorf("Failed to publish %d events to RabbitMQ after %d attempts", len(events), attempt)
+				deadLetter("rabbitmq", events, fmt.Errorf("not confirmed after %d attempts", attempt))
+				return
+			}
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+
+		if r.channel == nil || r.channel.IsClosed() {
+			r.close()
+			if err := r.connect(); err != nil {
+				glog.Warningf("Failed to reconnect to RabbitMQ: %v", err)
+				continue
+			}
+			glog.Infof("Reconnected to RabbitMQ")
+		}
+
+		events = r.publish(events)
+	}
+}
+
+// publish sends events on the current channel and returns those that were
+// not confirmed by the broker. When a publish fails, the events from it on
+// are returned after the confirmations of the earlier ones, so nacked
+// events are retried too.
+func (r *RabbitMQSink) publish(events []EventData) []EventData {
+	ctx, cancel := context.WithTimeout(context.Background(), rabbitMQConfirmTimeout)
+	defer cancel()
+
+	confirms := make([]*amqp.DeferredConfirmation, len(events))
+	var unpublished []EventData
+	for i, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		confirms[i], err = r.channel.PublishWithDeferredConfirmWithContext(ctx, r.exchange, expandSubject(r.routingKey, evt.Event), false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    eventTimestamp(evt.Event),
+			Body:         eJSONBytes,
+		})
+		if err != nil {
+			glog.Warningf("Failed to publish event to RabbitMQ: %v", err)
+			unpublished = events[i:]
+			break
+		}
+	}
+
+	var unconfirmed []EventData
+	for i, confirm := range confirms {
+		if confirm == nil {
+			continue
+		}
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil || !acked {
+			unconfirmed = append(unconfirmed, events[i])
+		}
+	}
+	if len(unconfirmed) > 0 {
+		glog.Warningf("RabbitMQ did not confirm %d events", len(unconfirmed))
+	}
+	return append(unconfirmed, unpublished...)
+}
+
+// connect dials the broker and opens a channel in confirm mode.
+func (r *RabbitMQSink) connect() error {
+	config := amqp.Config{
+		Properties: amqp.Table{"connection_name": "eventrouter"},
+	}
+	if r.tlsCAFile != "" || r.tlsCertFile != "" {
+		tlsConfig, err := newTLSConfig(r.tlsCAFile, r.tlsCertFile, r.tlsKeyFile, false)
+		if err != nil {
+			return err
+		}
+		config.TLSClientConfig = tlsConfig
+	}
+
+	conn, err := amqp.DialConfig(r.url, config)
+	if err != nil {
+		return err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return errors.New("rabbitmq broker does not support publisher confirms: " + err.Error())
+	}
+
+	r.conn = conn
+	r.channel = channel
+	return nil
+}
+
+// close tears down the current channel and connection, if any.
+func (r *RabbitMQSink) close() {
+	if r.channel != nil {
+		r.channel.Close()
+		r.channel = nil
+	}
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
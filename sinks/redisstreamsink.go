@@ -0,0 +1,148 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/redis/go-redis/v9"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RedisStreamSinkConfig holds the options used to construct a RedisStreamSink.
+type RedisStreamSinkConfig struct {
+	// Addrs lists the servers to connect to. With ClusterMode set they are
+	// used as cluster seed nodes.
+	Addrs       []string
+	ClusterMode bool
+	Username    string
+	Password    string
+	DB          int
+
+	Stream string
+
+	// MaxLen approximately caps the stream length; zero disables trimming.
+	MaxLen int64
+
+	TLSEnabled  bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// RedisStreamSink appends events to a Redis stream with XADD, so lightweight
+// consumers can tail cluster events with XREAD or consumer groups.
+type RedisStreamSink struct {
+	client  redis.UniversalClient
+	stream  string
+	maxLen  int64
+	eventCh channels.Channel
+}
+
+// NewRedisStreamSink constructs a new RedisStreamSink and checks that the
+// server is reachable.
+func NewRedisStreamSink(cfg RedisStreamSinkConfig) (*RedisStreamSink, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:    cfg.Addrs,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, false)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	var client redis.UniversalClient
+	if cfg.ClusterMode {
+		client = redis.NewClusterClient(opts.Cluster())
+	} else {
+		client = redis.NewClient(opts.Simple())
+	}
+	if err := client.Ping(context.TODO()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisStreamSink{
+		client:  client,
+		stream:  cfg.Stream,
+		maxLen:  cfg.MaxLen,
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (r *RedisStreamSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	r.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through r.eventCh,
+// and appending it to the stream.
+func (r *RedisStreamSink) Run(stopCh <-chan bool) {
+	defer r.client.Close()
+	runBatches(r.eventCh, stopCh, r.drainEvents)
+}
+
+// drainEvents appends an array of event data to the stream in one pipeline.
+// Each entry carries the namespace, reason and type as separate fields next
+// to the full EventData JSON.
+func (r *RedisStreamSink) drainEvents(events []EventData) {
+	// added are the events of cmds.
+	pipe := r.client.Pipeline()
+	var cmds []*redis.StringCmd
+	var added []EventData
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		cmds = append(cmds, pipe.XAdd(context.TODO(), &redis.XAddArgs{
+			Stream: r.stream,
+			MaxLen: r.maxLen,
+			Approx: r.maxLen > 0,
+			Values: map[string]interface{}{
+				"namespace": evt.Event.InvolvedObject.Namespace,
+				"reason":    evt.Event.Reason,
+				"type":      evt.Event.Type,
+				"event":     eJSONBytes,
+			},
+		}))
+		added = append(added, evt)
+	}
+
+	if _, err := pipe.Exec(context.TODO()); err != nil {
+		glog.Errorf("Failed to add events to redis stream %s: %v", r.stream, err)
+		for i, cmd := range cmds {
+			if cmd.Err() != nil {
+				deadLetter("redis", []EventData{added[i]}, cmd.Err())
+			}
+		}
+	}
+}
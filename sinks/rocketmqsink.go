@@ -0,0 +1,220 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	rocketmq "github.com/apache/rocketmq-client-go/v2"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+	"github.com/apache/rocketmq-client-go/v2/producer"
+	"github.com/apache/rocketmq-client-go/v2/rlog"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// rocketMQMaxBatchBytes keeps batches below the broker's default 4MB
+// maxMessageSize, leaving room for the per-message batch encoding.
+const rocketMQMaxBatchBytes = 4*1024*1024 - 64*1024
+
+// RocketMQSinkConfig holds the options used to construct a RocketMQSink.
+type RocketMQSinkConfig struct {
+	NameServers []string
+	Topic       string
+	Group       string
+	Namespace   string
+
+	// AccessKey and SecretKey authenticate with the broker's ACL; ACL is
+	// not used when AccessKey is empty.
+	AccessKey string
+	SecretKey string
+
+	// RetryMax is how often a failed batch is resent, with backoff. The
+	// client's own retries only apply to synchronous sends.
+	RetryMax int
+
+	// MaxInflight is how many batches may be waiting for their send
+	// callback at once before drainEvents blocks.
+	MaxInflight int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// RocketMQSink sends batches of events to an Apache RocketMQ topic. Each
+// message is tagged with the event's reason and keyed by the involved
+// object's UID, so consumers can subscribe to reasons with tag expressions
+// and the console can look up an object's events by key. Batches are sent
+// asynchronously; failures are reported in the send callback.
+type RocketMQSink struct {
+	topic    string
+	producer rocketmq.Producer
+	retryMax int
+	inflight chan struct{}
+	eventCh  channels.Channel
+}
+
+// NewRocketMQSink constructs a new RocketMQSink and starts its producer.
+func NewRocketMQSink(cfg RocketMQSinkConfig) (*RocketMQSink, error) {
+	nameServers, err := primitive.NewNamesrvAddr(cfg.NameServers...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxInflight < 1 {
+		cfg.MaxInflight = 1
+	}
+
+	// The client logs through logrus at info level; keep the warnings.
+	rlog.SetLogLevel("warn")
+	// The client's goroutines only recover panics when a handler is set.
+	if primitive.PanicHandler == nil {
+		primitive.PanicHandler = func(r interface{}) {
+			glog.Errorf("Recovered from panic in RocketMQ client: %v", r)
+		}
+	}
+
+	opts := []producer.Option{
+		producer.WithNameServer(nameServers),
+		producer.WithGroupName(cfg.Group),
+	}
+	if cfg.Namespace != "" {
+		opts = append(opts, producer.WithNamespace(cfg.Namespace))
+	}
+	if cfg.AccessKey != "" {
+		opts = append(opts, producer.WithCredentials(primitive.Credentials{
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+		}))
+	}
+
+	p, err := rocketmq.NewProducer(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Start(); err != nil {
+		return nil, err
+	}
+
+	return &RocketMQSink{
+		topic:    cfg.Topic,
+		producer: p,
+		retryMax: cfg.RetryMax,
+		inflight: make(chan struct{}, cfg.MaxInflight),
+		eventCh:  newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (r *RocketMQSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	r.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through r.eventCh, and
+// sending it to the topic. Once stopped, it waits for the outstanding send
+// callbacks before shutting the producer down.
+func (r *RocketMQSink) Run(stopCh <-chan bool) {
+	runBatches(r.eventCh, stopCh, r.drainEvents)
+
+	for i := 0; i < cap(r.inflight); i++ {
+		r.inflight <- struct{}{}
+	}
+	if err := r.producer.Shutdown(); err != nil {
+		glog.Warningf("Failed to shut down RocketMQ producer: %v", err)
+	}
+}
+
+// drainEvents sends an array of event data as batch messages, splitting
+// them at the broker's message size limit.
+func (r *RocketMQSink) drainEvents(events []EventData) {
+	var msgs []*primitive.Message
+	var batch []EventData
+	batchBytes := 0
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(msgs) > 0 && batchBytes+len(eJSONBytes) > rocketMQMaxBatchBytes {
+			r.send(msgs, batch)
+			msgs, batch, batchBytes = nil, nil, 0
+		}
+
+		e := evt.Event
+		msg := primitive.NewMessage(r.topic, eJSONBytes)
+		if e.Reason != "" {
+			msg.WithTag(e.Reason)
+		}
+		if uid := string(e.InvolvedObject.UID); uid != "" {
+			msg.WithKeys([]string{uid})
+		}
+		msgs = append(msgs, msg)
+		batch = append(batch, evt)
+		batchBytes += len(eJSONBytes)
+	}
+	if len(msgs) > 0 {
+		r.send(msgs, batch)
+	}
+}
+
+// send sends a batch asynchronously, blocking while MaxInflight batches
+// are outstanding.
+func (r *RocketMQSink) send(msgs []*primitive.Message, events []EventData) {
+	r.inflight <- struct{}{}
+	r.sendAsync(msgs, events, 0)
+}
+
+// sendAsync makes one attempt to send a batch. The client does not retry
+// asynchronous sends, so a failure reported to the callback resends the
+// batch with backoff up to retryMax times, keeping its inflight slot, and
+// then dead letters its events.
+func (r *RocketMQSink) sendAsync(msgs []*primitive.Message, events []EventData, attempt int) {
+	// The client may invoke the callback more than once for a failed send.
+	var once sync.Once
+	done := func(result *primitive.SendResult, err error) {
+		once.Do(func() {
+			if err == nil && result.Status != primitive.SendOK {
+				glog.Warningf("RocketMQ accepted %d events with status %d", len(events), result.Status)
+			}
+			if err != nil && attempt < r.retryMax {
+				glog.Warningf("Failed to send events to RocketMQ, retrying: %v", err)
+				go func() {
+					time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+					r.sendAsync(msgs, events, attempt+1)
+				}()
+				return
+			}
+			<-r.inflight
+			if err != nil {
+				glog.Errorf("Failed to send %d events to RocketMQ: %v", len(events), err)
+				deadLetter("rocketmq", events, err)
+			}
+		})
+	}
+
+	err := r.producer.SendAsync(context.TODO(), func(ctx context.Context, result *primitive.SendResult, err error) {
+		done(result, err)
+	}, msgs...)
+	if err != nil {
+		done(nil, err)
+	}
+}
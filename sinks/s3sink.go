@@ -0,0 +1,81 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// S3Sink archives events to an S3 bucket as gzip-compressed NDJSON objects,
+// partitioned by cluster and hour so the history can be queried with Athena.
+type S3Sink struct {
+	client  *s3.Client
+	bucket  string
+	archive *eventArchive
+}
+
+// NewS3Sink constructs a new S3Sink. An object is written whenever
+// flushEvents events have been buffered or flushInterval has passed,
+// whichever comes first.
+func NewS3Sink(bucket string, prefix string, clusterName string, region string, flushEvents int, flushInterval time.Duration, retryMax int, overflow bool, bufferSize int) (*S3Sink, error) {
+	if clusterName == "" {
+		return nil, errors.New("s3 sink requires a cluster name")
+	}
+
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &S3Sink{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+	}
+	if s.archive, err = newEventArchive("s3", prefix, clusterName, flushEvents, flushInterval, retryMax, overflow, bufferSize, s.putObject); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *S3Sink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.archive.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run buffers events and uploads them to the bucket until stopCh fires.
+func (s *S3Sink) Run(stopCh <-chan bool) {
+	s.archive.run(stopCh)
+}
+
+// putObject uploads a finished archive object.
+func (s *S3Sink) putObject(key string, body []byte) error {
+	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/gzip"),
+	})
+	return err
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/getsentry/sentry-go"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SentrySinkConfig holds the options used to construct a SentrySink.
+type SentrySinkConfig struct {
+	DSN         string
+	Environment string
+	Release     string
+
+	// SampleRate is the fraction of events sent, between 0 and 1; as with
+	// the Sentry SDK, 0 sends all of them.
+	SampleRate float64
+
+	// Reasons and Namespaces optionally restrict which Warning events are
+	// captured; empty lists capture all of them.
+	Reasons    []string
+	Namespaces []string
+
+	ClusterName string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SentrySink captures Warning events as Sentry events. Events are
+// fingerprinted on the involved object and reason, so a failure that keeps
+// recurring shows up as a single issue.
+type SentrySink struct {
+	client      *sentry.Client
+	filter      eventFilter
+	sampleRate  float64
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewSentrySink constructs a new SentrySink.
+func NewSentrySink(cfg SentrySinkConfig) (*SentrySink, error) {
+	// The synchronous transport leaves buffering to the event channel
+	// instead of the SDK's small fixed queue, which drops when full.
+	// Sampling is done by the sink, so the client sends everything it is
+	// given and a nil event ID always means the event was lost.
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  1,
+		Transport:   sentry.NewHTTPSyncTransport(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SentrySink{
+		client:      client,
+		filter:      newEventFilter([]string{v1.EventTypeWarning}, cfg.Reasons, cfg.Namespaces),
+		sampleRate:  cfg.SampleRate,
+		clusterName: cfg.ClusterName,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. Matching events that are
+// sampled in are written to the event channel, which is drained by Run.
+func (s *SentrySink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !s.filter.match(eNew) {
+		return
+	}
+	if s.sampleRate > 0 && s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return
+	}
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and capturing it in Sentry.
+func (s *SentrySink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+	s.client.Flush(10 * time.Second)
+}
+
+// drainEvents captures every event in an array of event data.
+func (s *SentrySink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		if id := s.client.CaptureEvent(s.newSentryEvent(evt), nil, nil); id == nil {
+			glog.V(2).Infof("Sentry dropped event %s/%s", evt.Event.Namespace, evt.Event.Name)
+			deadLetter("sentry", []EventData{evt}, errors.New("dropped by the Sentry client"))
+		}
+	}
+}
+
+// newSentryEvent converts event data to a Sentry event.
+func (s *SentrySink) newSentryEvent(evt EventData) *sentry.Event {
+	e := evt.Event
+	o := e.InvolvedObject
+
+	se := sentry.NewEvent()
+	se.Level = sentry.LevelWarning
+	se.Logger = "eventrouter"
+	se.Message = e.Reason + ": " + e.Message
+	se.Transaction = o.Kind + "/" + o.Name
+	se.Fingerprint = []string{o.Namespace, o.Kind, o.Name, e.Reason}
+	if ts := eventTimestamp(e); !ts.IsZero() {
+		se.Timestamp = ts
+	}
+
+	se.Tags = map[string]string{
+		"namespace": o.Namespace,
+		"kind":      o.Kind,
+		"name":      o.Name,
+		"reason":    e.Reason,
+		"verb":      evt.Verb,
+	}
+	if s.clusterName != "" {
+		se.Tags["cluster"] = s.clusterName
+	}
+	if e.Source.Component != "" {
+		se.Tags["source"] = e.Source.Component
+	}
+
+	se.Contexts["kubernetes"] = sentry.Context{
+		"event":           e.Namespace + "/" + e.Name,
+		"involved_object": o,
+		"count":           e.Count,
+		"first_timestamp": e.FirstTimestamp.Time,
+		"last_timestamp":  e.LastTimestamp.Time,
+		"source":          e.Source,
+	}
+	return se
+}
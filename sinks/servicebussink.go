@@ -0,0 +1,179 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ServiceBusSink sends events to an Azure Service Bus queue or topic. Each
+// message carries namespace, reason, type and kind application properties,
+// so topic subscriptions can filter on them with SQL rules such as
+// `type = 'Warning' AND namespace = 'prod'`.
+type ServiceBusSink struct {
+	client      *azservicebus.Client
+	sender      *azservicebus.Sender
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewServiceBusSink constructs a new ServiceBusSink sending to queueOrTopic.
+// When connString is empty it authenticates against namespace (e.g.
+// eventrouter.servicebus.windows.net) with the default Azure credential
+// chain, which covers workload identity and managed identities; the
+// identity needs the Azure Service Bus Data Sender role.
+func NewServiceBusSink(namespace string, connString string, queueOrTopic string, clusterName string, tlsCfg AzureTLSConfig, overflow bool, bufferSize int) (*ServiceBusSink, error) {
+	tlsConfig, err := newAzureTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := &azservicebus.ClientOptions{TLSConfig: tlsConfig}
+
+	var client *azservicebus.Client
+	if connString != "" {
+		client, err = azservicebus.NewClientFromConnectionString(connString, opts)
+	} else {
+		cred, credErr := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: azureClientOptions(tlsConfig),
+		})
+		if credErr != nil {
+			return nil, credErr
+		}
+		client, err = azservicebus.NewClient(namespace, cred, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := client.NewSender(queueOrTopic, nil)
+	if err != nil {
+		client.Close(context.TODO())
+		return nil, err
+	}
+
+	return &ServiceBusSink{
+		client:      client,
+		sender:      sender,
+		clusterName: clusterName,
+		eventCh:     newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *ServiceBusSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and sending it to Service Bus.
+func (s *ServiceBusSink) Run(stopCh <-chan bool) {
+	defer s.client.Close(context.TODO())
+	defer s.sender.Close(context.TODO())
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents sends an array of event data in as few message batches as the
+// entity's maximum message size allows.
+func (s *ServiceBusSink) drainEvents(events []EventData) {
+	batch, err := s.sender.NewMessageBatch(context.TODO(), nil)
+	if err != nil {
+		glog.Errorf("Failed to create service bus message batch: %v", err)
+		deadLetter("servicebus", events, err)
+		return
+	}
+
+	// batched are the events of batch.
+	var batched []EventData
+	for i := 0; i < len(events); i++ {
+		msg, err := s.newMessage(events[i])
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		err = batch.AddMessage(msg, nil)
+		if errors.Is(err, azservicebus.ErrMessageTooLarge) {
+			if batch.NumMessages() == 0 {
+				glog.Warningf("Dropping event %s/%s: too large for a service bus message", events[i].Event.Namespace, events[i].Event.Name)
+				deadLetter("servicebus", events[i:i+1], err)
+				continue
+			}
+
+			s.send(batch, batched)
+			batched = nil
+			if batch, err = s.sender.NewMessageBatch(context.TODO(), nil); err != nil {
+				glog.Errorf("Failed to create service bus message batch: %v", err)
+				deadLetter("servicebus", events[i:], err)
+				return
+			}
+
+			// rewind so we can retry adding this event to the new batch
+			i--
+		} else if err != nil {
+			glog.Warningf("Failed to add event to service bus message batch: %v", err)
+			deadLetter("servicebus", events[i:i+1], err)
+		} else {
+			batched = append(batched, events[i])
+		}
+	}
+
+	if batch.NumMessages() > 0 {
+		s.send(batch, batched)
+	}
+}
+
+// newMessage builds the message for a single event.
+func (s *ServiceBusSink) newMessage(evt EventData) (*azservicebus.Message, error) {
+	eJSONBytes, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	e := evt.Event
+	return &azservicebus.Message{
+		Body:        eJSONBytes,
+		ContentType: to.Ptr("application/json"),
+		Subject:     to.Ptr(e.Reason),
+		ApplicationProperties: map[string]any{
+			"cluster":   s.clusterName,
+			"verb":      evt.Verb,
+			"namespace": e.InvolvedObject.Namespace,
+			"kind":      e.InvolvedObject.Kind,
+			"reason":    e.Reason,
+			"type":      e.Type,
+		},
+	}, nil
+}
+
+// send sends a single message batch of events, dead lettering them if it
+// fails.
+func (s *ServiceBusSink) send(batch *azservicebus.MessageBatch, events []EventData) {
+	if err := s.sender.SendMessageBatch(context.TODO(), batch, nil); err != nil {
+		glog.Errorf("Failed to send %d events to service bus: %v", batch.NumMessages(), err)
+		deadLetter("servicebus", events, err)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// serviceNowMaxShortDescription is the length of the incident
+// short_description column.
+const serviceNowMaxShortDescription = 160
+
+// ServiceNowSinkConfig holds the options used to construct a
+// ServiceNowSink.
+type ServiceNowSinkConfig struct {
+	// URL is the instance URL, e.g. https://example.service-now.com.
+	URL string
+
+	// Username and Password authenticate with basic auth unless Token, an
+	// OAuth access token, is set. The user needs the itil role.
+	Username string
+	Password string
+	Token    string
+
+	// Reasons and Namespaces, when set, limit the Warning events that open
+	// incidents.
+	Reasons    []string
+	Namespaces []string
+
+	// AssignmentGroups maps namespaces to assignment groups, by name or
+	// sys_id; AssignmentGroup is used for other namespaces.
+	AssignmentGroups map[string]string
+	AssignmentGroup  string
+
+	// Urgencies maps reasons to an urgency of 1 (high) to 3 (low); other
+	// incidents get Urgency. Impact is the same for all incidents.
+	Urgencies map[string]string
+	Urgency   string
+	Impact    string
+
+	// Category and CallerID are set on new incidents when not empty.
+	Category string
+	CallerID string
+
+	// UpdateInterval limits how often an open incident is updated when the
+	// event keeps recurring.
+	UpdateInterval time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// ServiceNowSink creates ServiceNow incidents from Warning events through
+// the Table API. Incidents carry a correlation ID derived from the cluster,
+// involved object and reason; while one is active, recurrences are added to
+// it as work notes instead of creating another incident.
+type ServiceNowSink struct {
+	tableURL         string
+	authorization    string
+	filter           eventFilter
+	assignmentGroups map[string]string
+	assignmentGroup  string
+	urgencies        map[string]string
+	urgency          string
+	impact           string
+	category         string
+	callerID         string
+	dedup            *deduplicator
+	clusterName      string
+	retryMax         int
+	client           *http.Client
+	eventCh          channels.Channel
+}
+
+// NewServiceNowSink constructs a new ServiceNowSink. Namespace and reason
+// keys are matched case insensitively, as configuration maps lose their
+// case.
+func NewServiceNowSink(cfg ServiceNowSinkConfig) *ServiceNowSink {
+	authorization := "Bearer " + cfg.Token
+	if cfg.Token == "" {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+cfg.Password))
+	}
+
+	return &ServiceNowSink{
+		tableURL:         strings.TrimSuffix(cfg.URL, "/") + "/api/now/table/incident",
+		authorization:    authorization,
+		filter:           newEventFilter([]string{v1.EventTypeWarning}, cfg.Reasons, cfg.Namespaces),
+		assignmentGroups: lowerKeys(cfg.AssignmentGroups),
+		assignmentGroup:  cfg.AssignmentGroup,
+		urgencies:        lowerKeys(cfg.Urgencies),
+		urgency:          cfg.Urgency,
+		impact:           cfg.Impact,
+		category:         cfg.Category,
+		callerID:         cfg.CallerID,
+		dedup:            newDeduplicator(cfg.UpdateInterval),
+		clusterName:      cfg.ClusterName,
+		retryMax:         cfg.RetryMax,
+		client:           &http.Client{Timeout: 30 * time.Second},
+		eventCh:          newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes matching
+// events to the event channel, which is drained by Run.
+func (s *ServiceNowSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !s.filter.match(eNew) {
+		return
+	}
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and creating or updating incidents.
+func (s *ServiceNowSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents handles one event at a time, skipping repeats within the
+// update interval.
+func (s *ServiceNowSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		if send, _ := s.dedup.observe(dedupKey(evt.Event), time.Now()); !send {
+			continue
+		}
+
+		correlationID := s.correlationID(evt.Event)
+		sysID, err := s.findActiveIncident(correlationID)
+		if err != nil {
+			glog.Errorf("Failed to look up ServiceNow incident %s: %v", correlationID, err)
+			deadLetter("servicenow", []EventData{evt}, err)
+			continue
+		}
+		if sysID != "" {
+			s.update(sysID, evt)
+		} else {
+			s.create(correlationID, evt)
+		}
+	}
+}
+
+// correlationID identifies the incidents for an event's object and reason.
+func (s *ServiceNowSink) correlationID(e *v1.Event) string {
+	sum := sha1.Sum([]byte(s.clusterName + "/" + dedupKey(e)))
+	return "eventrouter-" + hex.EncodeToString(sum[:10])
+}
+
+// findActiveIncident returns the sys_id of the active incident with
+// correlationID, if any.
+func (s *ServiceNowSink) findActiveIncident(correlationID string) (string, error) {
+	q := url.Values{}
+	q.Set("sysparm_query", "active=true^correlation_id="+correlationID)
+	q.Set("sysparm_fields", "sys_id")
+	q.Set("sysparm_limit", "1")
+
+	respBody, err := requestWithRetry(s.client, http.MethodGet, s.tableURL+"?"+q.Encode(), nil, s.retryMax, s.setHeaders)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Result []struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Result) == 0 {
+		return "", nil
+	}
+	return resp.Result[0].SysID, nil
+}
+
+// create opens an incident for evt.
+func (s *ServiceNowSink) create(correlationID string, evt EventData) {
+	e := evt.Event
+	o := e.InvolvedObject
+
+	shortDescription := fmt.Sprintf("%s %s/%s: %s", o.Kind, o.Namespace, o.Name, e.Reason)
+	if s.clusterName != "" {
+		shortDescription = "[" + s.clusterName + "] " + shortDescription
+	}
+	urgency, ok := s.urgencies[strings.ToLower(e.Reason)]
+	if !ok {
+		urgency = s.urgency
+	}
+	group, ok := s.assignmentGroups[strings.ToLower(o.Namespace)]
+	if !ok {
+		group = s.assignmentGroup
+	}
+
+	incident := map[string]string{
+		"short_description":   truncateRunes(shortDescription, serviceNowMaxShortDescription),
+		"description":         s.description(evt),
+		"urgency":             urgency,
+		"impact":              s.impact,
+		"correlation_id":      correlationID,
+		"correlation_display": "eventrouter",
+	}
+	for field, value := range map[string]string{
+		"assignment_group": group,
+		"category":         s.category,
+		"caller_id":        s.callerID,
+	} {
+		if value != "" {
+			incident[field] = value
+		}
+	}
+
+	body, err := json.Marshal(incident)
+	if err != nil {
+		glog.Warningf("Failed to json serialize servicenow incident: %v", err)
+		return
+	}
+	respBody, err := requestWithRetry(s.client, http.MethodPost, s.tableURL, body, s.retryMax, s.setHeaders)
+	if err != nil {
+		glog.Errorf("Failed to create ServiceNow incident for %s: %v", dedupKey(e), err)
+		deadLetter("servicenow", []EventData{evt}, err)
+		return
+	}
+	var resp struct {
+		Result struct {
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	json.Unmarshal(respBody, &resp)
+	glog.Infof("Created ServiceNow incident %s for %s", resp.Result.Number, dedupKey(e))
+}
+
+// update adds the latest occurrence of evt to an active incident as a work
+// note.
+func (s *ServiceNowSink) update(sysID string, evt EventData) {
+	e := evt.Event
+	body, err := json.Marshal(map[string]string{
+		"work_notes": fmt.Sprintf("Still occurring: %d times, last at %s.\n%s",
+			e.Count, eventTimestamp(e).UTC().Format(time.RFC3339), e.Message),
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize servicenow update: %v", err)
+		return
+	}
+	if _, err := requestWithRetry(s.client, http.MethodPatch, s.tableURL+"/"+url.PathEscape(sysID), body, s.retryMax, s.setHeaders); err != nil {
+		glog.Errorf("Failed to update ServiceNow incident %s: %v", sysID, err)
+		deadLetter("servicenow", []EventData{evt}, err)
+	}
+}
+
+// description lists the event's message and fields as plain text.
+func (s *ServiceNowSink) description(evt EventData) string {
+	e := evt.Event
+	o := e.InvolvedObject
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", e.Message)
+	if s.clusterName != "" {
+		fmt.Fprintf(&b, "Cluster: %s\n", s.clusterName)
+	}
+	fmt.Fprintf(&b, "Object: %s %s/%s\n", o.Kind, o.Namespace, o.Name)
+	fmt.Fprintf(&b, "Reason: %s\n", e.Reason)
+	fmt.Fprintf(&b, "Count: %d\n", e.Count)
+	if ts := eventTimestamp(e); !ts.IsZero() {
+		fmt.Fprintf(&b, "Last seen: %s\n", ts.UTC().Format(time.RFC3339))
+	}
+	if e.Source.Component != "" {
+		fmt.Fprintf(&b, "Source: %s\n", e.Source.Component)
+	}
+	return b.String()
+}
+
+// setHeaders adds content type and credentials to a Table API request.
+func (s *ServiceNowSink) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", s.authorization)
+}
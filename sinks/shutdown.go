@@ -0,0 +1,92 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	// sinkStop is the stop channel passed to ManufactureSink, closed when
+	// the sinks should flush what they buffered and return from Run.
+	sinkStop <-chan struct{}
+	// sinksRunning tracks the sinks whose Run has not returned yet.
+	sinksRunning sync.WaitGroup
+	// shutdownTimeout bounds how long WaitForShutdown waits for the sinks.
+	// Sinks that drain for longer, e.g. Event Hub, raise it.
+	shutdownTimeout = 10 * time.Second
+)
+
+// runSink starts run in a goroutine and stops it once sinkStop is closed.
+func runSink(run func(stopCh <-chan bool)) {
+	stopCh := make(chan bool)
+	sinksRunning.Add(1)
+	go func() {
+		defer sinksRunning.Done()
+		run(stopCh)
+	}()
+	go func() {
+		<-sinkStop
+		close(stopCh)
+	}()
+}
+
+// runSinkContext starts run in a goroutine and cancels its context once
+// sinkStop is closed.
+func runSinkContext(run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sinksRunning.Add(1)
+	go func() {
+		defer sinksRunning.Done()
+		run(ctx)
+	}()
+	go func() {
+		<-sinkStop
+		cancel()
+	}()
+}
+
+// extendShutdownTimeout raises shutdownTimeout to d for a sink that may
+// take up to d to return from Run once stopped.
+func extendShutdownTimeout(d time.Duration) {
+	if d > shutdownTimeout {
+		shutdownTimeout = d
+	}
+}
+
+// WaitForShutdown waits for the sinks' Run to return once the stop channel
+// passed to ManufactureSink is closed, and then for the dead letter archive
+// to write what they gave up on. It reports whether both finished within
+// the longest drain timeout of the configured sinks.
+func WaitForShutdown() bool {
+	timeout := time.NewTimer(shutdownTimeout)
+	defer timeout.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		sinksRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-timeout.C:
+		return false
+	}
+	return stopDeadLetters(timeout.C)
+}
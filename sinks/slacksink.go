@@ -0,0 +1,242 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+)
+
+// slackPostMessageURL is the Web API method used with a bot token.
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// slackDefaultTemplate renders a Block Kit message with the event's reason,
+// location and message. Templates are executed with a notification and
+// must produce a chat.postMessage/webhook JSON payload.
+const slackDefaultTemplate = `{
+  "text": {{json (printf "%s %s/%s: %s" .Event.Reason .Event.InvolvedObject.Kind .Event.InvolvedObject.Name .Event.Message)}},
+  "blocks": [
+    {"type": "header", "text": {"type": "plain_text", "text": {{json (truncate 150 (printf "%s: %s" .Event.Type .Event.Reason))}}}},
+    {"type": "section", "fields": [
+      {"type": "mrkdwn", "text": {{json (printf "*Cluster:*\n%s" .Cluster)}}},
+      {"type": "mrkdwn", "text": {{json (printf "*Namespace:*\n%s" .Event.InvolvedObject.Namespace)}}},
+      {"type": "mrkdwn", "text": {{json (printf "*Object:*\n%s/%s" .Event.InvolvedObject.Kind .Event.InvolvedObject.Name)}}},
+      {"type": "mrkdwn", "text": {{json (printf "*Count:*\n%d" .Event.Count)}}}
+    ]},
+    {"type": "section", "text": {"type": "plain_text", "text": {{json (truncate 3000 .Event.Message)}}}}
+    {{- if .Suppressed}},
+    {"type": "context", "elements": [{"type": "mrkdwn", "text": {{json (printf "%d repeats suppressed since the last notification" .Suppressed)}}}]}
+    {{- end}}
+  ]
+}`
+
+// SlackSinkConfig holds the options used to construct a SlackSink.
+type SlackSinkConfig struct {
+	// Destinations are either incoming webhook URLs or, when BotToken is
+	// set, channel IDs or names posted to with chat.postMessage.
+	// Routes maps involved object namespaces to destinations; other
+	// namespaces go to Default.
+	Default  string
+	Routes   map[string]string
+	BotToken string
+
+	// Template is a text/template for the message payload; empty uses
+	// slackDefaultTemplate.
+	Template string
+
+	// Types, Reasons and Namespaces select the events that are posted.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// DedupWindow suppresses repeats of an event within the window.
+	DedupWindow time.Duration
+
+	// RateLimit and RateBurst bound the messages per second posted to each
+	// destination; messages beyond that are dropped.
+	RateLimit float64
+	RateBurst int
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SlackSink posts selected events to Slack channels.
+type SlackSink struct {
+	defaultDest string
+	routes      map[string]string
+	botToken    string
+	tmpl        *template.Template
+	filter      eventFilter
+	dedup       *deduplicator
+	rateLimit   rate.Limit
+	rateBurst   int
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+
+	limiters map[string]*rate.Limiter
+}
+
+// NewSlackSink constructs a new SlackSink.
+func NewSlackSink(cfg SlackSinkConfig) (*SlackSink, error) {
+	text := cfg.Template
+	if text == "" {
+		text = slackDefaultTemplate
+	}
+	tmpl, err := newNotificationTemplate("slack", text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlackSink{
+		defaultDest: cfg.Default,
+		routes:      cfg.Routes,
+		botToken:    cfg.BotToken,
+		tmpl:        tmpl,
+		filter:      newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		dedup:       newDeduplicator(cfg.DedupWindow),
+		rateLimit:   rate.Limit(cfg.RateLimit),
+		rateBurst:   cfg.RateBurst,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+		limiters:    map[string]*rate.Limiter{},
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (s *SlackSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !s.filter.match(eNew) {
+		return
+	}
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and posting it to Slack.
+func (s *SlackSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents posts one message per event that is neither a recent repeat
+// nor over its destination's rate limit.
+func (s *SlackSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		send, suppressed := s.dedup.observe(dedupKey(evt.Event), time.Now())
+		if !send {
+			continue
+		}
+
+		dest := s.destination(evt.Event)
+		if dest == "" {
+			continue
+		}
+		if !s.limiter(dest).Allow() {
+			glog.V(2).Infof("Dropping Slack message for %s: rate limit exceeded", dedupKey(evt.Event))
+			continue
+		}
+
+		payload, err := executeTemplate(s.tmpl, newNotification(evt, s.clusterName, suppressed))
+		if err != nil {
+			glog.Warningf("Failed to render slack template: %v", err)
+			continue
+		}
+		if err := s.post(dest, payload); err != nil {
+			glog.Errorf("Failed to post event to Slack: %v", err)
+			deadLetter("slack", []EventData{evt}, err)
+		}
+	}
+}
+
+// destination returns where an event is posted.
+func (s *SlackSink) destination(e *v1.Event) string {
+	if dest, ok := s.routes[e.InvolvedObject.Namespace]; ok {
+		return dest
+	}
+	return s.defaultDest
+}
+
+// limiter returns the rate limiter of a destination.
+func (s *SlackSink) limiter(dest string) *rate.Limiter {
+	limiter, ok := s.limiters[dest]
+	if !ok {
+		limiter = rate.NewLimiter(s.rateLimit, s.rateBurst)
+		s.limiters[dest] = limiter
+	}
+	return limiter
+}
+
+// post sends payload to a webhook URL or, with the bot token, to a channel.
+func (s *SlackSink) post(dest string, payload []byte) error {
+	if strings.HasPrefix(dest, "https://") {
+		_, err := postWithRetry(s.client, dest, payload, s.retryMax, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json")
+		})
+		return err
+	}
+	if s.botToken == "" {
+		return fmt.Errorf("slack destination %q is not a webhook URL and no bot token is configured", dest)
+	}
+
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("slack template did not produce a JSON object: %v", err)
+	}
+	channel, _ := json.Marshal(dest)
+	msg["channel"] = channel
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	respBody, err := postWithRetry(s.client, slackPostMessageURL, body, s.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Authorization", "Bearer "+s.botToken)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The Web API reports failures in the body of a 200 response.
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("chat.postMessage to %s: %s", dest, result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,509 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// snowflakeMaxRequestBytes keeps appends well below the 16MB request
+	// limit of the streaming API.
+	snowflakeMaxRequestBytes = 4 << 20
+
+	// snowflakeTokenLifetime is how long a key-pair JWT and the scoped
+	// token exchanged for it are used before authenticating again.
+	snowflakeTokenLifetime = 50 * time.Minute
+
+	// snowflakeStatusInterval is how often the channel's committed offset
+	// is checked, to release the appended rows it covers.
+	snowflakeStatusInterval = 30 * time.Second
+
+	// snowflakeMaxUncommittedBytes is how much of the appended rows may be
+	// held before the committed offset is checked on every append.
+	snowflakeMaxUncommittedBytes = 64 << 20
+)
+
+// SnowflakeSinkConfig holds the options used to construct a SnowflakeSink.
+type SnowflakeSinkConfig struct {
+	// Account is the account identifier, e.g. myorg-myaccount. URL defaults
+	// to https://<account>.snowflakecomputing.com.
+	Account string
+	URL     string
+
+	// User authenticates with the unencrypted PKCS#8 RSA private key in
+	// PrivateKeyFile, whose public key is set as the user's RSA_PUBLIC_KEY.
+	User           string
+	PrivateKeyFile string
+
+	Database string
+	Schema   string
+
+	// Pipe defaults to the table's default streaming pipe, <TABLE>-STREAMING.
+	Table string
+	Pipe  string
+
+	// Channel names this eventrouter's channel; it must be unique per
+	// writer, so it defaults to one per cluster.
+	Channel string
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SnowflakeSink inserts events into a Snowflake table with the Snowpipe
+// Streaming REST API, without staging files in an object store.
+//
+// Every append carries an offset token, the running number of the last row
+// it contains. Appended rows are kept in memory until the channel's last
+// committed offset token covers them. After an error the channel is reopened,
+// which discards the rows it had not yet committed, and every append after
+// the committed offset token is resent in order, so each event is committed
+// exactly once as long as the eventrouter keeps running.
+type SnowflakeSink struct {
+	accountURL  string
+	account     string
+	user        string
+	key         *rsa.PrivateKey
+	fingerprint string
+	pipePath    string
+	channel     string
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+
+	ingestHost    string
+	token         string
+	tokenExpiry   time.Time
+	continuation  string
+	offset        int64
+	channelIsOpen bool
+
+	// uncommitted are the appends sent since the last committed offset
+	// token, in order, holding uncommittedBytes of rows.
+	uncommitted      []snowflakeAppend
+	uncommittedBytes int
+	lastStatus       time.Time
+}
+
+// snowflakeAppend is an append of NDJSON rows, the last of which is
+// numbered offset.
+type snowflakeAppend struct {
+	body   []byte
+	offset int64
+}
+
+// NewSnowflakeSink constructs a new SnowflakeSink. The channel is opened on
+// the first append.
+func NewSnowflakeSink(cfg SnowflakeSinkConfig) (*SnowflakeSink, error) {
+	key, err := loadRSAPrivateKey(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pub)
+
+	accountURL := cfg.URL
+	if accountURL == "" {
+		accountURL = "https://" + cfg.Account + ".snowflakecomputing.com"
+	}
+	pipe := cfg.Pipe
+	if pipe == "" {
+		pipe = strings.ToUpper(cfg.Table) + "-STREAMING"
+	}
+
+	// JWT claims use the account without any region suffix, upper cased.
+	account := strings.ToUpper(strings.SplitN(cfg.Account, ".", 2)[0])
+
+	return &SnowflakeSink{
+		accountURL:  strings.TrimSuffix(accountURL, "/"),
+		account:     account,
+		user:        strings.ToUpper(cfg.User),
+		key:         key,
+		fingerprint: "SHA256:" + base64.StdEncoding.EncodeToString(sum[:]),
+		pipePath: fmt.Sprintf("/databases/%s/schemas/%s/pipes/%s",
+			url.PathEscape(cfg.Database), url.PathEscape(cfg.Schema), url.PathEscape(pipe)),
+		channel:     cfg.Channel,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 60 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// loadRSAPrivateKey reads an unencrypted PKCS#8 or PKCS#1 RSA private key.
+func loadRSAPrivateKey(file string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", file)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %v", file, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an RSA key", file)
+	}
+	return rsaKey, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SnowflakeSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and appending it to the channel.
+func (s *SnowflakeSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents appends an array of event data as NDJSON rows, in as few
+// requests as the size limit allows.
+func (s *SnowflakeSink) drainEvents(events []EventData) {
+	// batched are the events in buf.
+	var buf bytes.Buffer
+	var batched []EventData
+	for _, evt := range events {
+		row, err := json.Marshal(s.row(evt))
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		if len(batched) > 0 && buf.Len()+len(row) > snowflakeMaxRequestBytes {
+			s.append(buf.Bytes(), batched)
+			buf.Reset()
+			batched = nil
+		}
+		buf.Write(row)
+		buf.WriteByte('\n')
+		batched = append(batched, evt)
+	}
+	if len(batched) > 0 {
+		s.append(buf.Bytes(), batched)
+	}
+}
+
+// row returns the flattened columns of an event plus the cluster and the
+// full event data for a VARIANT column. Zero timestamps become NULL.
+func (s *SnowflakeSink) row(evt EventData) map[string]interface{} {
+	row := flattenEventData(evt)
+	for k, v := range row {
+		if t, ok := v.(time.Time); ok && t.IsZero() {
+			row[k] = nil
+		}
+	}
+	row["cluster"] = s.clusterName
+	row["event"] = evt
+	return row
+}
+
+// append sends the rows of events with the offset token of the last of
+// them, reopening the channel and retrying with backoff after errors. The
+// events are dead lettered if the retries run out.
+func (s *SnowflakeSink) append(body []byte, events []EventData) {
+	rows := len(events)
+	// body is kept until committed, while the caller reuses its buffer.
+	body = append([]byte(nil), body...)
+
+	// offset is fixed by the first attempt that reaches an open channel,
+	// so retries resend the rows under the same offset token.
+	var offset int64
+	for attempt := 0; ; attempt++ {
+		err := s.tryAppend(body, rows, &offset)
+		if err == nil {
+			s.checkCommitted()
+			return
+		}
+		s.channelIsOpen = false
+		if attempt >= s.retryMax {
+			glog.Errorf("Failed to append %d events to Snowflake: %v", rows, err)
+			deadLetter("snowflake", events, err)
+			return
+		}
+		glog.Warningf("Failed to append events to Snowflake, retrying: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// tryAppend makes one append attempt. After reopening the channel, it first
+// resends the uncommitted appends, and returns without sending when the
+// channel shows the offset as already committed.
+func (s *SnowflakeSink) tryAppend(body []byte, rows int, offset *int64) error {
+	if !s.channelIsOpen {
+		if err := s.openChannel(); err != nil {
+			return err
+		}
+		committed := s.offset
+		for _, a := range s.uncommitted {
+			if err := s.appendRows(a.body, a.offset); err != nil {
+				return err
+			}
+		}
+		if len(s.uncommitted) > 0 {
+			glog.V(2).Infof("Resent %d uncommitted Snowflake appends after offset %d", len(s.uncommitted), committed)
+		}
+		if *offset != 0 && committed >= *offset {
+			glog.V(2).Infof("Snowflake already committed offset %d, not resending", *offset)
+			return nil
+		}
+	}
+	if *offset == 0 {
+		*offset = s.offset + int64(rows)
+	}
+
+	if err := s.appendRows(body, *offset); err != nil {
+		return err
+	}
+	s.uncommitted = append(s.uncommitted, snowflakeAppend{body: body, offset: *offset})
+	s.uncommittedBytes += len(body)
+	return nil
+}
+
+// appendRows sends NDJSON rows to the open channel under offset.
+func (s *SnowflakeSink) appendRows(body []byte, offset int64) error {
+	q := url.Values{}
+	q.Set("continuationToken", s.continuation)
+	q.Set("offsetToken", strconv.FormatInt(offset, 10))
+	var resp struct {
+		NextContinuationToken string `json:"next_continuation_token"`
+	}
+	err := s.do(http.MethodPost, "https://"+s.ingestHost+"/v2/streaming/data"+s.channelPath()+"/rows?"+q.Encode(),
+		"application/x-ndjson", body, &resp)
+	if err != nil {
+		return err
+	}
+	s.continuation = resp.NextContinuationToken
+	s.offset = offset
+	return nil
+}
+
+// checkCommitted releases the appends covered by the channel's committed
+// offset token, checking it every snowflakeStatusInterval, or on every
+// append while more than snowflakeMaxUncommittedBytes are held.
+func (s *SnowflakeSink) checkCommitted() {
+	if len(s.uncommitted) == 0 {
+		return
+	}
+	if time.Since(s.lastStatus) < snowflakeStatusInterval && s.uncommittedBytes <= snowflakeMaxUncommittedBytes {
+		return
+	}
+	s.lastStatus = time.Now()
+
+	body, err := json.Marshal(map[string][]string{"channel_names": {s.channel}})
+	if err != nil {
+		return
+	}
+	var resp struct {
+		ChannelStatuses map[string]struct {
+			LastCommittedOffsetToken string `json:"last_committed_offset_token"`
+		} `json:"channel_statuses"`
+	}
+	if err := s.do(http.MethodPost, "https://"+s.ingestHost+"/v2/streaming"+s.pipePath+":bulk-channel-status",
+		"application/json", body, &resp); err != nil {
+		glog.Warningf("Failed to get Snowflake channel status: %v", err)
+		return
+	}
+	committed, err := parseSnowflakeOffset(resp.ChannelStatuses[s.channel].LastCommittedOffsetToken)
+	if err != nil {
+		glog.Warningf("Failed to get Snowflake channel status: %v", err)
+		return
+	}
+	s.release(committed)
+	if s.uncommittedBytes > snowflakeMaxUncommittedBytes {
+		glog.Warningf("Holding %d bytes of Snowflake rows appended after offset %d until they are committed", s.uncommittedBytes, committed)
+	}
+}
+
+// release drops the appends up to the committed offset.
+func (s *SnowflakeSink) release(committed int64) {
+	n := 0
+	for n < len(s.uncommitted) && s.uncommitted[n].offset <= committed {
+		s.uncommittedBytes -= len(s.uncommitted[n].body)
+		n++
+	}
+	s.uncommitted = s.uncommitted[n:]
+}
+
+// channelPath returns the path of the channel under the ingest host's
+// streaming API.
+func (s *SnowflakeSink) channelPath() string {
+	return s.pipePath + "/channels/" + url.PathEscape(s.channel)
+}
+
+// parseSnowflakeOffset parses an offset token set by this sink, or an empty
+// one as zero.
+func parseSnowflakeOffset(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("channel has foreign offset token %q", token)
+	}
+	return n, nil
+}
+
+// openChannel opens, or reopens, the channel and continues numbering rows
+// after its last committed offset token, releasing the appends it covers.
+func (s *SnowflakeSink) openChannel() error {
+	if err := s.authenticate(); err != nil {
+		return err
+	}
+	var resp struct {
+		NextContinuationToken string `json:"next_continuation_token"`
+		ChannelStatus         struct {
+			LastCommittedOffsetToken string `json:"last_committed_offset_token"`
+		} `json:"channel_status"`
+	}
+	if err := s.do(http.MethodPut, "https://"+s.ingestHost+"/v2/streaming"+s.channelPath(), "application/json", []byte("{}"), &resp); err != nil {
+		return fmt.Errorf("opening channel: %v", err)
+	}
+
+	committed, err := parseSnowflakeOffset(resp.ChannelStatus.LastCommittedOffsetToken)
+	if err != nil {
+		return err
+	}
+	s.continuation = resp.NextContinuationToken
+	s.offset = committed
+	s.channelIsOpen = true
+	s.release(committed)
+	glog.V(2).Infof("Opened Snowflake channel %s at offset %d", s.channelPath(), committed)
+	return nil
+}
+
+// authenticate discovers the ingest host and exchanges a key-pair JWT for a
+// scoped token, unless the current token is still valid.
+func (s *SnowflakeSink) authenticate() error {
+	if s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return nil
+	}
+
+	now := time.Now()
+	qualifiedUser := s.account + "." + s.user
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": qualifiedUser + "." + s.fingerprint,
+		"sub": qualifiedUser,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}).SignedString(s.key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.accountURL+"/v2/streaming/hostname", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	host, err := s.send(req)
+	if err != nil {
+		return fmt.Errorf("discovering ingest host: %v", err)
+	}
+	ingestHost := strings.TrimSpace(string(host))
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("scope", ingestHost)
+	req, err = http.NewRequest(http.MethodPost, s.accountURL+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+signed)
+	token, err := s.send(req)
+	if err != nil {
+		return fmt.Errorf("exchanging scoped token: %v", err)
+	}
+
+	s.ingestHost = ingestHost
+	s.token = strings.TrimSpace(string(token))
+	s.tokenExpiry = now.Add(snowflakeTokenLifetime)
+	return nil
+}
+
+// do sends a request to the ingest host with the scoped token and decodes
+// the JSON response into out.
+func (s *SnowflakeSink) do(method, url, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "OAUTH")
+
+	respBody, err := s.send(req)
+	if err != nil {
+		if statusErr, ok := err.(*httpStatusError); ok && statusErr.StatusCode == http.StatusUnauthorized {
+			s.token = ""
+		}
+		return err
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// send makes a single request and returns the response body, or an
+// *httpStatusError for non-2xx responses.
+func (s *SnowflakeSink) send(req *http.Request) ([]byte, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return respBody, nil
+}
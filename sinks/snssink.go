@@ -0,0 +1,162 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// snsMaxBatchEntries and snsMaxBatchBytes are the PublishBatch limits.
+	snsMaxBatchEntries = 10
+	snsMaxBatchBytes   = 256 * 1024
+)
+
+// SNSSink publishes events to an AWS SNS topic. Each message carries
+// severity, namespace and reason message attributes so that subscriptions
+// can use filter policies instead of a custom consumer.
+type SNSSink struct {
+	client   *sns.Client
+	topicARN string
+	eventCh  channels.Channel
+}
+
+// NewSNSSink constructs a new SNSSink publishing to topicARN. Credentials are
+// taken from the default AWS credential chain.
+func NewSNSSink(topicARN string, region string, overflow bool, bufferSize int) (*SNSSink, error) {
+	cfg, err := newAWSConfig(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SNSSink{
+		client:   sns.NewFromConfig(cfg),
+		topicARN: topicARN,
+		eventCh:  newEventChannel(overflow, bufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SNSSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and publishing it to the topic.
+func (s *SNSSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents publishes an array of event data in as few PublishBatch calls
+// as the SNS batch limits allow.
+func (s *SNSSink) drainEvents(events []EventData) {
+	fifo := strings.HasSuffix(s.topicARN, ".fifo")
+
+	var entries []types.PublishBatchRequestEntry
+	batchBytes := 0
+	for i, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(entries) == snsMaxBatchEntries || (len(entries) > 0 && batchBytes+len(eJSONBytes) > snsMaxBatchBytes) {
+			s.publishBatch(entries, events)
+			entries = nil
+			batchBytes = 0
+		}
+
+		entry := types.PublishBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			Message:           aws.String(string(eJSONBytes)),
+			MessageAttributes: snsMessageAttributes(evt.Event),
+		}
+		if fifo {
+			// FIFO topics order messages within a group, so group by the
+			// involved object to keep its events in sequence.
+			entry.MessageGroupId = aws.String(string(evt.Event.InvolvedObject.UID))
+		}
+		entries = append(entries, entry)
+		batchBytes += len(eJSONBytes)
+	}
+
+	if len(entries) > 0 {
+		s.publishBatch(entries, events)
+	}
+}
+
+// publishBatch sends a single PublishBatch request and logs and dead
+// letters failed entries, whose IDs index events.
+func (s *SNSSink) publishBatch(entries []types.PublishBatchRequestEntry, events []EventData) {
+	out, err := s.client.PublishBatch(context.TODO(), &sns.PublishBatchInput{
+		TopicArn:                   aws.String(s.topicARN),
+		PublishBatchRequestEntries: entries,
+	})
+	if err != nil {
+		glog.Errorf("Failed to publish events to SNS: %v", err)
+		failed := make([]EventData, 0, len(entries))
+		for _, entry := range entries {
+			failed = append(failed, snsEntryEvent(events, entry.Id))
+		}
+		deadLetter("sns", failed, err)
+		return
+	}
+	for _, failed := range out.Failed {
+		glog.Errorf("Failed to publish event to SNS: %s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message))
+		deadLetter("sns", []EventData{snsEntryEvent(events, failed.Id)},
+			fmt.Errorf("%s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message)))
+	}
+}
+
+// snsEntryEvent returns the event of a batch entry by its ID.
+func snsEntryEvent(events []EventData, id *string) EventData {
+	i, _ := strconv.Atoi(aws.ToString(id))
+	return events[i]
+}
+
+// snsMessageAttributes returns the filterable attributes for an event. SNS
+// rejects attributes with empty values, so those are left out.
+func snsMessageAttributes(e *v1.Event) map[string]types.MessageAttributeValue {
+	attrs := map[string]types.MessageAttributeValue{}
+	for name, value := range map[string]string{
+		"severity":  e.Type,
+		"namespace": e.InvolvedObject.Namespace,
+		"reason":    e.Reason,
+	} {
+		if value == "" {
+			continue
+		}
+		attrs[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SplunkHECSinkConfig holds the options used to construct a SplunkHECSink.
+type SplunkHECSinkConfig struct {
+	// URL is the HEC base URL, e.g. https://splunk:8088.
+	URL        string
+	Token      string
+	Index      string
+	SourceType string
+	Source     string
+	Host       string
+
+	// Gzip compresses request bodies.
+	Gzip bool
+
+	// UseAck enables indexer acknowledgement: every batch is sent on a
+	// request channel and polled until Splunk confirms it was indexed, and
+	// resent if that does not happen within AckTimeout. The token must have
+	// indexer acknowledgement enabled.
+	UseAck     bool
+	AckTimeout time.Duration
+
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// splunkHECEvent is a single event in the HEC event format.
+type splunkHECEvent struct {
+	Time       float64   `json:"time"`
+	Host       string    `json:"host,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	SourceType string    `json:"sourcetype,omitempty"`
+	Index      string    `json:"index,omitempty"`
+	Event      EventData `json:"event"`
+}
+
+// SplunkHECSink sends events to a Splunk HTTP Event Collector.
+type SplunkHECSink struct {
+	cfg     SplunkHECSinkConfig
+	baseURL string
+	channel string
+	client  *http.Client
+	eventCh channels.Channel
+}
+
+// NewSplunkHECSink constructs a new SplunkHECSink.
+func NewSplunkHECSink(cfg SplunkHECSinkConfig) (*SplunkHECSink, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLSCAFile, "", "", cfg.TLSInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SplunkHECSink{
+		cfg:     cfg,
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+		channel: uuid.NewString(),
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SplunkHECSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and sending it to HEC.
+func (s *SplunkHECSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents sends an array of event data as one batched HEC request.
+func (s *SplunkHECSink) drainEvents(events []EventData) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(splunkHECEvent{
+			Time:       float64(eventTimestamp(evt.Event).UnixNano()) / float64(time.Second),
+			Host:       s.cfg.Host,
+			Source:     s.cfg.Source,
+			SourceType: s.cfg.SourceType,
+			Index:      s.cfg.Index,
+			Event:      evt,
+		})
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		buf.Write(eJSONBytes)
+	}
+
+	body := buf.Bytes()
+	if s.cfg.Gzip {
+		var err error
+		if body, err = gzipBytes(body); err != nil {
+			glog.Errorf("Failed to compress HEC request: %v", err)
+			deadLetter("splunk", events, err)
+			return
+		}
+	}
+
+	for attempt := 0; attempt <= s.cfg.RetryMax; attempt++ {
+		ackID, err := s.send(body)
+		if err != nil {
+			glog.Errorf("Failed to send %d events to Splunk HEC: %v", len(events), err)
+			deadLetter("splunk", events, err)
+			return
+		}
+		if !s.cfg.UseAck || s.waitForAck(ackID) {
+			return
+		}
+		glog.Warningf("Splunk HEC did not acknowledge %d events within %v, resending", len(events), s.cfg.AckTimeout)
+	}
+	glog.Errorf("Splunk HEC never acknowledged %d events", len(events))
+	deadLetter("splunk", events, fmt.Errorf("not acknowledged after %d attempts", s.cfg.RetryMax+1))
+}
+
+// send posts a batch to the event endpoint and returns its ack ID.
+func (s *SplunkHECSink) send(body []byte) (int64, error) {
+	respBody, err := postWithRetry(s.client, s.baseURL+"/services/collector/event", body, s.cfg.RetryMax, s.setHeaders)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		AckID int64 `json:"ackId"`
+	}
+	if s.cfg.UseAck {
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return 0, fmt.Errorf("decoding HEC response: %v", err)
+		}
+	}
+	return resp.AckID, nil
+}
+
+// waitForAck polls the ack endpoint until Splunk reports ackID as indexed or
+// AckTimeout passes.
+func (s *SplunkHECSink) waitForAck(ackID int64) bool {
+	deadline := time.Now().Add(s.cfg.AckTimeout)
+	body, _ := json.Marshal(map[string][]int64{"acks": {ackID}})
+	key := fmt.Sprint(ackID)
+
+	for attempt := 1; time.Now().Before(deadline); attempt++ {
+		time.Sleep(backoff(attempt, time.Second, 10*time.Second))
+
+		respBody, err := postWithRetry(s.client, s.baseURL+"/services/collector/ack", body, s.cfg.RetryMax, func(req *http.Request) {
+			req.Header.Set("Authorization", "Splunk "+s.cfg.Token)
+			req.Header.Set("X-Splunk-Request-Channel", s.channel)
+		})
+		if err != nil {
+			glog.Warningf("Failed to query Splunk HEC ack status: %v", err)
+			continue
+		}
+
+		var resp struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			glog.Warningf("Failed to decode Splunk HEC ack response: %v", err)
+			continue
+		}
+		if resp.Acks[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// setHeaders adds the token, channel and encoding headers to a request.
+func (s *SplunkHECSink) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Splunk "+s.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.cfg.UseAck {
+		req.Header.Set("X-Splunk-Request-Channel", s.channel)
+	}
+}
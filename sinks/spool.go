@@ -0,0 +1,357 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// spoolSegmentBytes is the size beyond which the spool starts a new
+// segment file, so delivered events free disk space before the spool is
+// empty.
+const spoolSegmentBytes = 8 << 20
+
+// errSpoolFull is returned when appending would grow a spool beyond its
+// maximum size.
+var errSpoolFull = errors.New("spool is full")
+
+// spoolSegment is a file of the spool, named by its sequence number.
+type spoolSegment struct {
+	seq   uint64
+	size  int64
+	count int
+}
+
+// spoolPos is a position in the spool: an offset in a segment.
+type spoolPos struct {
+	seq uint64
+	off int64
+}
+
+// diskSpool is a first-in, first-out queue of events on disk, kept as
+// newline-delimited JSON in numbered segment files of a directory. Events
+// are read from the head without being removed, and only committed once
+// delivered. The head is saved in the directory's head file, so events
+// spooled by a previous run are replayed on start.
+type diskSpool struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	segments []*spoolSegment
+	head     spoolPos
+	nextSeq  uint64
+	size     int64
+	count    int
+	w        *os.File
+}
+
+// newDiskSpool opens the spool in dir, creating the directory and picking
+// up the segments left there.
+func newDiskSpool(dir string, maxBytes int64) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	head, err := readSpoolHead(filepath.Join(dir, "head"))
+	if err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &diskSpool{dir: dir, maxBytes: maxBytes, nextSeq: head.seq + 1}
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), ".ndjson"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+		if seq < head.seq {
+			// Used up, but not removed before the last run stopped.
+			os.Remove(name)
+			continue
+		}
+		skip := int64(0)
+		if seq == head.seq {
+			skip = head.off
+		}
+		count, size, err := countLines(name, skip)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, &spoolSegment{seq: seq, size: size, count: count})
+		s.size += size
+		s.count += count
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+	if len(s.segments) > 0 {
+		s.head = spoolPos{seq: s.segments[0].seq}
+		if s.head.seq == head.seq {
+			s.head.off = head.off
+		}
+		glog.Infof("Found %d spooled events in %s", s.count, dir)
+	}
+	return s, nil
+}
+
+// readSpoolHead reads the head position saved in name, if there is one.
+func readSpoolHead(name string) (spoolPos, error) {
+	var pos spoolPos
+	b, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, err
+	}
+	if _, err := fmt.Sscanf(string(b), "%d %d", &pos.seq, &pos.off); err != nil {
+		glog.Warningf("Ignoring invalid spool head %s: %v", name, err)
+		return spoolPos{}, nil
+	}
+	return pos, nil
+}
+
+// countLines returns the number of lines in a file after the first skip
+// bytes, and the file's size. A last line without a newline, left by a
+// crash, is counted too.
+func countLines(name string, skip int64) (int, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	size := int64(0)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		size += int64(len(line))
+		if len(line) > 0 && size > skip {
+			count++
+		}
+		if err == io.EOF {
+			return count, size, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// path returns the file name of a segment.
+func (s *diskSpool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d.ndjson", seq))
+}
+
+// len returns the number of spooled events.
+func (s *diskSpool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// append adds events to the tail of the spool. It stops at the first event
+// that does not fit, returning errSpoolFull and the number of events
+// appended.
+func (s *diskSpool) append(events []EventData) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, evt := range events {
+		line, err := json.Marshal(evt)
+		if err != nil {
+			return i, err
+		}
+		line = append(line, '\n')
+		if s.size+int64(len(line)) > s.maxBytes {
+			return i, errSpoolFull
+		}
+
+		// Segments left by a previous run are never appended to, in case
+		// they end with a partial line.
+		tail := s.tail()
+		if s.w == nil || tail.size+int64(len(line)) > spoolSegmentBytes && tail.size > 0 {
+			if err := s.openSegment(); err != nil {
+				return i, err
+			}
+			tail = s.tail()
+		}
+		n, err := s.w.Write(line)
+		tail.size += int64(n)
+		s.size += int64(n)
+		if err != nil {
+			return i, err
+		}
+		tail.count++
+		s.count++
+	}
+	return len(events), nil
+}
+
+// tail returns the last segment, or nil if there is none.
+func (s *diskSpool) tail() *spoolSegment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// openSegment starts a new segment for appending.
+func (s *diskSpool) openSegment() error {
+	seq := s.nextSeq
+	f, err := os.OpenFile(s.path(seq), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	s.nextSeq++
+	if s.w != nil {
+		s.w.Close()
+	}
+	s.w = f
+	if len(s.segments) == 0 {
+		s.head = spoolPos{seq: seq}
+	}
+	s.segments = append(s.segments, &spoolSegment{seq: seq})
+	return nil
+}
+
+// read returns up to n events from the head of the spool, without removing
+// them, and the position after them to pass to commit. Lines that cannot
+// be decoded are skipped, but count towards n.
+func (s *diskSpool) read(n int) ([]EventData, int, spoolPos, error) {
+	// Segments are only read up to the size they had, so events being
+	// appended meanwhile are not read partially written.
+	s.mu.Lock()
+	segments := make([]spoolSegment, len(s.segments))
+	for i, seg := range s.segments {
+		segments[i] = *seg
+	}
+	pos := s.head
+	s.mu.Unlock()
+
+	var events []EventData
+	read := 0
+	for _, seg := range segments {
+		if read >= n {
+			break
+		}
+		if seg.seq < pos.seq {
+			continue
+		}
+		if seg.seq > pos.seq {
+			pos = spoolPos{seq: seg.seq}
+		}
+
+		f, err := os.Open(s.path(seg.seq))
+		if err != nil {
+			return nil, 0, pos, err
+		}
+		if _, err := f.Seek(pos.off, io.SeekStart); err != nil {
+			f.Close()
+			return nil, 0, pos, err
+		}
+		r := bufio.NewReader(io.LimitReader(f, seg.size-pos.off))
+		for read < n {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				pos.off += int64(len(line))
+				read++
+				var evt EventData
+				if jErr := json.Unmarshal(bytes.TrimSpace(line), &evt); jErr != nil || evt.Event == nil {
+					glog.Warningf("Skipping undecodable spooled event in %s: %v", s.path(seg.seq), jErr)
+				} else {
+					events = append(events, evt)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, 0, pos, err
+			}
+		}
+		f.Close()
+	}
+	return events, read, pos, nil
+}
+
+// commit removes the n events before pos, as returned by read, from the
+// spool, deleting the segments that are used up.
+func (s *diskSpool) commit(n int, pos spoolPos) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count -= n
+	s.head = pos
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		usedUp := seg.seq < pos.seq || seg.seq == pos.seq && pos.off >= seg.size
+		if !usedUp {
+			break
+		}
+		if seg == s.tail() {
+			if s.count > 0 {
+				break
+			}
+			// Start over with a new segment rather than appending to
+			// a used up one.
+			if s.w != nil {
+				s.w.Close()
+				s.w = nil
+			}
+		}
+		if err := os.Remove(s.path(seg.seq)); err != nil {
+			glog.Errorf("Failed to remove spool segment: %v", err)
+		}
+		s.size -= seg.size
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) > 0 && s.head.seq < s.segments[0].seq {
+		s.head = spoolPos{seq: s.segments[0].seq}
+	}
+	if err := s.saveHead(); err != nil {
+		glog.Errorf("Failed to save spool head: %v", err)
+	}
+}
+
+// saveHead saves the head position, replacing the head file atomically.
+func (s *diskSpool) saveHead() error {
+	name := filepath.Join(s.dir, "head")
+	if err := os.WriteFile(name+".tmp", []byte(fmt.Sprintf("%d %d\n", s.head.seq, s.head.off)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
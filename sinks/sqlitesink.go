@@ -0,0 +1,242 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+
+	// Registers the pure Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTimeFormat stores timestamps as fixed width UTC text, which sorts
+// chronologically and works with SQLite's date and time functions.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// sqliteSchema creates the events table and its indexes. %[1]s is the
+// quoted table name and %[2]s the unquoted one, for index names.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id INTEGER PRIMARY KEY,
+	cluster TEXT NOT NULL,
+	verb TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	name TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	type TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	last_timestamp TEXT NOT NULL,
+	event TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "%[2]s_namespace_idx" ON %[1]s (namespace, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_reason_idx" ON %[1]s (reason, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_type_idx" ON %[1]s (type, last_timestamp);
+CREATE INDEX IF NOT EXISTS "%[2]s_last_timestamp_idx" ON %[1]s (last_timestamp);
+`
+
+// SQLiteSinkConfig holds the options used to construct an SQLiteSink.
+type SQLiteSinkConfig struct {
+	// Path is the database file, e.g. /var/lib/eventrouter/events.db.
+	Path  string
+	Table string
+
+	// MaxAge and MaxRows prune events older than the age or beyond the
+	// newest rows every PruneInterval; zero disables either.
+	MaxAge        time.Duration
+	MaxRows       int
+	PruneInterval time.Duration
+
+	ClusterName string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SQLiteSink stores events in a local SQLite database, for a queryable
+// history without running a database server. The commonly queried fields
+// are indexed columns next to the complete EventData JSON, which SQLite's
+// JSON functions can query. The database uses write-ahead logging, so it
+// can be read, e.g. with the sqlite3 shell, while events are written.
+type SQLiteSink struct {
+	db            *sql.DB
+	table         string
+	maxAge        time.Duration
+	maxRows       int
+	pruneInterval time.Duration
+	clusterName   string
+	eventCh       channels.Channel
+}
+
+// NewSQLiteSink opens or creates the database and constructs a new
+// SQLiteSink, creating the table if it does not exist.
+func NewSQLiteSink(cfg SQLiteSinkConfig) (*SQLiteSink, error) {
+	if cfg.PruneInterval <= 0 && (cfg.MaxAge > 0 || cfg.MaxRows > 0) {
+		return nil, fmt.Errorf("invalid sqlite prune interval %v, expected a positive duration", cfg.PruneInterval)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids busy errors
+	// between the inserts and the pruning.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteSink{
+		db:            db,
+		table:         `"` + strings.ReplaceAll(cfg.Table, `"`, `""`) + `"`,
+		maxAge:        cfg.MaxAge,
+		maxRows:       cfg.MaxRows,
+		pruneInterval: cfg.PruneInterval,
+		clusterName:   cfg.ClusterName,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}
+	schema := fmt.Sprintf(sqliteSchema, s.table, strings.ReplaceAll(cfg.Table, `"`, `""`))
+	if _, err := db.ExecContext(context.TODO(), schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SQLiteSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run prunes the table every pruneInterval and sits in a loop, waiting for
+// data to come in through s.eventCh, and inserting it into the table.
+func (s *SQLiteSink) Run(stopCh <-chan bool) {
+	defer s.db.Close()
+
+	done := make(chan bool)
+	defer close(done)
+	if s.maxAge > 0 || s.maxRows > 0 {
+		go func() {
+			ticker := time.NewTicker(s.pruneInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s.prune()
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+}
+
+// drainEvents inserts an array of event data in a single transaction.
+func (s *SQLiteSink) drainEvents(events []EventData) {
+	if err := s.insert(events); err != nil {
+		glog.Errorf("Failed to insert %d events into SQLite: %v", len(events), err)
+		deadLetter("sqlite", events, err)
+	}
+}
+
+func (s *SQLiteSink) insert(events []EventData) error {
+	tx, err := s.db.BeginTx(context.TODO(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO " + s.table +
+		" (cluster, verb, namespace, kind, name, reason, type, count, message, last_timestamp, event)" +
+		" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, evt := range events {
+		eJSONBytes, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		e := evt.Event
+		_, err = stmt.Exec(
+			s.clusterName,
+			evt.Verb,
+			e.InvolvedObject.Namespace,
+			e.InvolvedObject.Kind,
+			e.InvolvedObject.Name,
+			e.Reason,
+			e.Type,
+			e.Count,
+			e.Message,
+			eventTimestamp(e).UTC().Format(sqliteTimeFormat),
+			string(eJSONBytes),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// prune deletes events beyond the retention limits.
+func (s *SQLiteSink) prune() {
+	var deleted int64
+	if s.maxAge > 0 {
+		cutoff := time.Now().Add(-s.maxAge).UTC().Format(sqliteTimeFormat)
+		res, err := s.db.ExecContext(context.TODO(), "DELETE FROM "+s.table+" WHERE last_timestamp < ?", cutoff)
+		if err != nil {
+			glog.Errorf("Failed to prune SQLite events older than %v: %v", s.maxAge, err)
+		} else if n, err := res.RowsAffected(); err == nil {
+			deleted += n
+		}
+	}
+	if s.maxRows > 0 {
+		res, err := s.db.ExecContext(context.TODO(), "DELETE FROM "+s.table+" WHERE id <= (SELECT MAX(id) FROM "+s.table+") - ?", s.maxRows)
+		if err != nil {
+			glog.Errorf("Failed to prune SQLite events beyond %d rows: %v", s.maxRows, err)
+		} else if n, err := res.RowsAffected(); err == nil {
+			deleted += n
+		}
+	}
+	if deleted > 0 {
+		glog.V(2).Infof("Pruned %d events from SQLite", deleted)
+	}
+}
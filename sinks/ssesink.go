@@ -0,0 +1,255 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// sseHeartbeatInterval is how often idle streams get a comment, which keeps
+// proxies from timing them out.
+const sseHeartbeatInterval = 15 * time.Second
+
+// sseEntry is a buffered event with its ID.
+type sseEntry struct {
+	id   int64
+	evt  EventData
+	data []byte
+}
+
+// SSESinkConfig holds the options used to construct an SSESink.
+type SSESinkConfig struct {
+	// ListenAddress is the address the server listens on, e.g. :8082.
+	ListenAddress string
+
+	// Token, if set, must be sent as a bearer token or as the token query
+	// parameter.
+	Token string
+
+	// HistorySize is how many recent events are kept for clients resuming
+	// with Last-Event-ID.
+	HistorySize int
+
+	// ClientBuffer is how many events are queued per client. A client that
+	// falls further behind is disconnected, and can resume from the
+	// history.
+	ClientBuffer int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SSESink serves /events/stream, which streams events as Server-Sent
+// Events, e.g. to curl -N or a browser's EventSource. Clients can limit the
+// events they receive with namespace, type and reason query parameters.
+// Each event has an increasing ID, and a client reconnecting with
+// Last-Event-ID first receives the events it missed that are still in the
+// history. IDs start from the startup time in microseconds, so they keep
+// increasing across restarts.
+type SSESink struct {
+	token        string
+	historySize  int
+	clientBuffer int
+	server       *http.Server
+	eventCh      channels.Channel
+
+	mu      sync.Mutex
+	nextID  int64
+	history []sseEntry
+	clients map[*sseClient]bool
+}
+
+// sseClient is a stream and the events queued for it.
+type sseClient struct {
+	filter eventFilter
+	send   chan sseEntry
+}
+
+// NewSSESink constructs a new SSESink. The server is started by Run.
+func NewSSESink(cfg SSESinkConfig) *SSESink {
+	s := &SSESink{
+		token:        cfg.Token,
+		historySize:  cfg.HistorySize,
+		clientBuffer: cfg.ClientBuffer,
+		eventCh:      newEventChannel(cfg.Overflow, cfg.BufferSize),
+		nextID:       time.Now().UnixMicro(),
+		clients:      map[*sseClient]bool{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events/stream", s.serve)
+	s.server = &http.Server{Addr: cfg.ListenAddress, Handler: mux}
+	return s
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SSESink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run starts the server and sits in a loop, waiting for data to come in
+// through s.eventCh, and streaming it to the connected clients.
+func (s *SSESink) Run(stopCh <-chan bool) {
+	go func() {
+		glog.Infof("Serving server-sent events on %s", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
+			glog.Errorf("SSE server failed: %v", err)
+		}
+	}()
+
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+	s.server.Close()
+}
+
+// drainEvents adds events to the history and queues them for the clients
+// whose filter they match.
+func (s *SSESink) drainEvents(events []EventData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, evt := range events {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		entry := sseEntry{id: s.nextID, evt: evt, data: data}
+		s.nextID++
+
+		s.history = append(s.history, entry)
+		if len(s.history) > s.historySize {
+			// Copy rather than reslice, so the array does not grow forever.
+			s.history = append([]sseEntry(nil), s.history[len(s.history)-s.historySize:]...)
+		}
+
+		for c := range s.clients {
+			if !c.filter.match(evt.Event) {
+				continue
+			}
+			select {
+			case c.send <- entry:
+			default:
+				glog.Warningf("Disconnecting SSE client: more than %d events behind", s.clientBuffer)
+				delete(s.clients, c)
+				close(c.send)
+			}
+		}
+	}
+}
+
+// serve streams events to a client, starting with the ones it missed.
+func (s *SSESink) serve(w http.ResponseWriter, r *http.Request) {
+	if !requestHasToken(r, s.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := int64(-1)
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	q := r.URL.Query()
+	c := &sseClient{
+		filter: newEventFilter(q["type"], q["reason"], q["namespace"]),
+		send:   make(chan sseEntry, s.clientBuffer),
+	}
+
+	// Collect the missed events and register under the same lock, so no
+	// event is skipped or sent twice.
+	s.mu.Lock()
+	var missed []sseEntry
+	if lastID >= 0 {
+		for _, entry := range s.history {
+			if entry.id > lastID && c.filter.match(entry.evt.Event) {
+				missed = append(missed, entry)
+			}
+		}
+	}
+	s.clients[c] = true
+	s.mu.Unlock()
+	defer s.unregister(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, entry := range missed {
+		if !writeSSE(w, entry) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case entry, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if !writeSSE(w, entry) {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE writes an event in the event stream format. JSON never contains
+// raw newlines, so data fits on one line.
+func writeSSE(w http.ResponseWriter, entry sseEntry) bool {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", entry.id, entry.evt.Verb, entry.data)
+	return err == nil
+}
+
+// unregister removes a client that disconnected.
+func (s *SSESink) unregister(c *sseClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.send)
+	}
+}
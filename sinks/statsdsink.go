@@ -0,0 +1,250 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// statsdMaxPacketBytes keeps UDP datagrams below a typical MTU.
+const statsdMaxPacketBytes = 1432
+
+// DogStatsD limits event text to 4KB.
+const statsdMaxEventText = 4000
+
+// StatsdSinkConfig holds the options used to construct a StatsdSink.
+type StatsdSinkConfig struct {
+	// Network is "udp" or "unixgram"; Address is host:port or a socket
+	// path.
+	Network string
+	Address string
+
+	// Flavor is "dogstatsd", which sends tags and events, or "statsd",
+	// which encodes the dimensions in the metric name.
+	Flavor string
+
+	// Prefix is prepended to metric names, e.g. "k8s" for k8s.events.
+	Prefix string
+
+	// Tags are added to every metric and event, as key:value.
+	Tags []string
+
+	ClusterName string
+
+	Overflow   bool
+	BufferSize int
+}
+
+// StatsdSink counts events in a StatsD or DogStatsD server: a counter
+// incremented for every event, dimensioned by namespace, reason, type and
+// involved object kind. With DogStatsD, Warning events are also sent as
+// events.
+type StatsdSink struct {
+	network string
+	address string
+	dog     bool
+	prefix  string
+	tags    []string
+	eventCh channels.Channel
+
+	conn net.Conn
+}
+
+// NewStatsdSink constructs a new StatsdSink. The socket is opened on the
+// first write and reopened after errors.
+func NewStatsdSink(cfg StatsdSinkConfig) (*StatsdSink, error) {
+	switch cfg.Network {
+	case "udp", "unixgram":
+	default:
+		return nil, fmt.Errorf("unknown statsd network %q", cfg.Network)
+	}
+	switch cfg.Flavor {
+	case "statsd", "dogstatsd":
+	default:
+		return nil, fmt.Errorf("unknown statsd flavor %q", cfg.Flavor)
+	}
+
+	tags := append([]string{}, cfg.Tags...)
+	if cfg.ClusterName != "" {
+		tags = append(tags, "cluster:"+cfg.ClusterName)
+	}
+	prefix := cfg.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	return &StatsdSink{
+		network: cfg.Network,
+		address: cfg.Address,
+		dog:     cfg.Flavor == "dogstatsd",
+		prefix:  prefix,
+		tags:    tags,
+		eventCh: newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *StatsdSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and sending it to the StatsD server.
+func (s *StatsdSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// drainEvents writes the lines for an array of event data, packed into as
+// few datagrams as possible.
+func (s *StatsdSink) drainEvents(events []EventData) {
+	var packet bytes.Buffer
+	for _, evt := range events {
+		for _, line := range s.lines(evt) {
+			if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketBytes {
+				s.write(packet.Bytes())
+				packet.Reset()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	if packet.Len() > 0 {
+		s.write(packet.Bytes())
+	}
+}
+
+// lines returns the datagram lines for an event.
+func (s *StatsdSink) lines(evt EventData) []string {
+	e := evt.Event
+	if !s.dog {
+		name := s.prefix + "events." + strings.Join([]string{
+			statsdName(e.InvolvedObject.Namespace),
+			statsdName(e.InvolvedObject.Kind),
+			statsdName(e.Reason),
+			statsdName(e.Type),
+		}, ".")
+		return []string{name + ":1|c"}
+	}
+
+	tags := append([]string{}, s.tags...)
+	for _, t := range []struct{ key, value string }{
+		{"namespace", e.InvolvedObject.Namespace},
+		{"kind", e.InvolvedObject.Kind},
+		{"reason", e.Reason},
+		{"type", e.Type},
+	} {
+		if t.value != "" {
+			tags = append(tags, t.key+":"+statsdTag(t.value))
+		}
+	}
+	tagList := "|#" + strings.Join(tags, ",")
+
+	lines := []string{s.prefix + "events:1|c" + tagList}
+	if e.Type == v1.EventTypeWarning {
+		lines = append(lines, s.event(e, tagList))
+	}
+	return lines
+}
+
+// event encodes a DogStatsD event for a Warning event. Events sharing an
+// aggregation key are grouped in the event stream.
+func (s *StatsdSink) event(e *v1.Event, tagList string) string {
+	title := statsdEventText(e.InvolvedObject.Kind + " " + e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name + ": " + e.Reason)
+	text := statsdEventText(truncateRunes(e.Message, statsdMaxEventText))
+	var b strings.Builder
+	fmt.Fprintf(&b, "_e{%d,%d}:%s|%s|d:%d|t:warning|s:kubernetes|k:%s",
+		len(title), len(text), title, text, eventTimestamp(e).Unix(), statsdTag(dedupKey(e)))
+	if e.Source.Host != "" {
+		b.WriteString("|h:" + statsdTag(e.Source.Host))
+	}
+	b.WriteString(tagList)
+	return b.String()
+}
+
+// statsdName makes s a metric name segment.
+func statsdName(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == ':' || r == '|' || r == '@' || r == '#' || r == ',' || r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// statsdTag removes the characters that delimit DogStatsD tags and fields.
+func statsdTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '|' || r == ',' || r == '#' || r == '\n' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// statsdEventText escapes newlines, which DogStatsD reads as \n.
+func statsdEventText(s string) string {
+	return strings.ReplaceAll(s, "\n", "\\n")
+}
+
+// write sends a datagram, reopening the socket once after an error.
+// Metrics are best effort, so a failed datagram is dropped.
+func (s *StatsdSink) write(packet []byte) {
+	for attempt := 0; attempt < 2; attempt++ {
+		err := s.tryWrite(packet)
+		if err == nil {
+			return
+		}
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		if attempt > 0 {
+			glog.Warningf("Failed to write to statsd %s: %v", s.address, err)
+		}
+	}
+}
+
+func (s *StatsdSink) tryWrite(packet []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write(packet)
+	return err
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// subjectReplacer strips characters that act as token separators or
+// wildcards in NATS subjects and AMQP routing keys.
+var subjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", "#", "_", " ", "_", "\t", "_")
+
+// topicReplacer strips characters that separate levels or act as wildcards
+// in MQTT topics.
+var topicReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// expandSubject fills the {namespace}, {name}, {reason}, {type} and {kind}
+// placeholders of a dot-separated subject template, e.g.
+// "k8s.events.{namespace}.{reason}". Values are escaped so each one stays a
+// single token, and empty values become "_".
+func expandSubject(tmpl string, e *v1.Event) string {
+	return expandTemplate(tmpl, subjectReplacer, e)
+}
+
+// expandTopic fills the placeholders of expandSubject and {cluster} in a
+// slash-separated MQTT topic template, e.g. "k8s/{cluster}/{namespace}/events".
+func expandTopic(tmpl string, clusterName string, e *v1.Event) string {
+	return expandTemplate(tmpl, topicReplacer, e, "{cluster}", clusterName)
+}
+
+// expandTemplate fills the event placeholders of tmpl and any extra
+// placeholder/value pairs, escaping values with escape.
+func expandTemplate(tmpl string, escape *strings.Replacer, e *v1.Event, extra ...string) string {
+	token := func(s string) string {
+		if s == "" {
+			return "_"
+		}
+		return escape.Replace(s)
+	}
+	pairs := []string{
+		"{namespace}", token(e.InvolvedObject.Namespace),
+		"{name}", token(e.InvolvedObject.Name),
+		"{reason}", token(e.Reason),
+		"{type}", token(e.Type),
+		"{kind}", token(e.InvolvedObject.Kind),
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i], token(extra[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// sumoLogicMaxRequestBytes is the uncompressed request size Sumo Logic
+// recommends staying below.
+const sumoLogicMaxRequestBytes = 1000 * 1000
+
+// SumoLogicSinkConfig holds the options used to construct a SumoLogicSink.
+type SumoLogicSinkConfig struct {
+	// URL is the hosted HTTP source's unique URL, which includes its
+	// credentials.
+	URL string
+
+	// Category, Host and Name override the source's metadata for events
+	// sent by this sink. Host defaults to the cluster name.
+	Category string
+	Host     string
+	Name     string
+
+	// Fields are sent as X-Sumo-Fields, next to the cluster.
+	Fields map[string]string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SumoLogicSink posts events as JSON lines to a Sumo Logic hosted HTTP
+// source, gzip compressed.
+type SumoLogicSink struct {
+	url           string
+	category      string
+	host          string
+	name          string
+	fields        string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewSumoLogicSink constructs a new SumoLogicSink.
+func NewSumoLogicSink(cfg SumoLogicSinkConfig) (*SumoLogicSink, error) {
+	if err := checkFlushInterval("sumo logic", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	host := cfg.Host
+	if host == "" {
+		host = cfg.ClusterName
+	}
+
+	fields := map[string]string{}
+	for k, v := range cfg.Fields {
+		fields[k] = v
+	}
+	if cfg.ClusterName != "" {
+		fields["cluster"] = cfg.ClusterName
+	}
+	pairs := make([]string, 0, len(fields))
+	for k, v := range fields {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return &SumoLogicSink{
+		url:           cfg.URL,
+		category:      cfg.Category,
+		host:          host,
+		name:          cfg.Name,
+		fields:        strings.Join(pairs, ","),
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SumoLogicSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh, and
+// sending it to Sumo Logic in batches of up to flushEvents events.
+func (s *SumoLogicSink) Run(stopCh <-chan bool) {
+	runTimedBatches(s.eventCh, stopCh, s.flushEvents, s.flushInterval, s.drainEvents)
+}
+
+// drainEvents sends an array of event data as JSON lines, split into
+// requests of at most sumoLogicMaxRequestBytes.
+func (s *SumoLogicSink) drainEvents(events []EventData) {
+	// batched are the events in buf.
+	var buf bytes.Buffer
+	var batched []EventData
+	for _, evt := range events {
+		data := flattenEventData(evt)
+		if s.clusterName != "" {
+			data["cluster"] = s.clusterName
+		}
+		line, err := json.Marshal(data)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+
+		if len(batched) > 0 && buf.Len()+len(line)+1 > sumoLogicMaxRequestBytes {
+			s.send(buf.Bytes(), batched)
+			buf.Reset()
+			batched = nil
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		batched = append(batched, evt)
+	}
+
+	if len(batched) > 0 {
+		s.send(buf.Bytes(), batched)
+	}
+}
+
+// send posts a single gzipped request of the lines of events, dead
+// lettering them if it fails.
+func (s *SumoLogicSink) send(lines []byte, events []EventData) {
+	body, err := gzipBytes(lines)
+	if err != nil {
+		glog.Warningf("Failed to compress sumo logic request: %v", err)
+		deadLetter("sumologic", events, err)
+		return
+	}
+
+	_, err = postWithRetry(s.client, s.url, body, s.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		if s.category != "" {
+			req.Header.Set("X-Sumo-Category", s.category)
+		}
+		if s.host != "" {
+			req.Header.Set("X-Sumo-Host", s.host)
+		}
+		if s.name != "" {
+			req.Header.Set("X-Sumo-Name", s.name)
+		}
+		if s.fields != "" {
+			req.Header.Set("X-Sumo-Fields", s.fields)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to send %d events to Sumo Logic: %v", len(events), err)
+		deadLetter("sumologic", events, err)
+	}
+}
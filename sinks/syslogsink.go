@@ -0,0 +1,252 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crewjam/rfc5424"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// syslogFacilities maps facility names to their RFC 5424 codes.
+var syslogFacilities = map[string]rfc5424.Priority{
+	"kern": rfc5424.Kern, "user": rfc5424.User, "daemon": rfc5424.Daemon,
+	"auth": rfc5424.Auth, "syslog": rfc5424.Syslog, "authpriv": rfc5424.Authpriv,
+	"local0": rfc5424.Local0, "local1": rfc5424.Local1, "local2": rfc5424.Local2,
+	"local3": rfc5424.Local3, "local4": rfc5424.Local4, "local5": rfc5424.Local5,
+	"local6": rfc5424.Local6, "local7": rfc5424.Local7,
+}
+
+// SyslogSinkConfig holds the options used to construct a SyslogSink.
+type SyslogSinkConfig struct {
+	// Network is "udp", "tcp" or "tls"; Address is host:port.
+	Network string
+	Address string
+
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	// Facility is a facility name such as local0.
+	Facility string
+
+	// SDID is the structured data element ID carrying the event fields. IDs
+	// without an "@" are reserved by IANA, so it should be name@<PEN>.
+	SDID string
+
+	// Hostname defaults to the cluster name, then the pod's hostname.
+	Hostname    string
+	ClusterName string
+
+	RetryMax int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// SyslogSink forwards events as RFC 5424 messages. The event message is the
+// MSG part, the reason the MSGID, and the namespace, reason, type and
+// involved object are structured data parameters. TCP and TLS streams use
+// octet counting framing (RFC 6587/5425).
+type SyslogSink struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	facility  rfc5424.Priority
+	sdID      string
+	hostname  string
+	cluster   string
+	retryMax  int
+	eventCh   channels.Channel
+
+	conn net.Conn
+}
+
+// NewSyslogSink constructs a new SyslogSink. The connection is opened on
+// the first write and reopened after errors.
+func NewSyslogSink(cfg SyslogSinkConfig) (*SyslogSink, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+	}
+
+	var tlsConfig *tls.Config
+	switch cfg.Network {
+	case "udp", "tcp":
+	case "tls":
+		var err error
+		tlsConfig, err = newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", cfg.Network)
+	}
+
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname = cfg.ClusterName
+	}
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	return &SyslogSink{
+		network:   cfg.Network,
+		address:   cfg.Address,
+		tlsConfig: tlsConfig,
+		facility:  facility,
+		sdID:      cfg.SDID,
+		hostname:  syslogToken(hostname, 255),
+		cluster:   cfg.ClusterName,
+		retryMax:  cfg.RetryMax,
+		eventCh:   newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (s *SyslogSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	s.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through s.eventCh,
+// and forwarding it to the syslog server.
+func (s *SyslogSink) Run(stopCh <-chan bool) {
+	runBatches(s.eventCh, stopCh, s.drainEvents)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// drainEvents writes one syslog message per event.
+func (s *SyslogSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		msg, err := s.message(evt).MarshalBinary()
+		if err != nil {
+			glog.Warningf("Failed to encode syslog message: %v", err)
+			continue
+		}
+		if s.network != "udp" {
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		s.write(evt, msg)
+	}
+}
+
+// message builds the syslog message for an event.
+func (s *SyslogSink) message(evt EventData) rfc5424.Message {
+	e := evt.Event
+	severity := rfc5424.Notice
+	if e.Type == v1.EventTypeWarning {
+		severity = rfc5424.Warning
+	}
+
+	m := rfc5424.Message{
+		Priority:  s.facility | severity,
+		Timestamp: eventTimestamp(e),
+		Hostname:  s.hostname,
+		AppName:   "eventrouter",
+		MessageID: syslogToken(e.Reason, 32),
+		Message:   []byte(e.Message),
+	}
+	params := []struct{ name, value string }{
+		{"cluster", s.cluster},
+		{"verb", evt.Verb},
+		{"namespace", e.InvolvedObject.Namespace},
+		{"kind", e.InvolvedObject.Kind},
+		{"name", e.InvolvedObject.Name},
+		{"uid", string(e.InvolvedObject.UID)},
+		{"reason", e.Reason},
+		{"type", e.Type},
+		{"count", fmt.Sprint(e.Count)},
+		{"component", e.Source.Component},
+	}
+	for _, p := range params {
+		if p.value != "" {
+			m.AddDatum(s.sdID, p.name, p.value)
+		}
+	}
+	return m
+}
+
+// syslogToken makes s a valid header field of at most n printable ASCII
+// characters.
+func syslogToken(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > n {
+		s = s[:n]
+	}
+	return s
+}
+
+// write sends the framed message of evt, reconnecting with backoff after
+// errors.
+func (s *SyslogSink) write(evt EventData, msg []byte) {
+	for attempt := 0; ; attempt++ {
+		err := s.tryWrite(msg)
+		if err == nil {
+			return
+		}
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		if attempt >= s.retryMax {
+			glog.Errorf("Failed to write event to syslog %s: %v", s.address, err)
+			deadLetter("syslog", []EventData{evt}, err)
+			return
+		}
+		glog.Warningf("Failed to write event to syslog, reconnecting: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+func (s *SyslogSink) tryWrite(msg []byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		var conn net.Conn
+		var err error
+		if s.network == "tls" {
+			conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+		} else {
+			conn, err = dialer.Dial(s.network, s.address)
+		}
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write(msg)
+	return err
+}
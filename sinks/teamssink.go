@@ -0,0 +1,217 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// teamsCard is an Adaptive Card element; cards are assembled from maps as
+// only a handful of element types are used.
+type teamsCard map[string]interface{}
+
+// TeamsSinkConfig holds the options used to construct a TeamsSink.
+type TeamsSinkConfig struct {
+	// WebhookURL receives events not matched by a route. It may be an
+	// incoming webhook or a Workflows "when a Teams webhook request is
+	// received" URL.
+	WebhookURL string
+
+	// Routes maps "reason:<reason>" or "namespace:<namespace>" to a webhook
+	// URL. Reason routes take precedence; matching is case insensitive.
+	Routes map[string]string
+
+	// Types, Reasons and Namespaces select the events that are posted.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// Repeats of an event within FlushInterval are coalesced into a single
+	// card, and cards for an event are posted at most once per DedupWindow.
+	FlushInterval time.Duration
+	DedupWindow   time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// TeamsSink posts selected events to Microsoft Teams as Adaptive Cards.
+type TeamsSink struct {
+	webhookURL    string
+	routes        map[string]string
+	filter        eventFilter
+	flushInterval time.Duration
+	dedup         *deduplicator
+	clusterName   string
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// teamsGroup is a run of repeats of one event within a flush interval.
+type teamsGroup struct {
+	latest EventData
+	count  int
+}
+
+// NewTeamsSink constructs a new TeamsSink.
+func NewTeamsSink(cfg TeamsSinkConfig) (*TeamsSink, error) {
+	if err := checkFlushInterval("teams", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]string, len(cfg.Routes))
+	for k, v := range cfg.Routes {
+		routes[strings.ToLower(k)] = v
+	}
+
+	return &TeamsSink{
+		webhookURL:    cfg.WebhookURL,
+		routes:        routes,
+		filter:        newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		flushInterval: cfg.FlushInterval,
+		dedup:         newDeduplicator(cfg.DedupWindow),
+		clusterName:   cfg.ClusterName,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (t *TeamsSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !t.filter.match(eNew) {
+		return
+	}
+	t.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through t.eventCh, and
+// posting what arrived every flushInterval.
+func (t *TeamsSink) Run(stopCh <-chan bool) {
+	runTimedBatches(t.eventCh, stopCh, 0, t.flushInterval, t.drainEvents)
+}
+
+// drainEvents coalesces repeats of the same event and posts one card per
+// distinct event.
+func (t *TeamsSink) drainEvents(events []EventData) {
+	groups := map[string]*teamsGroup{}
+	var keys []string
+	for _, evt := range events {
+		key := dedupKey(evt.Event)
+		g, ok := groups[key]
+		if !ok {
+			g = &teamsGroup{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.latest = evt
+		g.count++
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		g := groups[key]
+		send, suppressed := t.dedup.observe(key, now)
+		if !send {
+			continue
+		}
+
+		dest := t.destination(g.latest.Event)
+		if dest == "" {
+			continue
+		}
+		if err := t.post(dest, t.card(g.latest, g.count+suppressed)); err != nil {
+			glog.Errorf("Failed to post event to Teams: %v", err)
+			deadLetter("teams", []EventData{g.latest}, err)
+		}
+	}
+}
+
+// destination returns the webhook URL an event is posted to.
+func (t *TeamsSink) destination(e *v1.Event) string {
+	if url, ok := t.routes["reason:"+strings.ToLower(e.Reason)]; ok {
+		return url
+	}
+	if url, ok := t.routes["namespace:"+strings.ToLower(e.InvolvedObject.Namespace)]; ok {
+		return url
+	}
+	return t.webhookURL
+}
+
+// card builds the Adaptive Card for an event seen occurrences times since
+// its last card.
+func (t *TeamsSink) card(evt EventData, occurrences int) teamsCard {
+	e := evt.Event
+	color := "Default"
+	if e.Type == v1.EventTypeWarning {
+		color = "Attention"
+	}
+
+	facts := []teamsCard{
+		{"title": "Cluster", "value": t.clusterName},
+		{"title": "Namespace", "value": e.InvolvedObject.Namespace},
+		{"title": "Object", "value": e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name},
+		{"title": "Count", "value": fmt.Sprint(e.Count)},
+		{"title": "Last seen", "value": eventTimestamp(e).UTC().Format(time.RFC3339)},
+	}
+	if occurrences > 1 {
+		facts = append(facts, teamsCard{"title": "Repeats", "value": fmt.Sprintf("%d since the last notification", occurrences)})
+	}
+
+	return teamsCard{
+		"type": "message",
+		"attachments": []teamsCard{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": teamsCard{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"msteams": teamsCard{"width": "Full"},
+				"body": []teamsCard{
+					{"type": "TextBlock", "size": "Medium", "weight": "Bolder", "color": color, "text": e.Type + ": " + e.Reason, "wrap": true},
+					{"type": "FactSet", "facts": facts},
+					{"type": "TextBlock", "text": e.Message, "wrap": true},
+				},
+			},
+		}},
+	}
+}
+
+// post sends a card to a webhook.
+func (t *TeamsSink) post(url string, card teamsCard) error {
+	body, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	_, err = postWithRetry(t.client, url, body, t.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	})
+	return err
+}
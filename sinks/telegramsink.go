@@ -0,0 +1,211 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Telegram Bot API flood limits: about one message per second to a chat,
+// 20 per minute to a group, and 30 per second overall.
+const (
+	telegramChatInterval = 3 * time.Second
+	telegramGlobalRate   = 30
+	telegramMaxMessage   = 4096
+)
+
+// telegramEscaper escapes the characters MarkdownV2 reserves.
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// TelegramSinkConfig holds the options used to construct a TelegramSink.
+type TelegramSinkConfig struct {
+	BotToken string
+
+	// ChatID receives events not matched by NamespaceChats, which maps
+	// involved object namespaces to chat IDs.
+	ChatID         string
+	NamespaceChats map[string]string
+
+	// Types, Reasons and Namespaces select the events that are sent.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// TelegramSink sends events to Telegram chats through the Bot API. Sends are
+// throttled to Telegram's flood limits, and when Telegram still asks us to
+// slow down the message is retried after the delay it names.
+type TelegramSink struct {
+	sendURL     string
+	chatID      string
+	chats       map[string]string
+	filter      eventFilter
+	clusterName string
+	retryMax    int
+	client      *http.Client
+	eventCh     channels.Channel
+
+	global   *rate.Limiter
+	limiters map[string]*rate.Limiter
+}
+
+// NewTelegramSink constructs a new TelegramSink.
+func NewTelegramSink(cfg TelegramSinkConfig) *TelegramSink {
+	return &TelegramSink{
+		sendURL:     "https://api.telegram.org/bot" + cfg.BotToken + "/sendMessage",
+		chatID:      cfg.ChatID,
+		chats:       cfg.NamespaceChats,
+		filter:      newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+		global:      rate.NewLimiter(telegramGlobalRate, telegramGlobalRate),
+		limiters:    map[string]*rate.Limiter{},
+	}
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (t *TelegramSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !t.filter.match(eNew) {
+		return
+	}
+	t.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through t.eventCh,
+// and sending it to Telegram.
+func (t *TelegramSink) Run(stopCh <-chan bool) {
+	runBatches(t.eventCh, stopCh, t.drainEvents)
+}
+
+// drainEvents sends one message per event.
+func (t *TelegramSink) drainEvents(events []EventData) {
+	for _, evt := range events {
+		chatID := t.chatID
+		if id, ok := t.chats[evt.Event.InvolvedObject.Namespace]; ok {
+			chatID = id
+		}
+		if chatID == "" {
+			continue
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"chat_id":                  chatID,
+			"text":                     t.format(evt),
+			"parse_mode":               "MarkdownV2",
+			"disable_web_page_preview": true,
+		})
+		if err != nil {
+			glog.Warningf("Failed to json serialize telegram message: %v", err)
+			continue
+		}
+		if err := t.send(chatID, body); err != nil {
+			glog.Errorf("Failed to send event to Telegram chat %s: %v", chatID, err)
+			deadLetter("telegram", []EventData{evt}, err)
+		}
+	}
+}
+
+// format renders an event as MarkdownV2.
+func (t *TelegramSink) format(evt EventData) string {
+	e := evt.Event
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s: %s*\n", telegramEscaper.Replace(e.Type), telegramEscaper.Replace(e.Reason))
+	if t.clusterName != "" {
+		fmt.Fprintf(&b, "Cluster: `%s`\n", telegramEscaper.Replace(t.clusterName))
+	}
+	fmt.Fprintf(&b, "Object: `%s`\n", telegramEscaper.Replace(e.InvolvedObject.Namespace+"/"+e.InvolvedObject.Kind+"/"+e.InvolvedObject.Name))
+	if e.Count > 1 {
+		fmt.Fprintf(&b, "Count: %d\n", e.Count)
+	}
+
+	// Leave room for the header; escaping can double the message's length.
+	message := truncateRunes(e.Message, (telegramMaxMessage-b.Len())/2)
+	b.WriteString(telegramEscaper.Replace(message))
+	return b.String()
+}
+
+// limiter returns the rate limiter of a chat.
+func (t *TelegramSink) limiter(chatID string) *rate.Limiter {
+	limiter, ok := t.limiters[chatID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(telegramChatInterval), 3)
+		t.limiters[chatID] = limiter
+	}
+	return limiter
+}
+
+// send posts a sendMessage request once the flood limits allow it. A 429
+// carries retry_after in its body, which is honored before retrying.
+func (t *TelegramSink) send(chatID string, body []byte) error {
+	var err error
+	for attempt := 0; attempt <= t.retryMax; attempt++ {
+		t.limiter(chatID).Wait(context.TODO())
+		t.global.Wait(context.TODO())
+
+		_, err = postWithRetry(t.client, t.sendURL, body, 0, func(req *http.Request) {
+			req.Header.Set("Content-Type", "application/json")
+		})
+		// Errors of the HTTP client quote the URL, which holds the bot
+		// token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		statusErr, ok := err.(*httpStatusError)
+		if !ok || statusErr.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+
+		var result struct {
+			Parameters struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		wait := backoff(attempt+1, time.Second, 30*time.Second)
+		if json.Unmarshal([]byte(statusErr.Body), &result) == nil && result.Parameters.RetryAfter > 0 {
+			wait = time.Duration(result.Parameters.RetryAfter) * time.Second
+		}
+		glog.V(2).Infof("Telegram flood control, retrying in %v", wait)
+		time.Sleep(wait)
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	v1 "k8s.io/api/core/v1"
+)
+
+// timescaleSchema creates the events hypertable, chunked by event timestamp,
+// and enables compression segmented by cluster and namespace. A hypertable's
+// unique indexes must include its time column, so unlike postgresSchema the
+// table has no serial primary key. %[1]s is the quoted table name, %[2]s the
+// escaped name used to prefix index names, %[3]s the quoted table name as a
+// string literal and %[4]s the bare table name as a string literal;
+// %[5]d and %[6]d are the chunk interval and compression delay in seconds.
+const timescaleSchema = `
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+CREATE TABLE IF NOT EXISTS %[1]s (
+  cluster TEXT NOT NULL,
+  verb TEXT NOT NULL,
+  namespace TEXT NOT NULL,
+  name TEXT NOT NULL,
+  uid TEXT NOT NULL,
+  type TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  message TEXT NOT NULL,
+  count INTEGER NOT NULL,
+  first_timestamp TIMESTAMPTZ,
+  last_timestamp TIMESTAMPTZ NOT NULL,
+  involved_object_kind TEXT NOT NULL,
+  involved_object_namespace TEXT NOT NULL,
+  involved_object_name TEXT NOT NULL,
+  event JSONB NOT NULL
+);
+SELECT create_hypertable(%[3]s, 'last_timestamp',
+  chunk_time_interval => INTERVAL '%[5]d seconds', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS "%[2]s_namespace_idx" ON %[1]s (namespace, last_timestamp DESC);
+CREATE INDEX IF NOT EXISTS "%[2]s_reason_idx" ON %[1]s (reason, last_timestamp DESC);
+CREATE INDEX IF NOT EXISTS "%[2]s_type_idx" ON %[1]s (type, last_timestamp DESC);
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM timescaledb_information.hypertables
+                 WHERE hypertable_name = %[4]s AND compression_enabled) THEN
+    ALTER TABLE %[1]s SET (
+      timescaledb.compress,
+      timescaledb.compress_segmentby = 'cluster, namespace',
+      timescaledb.compress_orderby = 'last_timestamp DESC'
+    );
+  END IF;
+END
+$$;
+SELECT add_compression_policy(%[3]s, INTERVAL '%[6]d seconds', if_not_exists => TRUE);
+`
+
+// timescaleRetentionPolicy drops chunks older than %[2]d seconds.
+const timescaleRetentionPolicy = `
+SELECT add_retention_policy(%[1]s, INTERVAL '%[2]d seconds', if_not_exists => TRUE);
+`
+
+// TimescaleSinkConfig holds the options used to construct a TimescaleSink.
+type TimescaleSinkConfig struct {
+	DSN         string
+	Table       string
+	ClusterName string
+
+	// ChunkInterval is the time range covered by each chunk of the hypertable.
+	ChunkInterval time.Duration
+
+	// CompressAfter is the age after which chunks are compressed.
+	CompressAfter time.Duration
+
+	// Retention, when set, drops chunks older than that.
+	Retention time.Duration
+
+	Overflow   bool
+	BufferSize int
+}
+
+// TimescaleSink writes events into a TimescaleDB hypertable with COPY. It
+// stores the same columns as PostgresSink, so queries work against either.
+type TimescaleSink struct {
+	pool        *pgxpool.Pool
+	table       string
+	clusterName string
+	eventCh     channels.Channel
+}
+
+// NewTimescaleSink connects to cfg.DSN and constructs a new TimescaleSink.
+// The hypertable and its compression and retention policies are created if
+// they do not exist; existing policies are left untouched.
+func NewTimescaleSink(cfg TimescaleSinkConfig) (*TimescaleSink, error) {
+	quoted := pgx.Identifier{cfg.Table}.Sanitize()
+	regclass := quoteLiteral(quoted)
+	schema := fmt.Sprintf(timescaleSchema,
+		quoted,
+		strings.ReplaceAll(cfg.Table, `"`, `""`),
+		regclass,
+		quoteLiteral(cfg.Table),
+		int64(cfg.ChunkInterval/time.Second),
+		int64(cfg.CompressAfter/time.Second),
+	)
+	if cfg.Retention > 0 {
+		schema += fmt.Sprintf(timescaleRetentionPolicy, regclass, int64(cfg.Retention/time.Second))
+	}
+
+	pool, err := newPostgresPool(cfg.DSN, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimescaleSink{
+		pool:        pool,
+		table:       cfg.Table,
+		clusterName: cfg.ClusterName,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// quoteLiteral quotes s as an SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (t *TimescaleSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	t.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through t.eventCh,
+// and copying it into the hypertable.
+func (t *TimescaleSink) Run(stopCh <-chan bool) {
+	defer t.pool.Close()
+	runBatches(t.eventCh, stopCh, func(events []EventData) {
+		copyEvents("timescaledb", t.pool, t.table, t.clusterName, events)
+	})
+}
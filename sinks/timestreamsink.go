@@ -0,0 +1,209 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// timestreamMaxBatchRecords is the WriteRecords request limit.
+	timestreamMaxBatchRecords = 100
+
+	// timestreamMaxMessageRunes keeps the message measure within the 2KB
+	// VARCHAR limit whatever the encoding of its runes.
+	timestreamMaxMessageRunes = 512
+)
+
+// TimestreamSinkConfig holds the options used to construct a TimestreamSink.
+type TimestreamSinkConfig struct {
+	Database string
+	Table    string
+
+	// MeasureName names the multi-measure records.
+	MeasureName string
+
+	Region      string
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// TimestreamSink writes an Amazon Timestream multi-measure record per event
+// occurrence. The dimensions are the cluster, namespace, involved object
+// kind, name and UID, reason and type, so occurrences can be counted and
+// graphed per combination of them, and occurrences for different objects at
+// the same time are separate records; the measures are the event's count and
+// the message.
+type TimestreamSink struct {
+	client        *timestreamwrite.Client
+	database      string
+	table         string
+	measureName   string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	eventCh       channels.Channel
+}
+
+// NewTimestreamSink constructs a new TimestreamSink.
+func NewTimestreamSink(cfg TimestreamSinkConfig) (*TimestreamSink, error) {
+	if err := checkFlushInterval("timestream", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	awsCfg, err := newAWSConfig(cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimestreamSink{
+		client:        timestreamwrite.NewFromConfig(awsCfg),
+		database:      cfg.Database,
+		table:         cfg.Table,
+		measureName:   cfg.MeasureName,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (t *TimestreamSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	t.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through t.eventCh, and
+// writing it to Timestream in batches of up to flushEvents events.
+func (t *TimestreamSink) Run(stopCh <-chan bool) {
+	runTimedBatches(t.eventCh, stopCh, t.flushEvents, t.flushInterval, t.drainEvents)
+}
+
+// drainEvents writes an array of event data in requests of at most
+// timestreamMaxBatchRecords records.
+func (t *TimestreamSink) drainEvents(events []EventData) {
+	records := make([]types.Record, 0, len(events))
+	for _, evt := range events {
+		records = append(records, t.record(evt))
+	}
+	for len(records) > 0 {
+		n := len(records)
+		if n > timestreamMaxBatchRecords {
+			n = timestreamMaxBatchRecords
+		}
+		t.write(records[:n], events[:n])
+		records, events = records[n:], events[n:]
+	}
+}
+
+// record builds the multi-measure record for an event. Timestream does not
+// allow empty dimension values, so empty fields are left out.
+func (t *TimestreamSink) record(evt EventData) types.Record {
+	e := evt.Event
+	var dims []types.Dimension
+	for _, d := range []struct{ name, value string }{
+		{"cluster", t.clusterName},
+		{"namespace", e.InvolvedObject.Namespace},
+		{"kind", e.InvolvedObject.Kind},
+		{"name", e.InvolvedObject.Name},
+		{"uid", string(e.InvolvedObject.UID)},
+		{"reason", e.Reason},
+		{"type", e.Type},
+	} {
+		if d.value != "" {
+			dims = append(dims, types.Dimension{Name: aws.String(d.name), Value: aws.String(d.value)})
+		}
+	}
+
+	measures := []types.MeasureValue{
+		{Name: aws.String("count"), Value: aws.String(strconv.Itoa(int(e.Count))), Type: types.MeasureValueTypeBigint},
+	}
+	if msg := truncateRunes(e.Message, timestreamMaxMessageRunes); msg != "" {
+		measures = append(measures, types.MeasureValue{Name: aws.String("message"), Value: aws.String(msg), Type: types.MeasureValueTypeVarchar})
+	}
+
+	return types.Record{
+		Dimensions:       dims,
+		MeasureName:      aws.String(t.measureName),
+		MeasureValueType: types.MeasureValueTypeMulti,
+		MeasureValues:    measures,
+		Time:             aws.String(strconv.FormatInt(eventTimestamp(e).UnixMilli(), 10)),
+		TimeUnit:         types.TimeUnitMilliseconds,
+		// Records only share dimensions and time when they are the same
+		// occurrence of an event for the same object, so a later write
+		// replaces the earlier one rather than being rejected as a
+		// duplicate.
+		Version: aws.Int64(time.Now().UnixNano()),
+	}
+}
+
+// write sends a single WriteRecords request, retrying with backoff up to
+// retryMax times. Rejected records are dead lettered, as retrying them would
+// be rejected again. events are the event data of records.
+func (t *TimestreamSink) write(records []types.Record, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+
+		_, err := t.client.WriteRecords(context.TODO(), &timestreamwrite.WriteRecordsInput{
+			DatabaseName: aws.String(t.database),
+			TableName:    aws.String(t.table),
+			Records:      records,
+		})
+		if err == nil {
+			return
+		}
+
+		var rejected *types.RejectedRecordsException
+		if errors.As(err, &rejected) {
+			for _, r := range rejected.RejectedRecords {
+				glog.Errorf("Timestream rejected record %d: %s", r.RecordIndex, aws.ToString(r.Reason))
+				if i := int(r.RecordIndex); i >= 0 && i < len(events) {
+					deadLetter("timestream", events[i:i+1], errors.New(aws.ToString(r.Reason)))
+				}
+			}
+			return
+		}
+		var validation *types.ValidationException
+		if errors.As(err, &validation) || attempt >= t.retryMax {
+			glog.Errorf("Failed to write %d events to Timestream: %v", len(records), err)
+			deadLetter("timestream", events, err)
+			return
+		}
+		glog.Warningf("Failed to write events to Timestream, retrying: %v", err)
+	}
+}
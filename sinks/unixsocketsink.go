@@ -0,0 +1,182 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// UnixSocketSinkConfig holds the options used to construct a
+// UnixSocketSink.
+type UnixSocketSinkConfig struct {
+	// Path is the unix stream socket the consumer listens on.
+	Path string
+
+	// MaxPending is how many events are held while the peer is down, at
+	// least 1; once it is reached the oldest events are dropped.
+	MaxPending int
+
+	// ReconnectInterval is how often a reconnect is attempted while events
+	// are pending.
+	ReconnectInterval time.Duration
+
+	Overflow   bool
+	BufferSize int
+}
+
+// UnixSocketSink streams events as newline-delimited JSON to a node-local
+// consumer such as vector or fluent-bit listening on a unix socket.
+type UnixSocketSink struct {
+	path              string
+	maxPending        int
+	reconnectInterval time.Duration
+	eventCh           channels.Channel
+
+	conn    net.Conn
+	pending [][]byte
+	// events are the event data of the pending lines, dead lettered if
+	// they are dropped.
+	events  []EventData
+	dropped int
+}
+
+// NewUnixSocketSink constructs a new UnixSocketSink. The socket is connected
+// on the first write, so the consumer does not have to be up when
+// eventrouter starts.
+func NewUnixSocketSink(cfg UnixSocketSinkConfig) (*UnixSocketSink, error) {
+	if cfg.MaxPending <= 0 {
+		return nil, fmt.Errorf("invalid unix socket max pending events %d, expected at least 1", cfg.MaxPending)
+	}
+	if cfg.ReconnectInterval <= 0 {
+		return nil, fmt.Errorf("invalid unix socket reconnect interval %v, expected a positive duration", cfg.ReconnectInterval)
+	}
+
+	return &UnixSocketSink{
+		path:              cfg.Path,
+		maxPending:        cfg.MaxPending,
+		reconnectInterval: cfg.ReconnectInterval,
+		eventCh:           newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (u *UnixSocketSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	u.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through u.eventCh and
+// writing it to the socket. While the peer is down events are held and
+// a reconnect is attempted every reconnectInterval.
+func (u *UnixSocketSink) Run(stopCh <-chan bool) {
+	ticker := time.NewTicker(u.reconnectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-u.eventCh.Out():
+			evt, ok := e.(EventData)
+			if !ok {
+				glog.Warningf("Invalid type sent through event channel: %T", e)
+				continue
+			}
+			u.add(evt)
+
+			// Pick up everything else that is buffered before writing.
+			numEvents := u.eventCh.Len()
+			for i := 0; i < numEvents; i++ {
+				if evt, ok := (<-u.eventCh.Out()).(EventData); ok {
+					u.add(evt)
+				}
+			}
+			u.flush()
+		case <-ticker.C:
+			if len(u.pending) > 0 {
+				u.flush()
+			}
+		case <-stopCh:
+			u.flush()
+			if len(u.pending) > 0 {
+				glog.Warningf("Dropped %d events still pending for unix socket %s at shutdown", len(u.pending), u.path)
+				deadLetter("unixsocket", u.events, fmt.Errorf("unix socket %s unavailable at shutdown", u.path))
+			}
+			if u.conn != nil {
+				u.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// add appends an event to the pending lines, dropping the oldest line when
+// maxPending is reached.
+func (u *UnixSocketSink) add(evt EventData) {
+	eJSONBytes, err := json.Marshal(evt)
+	if err != nil {
+		glog.Warningf("Failed to json serialize event: %v", err)
+		return
+	}
+	if len(u.pending) >= u.maxPending {
+		deadLetter("unixsocket", u.events[:1], fmt.Errorf("more than %d events pending for unix socket %s", u.maxPending, u.path))
+		u.pending, u.events = u.pending[1:], u.events[1:]
+		u.dropped++
+	}
+	u.pending = append(u.pending, append(eJSONBytes, '\n'))
+	u.events = append(u.events, evt)
+}
+
+// flush writes pending lines to the socket in order, keeping whatever could
+// not be written for the next attempt.
+func (u *UnixSocketSink) flush() {
+	if len(u.pending) == 0 {
+		return
+	}
+	if u.conn == nil {
+		conn, err := net.DialTimeout("unix", u.path, 10*time.Second)
+		if err != nil {
+			glog.V(2).Infof("Unix socket %s unavailable, holding %d events: %v", u.path, len(u.pending), err)
+			return
+		}
+		glog.Infof("Connected to unix socket %s", u.path)
+		u.conn = conn
+	}
+	if u.dropped > 0 {
+		glog.Warningf("Dropped %d events while unix socket %s was unavailable", u.dropped, u.path)
+		u.dropped = 0
+	}
+
+	for len(u.pending) > 0 {
+		u.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := u.conn.Write(u.pending[0]); err != nil {
+			// A partially written line is sent again in full on the new
+			// connection, so the consumer never sees a fragment first.
+			glog.Warningf("Failed to write to unix socket %s, reconnecting: %v", u.path, err)
+			u.conn.Close()
+			u.conn = nil
+			return
+		}
+		u.pending, u.events = u.pending[1:], u.events[1:]
+	}
+	u.pending, u.events = nil, nil
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	vectorpb "github.com/heptiolabs/eventrouter/proto/vector"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	v1 "k8s.io/api/core/v1"
+)
+
+// VectorSinkConfig holds the options used to construct a VectorSink.
+type VectorSinkConfig struct {
+	// Address is the host:port of a Vector "vector" source.
+	Address string
+
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	Compression bool
+
+	// Timeout bounds each push. With acknowledgements enabled on the
+	// source a push only returns once Vector's sinks delivered the events,
+	// so it has to cover their delivery time.
+	Timeout time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// VectorSink forwards events to a Vector aggregator over the native
+// protocol of its "vector" source. Every field of the event data arrives
+// as structured data, alongside the message and timestamp fields of
+// Vector's log schema.
+//
+// A batch is only considered delivered once PushEvents succeeds, so with
+// end to end acknowledgements enabled in Vector, failed deliveries are
+// retried from here.
+type VectorSink struct {
+	conn        *grpc.ClientConn
+	client      vectorpb.VectorClient
+	callOpts    []grpc.CallOption
+	timeout     time.Duration
+	clusterName string
+	retryMax    int
+	eventCh     channels.Channel
+}
+
+// NewVectorSink constructs a new VectorSink. The connection is established
+// lazily, so Vector does not have to be up when eventrouter starts.
+func NewVectorSink(cfg VectorSinkConfig) (*VectorSink, error) {
+	creds := insecure.NewCredentials()
+	if cfg.TLSEnabled {
+		tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(cfg.Address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(grpcServiceConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	callOpts := []grpc.CallOption{grpc.WaitForReady(true)}
+	if cfg.Compression {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+
+	return &VectorSink{
+		conn:        conn,
+		client:      vectorpb.NewVectorClient(conn),
+		callOpts:    callOpts,
+		timeout:     cfg.Timeout,
+		clusterName: cfg.ClusterName,
+		retryMax:    cfg.RetryMax,
+		eventCh:     newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (v *VectorSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	v.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through v.eventCh,
+// and pushing it to Vector.
+func (v *VectorSink) Run(stopCh <-chan bool) {
+	defer v.conn.Close()
+	runBatches(v.eventCh, stopCh, v.drainEvents)
+}
+
+// drainEvents pushes an array of event data in as few requests as the
+// message size limit allows.
+func (v *VectorSink) drainEvents(events []EventData) {
+	// batched are the events of req.
+	req := &vectorpb.PushEventsRequest{}
+	var batched []EventData
+	size := 0
+	for _, evt := range events {
+		ew, err := v.newVectorEvent(evt)
+		if err != nil {
+			glog.Warningf("Failed to convert event for Vector: %v", err)
+			continue
+		}
+		n := proto.Size(ew) + 8
+		if len(req.Events) > 0 && size+n > grpcMaxRequestBytes {
+			v.push(req, batched)
+			req = &vectorpb.PushEventsRequest{}
+			batched, size = nil, 0
+		}
+		req.Events = append(req.Events, ew)
+		batched = append(batched, evt)
+		size += n
+	}
+
+	if len(req.Events) > 0 {
+		v.push(req, batched)
+	}
+}
+
+// push sends a request of events, retrying with backoff until it is
+// acknowledged, and dead letters them if the retries run out.
+func (v *VectorSink) push(req *vectorpb.PushEventsRequest, events []EventData) {
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+		_, err := v.client.PushEvents(ctx, req, v.callOpts...)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= v.retryMax {
+			glog.Errorf("Failed to push %d events to Vector: %v", len(req.Events), err)
+			deadLetter("vector", events, err)
+			return
+		}
+		glog.Warningf("Failed to push events to Vector, retrying: %v", err)
+		time.Sleep(backoff(attempt+1, time.Second, 30*time.Second))
+	}
+}
+
+// newVectorEvent converts event data to a Vector log event. The event data
+// is converted through its JSON form so field names match the other sinks.
+func (v *VectorSink) newVectorEvent(evt EventData) (*vectorpb.EventWrapper, error) {
+	eJSONBytes, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(eJSONBytes))
+	dec.UseNumber()
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]*vectorpb.Value{}
+	for k, val := range data {
+		fields[k] = vectorValue(val)
+	}
+	fields["message"] = vectorValue(evt.Event.Message)
+	if ts := eventTimestamp(evt.Event); !ts.IsZero() {
+		fields["timestamp"] = &vectorpb.Value{Kind: &vectorpb.Value_Timestamp{Timestamp: timestamppb.New(ts)}}
+	}
+	if v.clusterName != "" {
+		fields["cluster"] = vectorValue(v.clusterName)
+	}
+
+	return &vectorpb.EventWrapper{Event: &vectorpb.EventWrapper_Log{Log: &vectorpb.Log{
+		Value: &vectorpb.Value{Kind: &vectorpb.Value_Map{Map: &vectorpb.ValueMap{Fields: fields}}},
+	}}}, nil
+}
+
+// vectorValue converts a decoded JSON value to a Vector value. Strings are
+// raw bytes, as in Vector itself.
+func vectorValue(val interface{}) *vectorpb.Value {
+	switch x := val.(type) {
+	case string:
+		return &vectorpb.Value{Kind: &vectorpb.Value_RawBytes{RawBytes: []byte(x)}}
+	case bool:
+		return &vectorpb.Value{Kind: &vectorpb.Value_Boolean{Boolean: x}}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return &vectorpb.Value{Kind: &vectorpb.Value_Integer{Integer: i}}
+		}
+		f, _ := x.Float64()
+		return &vectorpb.Value{Kind: &vectorpb.Value_Float{Float: f}}
+	case map[string]interface{}:
+		fields := make(map[string]*vectorpb.Value, len(x))
+		for k, item := range x {
+			fields[k] = vectorValue(item)
+		}
+		return &vectorpb.Value{Kind: &vectorpb.Value_Map{Map: &vectorpb.ValueMap{Fields: fields}}}
+	case []interface{}:
+		items := make([]*vectorpb.Value, len(x))
+		for i, item := range x {
+			items[i] = vectorValue(item)
+		}
+		return &vectorpb.Value{Kind: &vectorpb.Value_Array{Array: &vectorpb.ValueArray{Items: items}}}
+	}
+	return &vectorpb.Value{Kind: &vectorpb.Value_Null{Null: vectorpb.ValueNull_NULL_VALUE}}
+}
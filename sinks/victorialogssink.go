@@ -0,0 +1,154 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// victoriaLogsStreamFields group events into log streams by cluster,
+// namespace, involved object kind and reason.
+const victoriaLogsStreamFields = "cluster,namespace,involved_object_kind,reason"
+
+// VictoriaLogsSinkConfig holds the options used to construct a
+// VictoriaLogsSink.
+type VictoriaLogsSinkConfig struct {
+	// URL is the base URL of VictoriaLogs or vlinsert, e.g.
+	// http://victorialogs:9428.
+	URL string
+
+	// AccountID and ProjectID select the tenant; both default to 0.
+	AccountID string
+	ProjectID string
+
+	Username string
+	Password string
+
+	ClusterName string
+
+	FlushEvents   int
+	FlushInterval time.Duration
+	RetryMax      int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// VictoriaLogsSink pushes events to VictoriaLogs through the JSON lines
+// ingestion API. The event message is the log message and the remaining
+// flattened fields are log fields.
+type VictoriaLogsSink struct {
+	insertURL     string
+	accountID     string
+	projectID     string
+	username      string
+	password      string
+	clusterName   string
+	flushEvents   int
+	flushInterval time.Duration
+	retryMax      int
+	client        *http.Client
+	eventCh       channels.Channel
+}
+
+// NewVictoriaLogsSink constructs a new VictoriaLogsSink.
+func NewVictoriaLogsSink(cfg VictoriaLogsSinkConfig) (*VictoriaLogsSink, error) {
+	if err := checkFlushInterval("victorialogs", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("_stream_fields", victoriaLogsStreamFields)
+	query.Set("_msg_field", "message")
+	query.Set("_time_field", "timestamp")
+
+	return &VictoriaLogsSink{
+		insertURL:     strings.TrimSuffix(cfg.URL, "/") + "/insert/jsonline?" + query.Encode(),
+		accountID:     cfg.AccountID,
+		projectID:     cfg.ProjectID,
+		username:      cfg.Username,
+		password:      cfg.Password,
+		clusterName:   cfg.ClusterName,
+		flushEvents:   cfg.FlushEvents,
+		flushInterval: cfg.FlushInterval,
+		retryMax:      cfg.RetryMax,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (v *VictoriaLogsSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	v.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through v.eventCh, and
+// pushing it to VictoriaLogs in batches of up to flushEvents events.
+func (v *VictoriaLogsSink) Run(stopCh <-chan bool) {
+	runTimedBatches(v.eventCh, stopCh, v.flushEvents, v.flushInterval, v.drainEvents)
+}
+
+// drainEvents pushes an array of event data with a single gzipped request.
+func (v *VictoriaLogsSink) drainEvents(events []EventData) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		line := flattenEventData(evt)
+		line["cluster"] = v.clusterName
+		line["timestamp"] = eventTimestamp(evt.Event)
+		eJSONBytes, err := json.Marshal(line)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		buf.Write(eJSONBytes)
+		buf.WriteByte('\n')
+	}
+
+	body, err := gzipBytes(buf.Bytes())
+	if err != nil {
+		glog.Warningf("Failed to compress victorialogs request: %v", err)
+		return
+	}
+
+	_, err = postWithRetry(v.client, v.insertURL, body, v.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/stream+json")
+		req.Header.Set("Content-Encoding", "gzip")
+		if v.accountID != "" {
+			req.Header.Set("AccountID", v.accountID)
+		}
+		if v.projectID != "" {
+			req.Header.Set("ProjectID", v.projectID)
+		}
+		if v.username != "" {
+			req.SetBasicAuth(v.username, v.password)
+		}
+	})
+	if err != nil {
+		glog.Errorf("Failed to push %d events to VictoriaLogs: %v", len(events), err)
+		deadLetter("victorialogs", events, err)
+	}
+}
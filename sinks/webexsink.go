@@ -0,0 +1,247 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// webexMaxMarkdownBytes keeps a message below Webex's 7439 byte limit;
+	// longer bursts are split across messages.
+	webexMaxMarkdownBytes = 7000
+
+	// webexMaxMessageRunes limits the event message within an entry.
+	webexMaxMessageRunes = 1000
+)
+
+// webexMessage is a create message request.
+type webexMessage struct {
+	RoomID   string `json:"roomId"`
+	Markdown string `json:"markdown"`
+	Text     string `json:"text"`
+}
+
+// WebexSinkConfig holds the options used to construct a WebexSink.
+type WebexSinkConfig struct {
+	// APIURL is https://webexapis.com; BotToken the bot's access token.
+	// The bot has to be a member of the rooms it posts to.
+	APIURL   string
+	BotToken string
+
+	// RoomID is the default room, and NamespaceRooms maps namespaces to
+	// other rooms.
+	RoomID         string
+	NamespaceRooms map[string]string
+
+	// Types, Reasons and Namespaces select the events that are posted.
+	Types      []string
+	Reasons    []string
+	Namespaces []string
+
+	// Events arriving within FlushInterval are coalesced into one message
+	// per room, and an event is posted at most once per DedupWindow.
+	FlushInterval time.Duration
+	DedupWindow   time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// WebexSink posts selected events to Webex rooms as Markdown messages from
+// a bot. Bursts are coalesced: everything that arrives within a flush
+// interval is posted as one message per room, with one entry per distinct
+// event.
+type WebexSink struct {
+	messagesURL    string
+	botToken       string
+	roomID         string
+	namespaceRooms map[string]string
+	filter         eventFilter
+	flushInterval  time.Duration
+	dedup          *deduplicator
+	clusterName    string
+	retryMax       int
+	client         *http.Client
+	eventCh        channels.Channel
+}
+
+// webexGroup is a run of repeats of one event within a flush interval.
+type webexGroup struct {
+	latest EventData
+	count  int
+}
+
+// NewWebexSink constructs a new WebexSink. Namespace keys are matched case
+// insensitively, as configuration maps lose their case.
+func NewWebexSink(cfg WebexSinkConfig) (*WebexSink, error) {
+	if err := checkFlushInterval("webex", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	return &WebexSink{
+		messagesURL:    strings.TrimSuffix(cfg.APIURL, "/") + "/v1/messages",
+		botToken:       cfg.BotToken,
+		roomID:         cfg.RoomID,
+		namespaceRooms: lowerKeys(cfg.NamespaceRooms),
+		filter:         newEventFilter(cfg.Types, cfg.Reasons, cfg.Namespaces),
+		flushInterval:  cfg.FlushInterval,
+		dedup:          newDeduplicator(cfg.DedupWindow),
+		clusterName:    cfg.ClusterName,
+		retryMax:       cfg.RetryMax,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		eventCh:        newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It writes the events
+// selected by the filter to the event channel, which is drained by Run.
+func (w *WebexSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	if !w.filter.match(eNew) {
+		return
+	}
+	w.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through w.eventCh, and
+// posting what arrived every flushInterval.
+func (w *WebexSink) Run(stopCh <-chan bool) {
+	runTimedBatches(w.eventCh, stopCh, 0, w.flushInterval, w.drainEvents)
+}
+
+// drainEvents coalesces repeats of the same event, then posts the distinct
+// events to their rooms.
+func (w *WebexSink) drainEvents(events []EventData) {
+	groups := map[string]*webexGroup{}
+	var keys []string
+	for _, evt := range events {
+		key := dedupKey(evt.Event)
+		g, ok := groups[key]
+		if !ok {
+			g = &webexGroup{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.latest = evt
+		g.count++
+	}
+
+	now := time.Now()
+	// posted are the events of the entries in byRoom.
+	byRoom := map[string][]string{}
+	posted := map[string][]EventData{}
+	var roomOrder []string
+	for _, key := range keys {
+		g := groups[key]
+		send, suppressed := w.dedup.observe(key, now)
+		if !send {
+			continue
+		}
+		room := w.destination(g.latest.Event)
+		if room == "" {
+			continue
+		}
+		if _, ok := byRoom[room]; !ok {
+			roomOrder = append(roomOrder, room)
+		}
+		byRoom[room] = append(byRoom[room], w.entry(g.latest, g.count+suppressed))
+		posted[room] = append(posted[room], g.latest)
+	}
+
+	for _, room := range roomOrder {
+		var msg strings.Builder
+		start := 0
+		for i, entry := range byRoom[room] {
+			if i > start && msg.Len()+len(entry) > webexMaxMarkdownBytes {
+				w.post(room, msg.String(), posted[room][start:i])
+				msg.Reset()
+				start = i
+			}
+			msg.WriteString(entry)
+		}
+		if n := len(byRoom[room]); n > start {
+			w.post(room, msg.String(), posted[room][start:n])
+		}
+	}
+}
+
+// destination returns the room an event is posted to.
+func (w *WebexSink) destination(e *v1.Event) string {
+	if room, ok := w.namespaceRooms[strings.ToLower(e.InvolvedObject.Namespace)]; ok {
+		return room
+	}
+	return w.roomID
+}
+
+// entry formats an event seen occurrences times since it was last posted.
+func (w *WebexSink) entry(evt EventData, occurrences int) string {
+	e := evt.Event
+	o := e.InvolvedObject
+	icon := "ℹ️"
+	if e.Type == v1.EventTypeWarning {
+		icon = "⚠️"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s **%s** `%s %s/%s`", icon, e.Reason, o.Kind, o.Namespace, o.Name)
+	if occurrences > 1 {
+		fmt.Fprintf(&b, " (%d times)", occurrences)
+	}
+	b.WriteString("\n\n> ")
+	b.WriteString(strings.ReplaceAll(truncateRunes(e.Message, webexMaxMessageRunes), "\n", "\n> "))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+// post sends a message with the entries of events to a room, dead lettering
+// them if it fails.
+func (w *WebexSink) post(room string, markdown string, events []EventData) {
+	count := len(events)
+	header := "Kubernetes events"
+	if w.clusterName != "" {
+		header += " in **" + w.clusterName + "**"
+	}
+
+	body, err := json.Marshal(webexMessage{
+		RoomID:   room,
+		Markdown: header + "\n\n" + markdown,
+		Text:     fmt.Sprintf("%d Kubernetes events", count),
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize webex message: %v", err)
+		return
+	}
+	_, err = postWithRetry(w.client, w.messagesURL, body, w.retryMax, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+w.botToken)
+	})
+	if err != nil {
+		glog.Errorf("Failed to post %d events to Webex: %v", count, err)
+		deadLetter("webex", events, err)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+)
+
+// WebSocket connection timing.
+const (
+	webSocketWriteWait  = 10 * time.Second
+	webSocketPongWait   = 60 * time.Second
+	webSocketPingPeriod = webSocketPongWait * 9 / 10
+)
+
+// WebSocketSinkConfig holds the options used to construct a WebSocketSink.
+type WebSocketSinkConfig struct {
+	// ListenAddress is the address the server listens on, e.g. :8081. It
+	// serves WebSocket connections at Path.
+	ListenAddress string
+	Path          string
+
+	// Token, if set, must be sent as a bearer token or as the token query
+	// parameter, which browsers have to use.
+	Token string
+
+	// AllowedOrigins lists the Origin headers accepted from browsers. When
+	// empty, only same-origin and non-browser clients are accepted.
+	AllowedOrigins []string
+
+	// ClientBuffer is how many events are queued per client. A client that
+	// falls further behind is disconnected.
+	ClientBuffer int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// WebSocketSink serves a WebSocket endpoint and broadcasts every event as a
+// JSON text message to the connected clients. Clients can limit the events
+// they receive with namespace, type and reason query parameters, each of
+// which may be repeated, e.g. /events/ws?namespace=default&type=Warning.
+type WebSocketSink struct {
+	token        string
+	clientBuffer int
+	upgrader     websocket.Upgrader
+	server       *http.Server
+	eventCh      channels.Channel
+
+	mu      sync.Mutex
+	clients map[*webSocketClient]bool
+}
+
+// webSocketClient is a connection and the events queued for it.
+type webSocketClient struct {
+	conn   *websocket.Conn
+	filter eventFilter
+	send   chan []byte
+}
+
+// NewWebSocketSink constructs a new WebSocketSink. The server is started by
+// Run.
+func NewWebSocketSink(cfg WebSocketSinkConfig) *WebSocketSink {
+	w := &WebSocketSink{
+		token:        cfg.Token,
+		clientBuffer: cfg.ClientBuffer,
+		eventCh:      newEventChannel(cfg.Overflow, cfg.BufferSize),
+		clients:      map[*webSocketClient]bool{},
+	}
+	if len(cfg.AllowedOrigins) > 0 {
+		allowed := stringSet(cfg.AllowedOrigins)
+		w.upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || allowed[origin] || allowed["*"]
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cfg.Path, w.serve)
+	w.server = &http.Server{Addr: cfg.ListenAddress, Handler: mux}
+	return w
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (w *WebSocketSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	w.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run starts the server and sits in a loop, waiting for data to come in
+// through w.eventCh, and broadcasting it to the connected clients.
+func (w *WebSocketSink) Run(stopCh <-chan bool) {
+	go func() {
+		glog.Infof("Serving WebSocket events on %s", w.server.Addr)
+		if err := w.server.ListenAndServe(); err != http.ErrServerClosed {
+			glog.Errorf("WebSocket server failed: %v", err)
+		}
+	}()
+
+	runBatches(w.eventCh, stopCh, w.drainEvents)
+	w.server.Close()
+	w.mu.Lock()
+	for c := range w.clients {
+		c.conn.Close()
+	}
+	w.mu.Unlock()
+}
+
+// drainEvents queues each event for the clients whose filter it matches.
+func (w *WebSocketSink) drainEvents(events []EventData) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.clients) == 0 {
+		return
+	}
+
+	for _, evt := range events {
+		msg, err := json.Marshal(evt)
+		if err != nil {
+			glog.Warningf("Failed to json serialize event: %v", err)
+			continue
+		}
+		for c := range w.clients {
+			if !c.filter.match(evt.Event) {
+				continue
+			}
+			select {
+			case c.send <- msg:
+			default:
+				glog.Warningf("Disconnecting WebSocket client %s: more than %d events behind", c.conn.RemoteAddr(), w.clientBuffer)
+				w.remove(c)
+			}
+		}
+	}
+}
+
+// serve authenticates and upgrades a connection, and registers the client
+// until the connection closes.
+func (w *WebSocketSink) serve(rw http.ResponseWriter, r *http.Request) {
+	if !requestHasToken(r, w.token) {
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		glog.V(2).Infof("Failed to upgrade WebSocket connection from %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	q := r.URL.Query()
+	c := &webSocketClient{
+		conn:   conn,
+		filter: newEventFilter(q["type"], q["reason"], q["namespace"]),
+		send:   make(chan []byte, w.clientBuffer),
+	}
+	w.mu.Lock()
+	w.clients[c] = true
+	w.mu.Unlock()
+	glog.V(2).Infof("WebSocket client %s connected", conn.RemoteAddr())
+
+	go w.write(c)
+	w.read(c)
+}
+
+// requestHasToken checks a request's token, if one is required. It is
+// accepted as a bearer token or as the token query parameter, as browsers
+// cannot set headers on WebSocket and EventSource requests.
+func requestHasToken(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// read discards messages from the client, which is needed to process pongs
+// and notice the connection closing, and unregisters the client then.
+func (w *WebSocketSink) read(c *webSocketClient) {
+	defer func() {
+		w.mu.Lock()
+		w.remove(c)
+		w.mu.Unlock()
+	}()
+
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+	for {
+		if _, _, err := c.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// write sends queued events and pings to the client until its send channel
+// is closed.
+func (w *WebSocketSink) write(c *webSocketClient) {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// remove unregisters a client and closes its send channel, which makes its
+// writer close the connection. w.mu must be held.
+func (w *WebSocketSink) remove(c *webSocketClient) {
+	if w.clients[c] {
+		delete(w.clients, c)
+		close(c.send)
+		glog.V(2).Infof("WebSocket client %s disconnected", c.conn.RemoteAddr())
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2017 Heptio Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/eapache/channels"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// zabbixMaxResponseBytes bounds the response read from the server.
+const zabbixMaxResponseBytes = 1 << 20
+
+// zabbixHeader starts every sender protocol packet: the signature and the
+// flags byte, without compression.
+var zabbixHeader = []byte{'Z', 'B', 'X', 'D', 0x01}
+
+// zabbixItem is a trapper item value.
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+// zabbixRequest is a sender data request.
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+	NS      int          `json:"ns"`
+}
+
+// ZabbixSinkConfig holds the options used to construct a ZabbixSink.
+type ZabbixSinkConfig struct {
+	// Server is the Zabbix server or proxy trapper address, host:port.
+	Server string
+
+	// Host is the Zabbix host the trapper items belong to.
+	Host string
+
+	// CountKey is the key of the numeric items counting events, sent as
+	// <CountKey>[<type>], e.g. k8s.events.count[Warning].
+	CountKey string
+
+	// MessageKey is the key of the text or log item events of
+	// MessageTypes are sent to, one value per event.
+	MessageKey   string
+	MessageTypes []string
+
+	// FlushInterval is how often values are sent, and the period the counts
+	// cover.
+	FlushInterval time.Duration
+
+	ClusterName string
+	RetryMax    int
+
+	Overflow   bool
+	BufferSize int
+}
+
+// ZabbixSink sends events to Zabbix trapper items with the sender protocol:
+// the number of events of each type per flush interval, and a line per
+// selected event. The items have to exist on the host, with type Zabbix
+// trapper; the protocol's TLS and PSK options are not supported.
+type ZabbixSink struct {
+	server        string
+	host          string
+	countKey      string
+	messageKey    string
+	messageFilter eventFilter
+	flushInterval time.Duration
+	clusterName   string
+	retryMax      int
+	eventCh       channels.Channel
+}
+
+// NewZabbixSink constructs a new ZabbixSink.
+func NewZabbixSink(cfg ZabbixSinkConfig) (*ZabbixSink, error) {
+	if err := checkFlushInterval("zabbix", cfg.FlushInterval); err != nil {
+		return nil, err
+	}
+
+	return &ZabbixSink{
+		server:        cfg.Server,
+		host:          cfg.Host,
+		countKey:      cfg.CountKey,
+		messageKey:    cfg.MessageKey,
+		messageFilter: newEventFilter(cfg.MessageTypes, nil, nil),
+		flushInterval: cfg.FlushInterval,
+		clusterName:   cfg.ClusterName,
+		retryMax:      cfg.RetryMax,
+		eventCh:       newEventChannel(cfg.Overflow, cfg.BufferSize),
+	}, nil
+}
+
+// UpdateEvents implements the EventSinkInterface. It really just writes the
+// event data to the event channel, which is drained by Run.
+func (z *ZabbixSink) UpdateEvents(eNew *v1.Event, eOld *v1.Event) {
+	z.eventCh.In() <- NewEventData(eNew, eOld)
+}
+
+// Run sits in a loop, waiting for data to come in through z.eventCh, and
+// sending what arrived every flushInterval.
+func (z *ZabbixSink) Run(stopCh <-chan bool) {
+	runTimedBatches(z.eventCh, stopCh, 0, z.flushInterval, z.drainEvents)
+}
+
+// drainEvents sends the counts and messages of an array of event data in a
+// single request.
+func (z *ZabbixSink) drainEvents(events []EventData) {
+	now := time.Now()
+	counts := map[string]int{}
+	var items []zabbixItem
+	for _, evt := range events {
+		e := evt.Event
+		counts[e.Type]++
+		if z.messageKey == "" || !z.messageFilter.match(e) {
+			continue
+		}
+		ts := eventTimestamp(e)
+		items = append(items, zabbixItem{
+			Host:  z.host,
+			Key:   z.messageKey,
+			Value: z.message(e),
+			Clock: ts.Unix(),
+			NS:    ts.Nanosecond(),
+		})
+	}
+
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		items = append(items, zabbixItem{
+			Host:  z.host,
+			Key:   fmt.Sprintf("%s[%s]", z.countKey, t),
+			Value: fmt.Sprint(counts[t]),
+			Clock: now.Unix(),
+			NS:    now.Nanosecond(),
+		})
+	}
+
+	body, err := json.Marshal(zabbixRequest{
+		Request: "sender data",
+		Data:    items,
+		Clock:   now.Unix(),
+		NS:      now.Nanosecond(),
+	})
+	if err != nil {
+		glog.Warningf("Failed to json serialize zabbix request: %v", err)
+		return
+	}
+
+	var resp []byte
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt, time.Second, 30*time.Second))
+		}
+		resp, err = z.send(body)
+		if err == nil {
+			break
+		}
+		if attempt >= z.retryMax {
+			glog.Errorf("Failed to send %d values to Zabbix %s: %v", len(items), z.server, err)
+			deadLetter("zabbix", events, err)
+			return
+		}
+		glog.Warningf("Failed to send values to Zabbix, retrying: %v", err)
+	}
+
+	var result struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		glog.Warningf("Failed to decode zabbix response: %v", err)
+		return
+	}
+	if result.Response != "success" {
+		glog.Errorf("Zabbix rejected %d values: %s", len(items), result.Info)
+		deadLetter("zabbix", events, fmt.Errorf("rejected: %s", result.Info))
+		return
+	}
+	// Values for items that do not exist or are not trappers are counted as
+	// failed but do not fail the request.
+	glog.V(2).Infof("Zabbix processed values: %s", result.Info)
+}
+
+// message formats the value of the message item for an event.
+func (z *ZabbixSink) message(e *v1.Event) string {
+	o := e.InvolvedObject
+	msg := fmt.Sprintf("%s %s %s %s/%s: %s", e.Type, e.Reason, o.Kind, o.Namespace, o.Name, e.Message)
+	if z.clusterName != "" {
+		msg = "[" + z.clusterName + "] " + msg
+	}
+	return msg
+}
+
+// send writes a sender protocol packet on a new connection and returns the
+// response's JSON body.
+func (z *ZabbixSink) send(body []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", z.server, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	var packet bytes.Buffer
+	packet.Write(zabbixHeader)
+	binary.Write(&packet, binary.LittleEndian, uint32(len(body)))
+	binary.Write(&packet, binary.LittleEndian, uint32(0))
+	packet.Write(body)
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], zabbixHeader[:4]) {
+		return nil, fmt.Errorf("invalid zabbix response header %q", header[:4])
+	}
+	size := binary.LittleEndian.Uint32(header[len(zabbixHeader):])
+	if size > zabbixMaxResponseBytes {
+		return nil, fmt.Errorf("zabbix response of %d bytes is too large", size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}